@@ -0,0 +1,98 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// AdmissionRule is a CEL expression evaluated against the admitted "object".
+// Resources for which it evaluates to false are rejected with its message.
+type AdmissionRule struct {
+	Expression string
+	Message    string
+}
+
+// AdmissionRules returns the admission rules of each kind of the group. They
+// mirror the checks done by the reconcilers, and are compiled into
+// ValidatingAdmissionPolicy objects at build time, so that invalid resources
+// are rejected on admission in clusters that cannot run the operator webhooks.
+func AdmissionRules() map[string][]AdmissionRule {
+	return map[string][]AdmissionRule{
+		"AtlasSchema": {
+			{
+				Expression: "(has(object.spec.url) && object.spec.url != '') || " +
+					"(has(object.spec.urlFrom) && has(object.spec.urlFrom.secretKeyRef)) || " +
+					"(has(object.spec.credentials) && has(object.spec.credentials.host))",
+				Message: "one of url, urlFrom.secretKeyRef or credentials.host must be set",
+			},
+			{
+				Expression: "has(object.spec.schema) && (" +
+					"has(object.spec.schema.sql) || has(object.spec.schema.hcl) || " +
+					"has(object.spec.schema.configMapKeyRef) || has(object.spec.schema.secretKeyRef) || " +
+					"has(object.spec.schema.configMapRef) || has(object.spec.schema.git) || " +
+					"has(object.spec.schema.url) || has(object.spec.schema.registry) || " +
+					"has(object.spec.schema.external) || has(object.spec.schema.layers) || " +
+					"has(object.spec.schema.schemaRef))",
+				Message: "no desired schema specified",
+			},
+			{
+				Expression: "!has(object.spec.schema) || !has(object.spec.schema.url) || object.spec.schema.url.startsWith('https://')",
+				Message:    "schema url must use https",
+			},
+			{
+				Expression: "!has(object.spec.docs) || " +
+					"((!has(object.spec.docs.url) || object.spec.docs.url.startsWith('https://')) && " +
+					"(!has(object.spec.docs.diagramURL) || object.spec.docs.diagramURL.startsWith('https://')))",
+				Message: "docs url and diagramURL must use https",
+			},
+			{
+				Expression: "!has(object.spec.schema) || !has(object.spec.schema.registry) || has(object.spec.schema.registry.tokenFrom.secretKeyRef)",
+				Message:    "schema.registry.tokenFrom.secretKeyRef must be set",
+			},
+			{
+				Expression: "!has(object.spec.vars) || !has(object.spec.schema) || !has(object.spec.schema.registry)",
+				Message:    "vars cannot be set with schema.registry, the schema is read by the Atlas CLI",
+			},
+			{
+				Expression: "!has(object.spec.preview) || has(object.spec.preview.tokenFrom.secretKeyRef)",
+				Message:    "preview.tokenFrom.secretKeyRef must be set",
+			},
+			{
+				Expression: "!has(object.spec.policy) || !has(object.spec.policy.lint) || !has(object.spec.policy.lint.rules) || " +
+					"object.spec.policy.lint.rules.all(r, r.key.endsWith('.hcl'))",
+				Message: "lint rules keys must be .hcl files",
+			},
+		},
+		"AtlasMigration": {
+			{
+				Expression: "!has(object.spec.dir.configMapRef) || !has(object.spec.dir.configMapRefs)",
+				Message:    "cannot define both configMapRef and configMapRefs",
+			},
+			{
+				Expression: "!has(object.spec.dir.local) || !(has(object.spec.dir.configMapRef) || has(object.spec.dir.configMapRefs))",
+				Message:    "cannot define both configmap and local directory",
+			},
+			{
+				Expression: "!has(object.spec.dir.path) || has(object.spec.dir.configMapRef) || has(object.spec.dir.configMapRefs) || has(object.spec.dir.local)",
+				Message:    "dir.path is only supported for configmap and local directories",
+			},
+			{
+				Expression: "!has(object.spec.project) || !(has(object.spec.exclude) || has(object.spec.baseline) || " +
+					"has(object.spec.revisionsSchema) || has(object.spec.txMode) || (has(object.spec.cloud) && " +
+					"(has(object.spec.cloud.url) || has(object.spec.cloud.project) || has(object.spec.cloud.tokenFrom.secretKeyRef))))",
+				Message: "exclude, baseline, revisionsSchema, cloud and txMode cannot be set with a project file",
+			},
+		},
+	}
+}
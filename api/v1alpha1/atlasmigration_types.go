@@ -23,10 +23,6 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 )
 
-const (
-	MigrateReadyCond = "Ready"
-)
-
 // AtlasMigrationSpec defines the desired state of AtlasMigration
 type AtlasMigrationSpec struct {
 	// EnvName sets the environment name used for reporting runs to Atlas Cloud.
@@ -38,12 +34,192 @@ type AtlasMigrationSpec struct {
 	// Credentials defines the credentials to use when connecting to the database.
 	// Used instead of URL or URLFrom.
 	Credentials Credentials `json:"credentials,omitempty"`
+	// Session settings of the connections to the database, e.g. "sql_mode" on
+	// MySQL or "statement_timeout" on PostgreSQL. They are rendered into the URL
+	// as the parameters of its dialect. Connection parameters, e.g. "tls" or
+	// "sslmode", are rejected.
+	Session map[string]string `json:"session,omitempty"`
+	// AuthTokenFrom references the key of a secret holding the auth token of a
+	// libsql:// URL, e.g. of a Turso database. The token is read on every
+	// reconcile, so rotated tokens are used once the secret is updated.
+	AuthTokenFrom TokenFrom `json:"authTokenFrom,omitempty"`
+	// TLSFrom references a secret holding the client certificate used to
+	// connect to the database with mutual TLS.
+	TLSFrom TLSFrom `json:"tlsFrom,omitempty"`
 	// Cloud defines the Atlas Cloud configuration.
 	Cloud Cloud `json:"cloud,omitempty"`
 	// Dir defines the directory to use for migrations as a configmap key reference.
 	Dir Dir `json:"dir"`
+	// Project uses an existing Atlas project file (atlas.hcl) instead of the
+	// configuration generated from the spec. The URL and the directory of the
+	// spec, if set, override the ones of the selected env.
+	Project *Project `json:"project,omitempty"`
 	// RevisionsSchema defines the schema that revisions table resides in
 	RevisionsSchema string `json:"revisionsSchema,omitempty"`
+	// Baseline is the version of the migration file describing the existing
+	// schema of the database. It is marked as applied on databases without a
+	// revisions table, and is not executed, e.g. when adopting an existing database.
+	Baseline string `json:"baseline,omitempty"`
+	// Exclude a list of glob patterns of database objects managed outside of
+	// the migration directory, e.g. extensions or tables of third-party tools.
+	// Excluded objects are ignored when inspecting the target database.
+	Exclude []string `json:"exclude,omitempty"`
+	// AllowDirty allows applying migrations to a database that has objects
+	// not created by Atlas, e.g. when bootstrapping an existing database.
+	AllowDirty bool `json:"allowDirty,omitempty"`
+	// ExecOrder controls how migration files added out of order, e.g. by
+	// merging branches, are applied. "linear" rejects them, "linear-skip"
+	// skips them, and "non-linear" applies them. Defaults to "linear".
+	// +kubebuilder:validation:Enum=linear;linear-skip;non-linear
+	ExecOrder string `json:"execOrder,omitempty"`
+	// TxMode controls the transactions migration files are applied in. "file"
+	// runs each file in its own transaction, "all" runs all pending files in a
+	// single transaction, and "none" runs the statements without a transaction,
+	// e.g. for CREATE INDEX CONCURRENTLY in PostgreSQL. Defaults to "file".
+	// +kubebuilder:validation:Enum=file;all;none
+	TxMode string `json:"txMode,omitempty"`
+	// ApplyLimit is the maximum number of migration files applied per reconcile.
+	// Larger backlogs of pending files are applied in batches, and the status is
+	// updated after each batch. All pending files are applied at once if not set.
+	// +kubebuilder:validation:Minimum=1
+	ApplyLimit int `json:"applyLimit,omitempty"`
+	// BatchInterval is the pause between two batches of ApplyLimit files.
+	// The next batch is applied right away if not set.
+	BatchInterval *metav1.Duration `json:"batchInterval,omitempty"`
+	// DryRun plans the pending migration files without applying them, and
+	// reports the statements they would execute in the status.
+	DryRun bool `json:"dryRun,omitempty"`
+	// Version is the target version of the migration directory. Only the pending
+	// migrations up to and including this version are applied. All pending
+	// migrations are applied if empty. A version lower than the current version
+	// of the database reverts the migrations applied after it, if allowed by the
+	// policy.
+	Version string `json:"version,omitempty"`
+	// Probe defines checks of the target database load that must pass before
+	// pending migrations are applied. The apply is deferred until they pass.
+	Probe Probe `json:"probe,omitempty"`
+	// ReplicationLag applies pending migrations one file at a time, and waits
+	// for the replicas of the target database to catch up before each file.
+	ReplicationLag *ReplicationLag `json:"replicationLag,omitempty"`
+	// DevURL is the URL of a dev database used to plan the statements that
+	// revert applied migrations, and to lint the pending files. Required for
+	// migrating down.
+	DevURL string `json:"devURL,omitempty"`
+	// DevURLFrom defines the URL of the dev database as a secret key reference.
+	// Used instead of DevURL.
+	DevURLFrom URLFrom `json:"devURLFrom,omitempty"`
+	// DevDB configures the ephemeral dev database the operator runs to lint the
+	// files or to migrate down, when neither DevURL nor DevURLFrom is set.
+	DevDB *DevDB `json:"devDB,omitempty"`
+	// Lint runs "atlas migrate lint" on the pending migration files, and
+	// refuses to apply them if it fails.
+	Lint *MigrationLint `json:"lint,omitempty"`
+	// Policy defines the policies to apply when migrating the database.
+	Policy MigrationPolicy `json:"policy,omitempty"`
+	// Approval requires the pending migration files to be approved before they
+	// are applied.
+	Approval *MigrationApproval `json:"approval,omitempty"`
+	// EventsPolicy controls the events emitted for the resource: "minimal"
+	// emits warnings and the outcomes of applies only, "normal" adds the
+	// events of status checks, and "verbose" adds an event per applied file
+	// and the lint findings. Defaults to "normal".
+	// +kubebuilder:validation:Enum=minimal;normal;verbose
+	EventsPolicy string `json:"eventsPolicy,omitempty"`
+	// Webhook posts the result of every apply to an HTTP endpoint.
+	Webhook *ApplyWebhook `json:"webhook,omitempty"`
+	// ApplyWindows restricts the applies to recurring windows, e.g. off-peak
+	// hours. Pending files are applied when the next window opens. Files may
+	// be applied at any time if not set.
+	ApplyWindows []ApplyWindow `json:"applyWindows,omitempty"`
+}
+
+// MigrationApproval defines how pending migration files are approved.
+type MigrationApproval struct {
+	// ApprovedPlan approves the pending files when set to their plan hash,
+	// reported in status.approval.planHash. The hash covers the versions and
+	// the contents of the files, so changing them requires a new approval.
+	ApprovedPlan string `json:"approvedPlan,omitempty"`
+}
+
+// Project defines an Atlas project file and the env selected from it.
+type Project struct {
+	// ConfigMapKeyRef references the configmap key holding the project file.
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// Git references the project file in a Git repository.
+	Git *Git `json:"git,omitempty"`
+	// Env is the name of the env block selected from the project file.
+	Env string `json:"env"`
+	// Vars are passed to the input variables of the project file.
+	Vars []Var `json:"vars,omitempty"`
+}
+
+// MigrationLint defines the lint run on the migration directory before it is applied.
+type MigrationLint struct {
+	// DevURL is the URL of the dev database the files are linted on.
+	// Defaults to spec.devURL.
+	DevURL string `json:"devURL,omitempty"`
+	// Latest lints the last Latest files of the directory. Defaults to the
+	// pending files.
+	// +kubebuilder:validation:Minimum=1
+	Latest int `json:"latest,omitempty"`
+	// ErrorOn lists the analyzers whose diagnostics fail the lint, in addition
+	// to the errors reported by Atlas. For example, "destructive" refuses to
+	// apply files that drop schemas, tables or columns.
+	ErrorOn []LintAnalyzer `json:"errorOn,omitempty"`
+	// Report exports the findings of the lint in SARIF format, to be ingested
+	// by code-scanning dashboards.
+	Report *LintReport `json:"report,omitempty"`
+}
+
+// LintAnalyzer is the name of an Atlas migration analyzer.
+// +kubebuilder:validation:Enum=destructive;data_depend;concurrent_index;incompatible;naming;condrop
+type LintAnalyzer string
+
+// MigrationPolicy defines the policies to apply when migrating the database.
+type MigrationPolicy struct {
+	// AllowDown allows reverting applied migrations when the version is set
+	// lower than the current version of the database. Each revert must also be
+	// confirmed by setting the db.atlasgo.io/confirm-down annotation to the
+	// target version.
+	AllowDown bool `json:"allowDown,omitempty"`
+	// DenyDestructive refuses to apply pending files holding destructive
+	// statements: DROP TABLE, DROP SCHEMA, DROP DATABASE, TRUNCATE and DROP
+	// COLUMN. They are applied once approved by setting the
+	// db.atlasgo.io/approve-destructive annotation to the version of the last
+	// destructive file.
+	DenyDestructive bool `json:"denyDestructive,omitempty"`
+}
+
+// Probe defines the pre-apply checks of the target database load.
+type Probe struct {
+	// Queries to run against the target database.
+	Queries []ProbeQuery `json:"queries,omitempty"`
+	// RetryAfter is the time to wait before probing again after a query
+	// exceeded its threshold. Defaults to 1m.
+	RetryAfter *metav1.Duration `json:"retryAfter,omitempty"`
+}
+
+// ProbeQuery is a SQL query that returns a single numeric value, such as the
+// number of active connections or the age of the longest running transaction.
+type ProbeQuery struct {
+	// Name of the probe, used when reporting a deferred apply.
+	Name string `json:"name"`
+	// SQL is the query to run. It must return a single numeric value.
+	SQL string `json:"sql"`
+	// Max is the highest value the query may return for the apply to proceed.
+	Max int64 `json:"max"`
+}
+
+// ReplicationLag defines the replication lag policy used between migration files.
+type ReplicationLag struct {
+	// SQL is a query that returns the replication lag in seconds. Defaults to a
+	// query over pg_stat_replication for PostgreSQL, and is required for other dialects.
+	SQL string `json:"sql,omitempty"`
+	// MaxSeconds is the highest replication lag allowed before applying the next file.
+	MaxSeconds int64 `json:"maxSeconds"`
+	// RetryAfter is the time to wait before checking the lag again after it
+	// exceeded the threshold. Defaults to 10s.
+	RetryAfter *metav1.Duration `json:"retryAfter,omitempty"`
 }
 
 // Cloud defines the Atlas Cloud configuration.
@@ -57,10 +233,38 @@ type Cloud struct {
 type Dir struct {
 	// ConfigMapRef defines the configmap to use for migrations
 	ConfigMapRef *corev1.LocalObjectReference `json:"configMapRef,omitempty"`
+	// ConfigMapRefs defines a list of configmaps that are merged into a single
+	// migration directory. Keys must be unique across all configmaps.
+	ConfigMapRefs []corev1.LocalObjectReference `json:"configMapRefs,omitempty"`
+	// Namespace of the configmaps of the directory. Defaults to the namespace of the
+	// resource. Referencing another namespace requires an AtlasReferenceGrant in it.
+	Namespace string `json:"namespace,omitempty"`
 	// Remote defines the Atlas Cloud migration directory.
 	Remote Remote `json:"remote,omitempty"`
 	// Local defines the local migration directory.
 	Local map[string]string `json:"local,omitempty"`
+	// Image reads the migration directory from a container image, e.g. for
+	// air-gapped clusters that receive migrations through their registries.
+	Image *DirImage `json:"image,omitempty"`
+	// Path selects a part of configmap and local directories. Only the keys
+	// starting with Path are used, and Path is trimmed from their file names.
+	// For example, "users/" or "users.". It cannot be set for image directories,
+	// which use Image.Path instead, or for remote directories.
+	Path string `json:"path,omitempty"`
+	// GenerateSum computes the atlas.sum file of configmap, local and image
+	// directories that do not hold one, as 'atlas migrate hash' does.
+	GenerateSum bool `json:"generateSum,omitempty"`
+}
+
+// DirImage defines a migration directory bundled into a container image. The
+// image must provide sh, tar and base64, e.g. an image based on busybox.
+type DirImage struct {
+	// Ref of the image, e.g. registry.example.com/myapp/migrations:v1.
+	Ref string `json:"ref"`
+	// Path of the directory in the image. Defaults to /migrations.
+	Path string `json:"path,omitempty"`
+	// ImagePullSecrets used to pull the image.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
 }
 
 // Remote defines the Atlas Cloud directory migration.
@@ -75,6 +279,15 @@ type TokenFrom struct {
 	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
 }
 
+// TLSFrom defines a reference to a secret that contains a client certificate.
+type TLSFrom struct {
+	// SecretRef references a secret in the same namespace holding the client
+	// certificate and its key in the tls.crt and tls.key keys, e.g. a
+	// kubernetes.io/tls secret, and optionally the CA certificate of the
+	// server in the ca.crt key.
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+}
+
 // AtlasMigrationStatus defines the observed state of AtlasMigration
 type AtlasMigrationStatus struct {
 	// Conditions represent the latest available observations of an object's state.
@@ -85,8 +298,115 @@ type AtlasMigrationStatus struct {
 	LastDeploymentURL string `json:"lastDeploymentUrl,omitempty"`
 	// ObservedHash is the hash of the most recent successful versioned migration.
 	ObservedHash string `json:"observed_hash"`
+	// Applying marks an apply in progress.
+	Applying *ApplyingStatus `json:"applying,omitempty"`
 	// LastApplied is the unix timestamp of the most recent successful versioned migration.
 	LastApplied int64 `json:"lastApplied"`
+	// History holds the most recent migration files applied to the database, oldest first.
+	History []AppliedChange `json:"history,omitempty"`
+	// Runs holds the CLI commands run by the most recent reconciles, oldest first.
+	Runs []CommandRun `json:"runs,omitempty"`
+	// Transitions holds the most recent transitions of the conditions, oldest first.
+	Transitions []ConditionTransition `json:"transitions,omitempty"`
+	// DryRun reports the migration files a dry-run would apply.
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+	// Lint reports the findings of the most recent lint of the pending files.
+	Lint *LintStatus `json:"lint,omitempty"`
+	// CloudToken reports the most recent check of the Atlas Cloud token.
+	CloudToken *CloudTokenStatus `json:"cloudToken,omitempty"`
+	// AppliedFiles holds the hashes of the applied migration files by version,
+	// used to detect applied files that were modified afterwards.
+	AppliedFiles map[string]string `json:"appliedFiles,omitempty"`
+	// Checkpoint reports how the database applies a directory holding checkpoint files.
+	Checkpoint *CheckpointStatus `json:"checkpoint,omitempty"`
+	// Approval reports the pending files awaiting approval, or the most
+	// recently approved ones.
+	Approval *MigrationApprovalStatus `json:"approval,omitempty"`
+	// OperatorVersion is the version of the operator that last reconciled the resource.
+	OperatorVersion string `json:"operatorVersion,omitempty"`
+	// AtlasVersion is the version of the Atlas CLI that last reconciled the resource.
+	AtlasVersion string `json:"atlasVersion,omitempty"`
+	// RevisionsSchema is the schema that holds the revisions table of the
+	// database, recorded by the most recent successful apply.
+	RevisionsSchema string `json:"revisionsSchema,omitempty"`
+	// ObservedTarget identifies the database of the most recent successful
+	// apply. It is a hash of the URL without its credentials.
+	ObservedTarget string `json:"observedTarget,omitempty"`
+	// ApplyWindows lists the open and the next apply windows of the migration,
+	// so the schedule can be checked.
+	ApplyWindows []PlannedWindow `json:"applyWindows,omitempty"`
+	// StatusVersion is the version of the structure of the status. Statuses
+	// written by older operator versions are upgraded on their first reconcile.
+	StatusVersion int `json:"statusVersion,omitempty"`
+}
+
+// MigrationApprovalStatus reports a plan of pending migration files.
+type MigrationApprovalStatus struct {
+	// PlanHash identifies the plan.
+	PlanHash string `json:"planHash"`
+	// Files of the plan.
+	Files []ApprovalFile `json:"files"`
+	// PlannedAt is the time the plan was first computed.
+	PlannedAt metav1.Time `json:"plannedAt"`
+	// Approved reports if the plan was approved.
+	Approved bool `json:"approved,omitempty"`
+	// ExpiresAt is the time the approval of the plan expires, copied from
+	// the approved AtlasPlan.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// ApprovalFile is a migration file of a plan.
+type ApprovalFile struct {
+	// Version of the file.
+	Version string `json:"version"`
+	// Hash is the base64 encoded sha256 of the file.
+	Hash string `json:"hash"`
+}
+
+const (
+	// CheckpointPathFromCheckpoint reports a database that started from a checkpoint file.
+	CheckpointPathFromCheckpoint = "FromCheckpoint"
+	// CheckpointPathLinear reports a database that applies the files linearly,
+	// skipping the checkpoint files.
+	CheckpointPathLinear = "Linear"
+)
+
+// CheckpointStatus reports how a database applies a directory holding
+// checkpoint files.
+type CheckpointStatus struct {
+	// Version of the checkpoint the database started from, or of the latest
+	// checkpoint of the directory for databases that apply it linearly.
+	Version string `json:"version"`
+	// Path taken by the database: FromCheckpoint or Linear.
+	// +kubebuilder:validation:Enum=FromCheckpoint;Linear
+	Path string `json:"path"`
+}
+
+// CloudTokenStatus reports the health of the Atlas Cloud token used to fetch
+// the remote migration directory.
+type CloudTokenStatus struct {
+	// CheckedAt is the time of the most recent check.
+	CheckedAt metav1.Time `json:"checkedAt"`
+	// ExpiresAt is the expiry of the token, if it carries one.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+	// RateLimit is the request quota of the token, if reported by Atlas Cloud.
+	RateLimit int `json:"rateLimit,omitempty"`
+	// RateRemaining is the number of requests left in the current rate-limit window.
+	RateRemaining int `json:"rateRemaining,omitempty"`
+}
+
+// DryRunStatus reports the migration files a dry-run would apply.
+type DryRunStatus struct {
+	// Files would be applied in this order.
+	Files []PlannedFile `json:"files,omitempty"`
+	// PlannedAt is the time of the dry-run.
+	PlannedAt metav1.Time `json:"plannedAt"`
+}
+
+// PlannedFile is a migration file a dry-run would apply.
+type PlannedFile struct {
+	Version    string   `json:"version"`
+	Statements []string `json:"statements,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -129,7 +449,7 @@ func (m *AtlasMigration) SetReady(status AtlasMigrationStatus) {
 		metav1.Condition{
 			Type:   MigrateReadyCond,
 			Status: metav1.ConditionTrue,
-			Reason: "Applied",
+			Reason: ReasonApplied,
 		},
 	)
 }
@@ -0,0 +1,147 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// AtlasMonitor observes a database managed outside of the operator, e.g. by
+// another deployment pipeline. It reports the migration files pending to be
+// applied to the database and the drift of its schema, and never changes the
+// database.
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.currentVersion`
+// +kubebuilder:printcolumn:name="Pending",type=integer,JSONPath=`.status.pending`
+// +kubebuilder:printcolumn:name="Drifted",type=boolean,JSONPath=`.status.drifted`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type AtlasMonitor struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AtlasMonitorSpec   `json:"spec,omitempty"`
+	Status AtlasMonitorStatus `json:"status,omitempty"`
+}
+
+// AtlasMonitorSpec defines the database observed by an AtlasMonitor.
+type AtlasMonitorSpec struct {
+	// URL of the observed database.
+	URL string `json:"url,omitempty"`
+	// URLs may be defined as a secret key reference.
+	URLFrom URLFrom `json:"urlFrom,omitempty"`
+	// Credentials defines the credentials to use when connecting to the database.
+	// Used instead of URL or URLFrom.
+	Credentials Credentials `json:"credentials,omitempty"`
+	// Cloud defines the Atlas Cloud configuration, to read a remote directory.
+	Cloud Cloud `json:"cloud,omitempty"`
+	// Dir is the migration directory applied to the database. If set, the
+	// current version of the database and its pending files are reported.
+	Dir *Dir `json:"dir,omitempty"`
+	// RevisionsSchema defines the schema that revisions table resides in.
+	RevisionsSchema string `json:"revisionsSchema,omitempty"`
+	// Schemas to inspect for drift. Defaults to the schema of the URL, or to
+	// all the schemas of the database.
+	Schemas []string `json:"schemas,omitempty"`
+	// Exclude a list of glob patterns of database objects ignored when
+	// inspecting the database.
+	Exclude []string `json:"exclude,omitempty"`
+	// Interval between observations. Defaults to 5m.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// AtlasMonitorStatus reports the most recent observation of the database.
+type AtlasMonitorStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ObservedAt is the time of the most recent observation.
+	ObservedAt *metav1.Time `json:"observedAt,omitempty"`
+	// CurrentVersion is the version of the last migration file applied to the
+	// database. Reported for monitors with a migration directory only.
+	CurrentVersion string `json:"currentVersion,omitempty"`
+	// Pending is the number of migration files of the directory that are not
+	// applied to the database.
+	Pending int `json:"pending,omitempty"`
+	// PendingVersions lists the versions of the pending files.
+	PendingVersions []string `json:"pendingVersions,omitempty"`
+	// SchemaHash identifies the inspected schema of the database.
+	SchemaHash string `json:"schemaHash,omitempty"`
+	// BaselineHash identifies the schema drift is reported against. It is
+	// reset to the inspected schema when the current version changes, or when
+	// the drift is accepted.
+	BaselineHash string `json:"baselineHash,omitempty"`
+	// Drifted reports if the schema of the database changed while its current
+	// version did not, e.g. by a manual change.
+	Drifted bool `json:"drifted,omitempty"`
+	// DriftDetectedAt is the time the drift was detected.
+	DriftDetectedAt *metav1.Time `json:"driftDetectedAt,omitempty"`
+}
+
+// NamespacedName returns the namespaced name of the object.
+func (m *AtlasMonitor) NamespacedName() types.NamespacedName {
+	return types.NamespacedName{
+		Name:      m.Name,
+		Namespace: m.Namespace,
+	}
+}
+
+// IsReady returns true if the ready condition is true.
+func (m *AtlasMonitor) IsReady() bool {
+	return meta.IsStatusConditionTrue(m.Status.Conditions, MonitorReadyCond)
+}
+
+// SetReady sets the ready condition to true.
+func (m *AtlasMonitor) SetReady(reason, message string) {
+	meta.SetStatusCondition(
+		&m.Status.Conditions,
+		metav1.Condition{
+			Type:    MonitorReadyCond,
+			Status:  metav1.ConditionTrue,
+			Reason:  reason,
+			Message: message,
+		},
+	)
+}
+
+// SetNotReady sets the ready condition to false.
+func (m *AtlasMonitor) SetNotReady(reason, message string) {
+	meta.SetStatusCondition(
+		&m.Status.Conditions,
+		metav1.Condition{
+			Type:    MonitorReadyCond,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: message,
+		},
+	)
+}
+
+//+kubebuilder:object:root=true
+
+// AtlasMonitorList contains a list of AtlasMonitor
+type AtlasMonitorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AtlasMonitor `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AtlasMonitor{}, &AtlasMonitorList{})
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PlanPhase is the review phase of an AtlasPlan.
+type PlanPhase string
+
+// Review phases of a plan.
+const (
+	PlanPending  PlanPhase = "Pending"
+	PlanApproved PlanPhase = "Approved"
+	PlanRejected PlanPhase = "Rejected"
+	PlanOutdated PlanPhase = "Outdated"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// AtlasPlan is a reviewable plan of changes to a database, created by the
+// operator when an AtlasSchema or AtlasMigration that requires approval has
+// changes to apply. The changes are applied only after the plan is approved.
+// +kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.owner.kind`
+// +kubebuilder:printcolumn:name="Owner",type=string,JSONPath=`.spec.owner.name`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type AtlasPlan struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AtlasPlanSpec   `json:"spec,omitempty"`
+	Status AtlasPlanStatus `json:"status,omitempty"`
+}
+
+// AtlasPlanSpec holds the changes of a plan. It is written by the operator.
+type AtlasPlanSpec struct {
+	// Hash identifies the plan. It is the plan hash reported in the approval
+	// status of the owner.
+	Hash string `json:"hash"`
+	// Owner is the resource the plan was computed for.
+	Owner PlanOwner `json:"owner"`
+	// SQL holds the statements of the plan.
+	SQL string `json:"sql,omitempty"`
+	// Files lists the versions of the migration files of the plan.
+	Files []string `json:"files,omitempty"`
+	// Objects lists the database objects affected by the plan, e.g. "table users".
+	Objects []string `json:"objects,omitempty"`
+	// Lint holds the lint findings of the plan.
+	Lint []LintFile `json:"lint,omitempty"`
+}
+
+// PlanOwner references the resource a plan was computed for.
+type PlanOwner struct {
+	// Kind of the resource.
+	// +kubebuilder:validation:Enum=AtlasSchema;AtlasMigration
+	Kind string `json:"kind"`
+	// Name of the resource.
+	Name string `json:"name"`
+}
+
+// AtlasPlanStatus defines the review status of an AtlasPlan. Reviewers approve
+// or reject the plan by setting its phase.
+type AtlasPlanStatus struct {
+	// Phase of the review. Pending until it is set to Approved or Rejected.
+	// Outdated when the plan was replaced before it was applied, as the
+	// database or the desired state changed.
+	// +kubebuilder:validation:Enum=Pending;Approved;Rejected;Outdated
+	Phase PlanPhase `json:"phase,omitempty"`
+	// Reviewer who approved or rejected the plan.
+	Reviewer string `json:"reviewer,omitempty"`
+	// Message explaining the review.
+	Message string `json:"message,omitempty"`
+	// ExpiresAt is the time the approval of the plan expires, if it was not
+	// applied before. Expired approvals return the plan to Pending.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AtlasPlanList contains a list of AtlasPlan
+type AtlasPlanList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AtlasPlan `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AtlasPlan{}, &AtlasPlanList{})
+}
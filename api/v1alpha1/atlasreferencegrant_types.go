@@ -0,0 +1,96 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//+kubebuilder:object:root=true
+
+// AtlasReferenceGrant allows resources in other namespaces to reference the
+// Secrets and ConfigMaps of its namespace, similar to the ReferenceGrant of
+// the Gateway API. Without a grant, resources may only reference objects in
+// their own namespace.
+type AtlasReferenceGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AtlasReferenceGrantSpec `json:"spec,omitempty"`
+}
+
+// AtlasReferenceGrantSpec defines the references allowed by an AtlasReferenceGrant.
+type AtlasReferenceGrantSpec struct {
+	// From lists the resources allowed to reference the objects in To.
+	// +kubebuilder:validation:MinItems=1
+	From []ReferenceGrantFrom `json:"from"`
+	// To lists the objects that may be referenced.
+	// +kubebuilder:validation:MinItems=1
+	To []ReferenceGrantTo `json:"to"`
+}
+
+// ReferenceGrantFrom defines the resources allowed to reference the granted objects.
+type ReferenceGrantFrom struct {
+	// Kind of the referencing resources.
+	// +kubebuilder:validation:Enum=AtlasSchema;AtlasMigration
+	Kind string `json:"kind"`
+	// Namespace of the referencing resources.
+	Namespace string `json:"namespace"`
+}
+
+// ReferenceGrantTo defines the objects that may be referenced.
+type ReferenceGrantTo struct {
+	// Kind of the referenced objects.
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	Kind string `json:"kind"`
+	// Name of the referenced object. All objects of the kind if empty.
+	Name string `json:"name,omitempty"`
+}
+
+// Allows reports if the grant allows a resource of the given kind and
+// namespace to reference the object of the given kind and name.
+func (g *AtlasReferenceGrant) Allows(fromKind, fromNamespace, toKind, toName string) bool {
+	from := false
+	for _, f := range g.Spec.From {
+		if f.Kind == fromKind && f.Namespace == fromNamespace {
+			from = true
+			break
+		}
+	}
+	if !from {
+		return false
+	}
+	for _, t := range g.Spec.To {
+		if t.Kind == toKind && (t.Name == "" || t.Name == toName) {
+			return true
+		}
+	}
+	return false
+}
+
+//+kubebuilder:object:root=true
+
+// AtlasReferenceGrantList contains a list of AtlasReferenceGrant
+type AtlasReferenceGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AtlasReferenceGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AtlasReferenceGrant{}, &AtlasReferenceGrantList{})
+}
@@ -34,14 +34,247 @@ type AtlasSchemaSpec struct {
 	// Credentials defines the credentials to use when connecting to the database.
 	// Used instead of URL or URLFrom.
 	Credentials Credentials `json:"credentials,omitempty"`
+	// DevURL is the URL of a dedicated dev database used to plan, lint and
+	// normalize the changes, instead of a dev database the operator runs in
+	// the cluster. The database must be empty, and have the scope of the URL
+	// of the target, e.g. a schema if the target is a schema.
+	DevURL string `json:"devURL,omitempty"`
+	// DevURLFrom defines the URL of the dev database as a secret key reference.
+	// Used instead of DevURL.
+	DevURLFrom URLFrom `json:"devURLFrom,omitempty"`
+	// DevDB configures the dev database the operator runs when neither DevURL
+	// nor DevURLFrom is set.
+	DevDB *DevDB `json:"devDB,omitempty"`
+	// Session settings of the connections to the database, e.g. "sql_mode" on
+	// MySQL or "statement_timeout" on PostgreSQL. They are rendered into the URL
+	// as the parameters of its dialect. Connection parameters, e.g. "tls" or
+	// "sslmode", are rejected.
+	Session map[string]string `json:"session,omitempty"`
+	// AuthTokenFrom references the key of a secret holding the auth token of a
+	// libsql:// URL, e.g. of a Turso database. The token is read on every
+	// reconcile, so rotated tokens are used once the secret is updated.
+	AuthTokenFrom TokenFrom `json:"authTokenFrom,omitempty"`
+	// TLSFrom references a secret holding the client certificate used to
+	// connect to the database with mutual TLS.
+	TLSFrom TLSFrom `json:"tlsFrom,omitempty"`
 	// Desired Schema of the target.
 	Schema Schema `json:"schema,omitempty"`
+	// Vars are substituted into the desired schema where they are referenced as ${name}.
+	Vars []Var `json:"vars,omitempty"`
 	// Exclude a list of glob patterns used to filter existing resources being taken into account.
 	Exclude []string `json:"exclude,omitempty"`
 	// Policy defines the policies to apply when managing the schema change lifecycle.
 	Policy Policy `json:"policy,omitempty"`
 	// The names of the schemas (named databases) on the target database to be managed.
+	// Each named schema is managed by a single AtlasSchema. All schemas of the
+	// database are managed if not set, unless the URL is scoped to a schema.
 	Schemas []string `json:"schemas,omitempty"`
+	// Vitess submits schema changes through the Vitess online DDL workflow.
+	// The schema is reported as ready once all submitted migrations complete.
+	Vitess *Vitess `json:"vitess,omitempty"`
+	// Preview applies the schema to a branch of a branchable database
+	// (PlanetScale or Neon) instead of the target database.
+	Preview *Preview `json:"preview,omitempty"`
+	// Approval requires the planned changes to be approved before they are
+	// applied. Setting it implies the "manual" approval policy.
+	Approval *Approval `json:"approval,omitempty"`
+	// ApprovedHash approves the plan with the given hash, reported in
+	// status.approval.planHash, when the approval policy is "manual".
+	ApprovedHash string `json:"approvedHash,omitempty"`
+	// Coexistence allows the schema to target a database that is also managed
+	// by AtlasMigration resources. The schema never writes to the database, and
+	// reports the changes it would apply as drift. With "migrationOwnsDDL", drift
+	// is reported only once the AtlasMigration resources of the database are ready.
+	// Schemas targeting such a database without coexistence are not applied.
+	// +kubebuilder:validation:Enum=readOnlySchema;migrationOwnsDDL
+	Coexistence string `json:"coexistence,omitempty"`
+	// MaterializedViews defines the refresh policies of the materialized views
+	// of the schema. The views are refreshed once the schema is applied, and
+	// then periodically. Supported by PostgreSQL only.
+	MaterializedViews []MaterializedView `json:"materializedViews,omitempty"`
+	// Docs publishes the documentation of the applied schema, generated again
+	// whenever changes are applied.
+	Docs *SchemaDocs `json:"docs,omitempty"`
+	// EventsPolicy controls the events emitted for the resource: "minimal"
+	// emits warnings and the outcomes of applies only, "normal" adds the
+	// events of status checks, and "verbose" adds the lint findings.
+	// Defaults to "normal".
+	// +kubebuilder:validation:Enum=minimal;normal;verbose
+	EventsPolicy string `json:"eventsPolicy,omitempty"`
+	// DriftDetection checks the database periodically for drift from the
+	// applied schema, and defines how the drift is remediated.
+	DriftDetection *DriftDetection `json:"driftDetection,omitempty"`
+	// Webhook posts the result of every apply to an HTTP endpoint.
+	Webhook *ApplyWebhook `json:"webhook,omitempty"`
+	// ApplyWindows restricts the applies to recurring windows, e.g. off-peak
+	// hours. Changes planned outside the windows are applied when the next one
+	// opens. Changes may be applied at any time if not set.
+	ApplyWindows []ApplyWindow `json:"applyWindows,omitempty"`
+	// DryRun plans the changes of the desired schema without applying them,
+	// and reports the planned statements in status.plan.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// DriftDetection defines how the database is checked for drift from the
+// applied schema, e.g. changes made to the database by hand.
+type DriftDetection struct {
+	// Interval between two checks for drift. Defaults to 10 minutes.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Remediate defines how drift is handled: "none" reports it in the status
+	// only, "notify" also emits a SchemaDrift event and counts it in the
+	// atlas_operator_schema_drift_total metric, and "apply" also applies the
+	// desired schema again to bring the database back in sync.
+	// Defaults to "notify".
+	// +kubebuilder:validation:Enum=none;notify;apply
+	Remediate string `json:"remediate,omitempty"`
+	// ConfigMap stores the full diff of the drift in the "drift.sql" key of a
+	// ConfigMap owned by the resource, while the status holds a truncated diff.
+	// Its name is reported in status.drift.configMap.
+	ConfigMap bool `json:"configMap,omitempty"`
+	// Name of the ConfigMap the diff is stored in. Defaults to "<resource name>-drift".
+	Name string `json:"name,omitempty"`
+}
+
+// SchemaDocs defines where the documentation of the applied schema is published.
+type SchemaDocs struct {
+	// Format of the documentation. Defaults to markdown.
+	// +kubebuilder:validation:Enum=markdown;html
+	Format string `json:"format,omitempty"`
+	// ConfigMap stores the documentation in the "schema.md" or "schema.html" key
+	// of a ConfigMap owned by the resource. Its name is reported in status.docs.
+	ConfigMap bool `json:"configMap,omitempty"`
+	// Name of the ConfigMap the documentation is stored in. Defaults to "<resource name>-docs".
+	Name string `json:"name,omitempty"`
+	// URL the documentation is uploaded to with a PUT request, e.g. a pre-signed
+	// URL of an object storage bucket. It must use https, and resolve to a
+	// public address.
+	URL string `json:"url,omitempty"`
+	// Diagram generates an ER diagram of the schema in the given format. It is
+	// stored in the "schema.mmd" or "schema.dot" key of the ConfigMap.
+	// +kubebuilder:validation:Enum=mermaid;dot
+	Diagram string `json:"diagram,omitempty"`
+	// DiagramURL the diagram is uploaded to with a PUT request. Like URL, it
+	// must use https, and resolve to a public address.
+	DiagramURL string `json:"diagramURL,omitempty"`
+}
+
+// MaterializedView defines the refresh policy of a materialized view.
+type MaterializedView struct {
+	// Name of the view, optionally qualified by its schema, e.g. public.daily_sales.
+	// +kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)?$`
+	Name string `json:"name"`
+	// Interval between two refreshes of the view.
+	Interval metav1.Duration `json:"interval"`
+	// Concurrently refreshes the view without locking out concurrent selects.
+	// The view must have a unique index.
+	Concurrently bool `json:"concurrently,omitempty"`
+}
+
+const (
+	// CoexistenceReadOnlySchema makes the AtlasSchema read-only.
+	CoexistenceReadOnlySchema = "readOnlySchema"
+	// CoexistenceMigrationOwnsDDL makes the AtlasMigration resources targeting
+	// the database the only ones writing to it.
+	CoexistenceMigrationOwnsDDL = "migrationOwnsDDL"
+)
+
+const (
+	// EventsMinimal emits warnings and the outcomes of applies only.
+	EventsMinimal = "minimal"
+	// EventsNormal adds the events of status checks, such as deferred applies.
+	EventsNormal = "normal"
+	// EventsVerbose adds the events of applied files and lint findings.
+	EventsVerbose = "verbose"
+)
+
+const (
+	// RemediateNone reports drift in the status only.
+	RemediateNone = "none"
+	// RemediateNotify reports drift with events and metrics.
+	RemediateNotify = "notify"
+	// RemediateApply applies the desired schema again once drift is detected.
+	RemediateApply = "apply"
+)
+
+const (
+	// ApprovalAuto applies planned changes without approval.
+	ApprovalAuto = "auto"
+	// ApprovalManual applies planned changes once their plan is approved.
+	ApprovalManual = "manual"
+)
+
+const (
+	// ReviewAlways requires every plan to be approved.
+	ReviewAlways = "ALWAYS"
+	// ReviewWarning requires plans with lint findings to be approved.
+	ReviewWarning = "WARNING"
+	// ReviewError requires plans failing the lint to be approved.
+	ReviewError = "ERROR"
+)
+
+// Approval defines how planned changes are approved.
+type Approval struct {
+	// Timeout after which a plan that was not approved is rejected. A rejected
+	// plan is planned again on the next change of the desired schema.
+	// Plans never expire if not set.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// RequiredApprovers is the number of distinct users that must approve a plan
+	// before it is applied, e.g. 2 to enforce the two-person rule. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	RequiredApprovers int `json:"requiredApprovers,omitempty"`
+}
+
+// Preview defines a database branch the schema changes are applied to.
+// The branch is created from the parent branch, the changes are applied to it
+// and reported in the status, and the branch is then kept, promoted or deleted.
+type Preview struct {
+	// Provider of the database.
+	// +kubebuilder:validation:Enum=planetscale;neon
+	Provider string `json:"provider"`
+	// TokenFrom references a secret key containing the provider API token.
+	// For PlanetScale, the token is in the form "<service-token-id>:<service-token>".
+	TokenFrom TokenFrom `json:"tokenFrom"`
+	// Organization is the PlanetScale organization.
+	Organization string `json:"organization,omitempty"`
+	// Project is the Neon project ID.
+	Project string `json:"project,omitempty"`
+	// Database is the name of the database.
+	Database string `json:"database"`
+	// Role is the Neon role used to connect to the branch.
+	Role string `json:"role,omitempty"`
+	// ParentBranch the preview branch is created from. Defaults to "main".
+	ParentBranch string `json:"parentBranch,omitempty"`
+	// OnComplete defines what happens to the branch once the changes were applied.
+	// "promote" opens a deploy request on PlanetScale, or sets the branch as
+	// primary on Neon. Defaults to "keep".
+	// +kubebuilder:validation:Enum=keep;promote;delete
+	OnComplete string `json:"onComplete,omitempty"`
+}
+
+// Var is a variable substituted into the desired schema.
+type Var struct {
+	// Name of the variable.
+	Name string `json:"name"`
+	// Value of the variable.
+	Value string `json:"value,omitempty"`
+	// ValueFrom reads the value of the variable from a secret or a configmap key.
+	ValueFrom VarSource `json:"valueFrom,omitempty"`
+}
+
+// VarSource references the value of a variable.
+type VarSource struct {
+	// SecretKeyRef references to the key of a secret in the same namespace.
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// ConfigMapKeyRef references to the key of a configmap in the same namespace.
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// Vitess defines how schema changes are submitted to a Vitess keyspace.
+type Vitess struct {
+	// DDLStrategy is the online DDL strategy used to submit schema changes,
+	// optionally followed by its flags. Defaults to "vitess".
+	// +kubebuilder:validation:Pattern=`^(vitess|online|gh-ost|pt-osc|direct|mysql)( [-a-z0-9=_ ]*)?$`
+	DDLStrategy string `json:"ddlStrategy,omitempty"`
 }
 
 // Credentials defines the credentials to use when connecting to the database.
@@ -54,6 +287,37 @@ type Credentials struct {
 	Port         int               `json:"port,omitempty"`
 	Database     string            `json:"database,omitempty"`
 	Parameters   map[string]string `json:"parameters,omitempty"`
+	// AzureAD authenticates with Microsoft Entra ID (Azure AD) tokens, used
+	// instead of the password.
+	AzureAD *AzureAD `json:"azureAD,omitempty"`
+	// TLS configures the verification of the certificate of the database.
+	TLS *CredentialsTLS `json:"tls,omitempty"`
+}
+
+// CredentialsTLS configures the TLS connections to the database.
+type CredentialsTLS struct {
+	// CAFrom references the PEM bundle of the CAs the certificate of the
+	// database is verified with, e.g. of a private CA.
+	CAFrom CAFrom `json:"caFrom,omitempty"`
+}
+
+// CAFrom references a key holding a PEM bundle of CA certificates.
+type CAFrom struct {
+	// SecretKeyRef references to the key of a secret in the same namespace.
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// ConfigMapKeyRef references to the key of a configmap in the same namespace.
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// AzureAD acquires the tokens of an identity with the workload identity of the
+// operator, or the managed identity of its node if workload identity is not
+// set up. Tokens are refreshed before they expire.
+type AzureAD struct {
+	// ClientID of the identity. Defaults to the client ID of the workload
+	// identity, or to the system-assigned managed identity.
+	ClientID string `json:"clientID,omitempty"`
+	// TenantID of the identity. Defaults to the tenant of the workload identity.
+	TenantID string `json:"tenantID,omitempty"`
 }
 
 // PasswordFrom references a key containing the password.
@@ -90,11 +354,104 @@ func (c *Credentials) URL() *url.URL {
 type Policy struct {
 	Lint Lint `json:"lint,omitempty"`
 	Diff Diff `json:"diff,omitempty"`
+	// Approval is "auto" to apply planned changes without approval, or "manual"
+	// to apply them only once their plan is approved. Defaults to "auto", unless
+	// spec.approval is set.
+	// +kubebuilder:validation:Enum=auto;manual
+	Approval string `json:"approval,omitempty"`
+	// Review requires planned changes to be approved depending on the findings
+	// of their lint, as the review policy of Atlas: ALWAYS for every plan,
+	// WARNING for plans with any finding, and ERROR for plans failing the lint,
+	// which then await approval instead of failing.
+	// +kubebuilder:validation:Enum=ALWAYS;WARNING;ERROR
+	Review string `json:"review,omitempty"`
+	// Plan requires the planned changes to match a schema plan approved in
+	// Atlas Cloud.
+	Plan *PlanPolicy `json:"plan,omitempty"`
+}
+
+// PlanPolicy references a schema plan reviewed in Atlas Cloud. The changes are
+// applied only if the plan was approved and holds the same statements.
+type PlanPolicy struct {
+	// Ref of the plan, e.g. "atlas://app/plans/add-users".
+	// +kubebuilder:validation:Pattern=`^atlas://`
+	Ref string `json:"ref"`
+	// TokenFrom references the Atlas Cloud token used to fetch the plan.
+	TokenFrom TokenFrom `json:"tokenFrom"`
 }
 
 // Lint defines the linting policies to apply before applying the schema.
 type Lint struct {
 	Destructive CheckConfig `json:"destructive,omitempty"`
+	// DataDepend configures the analyzer of changes that may fail depending on
+	// the data, e.g. adding a unique index. Unset analyzers report warnings.
+	DataDepend *CheckConfig `json:"data_depend,omitempty"`
+	// ConcurrentIndex configures the analyzer of PostgreSQL indexes created or
+	// dropped without the CONCURRENTLY option.
+	ConcurrentIndex *CheckConfig `json:"concurrent_index,omitempty"`
+	// Naming configures the analyzer of the naming conventions of objects.
+	Naming *NamingConfig `json:"naming,omitempty"`
+	// Incompatible configures the analyzer of backward-incompatible changes,
+	// e.g. renamed tables or columns.
+	Incompatible *CheckConfig `json:"incompatible,omitempty"`
+	// ConDrop configures the analyzer of constraints dropped from tables.
+	ConDrop *CheckConfig `json:"condrop,omitempty"`
+	// Rules references configmap keys holding custom Atlas lint rule definitions
+	// in HCL. They are rendered into the lint block of the generated config, and
+	// any diagnostic they report fails the lint. The other keys of the configmaps
+	// are written next to the config, to be read by the src of the rules.
+	Rules []corev1.ConfigMapKeySelector `json:"rules,omitempty"`
+	// Report exports the findings of the lint in SARIF format, to be ingested
+	// by code-scanning dashboards.
+	Report *LintReport `json:"report,omitempty"`
+}
+
+// LintReport defines where the SARIF report of the lint findings is exported.
+type LintReport struct {
+	// ConfigMap stores the report in the "lint.sarif" key of a ConfigMap owned by
+	// the resource. Its name is reported in status.lintReport.
+	ConfigMap bool `json:"configMap,omitempty"`
+	// Name of the ConfigMap the report is stored in. Defaults to "<resource name>-lint".
+	Name string `json:"name,omitempty"`
+	// URL the report is sent to with a POST request.
+	URL string `json:"url,omitempty"`
+}
+
+// ApplyWindow defines a recurring window changes may be applied in.
+type ApplyWindow struct {
+	// Schedule is a cron expression of the starts of the window, with the
+	// minute, hour, day of month, month and day of week fields, e.g.
+	// "0 22 * * 1-5" for 10pm on weekdays. The @hourly, @daily, @weekly,
+	// @monthly and @yearly shorthands are supported.
+	Schedule string `json:"schedule"`
+	// Duration the window stays open after each start.
+	Duration metav1.Duration `json:"duration"`
+	// TimeZone of the schedule, as an IANA name, e.g. "Europe/Berlin".
+	// Defaults to UTC. Windows follow the daylight saving time transitions of
+	// the zone: a start skipped by a transition opens the window at the end
+	// of the skipped hour, and a start repeated by a transition opens it once.
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// PlannedWindow is an upcoming window changes may be applied in.
+type PlannedWindow struct {
+	Start metav1.Time `json:"start"`
+	End   metav1.Time `json:"end"`
+}
+
+// ApplyWebhook defines an HTTP endpoint the results of applies are posted to.
+type ApplyWebhook struct {
+	// URL the results are sent to with a POST request.
+	URL string `json:"url"`
+	// SecretFrom references the key of a secret holding the key the payloads
+	// are signed with. The HMAC-SHA256 signature of the payload is sent in the
+	// X-Atlas-Signature-256 header as "sha256=<hex digest>".
+	SecretFrom TokenFrom `json:"secretFrom,omitempty"`
+	// Retries is the number of times a failed delivery is retried, waiting
+	// twice as long before each retry, starting at one second. Defaults to 3.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=5
+	Retries *int `json:"retries,omitempty"`
 }
 
 // Diff defines the diff policies to apply when planning schema changes.
@@ -134,6 +491,31 @@ type SkipChanges struct {
 	DropForeignKey bool `json:"drop_foreign_key,omitempty"`
 	// +optional
 	ModifyForeignKey bool `json:"modify_foreign_key,omitempty"`
+	// View changes apply to materialized views as well.
+	// +optional
+	AddView bool `json:"add_view,omitempty"`
+	// +optional
+	DropView bool `json:"drop_view,omitempty"`
+	// +optional
+	ModifyView bool `json:"modify_view,omitempty"`
+	// +optional
+	AddFunc bool `json:"add_func,omitempty"`
+	// +optional
+	DropFunc bool `json:"drop_func,omitempty"`
+	// +optional
+	ModifyFunc bool `json:"modify_func,omitempty"`
+	// +optional
+	AddProc bool `json:"add_proc,omitempty"`
+	// +optional
+	DropProc bool `json:"drop_proc,omitempty"`
+	// +optional
+	ModifyProc bool `json:"modify_proc,omitempty"`
+	// +optional
+	AddTrigger bool `json:"add_trigger,omitempty"`
+	// +optional
+	DropTrigger bool `json:"drop_trigger,omitempty"`
+	// +optional
+	ModifyTrigger bool `json:"modify_trigger,omitempty"`
 }
 
 // CheckConfig defines the configuration of a linting check.
@@ -141,11 +523,153 @@ type CheckConfig struct {
 	Error bool `json:"error,omitempty"`
 }
 
+// NamingConfig configures the analyzer of the naming conventions of objects.
+type NamingConfig struct {
+	Error bool `json:"error,omitempty"`
+	// Match is the regular expression the names of the objects must match.
+	// +kubebuilder:validation:MinLength=1
+	Match string `json:"match"`
+	// Message is reported for the objects whose names do not match.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// AppliedChange records a change applied to the target database.
+type AppliedChange struct {
+	// Time the change was applied.
+	Time metav1.Time `json:"time"`
+	// Version of the applied migration file. Empty for schema applies.
+	Version string `json:"version,omitempty"`
+	// Statements holds the applied SQL statements.
+	Statements []string `json:"statements,omitempty"`
+}
+
+// MaxHistory is the number of applied changes kept in the status of a resource.
+const MaxHistory = 20
+
+// AppendHistory appends the given changes to the history, and keeps the most
+// recent MaxHistory changes.
+func AppendHistory(h []AppliedChange, changes ...AppliedChange) []AppliedChange {
+	h = append(h, changes...)
+	if len(h) > MaxHistory {
+		h = h[len(h)-MaxHistory:]
+	}
+	return h
+}
+
+// LintStatus reports the findings of the most recent lint.
+type LintStatus struct {
+	// Time the lint ran.
+	Time metav1.Time `json:"time"`
+	// Files holds the linted files that have findings.
+	Files []LintFile `json:"files,omitempty"`
+}
+
+// LintFile reports the findings of a linted file.
+type LintFile struct {
+	// Name of the file.
+	Name string `json:"name"`
+	// Error reported by Atlas for the file, if it failed the lint.
+	Error string `json:"error,omitempty"`
+	// Diagnostics reported by the analyzers.
+	Diagnostics []LintDiagnostic `json:"diagnostics,omitempty"`
+}
+
+// LintDiagnostic is a finding of an analyzer.
+type LintDiagnostic struct {
+	// Code of the rule, e.g. DS102.
+	Code string `json:"code"`
+	// Severity is "error" if the finding fails the lint, and "warning" otherwise.
+	// +kubebuilder:validation:Enum=error;warning
+	Severity string `json:"severity"`
+	// Text describes the finding.
+	Text string `json:"text"`
+	// Pos is the position of the statement in the file, in bytes.
+	Pos int `json:"pos"`
+}
+
+// ConditionTransition records a change of the status or the reason of a condition.
+type ConditionTransition struct {
+	// Time the transition was observed.
+	Time metav1.Time `json:"time"`
+	// Type of the condition.
+	Type string `json:"type"`
+	// Status of the condition after the transition.
+	Status metav1.ConditionStatus `json:"status"`
+	// Reason of the condition after the transition.
+	Reason string `json:"reason,omitempty"`
+	// Message of the condition after the transition, truncated to MaxTransitionMessage bytes.
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	// MaxTransitions is the number of condition transitions kept in the status of a resource.
+	MaxTransitions = 20
+	// MaxTransitionMessage is the maximum length of the message of a transition.
+	MaxTransitionMessage = 256
+)
+
+// AppendTransition appends the given transitions, and keeps the most recent
+// MaxTransitions transitions.
+func AppendTransition(t []ConditionTransition, transitions ...ConditionTransition) []ConditionTransition {
+	t = append(t, transitions...)
+	if len(t) > MaxTransitions {
+		t = t[len(t)-MaxTransitions:]
+	}
+	return t
+}
+
+// CommandRun records the Atlas CLI commands run by a reconcile.
+type CommandRun struct {
+	// Time the reconcile ran.
+	Time metav1.Time `json:"time"`
+	// Commands holds the command lines in the order they were run. Passwords
+	// and the values of variables are redacted.
+	Commands []string `json:"commands,omitempty"`
+}
+
+// MaxRuns is the number of command runs kept in the status of a resource.
+const MaxRuns = 10
+
+// AppendRun appends the given runs, and keeps the most recent MaxRuns runs.
+func AppendRun(r []CommandRun, runs ...CommandRun) []CommandRun {
+	r = append(r, runs...)
+	if len(r) > MaxRuns {
+		r = r[len(r)-MaxRuns:]
+	}
+	return r
+}
+
 // URLFrom defines a reference to a secret key that contains the Atlas URL of the
 // target database schema.
 type URLFrom struct {
 	// SecretKeyRef references to the key of a secret in the same namespace.
 	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// Namespace of the secret. Defaults to the namespace of the resource.
+	// Referencing another namespace requires an AtlasReferenceGrant in it.
+	Namespace string `json:"namespace,omitempty"`
+	// JSONPath extracts the URL from a secret key holding a JSON object, e.g.
+	// "{.url}", or builds it from the fields of the object, e.g.
+	// "postgres://{.username}:{.password}@{.host}:{.port}/{.dbname}". It uses
+	// the JSONPath template syntax of kubectl. The fields a URL is built from
+	// are percent-encoded, while a single field is extracted as is.
+	JSONPath string `json:"jsonPath,omitempty"`
+}
+
+// DevDB configures the dev database run by the operator in the namespace of
+// the resource.
+type DevDB struct {
+	// Image of the dev database. Defaults to an image of the database of the
+	// target URL: "mysql:8", "mariadb:11" or "postgres:15". Other images must
+	// match the --allowed-images flag of the operator.
+	Image string `json:"image,omitempty"`
+	// Resources of the dev database container.
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// TTL keeps the dev database of an AtlasMigration for the following
+	// reconciles, until it is unused for TTL. By default, it is deleted once the
+	// reconcile that required it completes. AtlasSchema resources keep their dev
+	// database while they exist.
+	TTL *metav1.Duration `json:"ttl,omitempty"`
 }
 
 // Schema defines the desired state of the target database schema in plain SQL or HCL.
@@ -153,6 +677,104 @@ type Schema struct {
 	SQL             string                       `json:"sql,omitempty"`
 	HCL             string                       `json:"hcl,omitempty"`
 	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// Namespace of the configmap referenced by configMapKeyRef. Defaults to the namespace
+	// of the resource. Referencing another namespace requires an AtlasReferenceGrant in it.
+	Namespace string `json:"namespace,omitempty"`
+	// SecretKeyRef references to the key of a secret in the same namespace holding
+	// the schema, for schemas with sensitive statements. The key must end with .hcl or .sql.
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// ConfigMapRef references a configmap whose keys are all loaded as the files
+	// of a multi-file schema. All keys must end with the same .hcl or .sql extension.
+	ConfigMapRef *corev1.LocalObjectReference `json:"configMapRef,omitempty"`
+	// Git defines a schema file stored in a Git repository.
+	Git *Git `json:"git,omitempty"`
+	// URL of a schema file served over HTTPS. The file name must end with .hcl or .sql.
+	URL string `json:"url,omitempty"`
+	// AuthHeaderFrom references a secret key containing the value of the
+	// Authorization header sent when fetching the schema from URL.
+	AuthHeaderFrom AuthHeaderFrom `json:"authHeaderFrom,omitempty"`
+	// Digest pins the content fetched from URL, in the form "sha256:<hex>".
+	Digest string `json:"digest,omitempty"`
+	// Registry defines a schema stored in the Atlas Cloud schema registry.
+	Registry *Registry `json:"registry,omitempty"`
+	// External defines a program, such as an ORM schema loader, that prints the desired schema.
+	External *ExternalSchema `json:"external,omitempty"`
+	// Layers is an ordered list of schema sources that are concatenated into
+	// the desired schema, e.g. a shared base schema followed by per-tenant overlays.
+	// All layers must be of the same format.
+	Layers []SchemaLayer `json:"layers,omitempty"`
+	// SchemaRef references another AtlasSchema in the same namespace whose desired
+	// schema is applied to the target of this resource. The referenced resource
+	// must define its schema directly, and not reference another AtlasSchema.
+	SchemaRef *corev1.LocalObjectReference `json:"schemaRef,omitempty"`
+}
+
+// SchemaLayer is a schema source composed into the desired schema.
+type SchemaLayer struct {
+	SQL             string                       `json:"sql,omitempty"`
+	HCL             string                       `json:"hcl,omitempty"`
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// ExternalSchema defines a program that prints the desired schema to its
+// standard output, similar to the external_schema data source of Atlas.
+// The program is run as a Job in the namespace of the resource, and is run
+// again only when its definition changes.
+type ExternalSchema struct {
+	// Image of the program. It must match the --allowed-images flag of the operator.
+	Image string `json:"image"`
+	// Command runs the program, with the shell of the image. Its standard
+	// error is reported as the termination message of the container.
+	Command []string `json:"command"`
+	// Format of the schema printed by the program.
+	// +kubebuilder:validation:Enum=sql;hcl
+	// +kubebuilder:default=sql
+	Format string `json:"format,omitempty"`
+	// Env holds the environment variables of the program.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+}
+
+// Registry defines a schema stored in the Atlas Cloud schema registry. Tags are
+// checked periodically, and the schema is re-applied when the tag moves.
+type Registry struct {
+	// Project is the name of the schema project in the registry.
+	Project string `json:"project"`
+	// Tag of the schema to use. Defaults to "latest".
+	Tag string `json:"tag,omitempty"`
+	// Version of the schema to use. Used instead of Tag.
+	Version string `json:"version,omitempty"`
+	// URL of Atlas Cloud. Defaults to the Atlas Cloud URL configured in the CLI.
+	URL string `json:"url,omitempty"`
+	// TokenFrom references a secret key containing the Atlas Cloud token.
+	TokenFrom TokenFrom `json:"tokenFrom"`
+	// Interval between checks of the tag for new versions. Defaults to 5m.
+	// Ignored when Version is set.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// AuthHeaderFrom references a key containing the value of an Authorization header.
+type AuthHeaderFrom struct {
+	// SecretKeyRef references to the key of a secret in the same namespace.
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// Git defines a schema file stored in a Git repository. The ref is checked
+// periodically, and the schema is re-applied when it moves.
+type Git struct {
+	// Repo is the URL of the repository, e.g. https://github.com/org/repo.git.
+	// Only https repositories are supported.
+	Repo string `json:"repo"`
+	// Ref is the branch, tag or commit to read the schema from. Defaults to HEAD.
+	Ref string `json:"ref,omitempty"`
+	// Path of the schema file within the repository. Must end with .hcl or .sql.
+	Path string `json:"path"`
+	// User is the user name used for HTTPS authentication. Defaults to "git".
+	User string `json:"user,omitempty"`
+	// PasswordFrom references a secret key containing the password or access
+	// token used for HTTPS authentication.
+	PasswordFrom PasswordFrom `json:"passwordFrom,omitempty"`
+	// Interval between checks of the ref for new commits. Defaults to 5m.
+	Interval *metav1.Duration `json:"interval,omitempty"`
 }
 
 // AtlasSchemaStatus defines the observed state of AtlasSchema
@@ -163,6 +785,186 @@ type AtlasSchemaStatus struct {
 	ObservedHash string `json:"observed_hash"`
 	// LastApplied is the unix timestamp of the most recent successful schema apply operation.
 	LastApplied int64 `json:"last_applied"`
+	// ObservedTarget identifies the database the most recent schema apply
+	// operation ran on. It is a hash of the URL without its credentials.
+	ObservedTarget string `json:"observedTarget,omitempty"`
+	// ObservedCommit is the commit SHA the Git schema source was resolved to
+	// in the most recent schema apply operation.
+	ObservedCommit string `json:"observed_commit,omitempty"`
+	// MigrationContext is the Vitess migration context of the online DDL
+	// migrations submitted by the most recent schema apply, while they run.
+	MigrationContext string `json:"migration_context,omitempty"`
+	// Preview reports the most recent preview branch apply.
+	Preview *PreviewStatus `json:"preview,omitempty"`
+	// Plan holds the changes planned for the database, until they are applied.
+	Plan *SchemaPlan `json:"plan,omitempty"`
+	// Planned previews the statements of the plan to reviewers, until they
+	// are applied.
+	Planned *PlannedStatus `json:"planned,omitempty"`
+	// Approval reports the plan awaiting approval.
+	Approval *ApprovalStatus `json:"approval,omitempty"`
+	// BreakGlass reports the most recent emergency apply that bypassed approval.
+	BreakGlass *BreakGlassStatus `json:"breakGlass,omitempty"`
+	// ApplyWindows lists the open and the next apply windows of the schema, so
+	// the schedule can be checked.
+	ApplyWindows []PlannedWindow `json:"applyWindows,omitempty"`
+	// Drift reports the drift of the database from the applied schema, until
+	// the database is brought back in sync.
+	Drift *DriftStatus `json:"drift,omitempty"`
+	// History holds the most recent changes applied to the database, oldest first.
+	History []AppliedChange `json:"history,omitempty"`
+	// Runs holds the CLI commands run by the most recent reconciles, oldest first.
+	Runs []CommandRun `json:"runs,omitempty"`
+	// Transitions holds the most recent transitions of the conditions, oldest first.
+	Transitions []ConditionTransition `json:"transitions,omitempty"`
+	// Lint reports the findings of the most recent lint.
+	Lint *LintStatus `json:"lint,omitempty"`
+	// LintReport is the name of the ConfigMap holding the SARIF report of the most recent lint.
+	LintReport string `json:"lintReport,omitempty"`
+	// Applying marks an apply in progress.
+	Applying *ApplyingStatus `json:"applying,omitempty"`
+	// MaterializedViews reports the refreshes of the materialized views.
+	MaterializedViews []MaterializedViewStatus `json:"materializedViews,omitempty"`
+	// Docs reports the most recent documentation of the applied schema.
+	Docs *DocsStatus `json:"docs,omitempty"`
+	// OperatorVersion is the version of the operator that last reconciled the resource.
+	OperatorVersion string `json:"operatorVersion,omitempty"`
+	// AtlasVersion is the version of the Atlas CLI that last reconciled the resource.
+	AtlasVersion string `json:"atlasVersion,omitempty"`
+	// StatusVersion is the version of the structure of the status. Statuses
+	// written by older operator versions are upgraded on their first reconcile.
+	StatusVersion int `json:"statusVersion,omitempty"`
+}
+
+// DocsStatus reports the most recent documentation of the applied schema.
+type DocsStatus struct {
+	// GeneratedAt is the time the documentation was generated.
+	GeneratedAt metav1.Time `json:"generatedAt"`
+	// ConfigMap is the name of the ConfigMap holding the documentation.
+	ConfigMap string `json:"configMap,omitempty"`
+	// SpecHash is the hash of the docs spec the documentation was published for.
+	SpecHash string `json:"specHash"`
+}
+
+// MaterializedViewStatus reports the refreshes of a materialized view.
+type MaterializedViewStatus struct {
+	// Name of the view.
+	Name string `json:"name"`
+	// LastRefreshTime is the time of the most recent successful refresh.
+	LastRefreshTime *metav1.Time `json:"lastRefreshTime,omitempty"`
+	// LastAttemptTime is the time of the most recent refresh attempt.
+	LastAttemptTime metav1.Time `json:"lastAttemptTime"`
+	// Error of the most recent refresh, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// ApplyingStatus marks an apply in progress. It is recorded before the operator
+// starts changing the database and cleared once the apply ends, so an operator
+// instance taking over after a restart or a leader failover detects the applies
+// that were interrupted, and recovers them instead of abandoning them.
+type ApplyingStatus struct {
+	// Holder is the identity of the operator instance that started the apply.
+	Holder string `json:"holder"`
+	// StartedAt is the time the apply started.
+	StartedAt metav1.Time `json:"startedAt"`
+}
+
+// DriftStatus reports the drift of the database from the applied schema.
+type DriftStatus struct {
+	// DetectedAt is the time the drift was first detected.
+	DetectedAt metav1.Time `json:"detectedAt"`
+	// Statements is the number of statements needed to bring the database back
+	// in sync.
+	Statements int `json:"statements"`
+	// Diff holds the statements needed to bring the database back in sync, with
+	// their string literals redacted. It is truncated to 4KiB.
+	Diff string `json:"diff,omitempty"`
+	// Truncated reports if statements were omitted from the diff.
+	Truncated bool `json:"truncated,omitempty"`
+	// ConfigMap holding the full diff, if enabled by the drift detection.
+	ConfigMap string `json:"configMap,omitempty"`
+}
+
+// BreakGlassStatus reports an emergency apply that bypassed approval.
+type BreakGlassStatus struct {
+	// Justification given in the db.atlasgo.io/break-glass annotation.
+	Justification string `json:"justification"`
+	// ObservedHash is the hash of the desired schema the bypass applies to.
+	ObservedHash string `json:"observedHash"`
+	// UsedAt is the time the bypass was first used.
+	UsedAt metav1.Time `json:"usedAt"`
+}
+
+// ApprovalStatus reports a plan awaiting approval.
+type ApprovalStatus struct {
+	// PlanHash identifies the plan.
+	PlanHash string `json:"planHash"`
+	// ApprovedPlan approves the plan when set to its PlanHash. It is set through
+	// the status subresource by users granted the "approve" verb on the resource.
+	ApprovedPlan string `json:"approvedPlan,omitempty"`
+	// Approvers records the users who approved a plan. Users approve a plan by
+	// adding themselves to this list through the status subresource.
+	Approvers []Approver `json:"approvers,omitempty"`
+	// ObservedHash is the hash of the desired schema the plan was computed for.
+	ObservedHash string `json:"observedHash"`
+	// Plan holds the statements awaiting approval.
+	Plan []string `json:"plan,omitempty"`
+	// PlannedAt is the time the plan was computed.
+	PlannedAt metav1.Time `json:"plannedAt"`
+	// Expired reports if the plan was rejected after the approval timeout.
+	Expired bool `json:"expired,omitempty"`
+}
+
+// SchemaPlan is the plan of the changes to apply to the database. The changes
+// are applied only if the database still requires the same changes.
+type SchemaPlan struct {
+	// Hash identifies the plan.
+	Hash string `json:"hash"`
+	// ObservedHash is the hash of the desired schema the plan was computed for.
+	ObservedHash string `json:"observedHash"`
+	// Statements to apply. Statements beyond the first 4KiB are omitted.
+	Statements []string `json:"statements,omitempty"`
+	// Truncated reports if statements were omitted.
+	Truncated bool `json:"truncated,omitempty"`
+	// DriftedFrom is the hash of the plan this plan replaced, as the database
+	// changed after it was computed. Such plans are applied once approved.
+	DriftedFrom string `json:"driftedFrom,omitempty"`
+	// PlannedAt is the time the plan was computed.
+	PlannedAt metav1.Time `json:"plannedAt"`
+}
+
+// PlannedStatus previews the statements planned for the database.
+type PlannedStatus struct {
+	// PlanHash identifies the previewed plan.
+	PlanHash string `json:"planHash"`
+	// Statements is the number of planned statements.
+	Statements int `json:"statements"`
+	// SQL holds the planned statements, with their string literals redacted.
+	// It is truncated to 4KiB.
+	SQL string `json:"sql,omitempty"`
+	// Truncated reports if statements were omitted from the SQL.
+	Truncated bool `json:"truncated,omitempty"`
+	// ConfigMap holding the full SQL, if it was truncated.
+	ConfigMap string `json:"configMap,omitempty"`
+}
+
+// Approver records the approval of a plan by a user.
+type Approver struct {
+	// Name of the user, as authenticated by the API server.
+	Name string `json:"name"`
+	// PlanHash is the hash of the approved plan.
+	PlanHash string `json:"planHash"`
+}
+
+// PreviewStatus reports the changes applied to a preview branch.
+type PreviewStatus struct {
+	// Branch is the name of the preview branch.
+	Branch string `json:"branch"`
+	// Diff holds the statements applied to the branch.
+	Diff []string `json:"diff,omitempty"`
+	// Outcome is what happened to the branch after the changes were applied:
+	// "kept", "promoted" or "deleted".
+	Outcome string `json:"outcome,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,235 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// The condition types, the condition reasons and the event reasons below are
+// part of the API of the operator, and automation may match on them. Their
+// values are stable: a value is never changed or reused with another meaning
+// in a later release. Reasons the operator stops reporting are kept, and
+// marked as deprecated.
+
+// Condition types.
+const (
+	// SchemaReadyCond reports if the desired schema of an AtlasSchema is applied.
+	SchemaReadyCond = "Ready"
+	// MigrateReadyCond reports if the migration directory of an AtlasMigration
+	// is applied up to its target version.
+	MigrateReadyCond = "Ready"
+	// MonitorReadyCond reports if the most recent observation of an
+	// AtlasMonitor succeeded.
+	MonitorReadyCond = "Ready"
+	// PendingApprovalCond reports a change awaiting approval. Its reason names
+	// the approval blocking the apply, and its message holds the plan hash or
+	// the version awaiting approval.
+	PendingApprovalCond = "PendingApproval"
+	// PlanOutdatedCond reports that the plan awaiting approval was replaced,
+	// as the database or the desired schema changed.
+	PlanOutdatedCond = "PlanOutdated"
+	// DriftedCond reports a database that drifted from the applied schema,
+	// while the drift is not remediated.
+	DriftedCond = "Drifted"
+	// HistoryDivergedCond reports applied migration files that were modified
+	// or removed from the directory.
+	HistoryDivergedCond = "HistoryDiverged"
+	// IncompatibleCLICond reports an Atlas CLI that does not support the
+	// features used by the migration directory.
+	IncompatibleCLICond = "IncompatibleCLI"
+	// CloudTokenCond reports the health of the Atlas Cloud token.
+	CloudTokenCond = "CloudTokenHealthy"
+	// TargetUnderMaintenanceCond is set while the target database is in
+	// recovery, read-only or restarting, e.g. during a maintenance window of
+	// a managed database.
+	TargetUnderMaintenanceCond = "TargetUnderMaintenance"
+)
+
+// Reasons of the Ready condition reporting the progress and the outcome of
+// a reconcile. Reasons of warnings are also the reasons of their events.
+const (
+	ReasonReconciling     = "Reconciling"
+	ReasonSuspended       = "Suspended"
+	ReasonImportingStatus = "ImportingStatus"
+	// ReasonApplied reports the changes were applied, or that there were none.
+	ReasonApplied = "Applied"
+	// ReasonBatchApplied reports a batch of migration files was applied, and
+	// that the next batch is pending.
+	ReasonBatchApplied = "BatchApplied"
+	ReasonDryRun       = "DryRun"
+	// ReasonInSync reports a database shared with AtlasMigration resources
+	// that matches the desired schema.
+	ReasonInSync = "InSync"
+	// ReasonMigrating reports a failure to apply migration files.
+	ReasonMigrating = "Migrating"
+	// ReasonApplyingSchema reports a failure to apply the desired schema.
+	ReasonApplyingSchema = "ApplyingSchema"
+	// ReasonError and ReasonTransientErr report unclassified failures of an
+	// AtlasMigration. Transient failures are retried.
+	ReasonError        = "Error"
+	ReasonTransientErr = "TransientErr"
+	// ReasonObserved and ReasonObserving report the outcome of an observation
+	// of an AtlasMonitor.
+	ReasonObserved  = "Observed"
+	ReasonObserving = "Observing"
+)
+
+// Reasons of the Ready condition reporting a failure to read the inputs of
+// a reconcile, or to prepare its execution.
+const (
+	ReasonReadSchema           = "ReadSchema"
+	ReasonReadingMigrationData = "ReadingMigrationData"
+	ReasonCalculatingHash      = "CalculatingHash"
+	ReasonChecksumMismatch     = "ChecksumMismatch"
+	ReasonCreatingConfigFile   = "CreatingConfigFile"
+	ReasonCreatingDevDB        = "CreatingDevDB"
+	ReasonGettingDevDB         = "GettingDevDB"
+	ReasonGettingDevDBURL      = "GettingDevDBURL"
+	ReasonRecreatingDevDB      = "RecreatingDevDB"
+	ReasonInvalidSchemaScope   = "InvalidSchemaScope"
+	ReasonCheckingOverlap      = "CheckingOverlap"
+	ReasonSchemaOverlap        = "SchemaOverlap"
+	ReasonListingMigrations    = "ListingMigrations"
+	ReasonCoexistenceConflict  = "CoexistenceConflict"
+	ReasonWaitingForMigrations = "WaitingForMigrations"
+	ReasonPlanningDrift        = "PlanningDrift"
+	ReasonPlanningSchema       = "PlanningSchema"
+	ReasonPlanningApproval     = "PlanningApproval"
+	ReasonFetchingPlan         = "FetchingPlan"
+	ReasonCloudTokenInvalid    = "CloudTokenInvalid"
+	ReasonIncompatibleCLI      = "IncompatibleCLI"
+	ReasonHistoryDiverged      = "HistoryDiverged"
+	ReasonMovingRevisions      = "MovingRevisions"
+	// ReasonRevisionsSchemaChanged reports a revisions schema that changed
+	// while the revisions table was not moved.
+	ReasonRevisionsSchemaChanged = "RevisionsSchemaChanged"
+	ReasonRevisionsMovePlanned   = "RevisionsMovePlanned"
+)
+
+// Reasons of the Ready condition reporting a change that is held back by a
+// policy of the resource, until the policy allows it.
+const (
+	ReasonInvalidApplyWindow   = "InvalidApplyWindow"
+	ReasonOutsideApplyWindow   = "OutsideApplyWindow"
+	ReasonCheckingHold         = "CheckingHold"
+	ReasonHeld                 = "Held"
+	ReasonApplyBudgetExhausted = "ApplyBudgetExhausted"
+	ReasonProbeDeferred        = "ProbeDeferred"
+	ReasonMarkingApply         = "MarkingApply"
+	ReasonApplyInterrupted     = "ApplyInterrupted"
+	ReasonLintFailed           = "LintFailed"
+	ReasonLintPolicyError      = "LintPolicyError"
+	ReasonVerifyingFirstRun    = "VerifyingFirstRun"
+	ReasonFirstRunDestructive  = "FirstRunDestructive"
+	ReasonDownNotAllowed       = "DownNotAllowed"
+	ReasonDownNotConfirmed     = "DownNotConfirmed"
+	ReasonTargetChanged        = "TargetChanged"
+	ReasonSchemaDrift          = "SchemaDrift"
+	ReasonPlanDrifted          = "PlanDrifted"
+	ReasonOnlineDDL            = "OnlineDDL"
+	ReasonOnlineDDLRunning     = "OnlineDDLRunning"
+	ReasonOnlineDDLFailed      = "OnlineDDLFailed"
+	ReasonPreviewBranch        = "PreviewBranch"
+	ReasonPreviewBranchPending = "PreviewBranchPending"
+)
+
+// Reasons of the Ready condition reporting a change awaiting approval. The
+// PendingApproval condition names the approval.
+const (
+	ReasonApprovalPending         = "ApprovalPending"
+	ReasonApprovalExpired         = "ApprovalExpired"
+	ReasonInvalidApprovalDeadline = "InvalidApprovalDeadline"
+	ReasonNoApprovedPlan          = "NoApprovedPlan"
+	ReasonPlanRejected            = "PlanRejected"
+	ReasonDestructiveNotApproved  = "DestructiveNotApproved"
+	ReasonBreakGlassInvalid       = "BreakGlassInvalid"
+)
+
+// Reasons of the PendingApproval condition.
+const (
+	ReasonAwaitingApproval            = "AwaitingApproval"
+	ReasonAwaitingCloudApproval       = "AwaitingCloudApproval"
+	ReasonAwaitingDestructiveApproval = "AwaitingDestructiveApproval"
+	ReasonAwaitingTargetConfirmation  = "AwaitingTargetConfirmation"
+	ReasonAwaitingDownConfirmation    = "AwaitingDownConfirmation"
+)
+
+// Reasons of the PlanOutdated condition.
+const (
+	ReasonDatabaseChanged      = "DatabaseChanged"
+	ReasonDesiredSchemaChanged = "DesiredSchemaChanged"
+)
+
+// Reasons of the HistoryDiverged condition.
+const (
+	ReasonFilesModified           = "FilesModified"
+	ReasonFilesRemoved            = "FilesRemoved"
+	ReasonFilesModifiedAndRemoved = "FilesModifiedAndRemoved"
+)
+
+// Reasons of the IncompatibleCLI condition.
+const (
+	ReasonVersionSkew = "VersionSkew"
+)
+
+// Reasons of the CloudTokenHealthy condition.
+const (
+	ReasonHealthy           = "Healthy"
+	ReasonTokenInvalid      = "TokenInvalid"
+	ReasonTokenExpired      = "TokenExpired"
+	ReasonTokenExpiring     = "TokenExpiring"
+	ReasonNoDirectoryAccess = "NoDirectoryAccess"
+	ReasonRateLimitLow      = "RateLimitLow"
+	ReasonCheckFailed       = "CheckFailed"
+)
+
+// Reasons of the TargetUnderMaintenance condition.
+const (
+	ReasonInRecovery = "InRecovery"
+	ReasonReadOnly   = "ReadOnly"
+	ReasonRestarting = "Restarting"
+)
+
+// Reasons of events that are not reasons of conditions.
+const (
+	ReasonApproved                   = "Approved"
+	ReasonDestructiveApproved        = "DestructiveApproved"
+	ReasonBreakGlass                 = "BreakGlass"
+	ReasonTargetConfirmed            = "TargetConfirmed"
+	ReasonApplyRecovered             = "ApplyRecovered"
+	ReasonFileApplied                = "FileApplied"
+	ReasonOnlineDDLSubmitted         = "OnlineDDLSubmitted"
+	ReasonPreviewApplied             = "PreviewApplied"
+	ReasonPreviewBranchCreated       = "PreviewBranchCreated"
+	ReasonRevisionsMoved             = "RevisionsMoved"
+	ReasonStatusImported             = "StatusImported"
+	ReasonPlanOutdated               = "PlanOutdated"
+	ReasonLintDiagnostic             = "LintDiagnostic"
+	ReasonLintReportError            = "LintReportError"
+	ReasonSchemaDocsError            = "SchemaDocsError"
+	ReasonSchemaDriftError           = "SchemaDriftError"
+	ReasonSchemaDriftResolved        = "SchemaDriftResolved"
+	ReasonObserveFailed              = "ObserveFailed"
+	ReasonMaintenanceEnded           = "MaintenanceEnded"
+	ReasonCloudTokenExpiring         = "CloudTokenExpiring"
+	ReasonCloudRateLimitLow          = "CloudRateLimitLow"
+	ReasonWebhookDeadLetter          = "WebhookDeadLetter"
+	ReasonRefreshingMaterializedView = "RefreshingMaterializedView"
+	ReasonCreatedDevDB               = "CreatedDevDB"
+	ReasonCleanUpDevDB               = "CleanUpDevDB"
+	ReasonCreatedDirectoryImageJob   = "CreatedDirectoryImageJob"
+	ReasonCreatedExternalSchemaJob   = "CreatedExternalSchemaJob"
+	ReasonGetURL                     = "GetURL"
+	ReasonGetPassword                = "GetPassword"
+)
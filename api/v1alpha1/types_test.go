@@ -1,6 +1,10 @@
 package v1alpha1
 
 import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -62,3 +66,73 @@ func TestCredentials_URL(t *testing.T) {
 		})
 	}
 }
+
+func TestAppendHistory(t *testing.T) {
+	var h []AppliedChange
+	for i := 0; i < MaxHistory+5; i++ {
+		h = AppendHistory(h, AppliedChange{Version: strconv.Itoa(i)})
+	}
+	require.Len(t, h, MaxHistory)
+	require.Equal(t, "5", h[0].Version)
+	require.Equal(t, strconv.Itoa(MaxHistory+4), h[MaxHistory-1].Version)
+}
+
+func TestAppendRun(t *testing.T) {
+	var r []CommandRun
+	for i := 0; i < MaxRuns+3; i++ {
+		r = AppendRun(r, CommandRun{Commands: []string{strconv.Itoa(i)}})
+	}
+	require.Len(t, r, MaxRuns)
+	require.Equal(t, []string{"3"}, r[0].Commands)
+	require.Equal(t, []string{strconv.Itoa(MaxRuns + 2)}, r[MaxRuns-1].Commands)
+}
+
+func TestAppendTransition(t *testing.T) {
+	var tr []ConditionTransition
+	for i := 0; i < MaxTransitions+1; i++ {
+		tr = AppendTransition(tr, ConditionTransition{Reason: strconv.Itoa(i)})
+	}
+	require.Len(t, tr, MaxTransitions)
+	require.Equal(t, "1", tr[0].Reason)
+	require.Equal(t, strconv.Itoa(MaxTransitions), tr[MaxTransitions-1].Reason)
+}
+
+func TestAdmissionRules(t *testing.T) {
+	types := map[string]reflect.Type{
+		"AtlasSchema":    reflect.TypeOf(AtlasSchema{}),
+		"AtlasMigration": reflect.TypeOf(AtlasMigration{}),
+	}
+	// field returns the field of the struct with the given JSON name.
+	field := func(typ reflect.Type, name string) (reflect.Type, bool) {
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if strings.Split(f.Tag.Get("json"), ",")[0] == name {
+				return f.Type, true
+			}
+		}
+		return nil, false
+	}
+	// Every field referenced by a rule must exist in the Go types.
+	paths := regexp.MustCompile(`object(\.\w+)+`)
+	for kind, rules := range AdmissionRules() {
+		typ, ok := types[kind]
+		require.True(t, ok, "unknown kind %s", kind)
+		for _, r := range rules {
+			require.NotEmpty(t, r.Message)
+			for _, p := range paths.FindAllString(r.Expression, -1) {
+				cur := typ
+				for _, name := range strings.Split(p, ".")[1:] {
+					if cur.Kind() == reflect.Ptr {
+						cur = cur.Elem()
+					}
+					if cur.Kind() != reflect.Struct {
+						// Macros and methods of lists and scalars, e.g. all or startsWith.
+						break
+					}
+					cur, ok = field(cur, name)
+					require.True(t, ok, "%s: unknown field %s in %s", kind, name, p)
+				}
+			}
+		}
+	}
+}
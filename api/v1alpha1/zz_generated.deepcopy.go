@@ -22,11 +22,176 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdmissionRule) DeepCopyInto(out *AdmissionRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdmissionRule.
+func (in *AdmissionRule) DeepCopy() *AdmissionRule {
+	if in == nil {
+		return nil
+	}
+	out := new(AdmissionRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppliedChange) DeepCopyInto(out *AppliedChange) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Statements != nil {
+		in, out := &in.Statements, &out.Statements
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppliedChange.
+func (in *AppliedChange) DeepCopy() *AppliedChange {
+	if in == nil {
+		return nil
+	}
+	out := new(AppliedChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplyWindow) DeepCopyInto(out *ApplyWindow) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplyWindow.
+func (in *ApplyWindow) DeepCopy() *ApplyWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplyWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplyWebhook) DeepCopyInto(out *ApplyWebhook) {
+	*out = *in
+	in.SecretFrom.DeepCopyInto(&out.SecretFrom)
+	if in.Retries != nil {
+		in, out := &in.Retries, &out.Retries
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplyWebhook.
+func (in *ApplyWebhook) DeepCopy() *ApplyWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplyWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplyingStatus) DeepCopyInto(out *ApplyingStatus) {
+	*out = *in
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplyingStatus.
+func (in *ApplyingStatus) DeepCopy() *ApplyingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplyingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Approval) DeepCopyInto(out *Approval) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Approval.
+func (in *Approval) DeepCopy() *Approval {
+	if in == nil {
+		return nil
+	}
+	out := new(Approval)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApprovalFile) DeepCopyInto(out *ApprovalFile) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApprovalFile.
+func (in *ApprovalFile) DeepCopy() *ApprovalFile {
+	if in == nil {
+		return nil
+	}
+	out := new(ApprovalFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApprovalStatus) DeepCopyInto(out *ApprovalStatus) {
+	*out = *in
+	if in.Approvers != nil {
+		in, out := &in.Approvers, &out.Approvers
+		*out = make([]Approver, len(*in))
+		copy(*out, *in)
+	}
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.PlannedAt.DeepCopyInto(&out.PlannedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApprovalStatus.
+func (in *ApprovalStatus) DeepCopy() *ApprovalStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ApprovalStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Approver) DeepCopyInto(out *Approver) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Approver.
+func (in *Approver) DeepCopy() *Approver {
+	if in == nil {
+		return nil
+	}
+	out := new(Approver)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AtlasMigration) DeepCopyInto(out *AtlasMigration) {
 	*out = *in
@@ -91,334 +256,1874 @@ func (in *AtlasMigrationSpec) DeepCopyInto(out *AtlasMigrationSpec) {
 	*out = *in
 	in.URLFrom.DeepCopyInto(&out.URLFrom)
 	in.Credentials.DeepCopyInto(&out.Credentials)
+	if in.Session != nil {
+		in, out := &in.Session, &out.Session
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.AuthTokenFrom.DeepCopyInto(&out.AuthTokenFrom)
+	in.TLSFrom.DeepCopyInto(&out.TLSFrom)
 	in.Cloud.DeepCopyInto(&out.Cloud)
 	in.Dir.DeepCopyInto(&out.Dir)
+	if in.Project != nil {
+		in, out := &in.Project, &out.Project
+		*out = new(Project)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BatchInterval != nil {
+		in, out := &in.BatchInterval, &out.BatchInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	in.Probe.DeepCopyInto(&out.Probe)
+	if in.ReplicationLag != nil {
+		in, out := &in.ReplicationLag, &out.ReplicationLag
+		*out = new(ReplicationLag)
+		(*in).DeepCopyInto(*out)
+	}
+	in.DevURLFrom.DeepCopyInto(&out.DevURLFrom)
+	if in.DevDB != nil {
+		in, out := &in.DevDB, &out.DevDB
+		*out = new(DevDB)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Lint != nil {
+		in, out := &in.Lint, &out.Lint
+		*out = new(MigrationLint)
+		(*in).DeepCopyInto(*out)
+	}
+	out.Policy = in.Policy
+	if in.Approval != nil {
+		in, out := &in.Approval, &out.Approval
+		*out = new(MigrationApproval)
+		**out = **in
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(ApplyWebhook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ApplyWindows != nil {
+		in, out := &in.ApplyWindows, &out.ApplyWindows
+		*out = make([]ApplyWindow, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasMigrationSpec.
+func (in *AtlasMigrationSpec) DeepCopy() *AtlasMigrationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasMigrationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasMigrationStatus) DeepCopyInto(out *AtlasMigrationStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Applying != nil {
+		in, out := &in.Applying, &out.Applying
+		*out = new(ApplyingStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]AppliedChange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Runs != nil {
+		in, out := &in.Runs, &out.Runs
+		*out = make([]CommandRun, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Transitions != nil {
+		in, out := &in.Transitions, &out.Transitions
+		*out = make([]ConditionTransition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(DryRunStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Lint != nil {
+		in, out := &in.Lint, &out.Lint
+		*out = new(LintStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CloudToken != nil {
+		in, out := &in.CloudToken, &out.CloudToken
+		*out = new(CloudTokenStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AppliedFiles != nil {
+		in, out := &in.AppliedFiles, &out.AppliedFiles
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Checkpoint != nil {
+		in, out := &in.Checkpoint, &out.Checkpoint
+		*out = new(CheckpointStatus)
+		**out = **in
+	}
+	if in.Approval != nil {
+		in, out := &in.Approval, &out.Approval
+		*out = new(MigrationApprovalStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ApplyWindows != nil {
+		in, out := &in.ApplyWindows, &out.ApplyWindows
+		*out = make([]PlannedWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasMigrationStatus.
+func (in *AtlasMigrationStatus) DeepCopy() *AtlasMigrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasMigrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasMonitor) DeepCopyInto(out *AtlasMonitor) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasMonitor.
+func (in *AtlasMonitor) DeepCopy() *AtlasMonitor {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasMonitor)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AtlasMonitor) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasMonitorList) DeepCopyInto(out *AtlasMonitorList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AtlasMonitor, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasMonitorList.
+func (in *AtlasMonitorList) DeepCopy() *AtlasMonitorList {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasMonitorList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AtlasMonitorList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasMonitorSpec) DeepCopyInto(out *AtlasMonitorSpec) {
+	*out = *in
+	in.URLFrom.DeepCopyInto(&out.URLFrom)
+	in.Credentials.DeepCopyInto(&out.Credentials)
+	in.Cloud.DeepCopyInto(&out.Cloud)
+	if in.Dir != nil {
+		in, out := &in.Dir, &out.Dir
+		*out = new(Dir)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Schemas != nil {
+		in, out := &in.Schemas, &out.Schemas
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasMonitorSpec.
+func (in *AtlasMonitorSpec) DeepCopy() *AtlasMonitorSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasMonitorSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasMonitorStatus) DeepCopyInto(out *AtlasMonitorStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ObservedAt != nil {
+		in, out := &in.ObservedAt, &out.ObservedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.PendingVersions != nil {
+		in, out := &in.PendingVersions, &out.PendingVersions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DriftDetectedAt != nil {
+		in, out := &in.DriftDetectedAt, &out.DriftDetectedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasMonitorStatus.
+func (in *AtlasMonitorStatus) DeepCopy() *AtlasMonitorStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasMonitorStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasPlan) DeepCopyInto(out *AtlasPlan) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasPlan.
+func (in *AtlasPlan) DeepCopy() *AtlasPlan {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasPlan)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AtlasPlan) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasPlanList) DeepCopyInto(out *AtlasPlanList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AtlasPlan, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasPlanList.
+func (in *AtlasPlanList) DeepCopy() *AtlasPlanList {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasPlanList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AtlasPlanList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasPlanSpec) DeepCopyInto(out *AtlasPlanSpec) {
+	*out = *in
+	out.Owner = in.Owner
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Objects != nil {
+		in, out := &in.Objects, &out.Objects
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Lint != nil {
+		in, out := &in.Lint, &out.Lint
+		*out = make([]LintFile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasPlanSpec.
+func (in *AtlasPlanSpec) DeepCopy() *AtlasPlanSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasPlanSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasPlanStatus) DeepCopyInto(out *AtlasPlanStatus) {
+	*out = *in
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasPlanStatus.
+func (in *AtlasPlanStatus) DeepCopy() *AtlasPlanStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasPlanStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasReferenceGrant) DeepCopyInto(out *AtlasReferenceGrant) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasReferenceGrant.
+func (in *AtlasReferenceGrant) DeepCopy() *AtlasReferenceGrant {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasReferenceGrant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AtlasReferenceGrant) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasReferenceGrantList) DeepCopyInto(out *AtlasReferenceGrantList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AtlasReferenceGrant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasReferenceGrantList.
+func (in *AtlasReferenceGrantList) DeepCopy() *AtlasReferenceGrantList {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasReferenceGrantList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AtlasReferenceGrantList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasReferenceGrantSpec) DeepCopyInto(out *AtlasReferenceGrantSpec) {
+	*out = *in
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]ReferenceGrantFrom, len(*in))
+		copy(*out, *in)
+	}
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]ReferenceGrantTo, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasReferenceGrantSpec.
+func (in *AtlasReferenceGrantSpec) DeepCopy() *AtlasReferenceGrantSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasReferenceGrantSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasSchema) DeepCopyInto(out *AtlasSchema) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasSchema.
+func (in *AtlasSchema) DeepCopy() *AtlasSchema {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasSchema)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AtlasSchema) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasSchemaList) DeepCopyInto(out *AtlasSchemaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AtlasSchema, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasSchemaList.
+func (in *AtlasSchemaList) DeepCopy() *AtlasSchemaList {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasSchemaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AtlasSchemaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasSchemaSpec) DeepCopyInto(out *AtlasSchemaSpec) {
+	*out = *in
+	in.URLFrom.DeepCopyInto(&out.URLFrom)
+	in.Credentials.DeepCopyInto(&out.Credentials)
+	in.DevURLFrom.DeepCopyInto(&out.DevURLFrom)
+	if in.DevDB != nil {
+		in, out := &in.DevDB, &out.DevDB
+		*out = new(DevDB)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Session != nil {
+		in, out := &in.Session, &out.Session
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.AuthTokenFrom.DeepCopyInto(&out.AuthTokenFrom)
+	in.TLSFrom.DeepCopyInto(&out.TLSFrom)
+	in.Schema.DeepCopyInto(&out.Schema)
+	if in.Vars != nil {
+		in, out := &in.Vars, &out.Vars
+		*out = make([]Var, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Policy.DeepCopyInto(&out.Policy)
+	if in.Schemas != nil {
+		in, out := &in.Schemas, &out.Schemas
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Vitess != nil {
+		in, out := &in.Vitess, &out.Vitess
+		*out = new(Vitess)
+		**out = **in
+	}
+	if in.Preview != nil {
+		in, out := &in.Preview, &out.Preview
+		*out = new(Preview)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Approval != nil {
+		in, out := &in.Approval, &out.Approval
+		*out = new(Approval)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaterializedViews != nil {
+		in, out := &in.MaterializedViews, &out.MaterializedViews
+		*out = make([]MaterializedView, len(*in))
+		copy(*out, *in)
+	}
+	if in.Docs != nil {
+		in, out := &in.Docs, &out.Docs
+		*out = new(SchemaDocs)
+		**out = **in
+	}
+	if in.DriftDetection != nil {
+		in, out := &in.DriftDetection, &out.DriftDetection
+		*out = new(DriftDetection)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(ApplyWebhook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ApplyWindows != nil {
+		in, out := &in.ApplyWindows, &out.ApplyWindows
+		*out = make([]ApplyWindow, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasSchemaSpec.
+func (in *AtlasSchemaSpec) DeepCopy() *AtlasSchemaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasSchemaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AtlasSchemaStatus) DeepCopyInto(out *AtlasSchemaStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Preview != nil {
+		in, out := &in.Preview, &out.Preview
+		*out = new(PreviewStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = new(SchemaPlan)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Planned != nil {
+		in, out := &in.Planned, &out.Planned
+		*out = new(PlannedStatus)
+		**out = **in
+	}
+	if in.Approval != nil {
+		in, out := &in.Approval, &out.Approval
+		*out = new(ApprovalStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BreakGlass != nil {
+		in, out := &in.BreakGlass, &out.BreakGlass
+		*out = new(BreakGlassStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ApplyWindows != nil {
+		in, out := &in.ApplyWindows, &out.ApplyWindows
+		*out = make([]PlannedWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Drift != nil {
+		in, out := &in.Drift, &out.Drift
+		*out = new(DriftStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]AppliedChange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Runs != nil {
+		in, out := &in.Runs, &out.Runs
+		*out = make([]CommandRun, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Transitions != nil {
+		in, out := &in.Transitions, &out.Transitions
+		*out = make([]ConditionTransition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Lint != nil {
+		in, out := &in.Lint, &out.Lint
+		*out = new(LintStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Applying != nil {
+		in, out := &in.Applying, &out.Applying
+		*out = new(ApplyingStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaterializedViews != nil {
+		in, out := &in.MaterializedViews, &out.MaterializedViews
+		*out = make([]MaterializedViewStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Docs != nil {
+		in, out := &in.Docs, &out.Docs
+		*out = new(DocsStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasSchemaStatus.
+func (in *AtlasSchemaStatus) DeepCopy() *AtlasSchemaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AtlasSchemaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuthHeaderFrom) DeepCopyInto(out *AuthHeaderFrom) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthHeaderFrom.
+func (in *AuthHeaderFrom) DeepCopy() *AuthHeaderFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthHeaderFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureAD) DeepCopyInto(out *AzureAD) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureAD.
+func (in *AzureAD) DeepCopy() *AzureAD {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureAD)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BreakGlassStatus) DeepCopyInto(out *BreakGlassStatus) {
+	*out = *in
+	in.UsedAt.DeepCopyInto(&out.UsedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BreakGlassStatus.
+func (in *BreakGlassStatus) DeepCopy() *BreakGlassStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BreakGlassStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CAFrom) DeepCopyInto(out *CAFrom) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CAFrom.
+func (in *CAFrom) DeepCopy() *CAFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(CAFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CheckConfig) DeepCopyInto(out *CheckConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CheckConfig.
+func (in *CheckConfig) DeepCopy() *CheckConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CheckConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CheckpointStatus) DeepCopyInto(out *CheckpointStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CheckpointStatus.
+func (in *CheckpointStatus) DeepCopy() *CheckpointStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CheckpointStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Cloud) DeepCopyInto(out *Cloud) {
+	*out = *in
+	in.TokenFrom.DeepCopyInto(&out.TokenFrom)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Cloud.
+func (in *Cloud) DeepCopy() *Cloud {
+	if in == nil {
+		return nil
+	}
+	out := new(Cloud)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudTokenStatus) DeepCopyInto(out *CloudTokenStatus) {
+	*out = *in
+	in.CheckedAt.DeepCopyInto(&out.CheckedAt)
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudTokenStatus.
+func (in *CloudTokenStatus) DeepCopy() *CloudTokenStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudTokenStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CommandRun) DeepCopyInto(out *CommandRun) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Commands != nil {
+		in, out := &in.Commands, &out.Commands
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommandRun.
+func (in *CommandRun) DeepCopy() *CommandRun {
+	if in == nil {
+		return nil
+	}
+	out := new(CommandRun)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConditionTransition) DeepCopyInto(out *ConditionTransition) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionTransition.
+func (in *ConditionTransition) DeepCopy() *ConditionTransition {
+	if in == nil {
+		return nil
+	}
+	out := new(ConditionTransition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Credentials) DeepCopyInto(out *Credentials) {
+	*out = *in
+	in.PasswordFrom.DeepCopyInto(&out.PasswordFrom)
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AzureAD != nil {
+		in, out := &in.AzureAD, &out.AzureAD
+		*out = new(AzureAD)
+		**out = **in
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(CredentialsTLS)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Credentials.
+func (in *Credentials) DeepCopy() *Credentials {
+	if in == nil {
+		return nil
+	}
+	out := new(Credentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CredentialsTLS) DeepCopyInto(out *CredentialsTLS) {
+	*out = *in
+	in.CAFrom.DeepCopyInto(&out.CAFrom)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CredentialsTLS.
+func (in *CredentialsTLS) DeepCopy() *CredentialsTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(CredentialsTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DevDB) DeepCopyInto(out *DevDB) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DevDB.
+func (in *DevDB) DeepCopy() *DevDB {
+	if in == nil {
+		return nil
+	}
+	out := new(DevDB)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Diff) DeepCopyInto(out *Diff) {
+	*out = *in
+	out.Skip = in.Skip
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Diff.
+func (in *Diff) DeepCopy() *Diff {
+	if in == nil {
+		return nil
+	}
+	out := new(Diff)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Dir) DeepCopyInto(out *Dir) {
+	*out = *in
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.ConfigMapRefs != nil {
+		in, out := &in.ConfigMapRefs, &out.ConfigMapRefs
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	out.Remote = in.Remote
+	if in.Local != nil {
+		in, out := &in.Local, &out.Local
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(DirImage)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Dir.
+func (in *Dir) DeepCopy() *Dir {
+	if in == nil {
+		return nil
+	}
+	out := new(Dir)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DirImage) DeepCopyInto(out *DirImage) {
+	*out = *in
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DirImage.
+func (in *DirImage) DeepCopy() *DirImage {
+	if in == nil {
+		return nil
+	}
+	out := new(DirImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DocsStatus) DeepCopyInto(out *DocsStatus) {
+	*out = *in
+	in.GeneratedAt.DeepCopyInto(&out.GeneratedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DocsStatus.
+func (in *DocsStatus) DeepCopy() *DocsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DocsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriftDetection) DeepCopyInto(out *DriftDetection) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriftDetection.
+func (in *DriftDetection) DeepCopy() *DriftDetection {
+	if in == nil {
+		return nil
+	}
+	out := new(DriftDetection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriftStatus) DeepCopyInto(out *DriftStatus) {
+	*out = *in
+	in.DetectedAt.DeepCopyInto(&out.DetectedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriftStatus.
+func (in *DriftStatus) DeepCopy() *DriftStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DriftStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRunStatus) DeepCopyInto(out *DryRunStatus) {
+	*out = *in
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make([]PlannedFile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.PlannedAt.DeepCopyInto(&out.PlannedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunStatus.
+func (in *DryRunStatus) DeepCopy() *DryRunStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRunStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalSchema) DeepCopyInto(out *ExternalSchema) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalSchema.
+func (in *ExternalSchema) DeepCopy() *ExternalSchema {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalSchema)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Git) DeepCopyInto(out *Git) {
+	*out = *in
+	in.PasswordFrom.DeepCopyInto(&out.PasswordFrom)
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Git.
+func (in *Git) DeepCopy() *Git {
+	if in == nil {
+		return nil
+	}
+	out := new(Git)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Lint) DeepCopyInto(out *Lint) {
+	*out = *in
+	out.Destructive = in.Destructive
+	if in.DataDepend != nil {
+		in, out := &in.DataDepend, &out.DataDepend
+		*out = new(CheckConfig)
+		**out = **in
+	}
+	if in.ConcurrentIndex != nil {
+		in, out := &in.ConcurrentIndex, &out.ConcurrentIndex
+		*out = new(CheckConfig)
+		**out = **in
+	}
+	if in.Naming != nil {
+		in, out := &in.Naming, &out.Naming
+		*out = new(NamingConfig)
+		**out = **in
+	}
+	if in.Incompatible != nil {
+		in, out := &in.Incompatible, &out.Incompatible
+		*out = new(CheckConfig)
+		**out = **in
+	}
+	if in.ConDrop != nil {
+		in, out := &in.ConDrop, &out.ConDrop
+		*out = new(CheckConfig)
+		**out = **in
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]corev1.ConfigMapKeySelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Report != nil {
+		in, out := &in.Report, &out.Report
+		*out = new(LintReport)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Lint.
+func (in *Lint) DeepCopy() *Lint {
+	if in == nil {
+		return nil
+	}
+	out := new(Lint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LintDiagnostic) DeepCopyInto(out *LintDiagnostic) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LintDiagnostic.
+func (in *LintDiagnostic) DeepCopy() *LintDiagnostic {
+	if in == nil {
+		return nil
+	}
+	out := new(LintDiagnostic)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LintFile) DeepCopyInto(out *LintFile) {
+	*out = *in
+	if in.Diagnostics != nil {
+		in, out := &in.Diagnostics, &out.Diagnostics
+		*out = make([]LintDiagnostic, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LintFile.
+func (in *LintFile) DeepCopy() *LintFile {
+	if in == nil {
+		return nil
+	}
+	out := new(LintFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LintReport) DeepCopyInto(out *LintReport) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LintReport.
+func (in *LintReport) DeepCopy() *LintReport {
+	if in == nil {
+		return nil
+	}
+	out := new(LintReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LintStatus) DeepCopyInto(out *LintStatus) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make([]LintFile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LintStatus.
+func (in *LintStatus) DeepCopy() *LintStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LintStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaterializedView) DeepCopyInto(out *MaterializedView) {
+	*out = *in
+	out.Interval = in.Interval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaterializedView.
+func (in *MaterializedView) DeepCopy() *MaterializedView {
+	if in == nil {
+		return nil
+	}
+	out := new(MaterializedView)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaterializedViewStatus) DeepCopyInto(out *MaterializedViewStatus) {
+	*out = *in
+	if in.LastRefreshTime != nil {
+		in, out := &in.LastRefreshTime, &out.LastRefreshTime
+		*out = (*in).DeepCopy()
+	}
+	in.LastAttemptTime.DeepCopyInto(&out.LastAttemptTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaterializedViewStatus.
+func (in *MaterializedViewStatus) DeepCopy() *MaterializedViewStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MaterializedViewStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationApproval) DeepCopyInto(out *MigrationApproval) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationApproval.
+func (in *MigrationApproval) DeepCopy() *MigrationApproval {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationApproval)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationApprovalStatus) DeepCopyInto(out *MigrationApprovalStatus) {
+	*out = *in
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make([]ApprovalFile, len(*in))
+		copy(*out, *in)
+	}
+	in.PlannedAt.DeepCopyInto(&out.PlannedAt)
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationApprovalStatus.
+func (in *MigrationApprovalStatus) DeepCopy() *MigrationApprovalStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationApprovalStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationLint) DeepCopyInto(out *MigrationLint) {
+	*out = *in
+	if in.ErrorOn != nil {
+		in, out := &in.ErrorOn, &out.ErrorOn
+		*out = make([]LintAnalyzer, len(*in))
+		copy(*out, *in)
+	}
+	if in.Report != nil {
+		in, out := &in.Report, &out.Report
+		*out = new(LintReport)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationLint.
+func (in *MigrationLint) DeepCopy() *MigrationLint {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationLint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationPolicy) DeepCopyInto(out *MigrationPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationPolicy.
+func (in *MigrationPolicy) DeepCopy() *MigrationPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamingConfig) DeepCopyInto(out *NamingConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamingConfig.
+func (in *NamingConfig) DeepCopy() *NamingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NamingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PasswordFrom) DeepCopyInto(out *PasswordFrom) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PasswordFrom.
+func (in *PasswordFrom) DeepCopy() *PasswordFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(PasswordFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlanOwner) DeepCopyInto(out *PlanOwner) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlanOwner.
+func (in *PlanOwner) DeepCopy() *PlanOwner {
+	if in == nil {
+		return nil
+	}
+	out := new(PlanOwner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlanPolicy) DeepCopyInto(out *PlanPolicy) {
+	*out = *in
+	in.TokenFrom.DeepCopyInto(&out.TokenFrom)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlanPolicy.
+func (in *PlanPolicy) DeepCopy() *PlanPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PlanPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlannedFile) DeepCopyInto(out *PlannedFile) {
+	*out = *in
+	if in.Statements != nil {
+		in, out := &in.Statements, &out.Statements
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlannedFile.
+func (in *PlannedFile) DeepCopy() *PlannedFile {
+	if in == nil {
+		return nil
+	}
+	out := new(PlannedFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlannedStatus) DeepCopyInto(out *PlannedStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlannedStatus.
+func (in *PlannedStatus) DeepCopy() *PlannedStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PlannedStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlannedWindow) DeepCopyInto(out *PlannedWindow) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasMigrationSpec.
-func (in *AtlasMigrationSpec) DeepCopy() *AtlasMigrationSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlannedWindow.
+func (in *PlannedWindow) DeepCopy() *PlannedWindow {
 	if in == nil {
 		return nil
 	}
-	out := new(AtlasMigrationSpec)
+	out := new(PlannedWindow)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AtlasMigrationStatus) DeepCopyInto(out *AtlasMigrationStatus) {
+func (in *Policy) DeepCopyInto(out *Policy) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]metav1.Condition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	in.Lint.DeepCopyInto(&out.Lint)
+	out.Diff = in.Diff
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = new(PlanPolicy)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasMigrationStatus.
-func (in *AtlasMigrationStatus) DeepCopy() *AtlasMigrationStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Policy.
+func (in *Policy) DeepCopy() *Policy {
 	if in == nil {
 		return nil
 	}
-	out := new(AtlasMigrationStatus)
+	out := new(Policy)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AtlasSchema) DeepCopyInto(out *AtlasSchema) {
+func (in *Preview) DeepCopyInto(out *Preview) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	in.TokenFrom.DeepCopyInto(&out.TokenFrom)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasSchema.
-func (in *AtlasSchema) DeepCopy() *AtlasSchema {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Preview.
+func (in *Preview) DeepCopy() *Preview {
 	if in == nil {
 		return nil
 	}
-	out := new(AtlasSchema)
+	out := new(Preview)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *AtlasSchema) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AtlasSchemaList) DeepCopyInto(out *AtlasSchemaList) {
+func (in *PreviewStatus) DeepCopyInto(out *PreviewStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]AtlasSchema, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Diff != nil {
+		in, out := &in.Diff, &out.Diff
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasSchemaList.
-func (in *AtlasSchemaList) DeepCopy() *AtlasSchemaList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreviewStatus.
+func (in *PreviewStatus) DeepCopy() *PreviewStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(AtlasSchemaList)
+	out := new(PreviewStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *AtlasSchemaList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AtlasSchemaSpec) DeepCopyInto(out *AtlasSchemaSpec) {
+func (in *Probe) DeepCopyInto(out *Probe) {
 	*out = *in
-	in.URLFrom.DeepCopyInto(&out.URLFrom)
-	in.Credentials.DeepCopyInto(&out.Credentials)
-	in.Schema.DeepCopyInto(&out.Schema)
-	if in.Exclude != nil {
-		in, out := &in.Exclude, &out.Exclude
-		*out = make([]string, len(*in))
+	if in.Queries != nil {
+		in, out := &in.Queries, &out.Queries
+		*out = make([]ProbeQuery, len(*in))
 		copy(*out, *in)
 	}
-	out.Policy = in.Policy
-	if in.Schemas != nil {
-		in, out := &in.Schemas, &out.Schemas
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.RetryAfter != nil {
+		in, out := &in.RetryAfter, &out.RetryAfter
+		*out = new(v1.Duration)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasSchemaSpec.
-func (in *AtlasSchemaSpec) DeepCopy() *AtlasSchemaSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Probe.
+func (in *Probe) DeepCopy() *Probe {
 	if in == nil {
 		return nil
 	}
-	out := new(AtlasSchemaSpec)
+	out := new(Probe)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AtlasSchemaStatus) DeepCopyInto(out *AtlasSchemaStatus) {
+func (in *ProbeQuery) DeepCopyInto(out *ProbeQuery) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]metav1.Condition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AtlasSchemaStatus.
-func (in *AtlasSchemaStatus) DeepCopy() *AtlasSchemaStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeQuery.
+func (in *ProbeQuery) DeepCopy() *ProbeQuery {
 	if in == nil {
 		return nil
 	}
-	out := new(AtlasSchemaStatus)
+	out := new(ProbeQuery)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CheckConfig) DeepCopyInto(out *CheckConfig) {
+func (in *Project) DeepCopyInto(out *Project) {
 	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Git != nil {
+		in, out := &in.Git, &out.Git
+		*out = new(Git)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Vars != nil {
+		in, out := &in.Vars, &out.Vars
+		*out = make([]Var, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CheckConfig.
-func (in *CheckConfig) DeepCopy() *CheckConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Project.
+func (in *Project) DeepCopy() *Project {
 	if in == nil {
 		return nil
 	}
-	out := new(CheckConfig)
+	out := new(Project)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Cloud) DeepCopyInto(out *Cloud) {
+func (in *ReferenceGrantFrom) DeepCopyInto(out *ReferenceGrantFrom) {
 	*out = *in
-	in.TokenFrom.DeepCopyInto(&out.TokenFrom)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Cloud.
-func (in *Cloud) DeepCopy() *Cloud {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantFrom.
+func (in *ReferenceGrantFrom) DeepCopy() *ReferenceGrantFrom {
 	if in == nil {
 		return nil
 	}
-	out := new(Cloud)
+	out := new(ReferenceGrantFrom)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Credentials) DeepCopyInto(out *Credentials) {
+func (in *ReferenceGrantTo) DeepCopyInto(out *ReferenceGrantTo) {
 	*out = *in
-	in.PasswordFrom.DeepCopyInto(&out.PasswordFrom)
-	if in.Parameters != nil {
-		in, out := &in.Parameters, &out.Parameters
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Credentials.
-func (in *Credentials) DeepCopy() *Credentials {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantTo.
+func (in *ReferenceGrantTo) DeepCopy() *ReferenceGrantTo {
 	if in == nil {
 		return nil
 	}
-	out := new(Credentials)
+	out := new(ReferenceGrantTo)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Diff) DeepCopyInto(out *Diff) {
+func (in *Registry) DeepCopyInto(out *Registry) {
 	*out = *in
-	out.Skip = in.Skip
+	in.TokenFrom.DeepCopyInto(&out.TokenFrom)
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Diff.
-func (in *Diff) DeepCopy() *Diff {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Registry.
+func (in *Registry) DeepCopy() *Registry {
 	if in == nil {
 		return nil
 	}
-	out := new(Diff)
+	out := new(Registry)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Dir) DeepCopyInto(out *Dir) {
+func (in *Remote) DeepCopyInto(out *Remote) {
 	*out = *in
-	if in.ConfigMapRef != nil {
-		in, out := &in.ConfigMapRef, &out.ConfigMapRef
-		*out = new(v1.LocalObjectReference)
-		**out = **in
-	}
-	out.Remote = in.Remote
-	if in.Local != nil {
-		in, out := &in.Local, &out.Local
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Dir.
-func (in *Dir) DeepCopy() *Dir {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Remote.
+func (in *Remote) DeepCopy() *Remote {
 	if in == nil {
 		return nil
 	}
-	out := new(Dir)
+	out := new(Remote)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Lint) DeepCopyInto(out *Lint) {
+func (in *ReplicationLag) DeepCopyInto(out *ReplicationLag) {
 	*out = *in
-	out.Destructive = in.Destructive
+	if in.RetryAfter != nil {
+		in, out := &in.RetryAfter, &out.RetryAfter
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Lint.
-func (in *Lint) DeepCopy() *Lint {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicationLag.
+func (in *ReplicationLag) DeepCopy() *ReplicationLag {
 	if in == nil {
 		return nil
 	}
-	out := new(Lint)
+	out := new(ReplicationLag)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PasswordFrom) DeepCopyInto(out *PasswordFrom) {
+func (in *Schema) DeepCopyInto(out *Schema) {
 	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.SecretKeyRef != nil {
 		in, out := &in.SecretKeyRef, &out.SecretKeyRef
-		*out = new(v1.SecretKeySelector)
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Git != nil {
+		in, out := &in.Git, &out.Git
+		*out = new(Git)
+		(*in).DeepCopyInto(*out)
+	}
+	in.AuthHeaderFrom.DeepCopyInto(&out.AuthHeaderFrom)
+	if in.Registry != nil {
+		in, out := &in.Registry, &out.Registry
+		*out = new(Registry)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.External != nil {
+		in, out := &in.External, &out.External
+		*out = new(ExternalSchema)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Layers != nil {
+		in, out := &in.Layers, &out.Layers
+		*out = make([]SchemaLayer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SchemaRef != nil {
+		in, out := &in.SchemaRef, &out.SchemaRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PasswordFrom.
-func (in *PasswordFrom) DeepCopy() *PasswordFrom {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Schema.
+func (in *Schema) DeepCopy() *Schema {
 	if in == nil {
 		return nil
 	}
-	out := new(PasswordFrom)
+	out := new(Schema)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Policy) DeepCopyInto(out *Policy) {
+func (in *SchemaDocs) DeepCopyInto(out *SchemaDocs) {
 	*out = *in
-	out.Lint = in.Lint
-	out.Diff = in.Diff
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Policy.
-func (in *Policy) DeepCopy() *Policy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SchemaDocs.
+func (in *SchemaDocs) DeepCopy() *SchemaDocs {
 	if in == nil {
 		return nil
 	}
-	out := new(Policy)
+	out := new(SchemaDocs)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Remote) DeepCopyInto(out *Remote) {
+func (in *SchemaLayer) DeepCopyInto(out *SchemaLayer) {
 	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Remote.
-func (in *Remote) DeepCopy() *Remote {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SchemaLayer.
+func (in *SchemaLayer) DeepCopy() *SchemaLayer {
 	if in == nil {
 		return nil
 	}
-	out := new(Remote)
+	out := new(SchemaLayer)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Schema) DeepCopyInto(out *Schema) {
+func (in *SchemaPlan) DeepCopyInto(out *SchemaPlan) {
 	*out = *in
-	if in.ConfigMapKeyRef != nil {
-		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
-		*out = new(v1.ConfigMapKeySelector)
-		(*in).DeepCopyInto(*out)
+	if in.Statements != nil {
+		in, out := &in.Statements, &out.Statements
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
+	in.PlannedAt.DeepCopyInto(&out.PlannedAt)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Schema.
-func (in *Schema) DeepCopy() *Schema {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SchemaPlan.
+func (in *SchemaPlan) DeepCopy() *SchemaPlan {
 	if in == nil {
 		return nil
 	}
-	out := new(Schema)
+	out := new(SchemaPlan)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -438,12 +2143,32 @@ func (in *SkipChanges) DeepCopy() *SkipChanges {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSFrom) DeepCopyInto(out *TLSFrom) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSFrom.
+func (in *TLSFrom) DeepCopy() *TLSFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TokenFrom) DeepCopyInto(out *TokenFrom) {
 	*out = *in
 	if in.SecretKeyRef != nil {
 		in, out := &in.SecretKeyRef, &out.SecretKeyRef
-		*out = new(v1.SecretKeySelector)
+		*out = new(corev1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
 }
@@ -463,7 +2188,7 @@ func (in *URLFrom) DeepCopyInto(out *URLFrom) {
 	*out = *in
 	if in.SecretKeyRef != nil {
 		in, out := &in.SecretKeyRef, &out.SecretKeyRef
-		*out = new(v1.SecretKeySelector)
+		*out = new(corev1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
 }
@@ -477,3 +2202,59 @@ func (in *URLFrom) DeepCopy() *URLFrom {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Var) DeepCopyInto(out *Var) {
+	*out = *in
+	in.ValueFrom.DeepCopyInto(&out.ValueFrom)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Var.
+func (in *Var) DeepCopy() *Var {
+	if in == nil {
+		return nil
+	}
+	out := new(Var)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarSource) DeepCopyInto(out *VarSource) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarSource.
+func (in *VarSource) DeepCopy() *VarSource {
+	if in == nil {
+		return nil
+	}
+	out := new(VarSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Vitess) DeepCopyInto(out *Vitess) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Vitess.
+func (in *Vitess) DeepCopy() *Vitess {
+	if in == nil {
+		return nil
+	}
+	out := new(Vitess)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -67,4 +67,11 @@ Create the name of the service account to use
 
 {{- define "atlas-operator.leaderElectionRole" -}}
 {{ include "atlas-operator.fullname" . }}-leader-election-role
-{{- end }}
\ No newline at end of file
+{{- end }}
+
+{{/*
+Whether any webhook of the operator is enabled. The webhooks share a service and its certificate.
+*/}}
+{{- define "atlas-operator.webhooks" -}}
+{{- if or .Values.approvalWebhook.enabled .Values.riskWebhook.enabled .Values.deletionWebhook.enabled .Values.scheduleWebhook.enabled }}true{{- end }}
+{{- end }}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const (
+	// webhookSignatureHeader carries the HMAC-SHA256 signature of the payload.
+	webhookSignatureHeader = "X-Atlas-Signature-256"
+	// webhookDeliveryHeader identifies the delivery. It is the same for all the
+	// attempts of a delivery, so receivers can drop duplicates.
+	webhookDeliveryHeader = "X-Atlas-Delivery"
+	// defaultWebhookRetries is the number of retries of a failed delivery, if
+	// the webhook does not set one.
+	defaultWebhookRetries = 3
+	// webhookDeliveryTimeout bounds a delivery and all its retries.
+	webhookDeliveryTimeout = 5 * time.Minute
+	// maxDeadLetterPayload is the size of the payload held by dead-letter events.
+	maxDeadLetterPayload = 1 << 10
+)
+
+// webhookBackoff is the delay before the first retry of a failed delivery.
+// It doubles with each retry.
+var webhookBackoff = time.Second
+
+// applyResult is the payload posted to the webhook of a resource after an apply.
+type applyResult struct {
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Time      time.Time `json:"time"`
+	// Status of the apply, "applied" or "failed".
+	Status  string                     `json:"status"`
+	Version string                     `json:"version,omitempty"`
+	Changes []dbv1alpha1.AppliedChange `json:"changes,omitempty"`
+	Error   string                     `json:"error,omitempty"`
+}
+
+// webhookStatusErr is returned for deliveries rejected by the endpoint.
+type webhookStatusErr struct {
+	code   int
+	status string
+}
+
+func (e *webhookStatusErr) Error() string {
+	return "unexpected status " + e.status
+}
+
+// retry reports if the delivery may succeed when retried. Client errors are
+// not retried, except for timeouts and rate limits.
+func (e *webhookStatusErr) retry() bool {
+	return e.code/100 != 4 || e.code == http.StatusRequestTimeout || e.code == http.StatusTooManyRequests
+}
+
+// appliedResult returns the result of an apply that ran the given changes.
+func appliedResult(kind string, obj client.Object, version string, changes []dbv1alpha1.AppliedChange) applyResult {
+	return applyResult{
+		Kind:      kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Time:      time.Now().UTC(),
+		Status:    "applied",
+		Version:   version,
+		Changes:   changes,
+	}
+}
+
+// failedResult returns the result of an apply that failed with the given error.
+func failedResult(kind string, obj client.Object, err error) applyResult {
+	return applyResult{
+		Kind:      kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Time:      time.Now().UTC(),
+		Status:    "failed",
+		Error:     err.Error(),
+	}
+}
+
+// notifyApply posts the result of an apply to the webhook, if set. Deliveries
+// are retried with backoff, so they run in the background and do not hold the
+// reconcile of the resource. Deliveries that fail after all their retries are
+// reported by a WebhookDeadLetter event holding the start of the payload.
+func notifyApply(ctx context.Context, c client.Reader, hc *http.Client, rec record.EventRecorder, obj client.Object, wh *dbv1alpha1.ApplyWebhook, res applyResult) {
+	if wh == nil {
+		return
+	}
+	payload, err := json.Marshal(res)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(payload)
+	id := hex.EncodeToString(sum[:16])
+	sig, err := webhookSignature(ctx, c, obj.GetNamespace(), wh, payload)
+	if err != nil {
+		rec.Eventf(obj, corev1.EventTypeWarning, dbv1alpha1.ReasonWebhookDeadLetter,
+			"Delivery %s to %s failed: %v. Payload: %s", id, webhookHost(wh.URL), err, deadLetter(payload))
+		return
+	}
+	// The object is updated by the reconcile while the delivery runs.
+	obj = obj.DeepCopyObject().(client.Object)
+	wh = wh.DeepCopy()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookDeliveryTimeout)
+		defer cancel()
+		if attempts, err := deliverWebhook(ctx, hc, wh, id, sig, payload); err != nil {
+			rec.Eventf(obj, corev1.EventTypeWarning, dbv1alpha1.ReasonWebhookDeadLetter,
+				"Delivery %s to %s failed after %d attempts: %v. Payload: %s", id, webhookHost(wh.URL), attempts, err, deadLetter(payload))
+		}
+	}()
+}
+
+// deadLetter returns the payload held by a dead-letter event, truncated to
+// keep the events of large applies small.
+func deadLetter(payload []byte) string {
+	if len(payload) <= maxDeadLetterPayload {
+		return string(payload)
+	}
+	return fmt.Sprintf("%s... (truncated, %d bytes)", payload[:maxDeadLetterPayload], len(payload))
+}
+
+// webhookSignature returns the signature of the payload with the key of the
+// webhook, if set.
+func webhookSignature(ctx context.Context, c client.Reader, ns string, wh *dbv1alpha1.ApplyWebhook, payload []byte) (string, error) {
+	s := wh.SecretFrom.SecretKeyRef
+	if s == nil {
+		return "", nil
+	}
+	key, err := getSecretValue(ctx, c, ns, *s)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", fmt.Errorf("secret %s/%s does not contain key %s", ns, s.Name, s.Key)
+	}
+	m := hmac.New(sha256.New, []byte(key))
+	m.Write(payload)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil)), nil
+}
+
+// deliverWebhook posts the payload to the webhook, retrying failed deliveries
+// with exponential backoff. It returns the number of attempts made.
+func deliverWebhook(ctx context.Context, hc *http.Client, wh *dbv1alpha1.ApplyWebhook, id, sig string, payload []byte) (int, error) {
+	retries := defaultWebhookRetries
+	if wh.Retries != nil {
+		retries = *wh.Retries
+	}
+	backoff := webhookBackoff
+	for attempt := 1; ; attempt++ {
+		err := postWebhook(ctx, hc, wh.URL, id, sig, payload)
+		if err == nil {
+			return attempt, nil
+		}
+		var sErr *webhookStatusErr
+		if attempt > retries || errors.As(err, &sErr) && !sErr.retry() {
+			return attempt, err
+		}
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// postWebhook makes a single delivery attempt of the payload.
+func postWebhook(ctx context.Context, hc *http.Client, u, id, sig string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return errors.New("invalid webhook URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookDeliveryHeader, id)
+	if sig != "" {
+		req.Header.Set(webhookSignatureHeader, sig)
+	}
+	resp, err := hc.Do(req)
+	// Errors of the client name the URL, which may hold credentials.
+	var uErr *url.Error
+	if errors.As(err, &uErr) {
+		return uErr.Err
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return &webhookStatusErr{code: resp.StatusCode, status: resp.Status}
+	}
+	return nil
+}
+
+// webhookHost returns the host of the webhook URL, to name the endpoint in
+// events without the credentials or the tokens the URL may hold.
+func webhookHost(s string) string {
+	if u, err := url.Parse(s); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "the webhook"
+}
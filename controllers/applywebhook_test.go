@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// webhookServer records the deliveries it receives, and responds with the
+// given status codes in order, then with 200.
+type webhookServer struct {
+	*httptest.Server
+	mu         sync.Mutex
+	codes      []int
+	deliveries []*http.Request
+	payloads   [][]byte
+}
+
+func newWebhookServer(t *testing.T, codes ...int) *webhookServer {
+	s := &webhookServer{codes: codes}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.deliveries = append(s.deliveries, r)
+		s.payloads = append(s.payloads, b)
+		code := http.StatusOK
+		if len(s.codes) > 0 {
+			code, s.codes = s.codes[0], s.codes[1:]
+		}
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(s.Close)
+	prev := webhookBackoff
+	webhookBackoff = time.Millisecond
+	t.Cleanup(func() { webhookBackoff = prev })
+	return s
+}
+
+// received returns the payloads received so far.
+func (s *webhookServer) received() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte(nil), s.payloads...)
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	tt := newTest(t)
+	tt.k8s.put(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "test"},
+		Data:       map[string][]byte{"key": []byte("s3cr3t")},
+	})
+	srv := newWebhookServer(t, http.StatusBadGateway, http.StatusTooManyRequests)
+	wh := &dbv1alpha1.ApplyWebhook{
+		URL: srv.URL,
+		SecretFrom: dbv1alpha1.TokenFrom{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "webhook"},
+				Key:                  "key",
+			},
+		},
+	}
+	payload := []byte(`{"status":"applied"}`)
+	sig, err := webhookSignature(context.Background(), tt.k8s, "test", wh, payload)
+	require.NoError(t, err)
+	m := hmac.New(sha256.New, []byte("s3cr3t"))
+	m.Write(payload)
+	require.Equal(t, "sha256="+hex.EncodeToString(m.Sum(nil)), sig)
+
+	// Failed deliveries are retried with the same delivery ID and signature.
+	n, err := deliverWebhook(context.Background(), srv.Client(), wh, "id", sig, payload)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	for i, r := range srv.deliveries {
+		require.Equal(t, "id", r.Header.Get(webhookDeliveryHeader))
+		require.Equal(t, sig, r.Header.Get(webhookSignatureHeader))
+		require.Equal(t, payload, srv.payloads[i])
+	}
+
+	// Rejected deliveries are not retried.
+	srv.codes = []int{http.StatusBadRequest}
+	n, err = deliverWebhook(context.Background(), srv.Client(), wh, "id", sig, payload)
+	require.EqualError(t, err, "unexpected status 400 Bad Request")
+	require.Equal(t, 1, n)
+
+	// Deliveries stop after the last retry.
+	retries := 1
+	wh.Retries = &retries
+	srv.codes = []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}
+	n, err = deliverWebhook(context.Background(), srv.Client(), wh, "id", sig, payload)
+	require.EqualError(t, err, "unexpected status 500 Internal Server Error")
+	require.Equal(t, 2, n)
+
+	// Payloads are not sent unsigned.
+	wh.SecretFrom.SecretKeyRef.Key = "missing"
+	_, err = webhookSignature(context.Background(), tt.k8s, "test", wh, payload)
+	require.EqualError(t, err, "secret test/webhook does not contain key missing")
+}
+
+func TestReconcile_Webhook(t *testing.T) {
+	tt := newTest(t)
+	srv := newWebhookServer(t)
+	tt.r.httpClient = srv.Client()
+	tt.mockCLI().plan = "ALTER TABLE `foo` ADD COLUMN `bar` int"
+	tt.mockCLI().applied = []string{"ALTER TABLE `foo` ADD COLUMN `bar` int"}
+	sc := conditionReconciling()
+	sc.Spec.Webhook = &dbv1alpha1.ApplyWebhook{URL: srv.URL}
+	tt.k8s.put(sc)
+	tt.k8s.put(devDBReady())
+
+	// Applied changes are posted to the webhook.
+	_, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.EqualValues(t, metav1.ConditionTrue, tt.cond().Status)
+	require.Eventually(t, func() bool { return len(srv.received()) == 1 }, time.Second, time.Millisecond)
+	var res applyResult
+	require.NoError(t, json.Unmarshal(srv.payloads[0], &res))
+	require.Equal(t, "AtlasSchema", res.Kind)
+	require.Equal(t, "test", res.Namespace)
+	require.Equal(t, "applied", res.Status)
+	require.Len(t, res.Changes, 1)
+	require.Equal(t, []string{"ALTER TABLE `foo` ADD COLUMN `bar` int"}, res.Changes[0].Statements)
+	require.Empty(t, srv.deliveries[0].Header.Get(webhookSignatureHeader))
+
+	// Failed applies are posted too, and undelivered results are dead-lettered.
+	tt.events()
+	srv.codes = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+	tt.mockCLI().applyErr = errors.New("sql/migrate: execute: executing statement \"ALTER TABLE `foo` DROP COLUMN `baz`\": Error 1091")
+	tt.k8s.put(devDBReady())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	ev := tt.events()
+	require.Len(t, ev, 1)
+	require.Eventually(t, func() bool {
+		ev = append(ev, tt.events()...)
+		return len(ev) == 2
+	}, time.Second, time.Millisecond)
+	require.Contains(t, ev[1], "Warning WebhookDeadLetter Delivery ")
+	require.Contains(t, ev[1], " failed after 4 attempts: unexpected status 503 Service Unavailable. Payload: {")
+	require.Len(t, srv.received(), 5)
+	require.NoError(t, json.Unmarshal(srv.payloads[4], &res))
+	require.Equal(t, "failed", res.Status)
+	require.Contains(t, res.Error, "Error 1091")
+
+	// Dead-letter events hold the start of large payloads.
+	require.Equal(t, `{"status":"applied"}`, deadLetter([]byte(`{"status":"applied"}`)))
+	large := deadLetter(make([]byte, 4*maxDeadLetterPayload))
+	require.Len(t, large, maxDeadLetterPayload+len("... (truncated, 4096 bytes)"))
+}
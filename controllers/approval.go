@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slices"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// awaitApproval sets the PendingApproval condition of a change that is not
+// applied until it is approved, and records an ApprovalPending event when
+// the change starts awaiting the approval.
+func awaitApproval(rec record.EventRecorder, obj runtime.Object, conds *[]metav1.Condition, reason, change, msg string) {
+	if c := meta.FindStatusCondition(*conds, dbv1alpha1.PendingApprovalCond); c == nil || c.Reason != reason || c.Message != msg {
+		rec.Eventf(obj, corev1.EventTypeNormal, dbv1alpha1.ReasonApprovalPending, "%s is awaiting approval", change)
+	}
+	meta.SetStatusCondition(conds, metav1.Condition{
+		Type:    dbv1alpha1.PendingApprovalCond,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: msg,
+	})
+}
+
+var expiredApprovals = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "atlas_operator_expired_approvals_total",
+	Help: "Number of plans rejected because they were not approved within the approval timeout.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(expiredApprovals)
+}
+
+// requiresApproval reports if the planned changes of the schema must be
+// approved before they are applied.
+func requiresApproval(sc *dbv1alpha1.AtlasSchema) bool {
+	return sc.Spec.Approval != nil || sc.Spec.Policy.Approval == dbv1alpha1.ApprovalManual
+}
+
+// reviewRequired reports if the review policy of the schema requires the
+// planned changes to be approved, given the error returned by their lint.
+func reviewRequired(m *managed, lintErr error) bool {
+	switch m.policy.Review {
+	case dbv1alpha1.ReviewAlways:
+		return true
+	case dbv1alpha1.ReviewError:
+		return lintErr != nil
+	case dbv1alpha1.ReviewWarning:
+		if lintErr != nil {
+			return true
+		}
+		for _, f := range m.lintFiles {
+			if f.Error != "" {
+				return true
+			}
+			for _, r := range f.Reports {
+				if len(r.Diagnostics) > 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// lintFindings reports if the lint error was caused by findings failing the
+// lint, rather than by a failure to run it.
+func lintFindings(err error) bool {
+	var (
+		dErr destructiveErr
+		aErr analyzerErr
+		rErr ruleErr
+	)
+	return errors.As(err, &dErr) || errors.As(err, &aErr) || errors.As(err, &rErr)
+}
+
+// approvalExpired reports if the plan of the desired schema was rejected after
+// the approval timeout.
+func approvalExpired(sc *dbv1alpha1.AtlasSchema, m *managed) bool {
+	a := sc.Status.Approval
+	return a != nil && a.Expired && a.ObservedHash == m.hash()
+}
+
+// approve reports if the planned changes of a schema that requires approval
+// were approved and can be applied. Plans that are not approved within the
+// approval timeout are rejected, and planned again only when the desired
+// schema changes.
+func (r *AtlasSchemaReconciler) approve(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed, plan *dbv1alpha1.SchemaPlan) (ctrl.Result, bool, error) {
+	a := sc.Status.Approval
+	// Nothing to approve.
+	if len(plan.Statements) == 0 {
+		sc.Status.Approval = nil
+		meta.RemoveStatusCondition(&sc.Status.Conditions, dbv1alpha1.PendingApprovalCond)
+		meta.RemoveStatusCondition(&sc.Status.Conditions, dbv1alpha1.PlanOutdatedCond)
+		return ctrl.Result{}, true, nil
+	}
+	h := plan.Hash
+	if a == nil || a.PlanHash != h {
+		// The plan awaiting approval no longer matches the changes to apply.
+		if a != nil && !a.Expired {
+			r.outdatePlan(ctx, sc, a, m, h, plan.Statements)
+		}
+		a = &dbv1alpha1.ApprovalStatus{
+			PlanHash:     h,
+			ObservedHash: m.hash(),
+			Plan:         plan.Statements,
+			PlannedAt:    metav1.Now(),
+		}
+		sc.Status.Approval = a
+		r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonApprovalPending, "Plan %s is awaiting approval", h)
+	}
+	review, err := reviewPlan(ctx, r.Client, r.scheme, sc, schemaPlan(sc, h, plan.Statements))
+	if err != nil {
+		return ctrl.Result{}, false, err
+	}
+	if review.Status.Phase == dbv1alpha1.PlanRejected {
+		meta.RemoveStatusCondition(&sc.Status.Conditions, dbv1alpha1.PendingApprovalCond)
+		msg := (&planRejectedErr{plan: review}).Error()
+		setNotReady(sc, dbv1alpha1.ReasonPlanRejected, msg)
+		r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonPlanRejected, msg)
+		return ctrl.Result{}, false, nil
+	}
+	spec := sc.Spec.Approval
+	if spec == nil {
+		spec = &dbv1alpha1.Approval{}
+	}
+	required := spec.RequiredApprovers
+	if required < 1 {
+		required = 1
+	}
+	if planApprovalExpired(review) {
+		if err := expirePlanApproval(ctx, r.Client, review); err != nil {
+			return ctrl.Result{}, false, err
+		}
+	}
+	// Approvals recorded on the resource are discarded after their deadline.
+	var n int
+	expired := approvalsExpired(sc)
+	if expired.IsZero() {
+		n = approvals(a, h)
+	} else {
+		a.ApprovedPlan, a.Approvers = "", nil
+	}
+	if n == 0 && (expired.IsZero() && sc.Spec.ApprovedHash == h || review.Status.Phase == dbv1alpha1.PlanApproved) {
+		n = 1
+	}
+	if n >= required {
+		sc.Status.Approval = nil
+		meta.RemoveStatusCondition(&sc.Status.Conditions, dbv1alpha1.PendingApprovalCond)
+		meta.RemoveStatusCondition(&sc.Status.Conditions, dbv1alpha1.PlanOutdatedCond)
+		names := approverNames(a, h)
+		if len(names) == 0 && review.Status.Phase == dbv1alpha1.PlanApproved && review.Status.Reviewer != "" {
+			names = []string{review.Status.Reviewer}
+		}
+		if len(names) > 0 {
+			r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonApproved, "Plan %s was approved by %s", h, strings.Join(names, ", "))
+		} else {
+			r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonApproved, "Plan %s was approved", h)
+		}
+		return ctrl.Result{}, true, nil
+	}
+	msg := fmt.Sprintf("plan %s is awaiting approval. Approve the AtlasPlan %s, or set spec.approvedHash or status.approval.approvedPlan to approve it", h, review.Name)
+	if required > 1 {
+		msg = fmt.Sprintf("plan %s has %d of %d required approvals. Add an entry to status.approval.approvers to approve it", h, n, required)
+	}
+	msg += expiredNote(expired)
+	meta.SetStatusCondition(&sc.Status.Conditions, metav1.Condition{
+		Type:    dbv1alpha1.PendingApprovalCond,
+		Status:  metav1.ConditionTrue,
+		Reason:  dbv1alpha1.ReasonAwaitingApproval,
+		Message: msg,
+	})
+	t := spec.Timeout
+	if t == nil {
+		setNotReady(sc, dbv1alpha1.ReasonApprovalPending, msg)
+		return ctrl.Result{}, false, nil
+	}
+	if remaining := time.Until(a.PlannedAt.Add(t.Duration)); remaining > 0 {
+		setNotReady(sc, dbv1alpha1.ReasonApprovalPending, msg)
+		return ctrl.Result{RequeueAfter: remaining}, false, nil
+	}
+	meta.RemoveStatusCondition(&sc.Status.Conditions, dbv1alpha1.PendingApprovalCond)
+	meta.RemoveStatusCondition(&sc.Status.Conditions, dbv1alpha1.PlanOutdatedCond)
+	a.Expired = true
+	expiredApprovals.Inc()
+	msg = fmt.Sprintf("plan %s was not approved within %s and was rejected. It is planned again on the next change", h, t.Duration)
+	setNotReady(sc, dbv1alpha1.ReasonApprovalExpired, msg)
+	r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonApprovalExpired, msg)
+	return ctrl.Result{}, false, nil
+}
+
+// approvalChanged triggers a reconcile when a plan is approved, as status
+// updates do not change the generation of the resource.
+var approvalChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		old, ok1 := e.ObjectOld.(*dbv1alpha1.AtlasSchema)
+		cur, ok2 := e.ObjectNew.(*dbv1alpha1.AtlasSchema)
+		return ok1 && ok2 && (approvedPlan(cur) != "" && approvedPlan(cur) != approvedPlan(old) || len(addedApprovers(old, cur)) > 0)
+	},
+}
+
+// approvals returns the number of distinct users who approved the plan with
+// the given hash. Setting approvedPlan counts as a single approval.
+func approvals(a *dbv1alpha1.ApprovalStatus, h string) int {
+	n := len(approverNames(a, h))
+	if n == 0 && a.ApprovedPlan == h {
+		return 1
+	}
+	return n
+}
+
+// approverNames returns the sorted names of the distinct users who approved
+// the plan with the given hash.
+func approverNames(a *dbv1alpha1.ApprovalStatus, h string) []string {
+	var names []string
+	for _, u := range a.Approvers {
+		if u.PlanHash == h && !slices.Contains(names, u.Name) {
+			names = append(names, u.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// planHash returns the hash identifying the plan of the desired schema.
+func planHash(m *managed, plan []string) string {
+	h := sha256.New()
+	h.Write([]byte(m.hash()))
+	for _, s := range plan {
+		h.Write([]byte(s))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
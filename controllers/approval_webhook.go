@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/exp/slices"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const (
+	// ApprovalWebhookPath is the path the approval webhook is served on.
+	ApprovalWebhookPath = "/validate-db-atlasgo-io-v1alpha1-atlasschema-approval"
+	// approveVerb is the RBAC verb required to approve the plan of an AtlasSchema.
+	approveVerb = "approve"
+)
+
+// ApprovalValidator validates updates to the status of AtlasSchema resources.
+// Approving a plan, by setting status.approval.approvedPlan or by adding an
+// entry to status.approval.approvers, is allowed only to users granted the
+// "approve" verb on the resource. Users may only add approvals of their own. The check is done with
+// a SubjectAccessReview, so approvals are authorized by RBAC and recorded by
+// the audit log of the API server.
+type ApprovalValidator struct {
+	client client.Client
+}
+
+// NewApprovalValidator returns a new ApprovalValidator.
+func NewApprovalValidator(c client.Client) *ApprovalValidator {
+	return &ApprovalValidator{client: c}
+}
+
+//+kubebuilder:webhook:path=/validate-db-atlasgo-io-v1alpha1-atlasschema-approval,mutating=false,failurePolicy=fail,sideEffects=None,groups=db.atlasgo.io,resources=atlasschemas/status,verbs=update,versions=v1alpha1,name=approval.atlasgo.io,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// Handle implements admission.Handler.
+func (v *ApprovalValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+	var old, cur dbv1alpha1.AtlasSchema
+	if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if err := json.Unmarshal(req.Object.Raw, &cur); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	// Only adding a new approval requires authorization. Clearing approvals
+	// is done by the operator once the plan is applied or replaced.
+	added := addedApprovers(&old, &cur)
+	approved := approvedPlan(&cur)
+	if (approved == "" || approved == approvedPlan(&old)) && len(added) == 0 {
+		return admission.Allowed("")
+	}
+	for _, a := range added {
+		if a.Name != req.UserInfo.Username {
+			return admission.Denied(fmt.Sprintf(
+				"user %q cannot record an approval on behalf of %q", req.UserInfo.Username, a.Name,
+			))
+		}
+	}
+	if resp := authorize(ctx, v.client, req, &cur, approveVerb); !resp.Allowed {
+		return resp
+	}
+	return admission.Allowed(fmt.Sprintf("approved by %s", req.UserInfo.Username))
+}
+
+// authorize checks with a SubjectAccessReview that the user of the request
+// is granted the given verb on the schema.
+func authorize(ctx context.Context, c client.Client, req admission.Request, sc *dbv1alpha1.AtlasSchema, verb string) admission.Response {
+	extra := make(map[string]authorizationv1.ExtraValue, len(req.UserInfo.Extra))
+	for k, e := range req.UserInfo.Extra {
+		extra[k] = authorizationv1.ExtraValue(e)
+	}
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   req.UserInfo.Username,
+			UID:    req.UserInfo.UID,
+			Groups: req.UserInfo.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: sc.Namespace,
+				Name:      sc.Name,
+				Verb:      verb,
+				Group:     dbv1alpha1.GroupVersion.Group,
+				Resource:  "atlasschemas",
+			},
+		},
+	}
+	if err := c.Create(ctx, sar); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if !sar.Status.Allowed {
+		return admission.Denied(fmt.Sprintf(
+			"user %q is not allowed to %s atlasschemas %s/%s", req.UserInfo.Username, verb, sc.Namespace, sc.Name,
+		))
+	}
+	return admission.Allowed("")
+}
+
+// addedApprovers returns the approvers of cur that are not recorded in old.
+func addedApprovers(old, cur *dbv1alpha1.AtlasSchema) []dbv1alpha1.Approver {
+	if cur.Status.Approval == nil {
+		return nil
+	}
+	var added []dbv1alpha1.Approver
+	for _, a := range cur.Status.Approval.Approvers {
+		if old.Status.Approval == nil || !slices.Contains(old.Status.Approval.Approvers, a) {
+			added = append(added, a)
+		}
+	}
+	return added
+}
+
+// approvedPlan returns the plan approved in the status of the schema, if any.
+func approvedPlan(sc *dbv1alpha1.AtlasSchema) string {
+	if sc.Status.Approval == nil {
+		return ""
+	}
+	return sc.Status.Approval.ApprovedPlan
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+type mockReviewer struct {
+	client.Client
+	allowed map[string]bool
+	reviews []authorizationv1.SubjectAccessReviewSpec
+}
+
+func (m *mockReviewer) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	sar := obj.(*authorizationv1.SubjectAccessReview)
+	m.reviews = append(m.reviews, sar.Spec)
+	sar.Status.Allowed = m.allowed[sar.Spec.User]
+	return nil
+}
+
+func TestApprovalValidator(t *testing.T) {
+	rv := &mockReviewer{allowed: map[string]bool{"alice": true}}
+	v := NewApprovalValidator(rv)
+	withApproval := func(approved string, approvers ...dbv1alpha1.Approver) runtime.RawExtension {
+		sc := conditionReconciling()
+		sc.Status.Approval = &dbv1alpha1.ApprovalStatus{PlanHash: "abc", ApprovedPlan: approved, Approvers: approvers}
+		b, err := json.Marshal(sc)
+		require.NoError(t, err)
+		return runtime.RawExtension{Raw: b}
+	}
+	request := func(user, old, cur string) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			UserInfo:  authenticationv1.UserInfo{Username: user},
+			OldObject: withApproval(old),
+			Object:    withApproval(cur),
+		}}
+	}
+
+	// Status updates that do not approve a plan are not reviewed.
+	resp := v.Handle(context.Background(), request("operator", "", ""))
+	require.True(t, resp.Allowed)
+	resp = v.Handle(context.Background(), request("operator", "abc", ""))
+	require.True(t, resp.Allowed)
+	require.Empty(t, rv.reviews)
+
+	// Approvals require the approve verb.
+	resp = v.Handle(context.Background(), request("bob", "", "abc"))
+	require.False(t, resp.Allowed)
+	require.EqualValues(t, `user "bob" is not allowed to approve atlasschemas test/my-atlas-schema`, string(resp.Result.Reason))
+	resp = v.Handle(context.Background(), request("alice", "", "abc"))
+	require.True(t, resp.Allowed)
+	require.Len(t, rv.reviews, 2)
+	require.EqualValues(t, &authorizationv1.ResourceAttributes{
+		Namespace: "test",
+		Name:      "my-atlas-schema",
+		Verb:      "approve",
+		Group:     "db.atlasgo.io",
+		Resource:  "atlasschemas",
+	}, rv.reviews[1].ResourceAttributes)
+
+	// Approvers record their own approvals only.
+	alice := dbv1alpha1.Approver{Name: "alice", PlanHash: "abc"}
+	resp = v.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		UserInfo:  authenticationv1.UserInfo{Username: "bob"},
+		OldObject: withApproval(""),
+		Object:    withApproval("", alice),
+	}})
+	require.False(t, resp.Allowed)
+	require.EqualValues(t, `user "bob" cannot record an approval on behalf of "alice"`, string(resp.Result.Reason))
+	resp = v.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+		OldObject: withApproval(""),
+		Object:    withApproval("", alice),
+	}})
+	require.True(t, resp.Allowed)
+	require.Len(t, rv.reviews, 3)
+	// Existing approvals are not reviewed again.
+	resp = v.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		UserInfo:  authenticationv1.UserInfo{Username: "operator"},
+		OldObject: withApproval("", alice),
+		Object:    withApproval("", alice),
+	}})
+	require.True(t, resp.Allowed)
+	require.Len(t, rv.reviews, 3)
+}
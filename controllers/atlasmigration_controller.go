@@ -23,19 +23,27 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"ariga.io/atlas/sql/migrate"
+	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -44,34 +52,82 @@ import (
 	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
 	"github.com/ariga/atlas-operator/controllers/watch"
 	"github.com/ariga/atlas-operator/internal/atlas"
+	"github.com/ariga/atlas-operator/internal/cloudapi"
+	"github.com/ariga/atlas-operator/internal/git"
+	"github.com/ariga/atlas-operator/internal/probe"
 )
 
 // CLI is the interface used to interact with Atlas CLI
 type MigrateCLI interface {
 	Apply(ctx context.Context, data *atlas.ApplyParams) (*atlas.ApplyReport, error)
+	Down(ctx context.Context, data *atlas.DownParams) (*atlas.DownReport, error)
 	Status(ctx context.Context, data *atlas.StatusParams) (*atlas.StatusReport, error)
+	Lint(ctx context.Context, data *atlas.LintParams) (*atlas.SummaryReport, error)
+	Version(ctx context.Context) (string, error)
+}
+
+// Prober is the interface used to run SQL probes against the target database
+type Prober interface {
+	Value(ctx context.Context, url, query string) (float64, error)
 }
 
 // AtlasMigrationReconciler reconciles a AtlasMigration object
 type AtlasMigrationReconciler struct {
 	client.Client
 	CLI              MigrateCLI
+	Prober           Prober
+	sqlExec          SQLExecutor
+	TokenChecker     TokenChecker
+	httpClient       *http.Client
+	jobLogs          JobLogReader
+	git              GitClient
 	Scheme           *runtime.Scheme
 	secretWatcher    *watch.ResourceWatcher
 	configMapWatcher *watch.ResourceWatcher
 	recorder         record.EventRecorder
+	applyLimiter     *HostLimiter
+	maxConcurrent    int
+	identity         string
+	holdNamespace    string
+	netGuard         *NetworkGuard
+	version          string
+	cliVersion       *cliVersion
+	// allowProject allows the resources to use their own project files.
+	allowProject bool
+	// allowedImages are the images the resources may run, e.g. as dev databases.
+	allowedImages []string
+	// allowAzureAD allows the resources to authenticate with Azure AD tokens.
+	allowAzureAD bool
 }
 
-func NewAtlasMigrationReconciler(mgr manager.Manager, cli MigrateCLI) *AtlasMigrationReconciler {
-	secretWatcher := watch.New()
-	configMapWatcher := watch.New()
+func NewAtlasMigrationReconciler(mgr manager.Manager, cli MigrateCLI, opts Options) *AtlasMigrationReconciler {
+	dedup := watch.WithDedupWindow(opts.WatchDedupWindow)
+	secretWatcher := watch.New(dedup)
+	configMapWatcher := watch.New(dedup)
+	httpClient := &http.Client{Timeout: 30 * time.Second}
 	return &AtlasMigrationReconciler{
 		CLI:              cli,
+		Prober:           probe.New(),
+		sqlExec:          probe.New(),
+		TokenChecker:     cloudapi.New(httpClient),
+		httpClient:       httpClient,
+		jobLogs:          &jobLogs{cs: kubernetes.NewForConfigOrDie(mgr.GetConfig())},
+		git:              git.NewClient("git"),
 		Client:           mgr.GetClient(),
 		Scheme:           mgr.GetScheme(),
 		configMapWatcher: &configMapWatcher,
 		secretWatcher:    &secretWatcher,
-		recorder:         mgr.GetEventRecorderFor("atlasmigration-controller"),
+		recorder:         newEventRecorder(mgr.GetEventRecorderFor("atlasmigration-controller")),
+		applyLimiter:     opts.ApplyLimiter,
+		maxConcurrent:    opts.MaxConcurrentReconciles,
+		identity:         opts.Identity,
+		holdNamespace:    opts.HoldNamespace,
+		netGuard:         opts.NetworkGuard,
+		version:          opts.Version,
+		cliVersion:       &cliVersion{},
+		allowProject:     opts.AllowProjectFiles,
+		allowedImages:    opts.AllowedImages,
+		allowAzureAD:     opts.AllowAzureAD,
 	}
 }
 
@@ -84,6 +140,50 @@ type (
 		Migration       *migration
 		Cloud           *cloud
 		RevisionsSchema string
+		Baseline        string
+		Exclude         []string
+		version         string
+		allowDirty      bool
+		execOrder       string
+		txMode          string
+		applyLimit      uint64
+		batchInterval   time.Duration
+		dryRun          bool
+		devURL          string
+		// devDB runs a dev database when devURL is not set.
+		devDB           *ephemeralDevDB
+		allowDown       bool
+		confirmDown     string
+		denyDestructive bool
+		probe           dbv1alpha1.Probe
+		replicationLag  *dbv1alpha1.ReplicationLag
+		project         string
+		vars            atlas.Vars
+		lint            *dbv1alpha1.MigrationLint
+		// owner of the objects created for the migration, e.g. lint reports.
+		owner *dbv1alpha1.AtlasMigration
+		// windows are the apply windows the pending files are applied in.
+		windows []*applyWindow
+		// approveDestructive is the version the destructive statements are approved up to.
+		approveDestructive string
+		// approval requires the pending files to be approved, and approvedPlan
+		// holds the hash of the approved plan.
+		approval     bool
+		approvedPlan string
+		// approvalsExpired is the time the approvals recorded on the resource
+		// expired, if they did. Expired approvals are discarded.
+		approvalsExpired time.Time
+		// observedTarget and observedVersion identify the database and the
+		// version of the last apply, and confirmTarget holds the target the
+		// confirm-target annotation confirms applying to.
+		observedTarget  string
+		observedVersion string
+		confirmTarget   string
+		// tlsHash is the hash of the client certificate of tlsFrom, if set.
+		tlsHash string
+		// azureAD reports the password of the URL is an Azure AD token, which
+		// is refreshed before it expires and is therefore not hashed.
+		azureAD bool
 	}
 
 	migration struct {
@@ -109,18 +209,38 @@ type (
 //+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-func (r *AtlasMigrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *AtlasMigrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, err error) {
 	log := log.FromContext(ctx)
 	var am dbv1alpha1.AtlasMigration
 	if err := r.Get(ctx, req.NamespacedName, &am); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	// Leave the resource as is while the networking of the cluster is broken.
+	if d, ok := r.netGuard.degraded(); ok {
+		return ctrl.Result{RequeueAfter: d}, nil
+	}
+	atlasVersion := r.cliVersion.get(ctx, r.CLI)
+	// Record the CLI commands run by this reconcile, to reproduce it locally.
+	ctx, cmds := atlas.WithCommandLog(ctx)
+	// Bring statuses written by older operator versions to the current structure,
+	// before the conditions are compared with the ones set by this reconcile.
+	if upgradeMigrationStatus(&am.Status) {
+		log.Info("upgraded the status written by an older operator version")
+	}
+	conds := append([]metav1.Condition(nil), am.Status.Conditions...)
 
 	// At the end of reconcile, update the status of the resource base on the error
 	defer func() {
+		stampVersions(&am.Status.OperatorVersion, &am.Status.AtlasVersion, r.version, atlasVersion)
+		am.Status.Transitions = dbv1alpha1.AppendTransition(am.Status.Transitions, transitions(conds, am.Status.Conditions)...)
+		if c := cmds.Commands(); len(c) > 0 {
+			am.Status.Runs = dbv1alpha1.AppendRun(am.Status.Runs, dbv1alpha1.CommandRun{Time: metav1.Now(), Commands: c})
+		}
 		clientErr := r.Status().Update(ctx, &am)
 		if clientErr != nil {
 			log.Error(clientErr, "failed to update resource status")
@@ -132,21 +252,55 @@ func (r *AtlasMigrationReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 
 	// When the resource is first created, create the "Ready" condition.
 	if len(am.Status.Conditions) == 0 {
-		am.SetNotReady("Reconciling", "Reconciling")
+		// Adopt the history exported from the previous resource managing the database.
+		imported, err := importMigrationStatus(&am)
+		if err != nil {
+			am.SetNotReady(dbv1alpha1.ReasonImportingStatus, err.Error())
+			return ctrl.Result{}, nil
+		}
+		if imported {
+			r.recorder.Event(&am, corev1.EventTypeNormal, dbv1alpha1.ReasonStatusImported, "Imported status from the "+importStatusAnnotation+" annotation")
+		}
+		am.SetNotReady(dbv1alpha1.ReasonReconciling, "Reconciling")
 		return ctrl.Result{Requeue: true}, nil
 	}
+	// Leave suspended resources as is until they are resumed.
+	if suspended(&am) {
+		am.SetNotReady(dbv1alpha1.ReasonSuspended, "Reconciles are suspended by the "+suspendAnnotation+" annotation")
+		return ctrl.Result{}, nil
+	}
+	// Report the next apply windows, and refuse invalid ones.
+	windows, err := parseWindows(am.Spec.ApplyWindows)
+	if err != nil {
+		am.Status.ApplyWindows = nil
+		am.SetNotReady(dbv1alpha1.ReasonInvalidApplyWindow, err.Error())
+		r.recorder.Event(&am, corev1.EventTypeWarning, dbv1alpha1.ReasonInvalidApplyWindow, err.Error())
+		return ctrl.Result{}, nil
+	}
+	am.Status.ApplyWindows = plannedApplyWindows(windows, time.Now())
 
 	// Extract migration data from the given resource
 	md, cleanUp, err := r.extractMigrationData(ctx, am)
+	var csErr *checksumErr
+	if errors.As(err, &csErr) {
+		am.SetNotReady(dbv1alpha1.ReasonChecksumMismatch, err.Error())
+		r.recorder.Event(&am, corev1.EventTypeWarning, dbv1alpha1.ReasonChecksumMismatch, err.Error())
+		return ctrl.Result{}, nil
+	}
 	if err != nil {
-		am.SetNotReady("ReadingMigrationData", err.Error())
+		am.SetNotReady(dbv1alpha1.ReasonReadingMigrationData, err.Error())
 		r.recordErrEvent(am, err)
 		return result(err)
 	}
 	defer cleanUp()
+	if md.devDB != nil {
+		// Delete the ephemeral dev database once the reconcile completes.
+		defer func() { res = md.devDB.release(ctx, res) }()
+	}
+	md.windows = windows
 	hash, err := md.hash()
 	if err != nil {
-		am.SetNotReady("CalculatingHash", err.Error())
+		am.SetNotReady(dbv1alpha1.ReasonCalculatingHash, err.Error())
 		return ctrl.Result{}, nil
 	}
 
@@ -154,26 +308,261 @@ func (r *AtlasMigrationReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	// This is done so that the observed status of the migration reflects its "in-progress" state while it is being
 	// reconciled.
 	if am.IsReady() && am.IsHashModified(hash) {
-		am.SetNotReady("Reconciling", "Current migration data has changed")
+		am.SetNotReady(dbv1alpha1.ReasonReconciling, "Current migration data has changed")
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	// Refuse to fetch the remote directory with a token that cannot be used.
+	var tErr *cloudTokenErr
+	if err := r.checkCloudToken(ctx, &am, md); errors.As(err, &tErr) {
+		am.SetNotReady(tErr.reason, err.Error())
+		r.recorder.Event(&am, corev1.EventTypeWarning, tErr.reason, err.Error())
+		return ctrl.Result{RequeueAfter: cloudTokenInterval}, nil
+	}
+
+	// Recover an apply interrupted by a restart or a failover of the operator.
+	if am.Status.Applying != nil {
+		if err := r.recoverApply(ctx, &am, md); err != nil {
+			am.SetNotReady(dbv1alpha1.ReasonApplyInterrupted, err.Error())
+			r.recordErrEvent(am, err)
+			return result(err)
+		}
+	}
+	// Refuse to silently orphan the applied history when the revisions table moves.
+	var rvErr *revisionsMovedErr
+	switch err := r.checkRevisions(ctx, &am, md); {
+	case errors.As(err, &rvErr):
+		am.SetNotReady(rvErr.reason, err.Error())
+		if rvErr.reason == dbv1alpha1.ReasonRevisionsSchemaChanged {
+			r.recorder.Event(&am, corev1.EventTypeWarning, rvErr.reason, err.Error())
+		}
+		return ctrl.Result{}, nil
+	case err != nil:
+		am.SetNotReady(dbv1alpha1.ReasonMovingRevisions, err.Error())
+		r.recordErrEvent(am, err)
+		return result(err)
+	}
+	if !md.dryRun {
+		if err := r.markApplying(ctx, &am); err != nil {
+			am.SetNotReady(dbv1alpha1.ReasonMarkingApply, err.Error())
+			return result(err)
+		}
+	}
+
 	// Reconcile given resource
 	status, err := r.reconcile(ctx, md)
+	am.Status.Applying = nil
+	err = refreshAzureToken(am.Spec.Credentials, err)
+	var dbErr *devDBPendingErr
+	if errors.As(err, &dbErr) {
+		am.SetNotReady(dbv1alpha1.ReasonCreatingDevDB, err.Error())
+		return ctrl.Result{RequeueAfter: 15 * time.Second}, nil
+	}
+	if d, ok := r.netGuard.observe(ctx, err); ok {
+		return ctrl.Result{RequeueAfter: d}, nil
+	}
+	if res, ok := r.migrationMaintenance(&am, err); ok {
+		return res, nil
+	}
+	var pErr *probeErr
+	if errors.As(err, &pErr) {
+		am.SetNotReady(dbv1alpha1.ReasonProbeDeferred, err.Error())
+		r.recorder.Event(&am, corev1.EventTypeNormal, dbv1alpha1.ReasonProbeDeferred, err.Error())
+		return ctrl.Result{RequeueAfter: pErr.retryAfter}, nil
+	}
+	var wErr *windowErr
+	if errors.As(err, &wErr) {
+		am.SetNotReady(dbv1alpha1.ReasonOutsideApplyWindow, err.Error())
+		r.recorder.Event(&am, corev1.EventTypeNormal, dbv1alpha1.ReasonOutsideApplyWindow, err.Error())
+		return ctrl.Result{RequeueAfter: wErr.retry(time.Now())}, nil
+	}
+	var hold *holdErr
+	if errors.As(err, &hold) {
+		am.SetNotReady(dbv1alpha1.ReasonHeld, err.Error())
+		r.recorder.Event(&am, corev1.EventTypeNormal, dbv1alpha1.ReasonHeld, err.Error())
+		return ctrl.Result{RequeueAfter: hold.retry(time.Now())}, nil
+	}
+	var bErr *budgetErr
+	if errors.As(err, &bErr) {
+		am.SetNotReady(dbv1alpha1.ReasonApplyBudgetExhausted, err.Error())
+		return ctrl.Result{RequeueAfter: budgetRetry}, nil
+	}
+	var batch *batchErr
+	if errors.As(err, &batch) {
+		am.Status.LastApplied = batch.status.LastApplied
+		am.Status.LastAppliedVersion = batch.status.LastAppliedVersion
+		am.Status.History = dbv1alpha1.AppendHistory(am.Status.History, batch.status.History...)
+		if batch.status.Lint != nil {
+			am.Status.Lint = batch.status.Lint
+		}
+		if batch.status.AppliedFiles != nil {
+			am.Status.AppliedFiles = batch.status.AppliedFiles
+		}
+		am.Status.Checkpoint = batch.status.Checkpoint
+		am.Status.Approval = batch.status.Approval
+		am.Status.RevisionsSchema = md.revisionsSchema()
+		am.Status.ObservedTarget = md.target()
+		am.SetNotReady(dbv1alpha1.ReasonBatchApplied, err.Error())
+		fileEvents(r.recorder, &am, batch.status.History)
+		notifyApply(ctx, r, r.httpClient, r.recorder, &am, am.Spec.Webhook, appliedResult("AtlasMigration", &am, batch.status.LastAppliedVersion, batch.status.History))
+		lintEvents(r.recorder, &am, batch.status.Lint)
+		r.recorder.Event(&am, corev1.EventTypeNormal, dbv1alpha1.ReasonBatchApplied, err.Error())
+		if batch.pause > 0 {
+			return ctrl.Result{RequeueAfter: batch.pause}, nil
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
+	var lErr *migrateLintErr
+	if errors.As(err, &lErr) {
+		am.Status.Lint = lErr.report
+		am.SetNotReady(dbv1alpha1.ReasonLintFailed, err.Error())
+		lintEvents(r.recorder, &am, lErr.report)
+		r.recorder.Event(&am, corev1.EventTypeWarning, dbv1alpha1.ReasonLintFailed, err.Error())
+		return ctrl.Result{}, nil
+	}
+	var dsErr *destructiveMigrationErr
+	if errors.As(err, &dsErr) {
+		awaitApproval(r.recorder, &am, &am.Status.Conditions, dbv1alpha1.ReasonAwaitingDestructiveApproval, "Version "+dsErr.version, err.Error())
+		am.SetNotReady(dbv1alpha1.ReasonDestructiveNotApproved, err.Error())
+		r.recorder.Event(&am, corev1.EventTypeWarning, dbv1alpha1.ReasonDestructiveNotApproved, err.Error())
+		return ctrl.Result{}, nil
+	}
+	var hErr *historyErr
+	if errors.As(err, &hErr) {
+		meta.SetStatusCondition(&am.Status.Conditions, metav1.Condition{
+			Type:    dbv1alpha1.HistoryDivergedCond,
+			Status:  metav1.ConditionTrue,
+			Reason:  hErr.reason(),
+			Message: err.Error(),
+		})
+		am.SetNotReady(dbv1alpha1.ReasonHistoryDiverged, err.Error())
+		r.recorder.Event(&am, corev1.EventTypeWarning, dbv1alpha1.ReasonHistoryDiverged, err.Error())
+		return ctrl.Result{}, nil
+	}
+	var cliErr *incompatibleCLIErr
+	if errors.As(err, &cliErr) {
+		meta.SetStatusCondition(&am.Status.Conditions, metav1.Condition{
+			Type:    dbv1alpha1.IncompatibleCLICond,
+			Status:  metav1.ConditionTrue,
+			Reason:  dbv1alpha1.ReasonVersionSkew,
+			Message: err.Error(),
+		})
+		am.SetNotReady(dbv1alpha1.ReasonIncompatibleCLI, err.Error())
+		r.recorder.Event(&am, corev1.EventTypeWarning, dbv1alpha1.ReasonIncompatibleCLI, err.Error())
+		return ctrl.Result{}, nil
+	}
+	var aErr *migrationApprovalErr
+	if errors.As(err, &aErr) {
+		am.Status.Approval = aErr.status
+		meta.SetStatusCondition(&am.Status.Conditions, metav1.Condition{
+			Type:    dbv1alpha1.PendingApprovalCond,
+			Status:  metav1.ConditionTrue,
+			Reason:  dbv1alpha1.ReasonAwaitingApproval,
+			Message: err.Error(),
+		})
+		am.SetNotReady(dbv1alpha1.ReasonApprovalPending, err.Error())
+		if aErr.planned {
+			r.recorder.Eventf(&am, corev1.EventTypeNormal, dbv1alpha1.ReasonApprovalPending, "Plan %s is awaiting approval", aErr.status.PlanHash)
+		}
+		return ctrl.Result{}, nil
+	}
+	var tgErr *targetChangedErr
+	if errors.As(err, &tgErr) {
+		awaitApproval(r.recorder, &am, &am.Status.Conditions, dbv1alpha1.ReasonAwaitingTargetConfirmation, "Applying to target "+tgErr.target, err.Error())
+		am.SetNotReady(dbv1alpha1.ReasonTargetChanged, err.Error())
+		r.recorder.Event(&am, corev1.EventTypeWarning, dbv1alpha1.ReasonTargetChanged, err.Error())
+		return ctrl.Result{}, nil
+	}
+	var rErr *planRejectedErr
+	if errors.As(err, &rErr) {
+		meta.RemoveStatusCondition(&am.Status.Conditions, dbv1alpha1.PendingApprovalCond)
+		am.SetNotReady(dbv1alpha1.ReasonPlanRejected, err.Error())
+		r.recorder.Event(&am, corev1.EventTypeWarning, dbv1alpha1.ReasonPlanRejected, err.Error())
+		return ctrl.Result{}, nil
+	}
+	var dErr *downErr
+	if errors.As(err, &dErr) {
+		if dErr.reason == dbv1alpha1.ReasonDownNotConfirmed {
+			awaitApproval(r.recorder, &am, &am.Status.Conditions, dbv1alpha1.ReasonAwaitingDownConfirmation, "Reverting to version "+md.version, err.Error())
+		}
+		am.SetNotReady(dErr.reason, err.Error())
+		r.recorder.Event(&am, corev1.EventTypeWarning, dErr.reason, err.Error())
+		return ctrl.Result{}, nil
+	}
 	if err != nil {
-		am.SetNotReady("Migrating", strings.TrimSpace(err.Error()))
+		am.SetNotReady(dbv1alpha1.ReasonMigrating, strings.TrimSpace(err.Error()))
 		r.recordErrEvent(am, err)
+		if isSQLErr(err) {
+			notifyApply(ctx, r, r.httpClient, r.recorder, &am, am.Spec.Webhook, failedResult("AtlasMigration", &am, err))
+		}
 		return result(err)
 	}
-	r.recorder.Eventf(&am, corev1.EventTypeNormal, "Applied", "Version %s applied", status.LastAppliedVersion)
+	if d := status.DryRun; d != nil {
+		msg := fmt.Sprintf("Dry-run: %d migration files would be applied", len(d.Files))
+		am.Status.DryRun = d
+		if status.Lint != nil {
+			am.Status.Lint = status.Lint
+		}
+		am.SetNotReady(dbv1alpha1.ReasonDryRun, msg)
+		r.recorder.Event(&am, corev1.EventTypeNormal, dbv1alpha1.ReasonDryRun, msg)
+		return ctrl.Result{}, nil
+	}
+	fileEvents(r.recorder, &am, status.History)
+	lintEvents(r.recorder, &am, status.Lint)
+	r.recorder.Eventf(&am, corev1.EventTypeNormal, dbv1alpha1.ReasonApplied, "Version %s applied", status.LastAppliedVersion)
+	if len(status.History) > 0 {
+		notifyApply(ctx, r, r.httpClient, r.recorder, &am, am.Spec.Webhook, appliedResult("AtlasMigration", &am, status.LastAppliedVersion, status.History))
+	}
+	// The status returned by reconcile holds the files applied by this run only,
+	// and none of the previous runs.
+	status.History = dbv1alpha1.AppendHistory(am.Status.History, status.History...)
+	status.Runs, status.Transitions = am.Status.Runs, am.Status.Transitions
+	status.Conditions, status.CloudToken = am.Status.Conditions, am.Status.CloudToken
+	status.ApplyWindows, status.StatusVersion = am.Status.ApplyWindows, am.Status.StatusVersion
+	status.RevisionsSchema = md.revisionsSchema()
+	status.ObservedTarget = md.target()
+	if status.Lint == nil {
+		status.Lint = am.Status.Lint
+	}
+	if status.AppliedFiles == nil {
+		status.AppliedFiles = am.Status.AppliedFiles
+	}
+	// Approvals of destructive statements authorize a single apply.
+	if md.denyDestructive {
+		if err := consumeAnnotation(ctx, r.Client, &am, approveDestructiveAnnotation); err != nil {
+			log.Error(err, "failed to remove the approve-destructive annotation")
+		}
+	}
+	// Confirmations of down migrations authorize a single revert, to the version
+	// the database was brought to.
+	if md.confirmDown != "" && md.confirmDown == status.LastAppliedVersion {
+		if err := consumeAnnotation(ctx, r.Client, &am, confirmDownAnnotation); err != nil {
+			log.Error(err, "failed to remove the confirm-down annotation")
+		}
+	}
+	maintenanceEnded(r.recorder, &am, &status.Conditions)
+	meta.RemoveStatusCondition(&status.Conditions, dbv1alpha1.HistoryDivergedCond)
+	meta.RemoveStatusCondition(&status.Conditions, dbv1alpha1.IncompatibleCLICond)
+	meta.RemoveStatusCondition(&status.Conditions, dbv1alpha1.PendingApprovalCond)
 	am.SetReady(status)
+	// Check the Git ref of the project file periodically for new commits.
+	if p := am.Spec.Project; p != nil && p.Git != nil {
+		if p.Git.Interval != nil {
+			return ctrl.Result{RequeueAfter: p.Git.Interval.Duration}, nil
+		}
+		return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+	}
+	// Check the Atlas Cloud token periodically.
+	if am.Status.CloudToken != nil {
+		return ctrl.Result{RequeueAfter: cloudTokenInterval}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
 func (r *AtlasMigrationReconciler) recordErrEvent(am dbv1alpha1.AtlasMigration, err error) {
-	reason := "Error"
+	reason := dbv1alpha1.ReasonError
 	if isTransient(err) {
-		reason = "TransientErr"
+		reason = dbv1alpha1.ReasonTransientErr
 	}
 	r.recorder.Event(&am, corev1.EventTypeWarning, reason, strings.TrimSpace(err.Error()))
 }
@@ -196,12 +585,40 @@ func (r *AtlasMigrationReconciler) reconcile(
 		return dbv1alpha1.AtlasMigrationStatus{}, err
 	}
 
-	// Check if there are any pending migration files
-	status, err := r.CLI.Status(ctx, &atlas.StatusParams{Env: md.EnvName, ConfigURL: atlasHCL})
+	// Check if there are any pending migration files up to the target version
+	status, err := r.CLI.Status(ctx, md.statusParams(atlasHCL))
 	if err != nil {
 		return dbv1alpha1.AtlasMigrationStatus{}, transient(err)
 	}
-	if len(status.Pending) == 0 {
+	// Refuse to migrate a different database the URL was repointed to
+	if !md.dryRun {
+		if err := checkTarget(md, status.Current); err != nil {
+			return dbv1alpha1.AtlasMigrationStatus{}, err
+		}
+	}
+	// Refuse to proceed if applied files were modified or removed
+	appliedFiles, err := checkHistory(md, status)
+	if err != nil {
+		return dbv1alpha1.AtlasMigrationStatus{}, err
+	}
+	// Narrow the pending files to the ones executed with checkpoint files
+	cp, err := checkpoint(md, status)
+	if err != nil {
+		return dbv1alpha1.AtlasMigrationStatus{}, err
+	}
+	amount, synced := status.Amount(md.version)
+	down := !synced && amount == 0
+	if down {
+		// The target version is not pending. It is either lower than
+		// the current version, or missing from the migration directory.
+		if !isApplied(status, md.version) {
+			return dbv1alpha1.AtlasMigrationStatus{}, fmt.Errorf("version %s not found in the migration directory", md.version)
+		}
+		if err := checkDown(md, status.Current); err != nil {
+			return dbv1alpha1.AtlasMigrationStatus{}, err
+		}
+	}
+	if synced {
 		var lastApplied int64
 		if len(status.Applied) > 0 {
 			lastApplied = status.Applied[len(status.Applied)-1].ExecutedAt.Unix()
@@ -210,26 +627,279 @@ func (r *AtlasMigrationReconciler) reconcile(
 			ObservedHash:       hash,
 			LastApplied:        lastApplied,
 			LastAppliedVersion: status.Current,
+			AppliedFiles:       appliedFiles,
+			Checkpoint:         cp,
 		}, nil
 	}
 
-	// Execute Atlas CLI migrate command
-	report, err := r.CLI.Apply(ctx, &atlas.ApplyParams{Env: md.EnvName, ConfigURL: atlasHCL})
+	// Refuse to proceed if the CLI does not support the directory
+	if err := r.checkCLI(ctx, md, cp); err != nil {
+		return dbv1alpha1.AtlasMigrationStatus{}, err
+	}
+
+	// Refuse to apply pending files that fail the lint
+	var linted *dbv1alpha1.LintStatus
+	if md.lint != nil && !down {
+		if linted, err = r.lint(ctx, md, len(status.Pending)); err != nil {
+			return dbv1alpha1.AtlasMigrationStatus{}, err
+		}
+	}
+
+	if md.dryRun {
+		if down {
+			return dbv1alpha1.AtlasMigrationStatus{}, errors.New("dry-run is not supported when migrating down")
+		}
+		planned, err := r.dryRun(ctx, md, atlasHCL, amount)
+		planned.Lint = linted
+		return planned, err
+	}
+
+	// Refuse to apply destructive statements that were not approved
+	if md.denyDestructive && !down {
+		if err := checkDestructive(md, status.Pending[:amount]); err != nil {
+			return dbv1alpha1.AtlasMigrationStatus{}, err
+		}
+	}
+
+	// Refuse to apply pending files that were not approved
+	var approval *dbv1alpha1.MigrationApprovalStatus
+	if md.approval && !down {
+		approval, err = checkApproval(md, status.Pending[:amount])
+		var aErr *migrationApprovalErr
+		if errors.As(err, &aErr) {
+			approval, err = r.reviewPlan(ctx, md, aErr, linted)
+		}
+		if err != nil {
+			return dbv1alpha1.AtlasMigrationStatus{}, err
+		}
+	}
+
+	// Apply the pending files in the apply windows only
+	if err := checkWindows(md.windows, time.Now()); err != nil {
+		return dbv1alpha1.AtlasMigrationStatus{}, err
+	}
+
+	// Defer the apply while the target database is under load
+	if err := r.probe(ctx, md); err != nil {
+		return dbv1alpha1.AtlasMigrationStatus{}, err
+	}
+
+	// Wait for an apply slot on the database server
+	u, err := url.Parse(md.URL)
 	if err != nil {
-		return dbv1alpha1.AtlasMigrationStatus{}, transient(err)
+		return dbv1alpha1.AtlasMigrationStatus{}, err
 	}
-	if report != nil && report.Error != "" {
-		err = errors.New(report.Error)
-		if !isSQLErr(err) {
-			err = transient(err)
+	if md.owner != nil {
+		if err := checkHold(ctx, r, r.holdNamespace, md.owner); err != nil {
+			return dbv1alpha1.AtlasMigrationStatus{}, err
 		}
+	}
+	release, err := r.applyLimiter.acquire(u.Host)
+	if err != nil {
 		return dbv1alpha1.AtlasMigrationStatus{}, err
 	}
-	return dbv1alpha1.AtlasMigrationStatus{
+	defer release()
+	if down {
+		return r.down(ctx, md, atlasHCL, hash)
+	}
+
+	// Apply at most applyLimit files. The remaining files are applied by the next reconciles.
+	var remaining uint64
+	if l := md.applyLimit; l > 0 && amount > l {
+		amount, remaining = l, amount-l
+	}
+
+	// Execute Atlas CLI migrate command. When a replication lag policy is set,
+	// files are applied one at a time, and replicas must catch up before each file.
+	var (
+		report   *atlas.ApplyReport
+		params   = md.applyParams(atlasHCL)
+		steps    = 1
+		history  []dbv1alpha1.AppliedChange
+		deferred *probeErr
+	)
+	if md.version != "" || remaining > 0 {
+		params.Amount = amount
+	}
+	if md.replicationLag != nil {
+		params.Amount = 1
+		steps = int(amount)
+	}
+	for i := 0; i < steps; i++ {
+		if err := r.checkReplicationLag(ctx, md); err != nil {
+			// Files applied before the deferral are recorded as a batch.
+			if !errors.As(err, &deferred) || i == 0 {
+				return dbv1alpha1.AtlasMigrationStatus{}, err
+			}
+			remaining += uint64(steps - i)
+			break
+		}
+		report, err = r.CLI.Apply(ctx, params)
+		if err != nil {
+			return dbv1alpha1.AtlasMigrationStatus{}, transient(err)
+		}
+		if report != nil && report.Error != "" {
+			err = errors.New(report.Error)
+			if !isSQLErr(err) {
+				err = transient(err)
+			}
+			return dbv1alpha1.AtlasMigrationStatus{}, err
+		}
+		for _, f := range report.Applied {
+			history = append(history, dbv1alpha1.AppliedChange{
+				Time:       metav1.NewTime(f.End),
+				Version:    f.Version,
+				Statements: f.Applied,
+			})
+		}
+	}
+	versions := make([]string, len(history))
+	for i, h := range history {
+		versions[i] = h.Version
+	}
+	if appliedFiles, err = appliedHashes(md, appliedFiles, versions); err != nil {
+		return dbv1alpha1.AtlasMigrationStatus{}, err
+	}
+	applied := dbv1alpha1.AtlasMigrationStatus{
 		ObservedHash:       hash,
 		LastApplied:        report.End.Unix(),
 		LastAppliedVersion: report.Target,
-	}, nil
+		History:            history,
+		Lint:               linted,
+		AppliedFiles:       appliedFiles,
+		Checkpoint:         cp,
+		Approval:           approval,
+	}
+	if deferred != nil {
+		return dbv1alpha1.AtlasMigrationStatus{}, &batchErr{status: applied, remaining: remaining, pause: deferred.retryAfter, deferred: deferred}
+	}
+	if remaining > 0 {
+		return dbv1alpha1.AtlasMigrationStatus{}, &batchErr{status: applied, remaining: remaining, pause: md.batchInterval}
+	}
+	return applied, nil
+}
+
+// dryRun plans the pending files up to the target version without applying
+// them. The returned status holds the planned files only.
+func (r *AtlasMigrationReconciler) dryRun(ctx context.Context, md atlasMigrationData, atlasHCL string, amount uint64) (dbv1alpha1.AtlasMigrationStatus, error) {
+	params := md.applyParams(atlasHCL)
+	params.DryRun = true
+	if md.version != "" {
+		params.Amount = amount
+	}
+	report, err := r.CLI.Apply(ctx, params)
+	if err != nil {
+		return dbv1alpha1.AtlasMigrationStatus{}, transient(err)
+	}
+	if report.Error != "" {
+		return dbv1alpha1.AtlasMigrationStatus{}, errors.New(report.Error)
+	}
+	d := &dbv1alpha1.DryRunStatus{PlannedAt: metav1.Now()}
+	for _, f := range report.Applied {
+		d.Files = append(d.Files, dbv1alpha1.PlannedFile{Version: f.Version, Statements: f.Applied})
+	}
+	return dbv1alpha1.AtlasMigrationStatus{DryRun: d}, nil
+}
+
+// isApplied reports if the given version was applied to the database.
+func isApplied(status *atlas.StatusReport, version string) bool {
+	for _, r := range status.Applied {
+		if r.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// batchErr is returned when a batch of ApplyLimit files was applied, and
+// more files are pending. The status holds the files applied by the batch.
+type batchErr struct {
+	status    dbv1alpha1.AtlasMigrationStatus
+	remaining uint64
+	pause     time.Duration
+	// deferred is set when the batch was cut short by the replication lag.
+	deferred *probeErr
+}
+
+func (e *batchErr) Error() string {
+	msg := fmt.Sprintf("applied version %s, %d migration files are pending", e.status.LastAppliedVersion, e.remaining)
+	if e.deferred != nil {
+		msg += ": " + e.deferred.Error()
+	}
+	return msg
+}
+
+// probeErr is returned when a probe query exceeds its threshold.
+type probeErr struct {
+	name       string
+	value      float64
+	max        int64
+	retryAfter time.Duration
+}
+
+func (e *probeErr) Error() string {
+	return fmt.Sprintf("probe %q returned %g, exceeding the maximum of %d. Retrying in %s", e.name, e.value, e.max, e.retryAfter)
+}
+
+// probe runs the probe queries against the target database and returns
+// a probeErr if any of them exceeds its threshold.
+func (r *AtlasMigrationReconciler) probe(ctx context.Context, md atlasMigrationData) error {
+	for _, q := range md.probe.Queries {
+		v, err := r.Prober.Value(ctx, md.URL, q.SQL)
+		if err != nil {
+			return transient(fmt.Errorf("probe %q: %w", q.Name, err))
+		}
+		if v > float64(q.Max) {
+			retryAfter := time.Minute
+			if d := md.probe.RetryAfter; d != nil {
+				retryAfter = d.Duration
+			}
+			return &probeErr{name: q.Name, value: v, max: q.Max, retryAfter: retryAfter}
+		}
+	}
+	return nil
+}
+
+// checkReplicationLag returns a probeErr if the replication lag of the target
+// database exceeds the threshold defined by the replication lag policy.
+func (r *AtlasMigrationReconciler) checkReplicationLag(ctx context.Context, md atlasMigrationData) error {
+	lag := md.replicationLag
+	if lag == nil {
+		return nil
+	}
+	query := lag.SQL
+	if query == "" {
+		u, err := url.Parse(md.URL)
+		if err != nil {
+			return err
+		}
+		if query = replicationLagQuery(u.Scheme); query == "" {
+			return fmt.Errorf("replicationLag.sql must be set for driver %q", driver(u.Scheme))
+		}
+	}
+	v, err := r.Prober.Value(ctx, md.URL, query)
+	if err != nil {
+		return transient(fmt.Errorf("replication lag: %w", err))
+	}
+	if v > float64(lag.MaxSeconds) {
+		retryAfter := 10 * time.Second
+		if d := lag.RetryAfter; d != nil {
+			retryAfter = d.Duration
+		}
+		return &probeErr{name: "replication-lag", value: v, max: lag.MaxSeconds, retryAfter: retryAfter}
+	}
+	return nil
+}
+
+// replicationLagQuery returns the default query used to measure the
+// replication lag in seconds for the given URL scheme, if there is one.
+func replicationLagQuery(scheme string) string {
+	switch driver(scheme) {
+	case "postgres":
+		return "SELECT COALESCE(EXTRACT(EPOCH FROM MAX(replay_lag)), 0) FROM pg_stat_replication"
+	default:
+		return ""
+	}
 }
 
 // Extract migration data from the given resource
@@ -240,46 +910,83 @@ func (r *AtlasMigrationReconciler) extractMigrationData(
 	var (
 		tmplData atlasMigrationData
 		err      error
-		creds    = am.Spec.Credentials
 	)
 
 	// Get database connection string
-	switch {
-	case am.Spec.URL != "":
-		tmplData.URL = am.Spec.URL
-	case am.Spec.URLFrom.SecretKeyRef != nil:
-		tmplData.URL, err = getSecretValue(ctx, r, am.Namespace, *am.Spec.URLFrom.SecretKeyRef)
+	if err := checkAzureAD(r.allowAzureAD, am.Spec.Credentials); err != nil {
+		return tmplData, nil, err
+	}
+	if tmplData.URL, err = migrationURL(ctx, r, &am); err != nil {
+		return tmplData, nil, err
+	}
+	tmplData.azureAD = am.Spec.URL == "" && am.Spec.URLFrom.SecretKeyRef == nil && am.Spec.Credentials.AzureAD != nil
+	if len(am.Spec.Session) > 0 || am.Spec.AuthTokenFrom.SecretKeyRef != nil || am.Spec.TLSFrom.SecretRef != nil {
+		u, err := url.Parse(tmplData.URL)
 		if err != nil {
 			return tmplData, nil, err
 		}
-	case creds.Host != "":
-		if err := hydrateCredentials(ctx, &creds, r, am.Namespace); err != nil {
+		if u, err = withSession(u, am.Spec.Session); err != nil {
 			return tmplData, nil, err
 		}
-		tmplData.URL = creds.URL().String()
+		if u, err = withAuthToken(ctx, r, am.Namespace, u, am.Spec.AuthTokenFrom); err != nil {
+			return tmplData, nil, err
+		}
+		if u, tmplData.tlsHash, err = withClientTLS(ctx, r, &am, "AtlasMigration", u, am.Spec.TLSFrom); err != nil {
+			return tmplData, nil, err
+		}
+		tmplData.URL = u.String()
+	}
+
+	// Get migration files from the configmaps or the local directory
+	var files map[string]string
+	switch d := am.Spec.Dir; {
+	case d.ConfigMapRef != nil && len(d.ConfigMapRefs) > 0:
+		return tmplData, nil, errors.New("cannot define both configMapRef and configMapRefs")
+	case (d.ConfigMapRef != nil || len(d.ConfigMapRefs) > 0) && d.Local != nil:
+		return tmplData, nil, errors.New("cannot define both configmap and local directory")
+	case d.Image != nil && (d.ConfigMapRef != nil || len(d.ConfigMapRefs) > 0 || d.Local != nil):
+		return tmplData, nil, errors.New("cannot define both image and configmap or local directory")
+	case d.Path != "" && d.ConfigMapRef == nil && len(d.ConfigMapRefs) == 0 && d.Local == nil:
+		return tmplData, nil, errors.New("dir.path is only supported for configmap and local directories")
+	case d.ConfigMapRef != nil:
+		files, err = r.dirData(ctx, &am, *d.ConfigMapRef)
+	case len(d.ConfigMapRefs) > 0:
+		files, err = r.dirData(ctx, &am, d.ConfigMapRefs...)
+	case d.Local != nil:
+		files = d.Local
+	case d.Image != nil:
+		files, err = r.bundleFiles(ctx, &am, d.Image)
+	}
+	if err != nil {
+		return tmplData, nil, err
 	}
 
 	// Get temporary directory
 	cleanUpDir := func() error { return nil }
-	if c := am.Spec.Dir.ConfigMapRef; c != nil {
-		tmplData.Migration = &migration{}
-		tmplData.Migration.Dir, cleanUpDir, err = r.createTmpDirFromCfgMap(ctx, am.Namespace, c.Name)
-		if err != nil {
-			return tmplData, nil, err
+	if files != nil {
+		if p := am.Spec.Dir.Path; p != "" {
+			if files = subPath(files, p); len(files) == 0 {
+				return tmplData, nil, fmt.Errorf("no migration files found under path %q", p)
+			}
 		}
-	}
-
-	// Get temporary directory in case of local directory
-	if m := am.Spec.Dir.Local; m != nil {
-		if tmplData.Migration != nil {
-			return tmplData, nil, errors.New("cannot define both configmap and local directory")
+		// Hand-edited ConfigMaps are detected before the CLI fails with a less clear error.
+		if am.Spec.Dir.ConfigMapRef != nil || len(am.Spec.Dir.ConfigMapRefs) > 0 {
+			if err := verifyChecksum(files); err != nil {
+				return tmplData, nil, err
+			}
+		}
+		if _, ok := files[migrate.HashFileName]; !ok && am.Spec.Dir.GenerateSum {
+			if files, err = withChecksum(files); err != nil {
+				return tmplData, nil, err
+			}
 		}
-
 		tmplData.Migration = &migration{}
-		tmplData.Migration.Dir, cleanUpDir, err = r.createTmpDirFromMap(ctx, m)
+		tmplData.Migration.Dir, cleanUpDir, err = r.createTmpDirFromMap(ctx, files)
 		if err != nil {
 			return tmplData, nil, err
 		}
+	} else if am.Spec.Dir.GenerateSum {
+		return tmplData, nil, errors.New("dir.generateSum is not supported for remote directories")
 	}
 
 	// Get Atlas Cloud Token from secret
@@ -308,26 +1015,113 @@ func (r *AtlasMigrationReconciler) extractMigrationData(
 		tmplData.EnvName = "kubernetes"
 	}
 
+	tmplData.owner = &am
 	tmplData.RevisionsSchema = am.Spec.RevisionsSchema
+	tmplData.Baseline = am.Spec.Baseline
+	tmplData.Exclude = am.Spec.Exclude
+	tmplData.version = am.Spec.Version
+	tmplData.allowDirty = am.Spec.AllowDirty
+	tmplData.execOrder = am.Spec.ExecOrder
+	tmplData.txMode = am.Spec.TxMode
+	tmplData.applyLimit = uint64(am.Spec.ApplyLimit)
+	tmplData.dryRun = am.Spec.DryRun
+	if am.Spec.BatchInterval != nil {
+		tmplData.batchInterval = am.Spec.BatchInterval.Duration
+	}
+	if tmplData.devURL, err = specDevURL(ctx, r, &am, "AtlasMigration", am.Spec.DevURL, am.Spec.DevURLFrom); err != nil {
+		cleanUpDir()
+		return tmplData, nil, err
+	}
+	// Run an ephemeral dev database for the lint and the reverts.
+	if tmplData.devURL == "" && ((am.Spec.Lint != nil && am.Spec.Lint.DevURL == "") || am.Spec.Policy.AllowDown) {
+		if u, err := url.Parse(tmplData.URL); err == nil && u.Scheme != "" {
+			tmplData.devDB = &ephemeralDevDB{r: r, owner: &am, target: u, cfg: am.Spec.DevDB}
+		}
+	}
+	tmplData.lint = am.Spec.Lint
+	tmplData.allowDown = am.Spec.Policy.AllowDown
+	tmplData.confirmDown = am.Annotations[confirmDownAnnotation]
+	tmplData.denyDestructive = am.Spec.Policy.DenyDestructive
+	tmplData.approveDestructive = am.Annotations[approveDestructiveAnnotation]
+	tmplData.observedTarget = am.Status.ObservedTarget
+	tmplData.observedVersion = am.Status.LastAppliedVersion
+	tmplData.confirmTarget = am.Annotations[confirmTargetAnnotation]
+	if a := am.Spec.Approval; a != nil {
+		tmplData.approval, tmplData.approvedPlan = true, a.ApprovedPlan
+	}
+	if _, err := approvalDeadline(&am); err != nil {
+		cleanUpDir()
+		return tmplData, nil, err
+	}
+	if t := approvalsExpired(&am); !t.IsZero() {
+		tmplData.approvalsExpired = t
+		tmplData.confirmDown, tmplData.approveDestructive, tmplData.approvedPlan = "", "", ""
+		tmplData.confirmTarget = ""
+	}
+	tmplData.probe = am.Spec.Probe
+	tmplData.replicationLag = am.Spec.ReplicationLag
+	if am.Spec.Project != nil {
+		if err := r.project(ctx, &am, &tmplData); err != nil {
+			cleanUpDir()
+			return tmplData, nil, err
+		}
+	}
 	return tmplData, cleanUpDir, nil
 }
 
-// createTmpDirFromCM creates a temporary directory by configmap
-func (r *AtlasMigrationReconciler) createTmpDirFromCfgMap(
-	ctx context.Context,
-	ns, cfgName string,
-) (string, func() error, error) {
+// dirData returns the migration files of the directory configmaps, which may
+// reside in another namespace if an AtlasReferenceGrant allows it.
+func (r *AtlasMigrationReconciler) dirData(ctx context.Context, am *dbv1alpha1.AtlasMigration, refs ...corev1.LocalObjectReference) (map[string]string, error) {
+	ns := am.Namespace
+	for _, ref := range refs {
+		var err error
+		if ns, err = refNamespace(ctx, r, am, "AtlasMigration", am.Spec.Dir.Namespace, "ConfigMap", ref.Name); err != nil {
+			return nil, err
+		}
+	}
+	return r.cfgMapData(ctx, ns, refs...)
+}
 
-	// Get configmap
-	configMap := corev1.ConfigMap{}
-	if err := r.Get(ctx, types.NamespacedName{
-		Namespace: ns,
-		Name:      cfgName,
-	}, &configMap); err != nil {
-		return "", nil, transient(err)
+// cfgMapData returns the data of the given configmaps merged into a single map.
+// It fails if the same key is defined in more than one configmap.
+func (r *AtlasMigrationReconciler) cfgMapData(
+	ctx context.Context,
+	ns string,
+	refs ...corev1.LocalObjectReference,
+) (map[string]string, error) {
+	var (
+		merged = make(map[string]string)
+		owners = make(map[string]string)
+	)
+	for _, ref := range refs {
+		configMap := corev1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{
+			Namespace: ns,
+			Name:      ref.Name,
+		}, &configMap); err != nil {
+			return nil, transient(err)
+		}
+		for key, value := range configMap.Data {
+			if owner, ok := owners[key]; ok {
+				return nil, fmt.Errorf("key %q is defined in both configmap %q and %q", key, owner, ref.Name)
+			}
+			owners[key] = ref.Name
+			merged[key] = value
+		}
 	}
+	return merged, nil
+}
 
-	return r.createTmpDirFromMap(ctx, configMap.Data)
+// subPath returns the entries of m whose keys start with the given path,
+// with the path trimmed from their keys.
+func subPath(m map[string]string, path string) map[string]string {
+	sub := make(map[string]string)
+	for key, value := range m {
+		if name := strings.TrimPrefix(key, path); name != key && name != "" {
+			sub[name] = value
+		}
+	}
+	return sub
 }
 
 // createTmpDirFromCM creates a temporary directory by configmap
@@ -359,10 +1153,37 @@ func (r *AtlasMigrationReconciler) createTmpDirFromMap(
 	}, nil
 }
 
+// migrationURL returns the URL of the database the migration targets.
+func migrationURL(ctx context.Context, r client.Reader, am *dbv1alpha1.AtlasMigration) (string, error) {
+	switch creds := am.Spec.Credentials; {
+	case am.Spec.URL != "":
+		return am.Spec.URL, nil
+	case am.Spec.URLFrom.SecretKeyRef != nil:
+		ns, err := refNamespace(ctx, r, am, "AtlasMigration", am.Spec.URLFrom.Namespace, "Secret", am.Spec.URLFrom.SecretKeyRef.Name)
+		if err != nil {
+			return "", err
+		}
+		return urlFromSecret(ctx, r, ns, am.Spec.URLFrom)
+	case creds.Host != "":
+		if err := hydrateCredentials(ctx, &creds, r, am.Namespace); err != nil {
+			return "", err
+		}
+		return creds.URL().String(), nil
+	}
+	return "", nil
+}
+
 func (r *AtlasMigrationReconciler) watch(am dbv1alpha1.AtlasMigration) {
+	dirNS := namespaceOr(am.Spec.Dir.Namespace, am.Namespace)
 	if c := am.Spec.Dir.ConfigMapRef; c != nil {
 		r.configMapWatcher.Watch(
-			types.NamespacedName{Name: c.Name, Namespace: am.Namespace},
+			types.NamespacedName{Name: c.Name, Namespace: dirNS},
+			am.NamespacedName(),
+		)
+	}
+	for _, c := range am.Spec.Dir.ConfigMapRefs {
+		r.configMapWatcher.Watch(
+			types.NamespacedName{Name: c.Name, Namespace: dirNS},
 			am.NamespacedName(),
 		)
 	}
@@ -370,34 +1191,94 @@ func (r *AtlasMigrationReconciler) watch(am dbv1alpha1.AtlasMigration) {
 		r.secretWatcher.Watch(
 			types.NamespacedName{Name: s.Name, Namespace: am.Namespace},
 			am.NamespacedName(),
+			s.Key,
 		)
 	}
-	if s := am.Spec.URLFrom.SecretKeyRef; s != nil {
+	if s := am.Spec.AuthTokenFrom.SecretKeyRef; s != nil {
+		r.secretWatcher.Watch(
+			types.NamespacedName{Name: s.Name, Namespace: am.Namespace},
+			am.NamespacedName(),
+			s.Key,
+		)
+	}
+	if s := am.Spec.TLSFrom.SecretRef; s != nil {
 		r.secretWatcher.Watch(
 			types.NamespacedName{Name: s.Name, Namespace: am.Namespace},
 			am.NamespacedName(),
+			tlsKeys[:]...,
+		)
+	}
+	if s := am.Spec.URLFrom.SecretKeyRef; s != nil {
+		r.secretWatcher.Watch(
+			types.NamespacedName{Name: s.Name, Namespace: namespaceOr(am.Spec.URLFrom.Namespace, am.Namespace)},
+			am.NamespacedName(),
+			s.Key,
+		)
+	}
+	if s := am.Spec.DevURLFrom.SecretKeyRef; s != nil {
+		r.secretWatcher.Watch(
+			types.NamespacedName{Name: s.Name, Namespace: namespaceOr(am.Spec.DevURLFrom.Namespace, am.Namespace)},
+			am.NamespacedName(),
+			s.Key,
 		)
 	}
 	if s := am.Spec.Credentials.PasswordFrom.SecretKeyRef; s != nil {
 		r.secretWatcher.Watch(
 			types.NamespacedName{Name: s.Name, Namespace: am.Namespace},
 			am.NamespacedName(),
+			s.Key,
 		)
 	}
+	if t := am.Spec.Credentials.TLS; t != nil {
+		if s := t.CAFrom.SecretKeyRef; s != nil {
+			r.secretWatcher.Watch(
+				types.NamespacedName{Name: s.Name, Namespace: am.Namespace},
+				am.NamespacedName(),
+				s.Key,
+			)
+		}
+		if c := t.CAFrom.ConfigMapKeyRef; c != nil {
+			r.configMapWatcher.Watch(
+				types.NamespacedName{Name: c.Name, Namespace: am.Namespace},
+				am.NamespacedName(),
+				c.Key,
+			)
+		}
+	}
+	if p := am.Spec.Project; p != nil {
+		r.watchProject(am, p)
+	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *AtlasMigrationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&dbv1alpha1.AtlasMigration{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		For(&dbv1alpha1.AtlasMigration{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			confirmDownChanged,
+			confirmTargetChanged,
+			approveDestructiveChanged,
+			moveRevisionsChanged,
+			bulkAnnotationsChanged,
+		))).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.maxConcurrent}).
 		Owns(&dbv1alpha1.AtlasMigration{}).
+		Owns(&dbv1alpha1.AtlasPlan{}).
+		Owns(&batchv1.Job{}).
 		Watches(&source.Kind{Type: &corev1.Secret{}}, r.secretWatcher).
 		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, r.configMapWatcher).
+		Watches(&source.Kind{Type: &coordinationv1.Lease{}},
+			releaseHeld(mgr.GetClient(), r.holdNamespace, func() client.ObjectList { return &dbv1alpha1.AtlasMigrationList{} }),
+			builder.WithPredicates(holdReleased)).
 		Complete(r)
 }
 
 // Render atlas.hcl file from the given data
 func (amd atlasMigrationData) render() (string, func() error, error) {
+	// Project files are used as-is.
+	if amd.project != "" {
+		return atlas.TempFile(amd.project, "hcl")
+	}
 	var buf bytes.Buffer
 	if err := tmpl.ExecuteTemplate(&buf, "atlas_migration.tmpl", amd); err != nil {
 		return "", nil, err
@@ -411,7 +1292,22 @@ func (amd atlasMigrationData) hash() (string, error) {
 	h := sha256.New()
 
 	// Hash cloud directory
-	h.Write([]byte(amd.URL))
+	u := amd.URL
+	if p, err := url.Parse(u); err == nil && amd.azureAD && p.User != nil {
+		p.User = url.User(p.User.Username())
+		u = p.String()
+	}
+	h.Write([]byte(u))
+	h.Write([]byte(amd.version))
+	h.Write([]byte(amd.execOrder))
+	h.Write([]byte(amd.txMode))
+	h.Write([]byte(amd.Baseline))
+	h.Write([]byte(amd.project))
+	h.Write([]byte(amd.tlsHash))
+	amd.hashVars(h)
+	for _, p := range amd.Exclude {
+		h.Write([]byte(p))
+	}
 	if amd.Cloud != nil {
 		h.Write([]byte(amd.Cloud.Token))
 		h.Write([]byte(amd.Cloud.URL))
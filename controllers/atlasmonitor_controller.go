@@ -0,0 +1,302 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/controllers/watch"
+	"github.com/ariga/atlas-operator/internal/atlas"
+	"github.com/ariga/atlas-operator/internal/git"
+)
+
+const (
+	// acceptDriftAnnotation accepts the drift of a monitored database. Its
+	// value is the schema hash of the drifted schema, so a later drift is not
+	// accepted by mistake.
+	acceptDriftAnnotation = "db.atlasgo.io/accept-drift"
+	// defaultMonitorInterval is the interval between observations of monitors
+	// that do not set one.
+	defaultMonitorInterval = 5 * time.Minute
+)
+
+type (
+	// MonitorCLI is the interface used to observe the monitored databases.
+	// It has no command that changes a database.
+	MonitorCLI interface {
+		Status(ctx context.Context, data *atlas.StatusParams) (*atlas.StatusReport, error)
+		SchemaInspect(ctx context.Context, data *atlas.SchemaInspectParams) (string, error)
+	}
+	// AtlasMonitorReconciler reconciles an AtlasMonitor object
+	AtlasMonitorReconciler struct {
+		client.Client
+		cli    MonitorCLI
+		Scheme *runtime.Scheme
+		// migrations reads the migration directories of the monitors the
+		// same way AtlasMigration resources read theirs.
+		migrations       *AtlasMigrationReconciler
+		secretWatcher    *watch.ResourceWatcher
+		configMapWatcher *watch.ResourceWatcher
+		recorder         record.EventRecorder
+		maxConcurrent    int
+	}
+)
+
+var (
+	monitorPending = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "atlas_monitor_pending_files",
+		Help: "The number of migration files pending to be applied to the database of the monitor.",
+	}, []string{"namespace", "name"})
+	monitorDrifted = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "atlas_monitor_drifted",
+		Help: "Whether the schema of the database of the monitor changed while its version did not (1) or not (0).",
+	}, []string{"namespace", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(monitorPending, monitorDrifted)
+}
+
+func NewAtlasMonitorReconciler(mgr manager.Manager, cli MonitorCLI, opts Options) *AtlasMonitorReconciler {
+	dedup := watch.WithDedupWindow(opts.WatchDedupWindow)
+	secretWatcher := watch.New(dedup)
+	configMapWatcher := watch.New(dedup)
+	return &AtlasMonitorReconciler{
+		Client: mgr.GetClient(),
+		cli:    cli,
+		Scheme: mgr.GetScheme(),
+		migrations: &AtlasMigrationReconciler{
+			Client:           mgr.GetClient(),
+			Scheme:           mgr.GetScheme(),
+			git:              git.NewClient("git"),
+			secretWatcher:    &secretWatcher,
+			configMapWatcher: &configMapWatcher,
+		},
+		secretWatcher:    &secretWatcher,
+		configMapWatcher: &configMapWatcher,
+		recorder:         newEventRecorder(mgr.GetEventRecorderFor("atlasmonitor-controller")),
+		maxConcurrent:    opts.MaxConcurrentReconciles,
+	}
+}
+
+//+kubebuilder:rbac:groups=db.atlasgo.io,resources=atlasmonitors,verbs=get;list;watch
+//+kubebuilder:rbac:groups=db.atlasgo.io,resources=atlasmonitors/status,verbs=get;update;patch
+
+// Reconcile observes the database of an AtlasMonitor and reports its state.
+// It never changes the database.
+func (r *AtlasMonitorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	var mon dbv1alpha1.AtlasMonitor
+	if err := r.Get(ctx, req.NamespacedName, &mon); err != nil {
+		if apierrors.IsNotFound(err) {
+			monitorPending.DeleteLabelValues(req.Namespace, req.Name)
+			monitorDrifted.DeleteLabelValues(req.Namespace, req.Name)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	am := monitorMigration(&mon)
+	defer func() {
+		if err := r.Status().Update(ctx, &mon); err != nil {
+			log.Error(err, "failed to update resource status")
+		}
+		// Watch the secrets and the configmaps of the monitor.
+		r.migrations.watch(am)
+	}()
+	// Leave suspended resources as is until they are resumed.
+	if suspended(&mon) {
+		mon.SetNotReady(dbv1alpha1.ReasonSuspended, "Reconciles are suspended by the "+suspendAnnotation+" annotation")
+		return ctrl.Result{}, nil
+	}
+	interval := defaultMonitorInterval
+	if i := mon.Spec.Interval; i != nil && i.Duration > 0 {
+		interval = i.Duration
+	}
+	if err := r.observe(ctx, &mon, am); err != nil {
+		mon.SetNotReady(dbv1alpha1.ReasonObserving, err.Error())
+		r.recorder.Event(&mon, corev1.EventTypeWarning, dbv1alpha1.ReasonObserveFailed, strings.TrimSpace(err.Error()))
+		return ctrl.Result{RequeueAfter: interval}, nil
+	}
+	mon.SetReady(dbv1alpha1.ReasonObserved, monitorSummary(&mon.Status))
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// observe runs "migrate status" and "schema inspect" against the database of
+// the monitor, and records their results in its status.
+func (r *AtlasMonitorReconciler) observe(ctx context.Context, mon *dbv1alpha1.AtlasMonitor, am dbv1alpha1.AtlasMigration) error {
+	// Image bundles are read by Jobs owned by the resource.
+	if d := mon.Spec.Dir; d != nil && d.Image != nil {
+		return errors.New("dir.image is not supported by AtlasMonitor")
+	}
+	md, cleanUp, err := r.migrations.extractMigrationData(ctx, am)
+	if err != nil {
+		return err
+	}
+	defer cleanUp()
+	if md.URL == "" {
+		return errors.New("no target database defined")
+	}
+	version := mon.Status.CurrentVersion
+	if mon.Spec.Dir != nil {
+		atlasHCL, clean, err := md.render()
+		if err != nil {
+			return err
+		}
+		defer clean()
+		status, err := r.cli.Status(ctx, md.statusParams(atlasHCL))
+		if err != nil {
+			return transient(err)
+		}
+		versions := make([]string, 0, len(status.Pending))
+		for _, f := range status.Pending {
+			versions = append(versions, f.Version)
+		}
+		version = status.Current
+		mon.Status.Pending, mon.Status.PendingVersions = len(versions), versions
+		monitorPending.WithLabelValues(mon.Namespace, mon.Name).Set(float64(len(versions)))
+	}
+	inspected, err := r.cli.SchemaInspect(ctx, &atlas.SchemaInspectParams{
+		URL:     md.URL,
+		Schema:  mon.Spec.Schemas,
+		Exclude: mon.Spec.Exclude,
+	})
+	if err != nil {
+		return transient(err)
+	}
+	sum := sha256.Sum256([]byte(inspected))
+	r.checkDrift(mon, version, hex.EncodeToString(sum[:]))
+	mon.Status.CurrentVersion = version
+	now := metav1.Now()
+	mon.Status.ObservedAt = &now
+	return nil
+}
+
+// checkDrift compares the inspected schema to the baseline of the monitor.
+// The baseline moves with the current version of the database, as migration
+// files change its schema, so only changes made outside of the migration
+// directory are reported as drift.
+func (r *AtlasMonitorReconciler) checkDrift(mon *dbv1alpha1.AtlasMonitor, version, hash string) {
+	s := &mon.Status
+	s.SchemaHash = hash
+	switch accepted := mon.Annotations[acceptDriftAnnotation] == hash; {
+	case s.BaselineHash == "" || version != s.CurrentVersion || accepted:
+		s.BaselineHash = hash
+		s.Drifted, s.DriftDetectedAt = false, nil
+	case hash == s.BaselineHash:
+		if s.Drifted {
+			r.recorder.Event(mon, corev1.EventTypeNormal, dbv1alpha1.ReasonSchemaDriftResolved, "The schema of the database is back to its baseline")
+		}
+		s.Drifted, s.DriftDetectedAt = false, nil
+	case !s.Drifted:
+		now := metav1.Now()
+		s.Drifted, s.DriftDetectedAt = true, &now
+		msg := "The schema of the database changed outside of the migration directory"
+		if version != "" {
+			msg = fmt.Sprintf("The schema of the database changed while its version %s did not", version)
+		}
+		r.recorder.Event(mon, corev1.EventTypeWarning, dbv1alpha1.ReasonSchemaDrift, msg)
+	}
+	drifted := 0.0
+	if s.Drifted {
+		drifted = 1
+	}
+	monitorDrifted.WithLabelValues(mon.Namespace, mon.Name).Set(drifted)
+}
+
+// monitorSummary returns the message of the ready condition of a monitor.
+func monitorSummary(s *dbv1alpha1.AtlasMonitorStatus) string {
+	var parts []string
+	if s.CurrentVersion != "" {
+		parts = append(parts, "Version "+s.CurrentVersion)
+	}
+	switch s.Pending {
+	case 0:
+	case 1:
+		parts = append(parts, "1 pending file")
+	default:
+		parts = append(parts, fmt.Sprintf("%d pending files", s.Pending))
+	}
+	if s.Drifted {
+		parts = append(parts, "schema drifted")
+	} else {
+		parts = append(parts, "no drift")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// monitorMigration returns the AtlasMigration reading the database and the
+// migration directory of the monitor. It is never stored nor applied.
+func monitorMigration(mon *dbv1alpha1.AtlasMonitor) dbv1alpha1.AtlasMigration {
+	am := dbv1alpha1.AtlasMigration{
+		ObjectMeta: metav1.ObjectMeta{Name: mon.Name, Namespace: mon.Namespace},
+		Spec: dbv1alpha1.AtlasMigrationSpec{
+			URL:             mon.Spec.URL,
+			URLFrom:         mon.Spec.URLFrom,
+			Credentials:     mon.Spec.Credentials,
+			Cloud:           mon.Spec.Cloud,
+			RevisionsSchema: mon.Spec.RevisionsSchema,
+			Exclude:         mon.Spec.Exclude,
+		},
+	}
+	if mon.Spec.Dir != nil {
+		am.Spec.Dir = *mon.Spec.Dir
+	}
+	return am
+}
+
+// acceptDriftChanged triggers a reconcile when the drift of a monitor is accepted.
+var acceptDriftChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.ObjectNew.GetAnnotations()[acceptDriftAnnotation] != e.ObjectOld.GetAnnotations()[acceptDriftAnnotation]
+	},
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AtlasMonitorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&dbv1alpha1.AtlasMonitor{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			acceptDriftChanged,
+			bulkAnnotationsChanged,
+		))).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.maxConcurrent}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, r.secretWatcher).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, r.configMapWatcher).
+		Complete(r)
+}
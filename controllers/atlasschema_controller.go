@@ -25,25 +25,32 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
 
 	"ariga.io/atlas/sql/sqlcheck"
-	"golang.org/x/exp/slices"
 	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -52,12 +59,16 @@ import (
 	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
 	"github.com/ariga/atlas-operator/controllers/watch"
 	"github.com/ariga/atlas-operator/internal/atlas"
+	"github.com/ariga/atlas-operator/internal/branch"
+	"github.com/ariga/atlas-operator/internal/cloudapi"
+	"github.com/ariga/atlas-operator/internal/git"
+	"github.com/ariga/atlas-operator/internal/probe"
+	"github.com/ariga/atlas-operator/internal/vitess"
 )
 
 const (
-	devDBSuffix     = "-atlas-dev-db"
-	hostReplace     = "REPLACE_HOST"
-	schemaReadyCond = "Ready"
+	devDBSuffix = "-atlas-dev-db"
+	hostReplace = "REPLACE_HOST"
 )
 
 var (
@@ -71,10 +82,28 @@ type (
 	AtlasSchemaReconciler struct {
 		client.Client
 		cli              CLI
+		git              GitClient
+		vitess           VitessClient
+		branches         func(*branch.Config) (branch.Provider, error)
+		jobLogs          JobLogReader
+		sqlExec          SQLExecutor
+		httpClient       *http.Client
+		uploadClient     *http.Client
 		scheme           *runtime.Scheme
 		configMapWatcher *watch.ResourceWatcher
 		secretWatcher    *watch.ResourceWatcher
+		schemaWatcher    *watch.ResourceWatcher
 		recorder         record.EventRecorder
+		applyLimiter     *HostLimiter
+		maxConcurrent    int
+		identity         string
+		holdNamespace    string
+		netGuard         *NetworkGuard
+		cloudPlans       PlanFetcher
+		version          string
+		cliVersion       *cliVersion
+		allowedImages    []string
+		allowAzureAD     bool
 	}
 	// devDB contains values used to render a devDB pod template.
 	devDB struct {
@@ -85,38 +114,101 @@ type (
 		DB          string
 		Port        int
 		UID         int
+		// Scheme of the dev database URL, e.g. "mariadb" for the mysql driver.
+		Scheme string
+		Image  string
 	}
 	// managed contains information about the managed database and its desired state.
 	managed struct {
-		ext        string
-		desired    string
+		ext     string
+		desired string
+		// secret reports if the desired schema is read from a secret. Its
+		// statements are then not copied to the status or to ConfigMaps.
+		secret bool
+		// files holds the desired schema files of multi-file schemas.
+		files      map[string]string
 		driver     string
 		url        *url.URL
 		exclude    []string
 		configfile string
-		policy     dbv1alpha1.Policy
-		schemas    []string
+		// to is the URL of the desired schema, when it is not stored locally.
+		to     string
+		cloud  *cloud
+		policy dbv1alpha1.Policy
+		// rules holds the custom lint rule definitions of the policy.
+		rules []string
+		// ruleFiles holds the files read by the rules through their src
+		// attribute, written next to the config file.
+		ruleFiles map[string]string
+		schemas   []string
+		commit    string
+		vitess    *dbv1alpha1.Vitess
+		// migrationContext is the Vitess migration context of the current apply.
+		migrationContext string
+		// lintFiles holds the file reports of the most recent lint.
+		lintFiles []*atlas.FileReport
+		// devURL is the URL of the dedicated dev database of the resource,
+		// if set. The operator runs a dev database otherwise, configured by devDB.
+		devURL string
+		devDB  *dbv1alpha1.DevDB
+		// tlsHash is the hash of the client certificate of tlsFrom, if set.
+		tlsHash string
 	}
 	CLI interface {
 		SchemaApply(context.Context, *atlas.SchemaApplyParams) (*atlas.SchemaApply, error)
 		SchemaInspect(ctx context.Context, data *atlas.SchemaInspectParams) (string, error)
 		Lint(ctx context.Context, data *atlas.LintParams) (*atlas.SummaryReport, error)
+		Version(ctx context.Context) (string, error)
+	}
+	// GitClient is the interface used to read schema files from Git repositories.
+	GitClient interface {
+		ReadFile(ctx context.Context, data *git.ReadFileParams) (*git.File, error)
+	}
+	// VitessClient is the interface used to track Vitess online DDL migrations.
+	VitessClient interface {
+		Migrations(ctx context.Context, url, migrationContext string) ([]vitess.Migration, error)
+	}
+	// previewBranch is a ready branch the schema changes are applied to.
+	previewBranch struct {
+		provider branch.Provider
+		branch   *branch.Branch
 	}
 	destructiveErr struct {
 		diags []sqlcheck.Diagnostic
 	}
 )
 
-func NewAtlasSchemaReconciler(mgr manager.Manager, cli CLI) *AtlasSchemaReconciler {
-	configMapWatcher := watch.New()
-	secretWatcher := watch.New()
+func NewAtlasSchemaReconciler(mgr manager.Manager, cli CLI, opts Options) *AtlasSchemaReconciler {
+	dedup := watch.WithDedupWindow(opts.WatchDedupWindow)
+	configMapWatcher := watch.New(dedup)
+	secretWatcher := watch.New(dedup)
+	schemaWatcher := watch.New(dedup)
+	httpClient := &http.Client{Timeout: 30 * time.Second}
 	return &AtlasSchemaReconciler{
 		Client:           mgr.GetClient(),
 		scheme:           mgr.GetScheme(),
 		cli:              cli,
+		git:              git.NewClient("git"),
+		vitess:           vitess.NewClient(),
+		branches:         branch.NewProvider,
+		jobLogs:          &jobLogs{cs: kubernetes.NewForConfigOrDie(mgr.GetConfig())},
+		sqlExec:          probe.New(),
+		httpClient:       httpClient,
+		uploadClient:     newUploadClient(),
 		configMapWatcher: &configMapWatcher,
 		secretWatcher:    &secretWatcher,
-		recorder:         mgr.GetEventRecorderFor("atlasschema-controller"),
+		schemaWatcher:    &schemaWatcher,
+		recorder:         newEventRecorder(mgr.GetEventRecorderFor("atlasschema-controller")),
+		applyLimiter:     opts.ApplyLimiter,
+		maxConcurrent:    opts.MaxConcurrentReconciles,
+		identity:         opts.Identity,
+		holdNamespace:    opts.HoldNamespace,
+		netGuard:         opts.NetworkGuard,
+		cloudPlans:       cloudapi.New(httpClient),
+		version:          opts.Version,
+		cliVersion:       &cliVersion{},
+		allowedImages:    opts.AllowedImages,
+		allowAzureAD:     opts.AllowAzureAD,
 	}
 }
 
@@ -126,7 +218,7 @@ func NewAtlasSchemaReconciler(mgr manager.Manager, cli CLI) *AtlasSchemaReconcil
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
-//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups="",resources=pods,verbs=delete
 
@@ -143,9 +235,30 @@ func (r *AtlasSchemaReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		err     error
 	)
 	if err := r.Get(ctx, req.NamespacedName, sc); err != nil {
+		if apierrors.IsNotFound(err) {
+			schemaDrifted.DeleteLabelValues(req.Namespace, req.Name)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	// Leave the resource as is while the networking of the cluster is broken.
+	if d, ok := r.netGuard.degraded(); ok {
+		return ctrl.Result{RequeueAfter: d}, nil
+	}
+	atlasVersion := r.cliVersion.get(ctx, r.cli)
+	// Record the CLI commands run by this reconcile, to reproduce it locally.
+	ctx, cmds := atlas.WithCommandLog(ctx)
+	// Bring statuses written by older operator versions to the current structure,
+	// before the conditions are compared with the ones set by this reconcile.
+	if upgradeSchemaStatus(&sc.Status) {
+		log.Info("upgraded the status written by an older operator version")
+	}
+	conds := append([]metav1.Condition(nil), sc.Status.Conditions...)
 	defer func() {
+		stampVersions(&sc.Status.OperatorVersion, &sc.Status.AtlasVersion, r.version, atlasVersion)
+		sc.Status.Transitions = dbv1alpha1.AppendTransition(sc.Status.Transitions, transitions(conds, sc.Status.Conditions)...)
+		if c := cmds.Commands(); len(c) > 0 {
+			sc.Status.Runs = dbv1alpha1.AppendRun(sc.Status.Runs, dbv1alpha1.CommandRun{Time: metav1.Now(), Commands: c})
+		}
 		if err := r.Status().Update(ctx, sc); err != nil {
 			log.Error(err, "failed to update status")
 		}
@@ -153,29 +266,112 @@ func (r *AtlasSchemaReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		r.watch(sc)
 
 		// Clean up any resources created by the controller after the reconciler is successful.
-		if meta.IsStatusConditionTrue(sc.Status.Conditions, schemaReadyCond) {
+		if meta.IsStatusConditionTrue(sc.Status.Conditions, dbv1alpha1.SchemaReadyCond) {
 			r.cleanUp(ctx, sc)
 		}
 
 	}()
 	// When the resource is first created, create the "Ready" condition.
 	if sc.Status.Conditions == nil || len(sc.Status.Conditions) == 0 {
-		setNotReady(sc, "Reconciling", "Reconciling")
+		// Adopt the history exported from the previous resource managing the database.
+		imported, err := importSchemaStatus(sc)
+		if err != nil {
+			setNotReady(sc, dbv1alpha1.ReasonImportingStatus, err.Error())
+			return ctrl.Result{}, nil
+		}
+		if imported {
+			r.recorder.Event(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonStatusImported, "Imported status from the "+importStatusAnnotation+" annotation")
+		}
+		setNotReady(sc, dbv1alpha1.ReasonReconciling, "Reconciling")
 		return ctrl.Result{Requeue: true}, nil
 	}
+	// Leave suspended resources as is until they are resumed.
+	if suspended(sc) {
+		setNotReady(sc, dbv1alpha1.ReasonSuspended, "Reconciles are suspended by the "+suspendAnnotation+" annotation")
+		return ctrl.Result{}, nil
+	}
+	// Report the next apply windows, and refuse invalid ones.
+	windows, err := parseWindows(sc.Spec.ApplyWindows)
+	if err != nil {
+		sc.Status.ApplyWindows = nil
+		setNotReady(sc, dbv1alpha1.ReasonInvalidApplyWindow, err.Error())
+		r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonInvalidApplyWindow, err.Error())
+		return ctrl.Result{}, nil
+	}
+	sc.Status.ApplyWindows = plannedApplyWindows(windows, time.Now())
 	managed, err = r.extractManaged(ctx, sc)
+	var scErr *scopeErr
+	if errors.As(err, &scErr) {
+		setNotReady(sc, dbv1alpha1.ReasonInvalidSchemaScope, err.Error())
+		r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonInvalidSchemaScope, err.Error())
+		return ctrl.Result{}, nil
+	}
 	if err != nil {
-		setNotReady(sc, "ReadSchema", err.Error())
+		setNotReady(sc, dbv1alpha1.ReasonReadSchema, err.Error())
 		return result(err)
 	}
 	// If the schema has changed and the schema's ready condition is not false, immediately set it to false.
 	// This is done so that the observed status of the schema reflects its "in-progress" state while it is being
 	// reconciled.
-	if !meta.IsStatusConditionFalse(sc.Status.Conditions, schemaReadyCond) && managed.hash() != sc.Status.ObservedHash {
-		setNotReady(sc, "Reconciling", "current schema does not match last applied")
+	if !meta.IsStatusConditionFalse(sc.Status.Conditions, dbv1alpha1.SchemaReadyCond) && managed.hash() != sc.Status.ObservedHash {
+		setNotReady(sc, dbv1alpha1.ReasonReconciling, "current schema does not match last applied")
 		return ctrl.Result{Requeue: true}, nil
 	}
-	if managed.driver != "sqlite" {
+	// Recover an apply interrupted by a restart or a failover of the operator.
+	if sc.Status.Applying != nil {
+		r.recoverApply(sc)
+	}
+	// Each named schema of a database is managed by a single resource.
+	owner, shared, err := r.overlap(ctx, sc, managed)
+	if err != nil {
+		setNotReady(sc, dbv1alpha1.ReasonCheckingOverlap, err.Error())
+		return result(err)
+	}
+	if owner != nil {
+		msg := overlapMessage(owner, shared)
+		setNotReady(sc, dbv1alpha1.ReasonSchemaOverlap, msg)
+		r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonSchemaOverlap, msg)
+		return ctrl.Result{RequeueAfter: coexistenceInterval}, nil
+	}
+	var pb *previewBranch
+	if sc.Spec.Preview != nil {
+		// The changes were already applied to the preview branch.
+		if ps := sc.Status.Preview; ps != nil && ps.Branch == previewName(sc, managed) &&
+			meta.IsStatusConditionTrue(sc.Status.Conditions, dbv1alpha1.SchemaReadyCond) {
+			return ctrl.Result{}, nil
+		}
+		if pb, err = r.previewBranch(ctx, sc, managed); err != nil {
+			setNotReady(sc, dbv1alpha1.ReasonPreviewBranch, err.Error())
+			return result(err)
+		}
+		if pb == nil {
+			setNotReady(sc, dbv1alpha1.ReasonPreviewBranchPending, fmt.Sprintf("waiting for branch %s to be ready", previewName(sc, managed)))
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
+	}
+	// Wait for the online DDL migrations submitted by the last apply to complete.
+	if mc := sc.Status.MigrationContext; mc != "" {
+		migrations, err := r.vitess.Migrations(ctx, managed.url.String(), mc)
+		if err != nil {
+			setNotReady(sc, dbv1alpha1.ReasonOnlineDDL, err.Error())
+			return result(transient(err))
+		}
+		done, failed := vitess.Done(migrations)
+		switch {
+		case failed != nil:
+			sc.Status.MigrationContext = ""
+			msg := fmt.Sprintf("online DDL migration %s %s: %s", failed.UUID, failed.Status, failed.Message)
+			setNotReady(sc, dbv1alpha1.ReasonOnlineDDLFailed, msg)
+			r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonOnlineDDLFailed, msg)
+			return ctrl.Result{}, nil
+		case !done:
+			setNotReady(sc, dbv1alpha1.ReasonOnlineDDLRunning, fmt.Sprintf("waiting for online DDL migrations of context %s to complete", mc))
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
+		// All migrations completed. Continue to verify the schema is in sync.
+		sc.Status.MigrationContext = ""
+	}
+	if managed.driver != "sqlite" && managed.devURL == "" {
 		// make sure we have a dev db running
 		devDB := &v1.Deployment{}
 		err = transient(
@@ -184,7 +380,7 @@ func (r *AtlasSchemaReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		if apierrors.IsNotFound(err) {
 			_, err = r.devDBDeployment(ctx, sc, managed)
 			if err != nil {
-				setNotReady(sc, "CreatingDevDB", err.Error())
+				setNotReady(sc, dbv1alpha1.ReasonCreatingDevDB, err.Error())
 				return result(err)
 			}
 			return ctrl.Result{
@@ -192,30 +388,49 @@ func (r *AtlasSchemaReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			}, nil
 		}
 		if err != nil {
-			setNotReady(sc, "GettingDevDB", err.Error())
+			setNotReady(sc, dbv1alpha1.ReasonGettingDevDB, err.Error())
 			return result(err)
 		}
+		if why := devDBStale(devDB, managed); why != "" {
+			if err := r.recreateDevDB(ctx, sc, devDB, why); err != nil {
+				setNotReady(sc, dbv1alpha1.ReasonRecreatingDevDB, err.Error())
+				return result(err)
+			}
+			return ctrl.Result{RequeueAfter: 15 * time.Second}, nil
+		}
 	}
-	devURL, err := r.devURL(ctx, req.Name, managed.driver)
+	devURL, err := r.devURL(ctx, req.Name, managed)
 	if err != nil {
-		setNotReady(sc, "GettingDevDBURL", err.Error())
+		setNotReady(sc, dbv1alpha1.ReasonGettingDevDBURL, err.Error())
 		return result(err)
 	}
-	conf, cleanconf, err := configFile(sc.Spec.Policy)
+	conf, cleanconf, err := configFile(managed)
 	if err != nil {
-		setNotReady(sc, "CreatingConfigFile", err.Error())
+		setNotReady(sc, dbv1alpha1.ReasonCreatingConfigFile, err.Error())
 		return result(err)
 	}
 	defer cleanconf()
 	managed.configfile = conf
+	// Schemas sharing the database with AtlasMigration resources are read-only.
+	if res, done, err := r.coexist(ctx, sc, managed, devURL); done {
+		return res, err
+	}
 	// Verify the first run doesn't contain destructive changes.
-	if sc.Status.LastApplied == 0 {
-		if err := r.verifyFirstRun(ctx, managed, devURL); err != nil {
-			reason := "VerifyingFirstRun"
+	if sc.Status.LastApplied == 0 && !sc.Spec.DryRun {
+		err := r.verifyFirstRun(ctx, managed, devURL)
+		if d, ok := r.netGuard.observe(ctx, err); ok {
+			return ctrl.Result{RequeueAfter: d}, nil
+		}
+		if res, ok := r.schemaMaintenance(sc, err); ok {
+			return res, nil
+		}
+		r.reportLint(ctx, sc, managed, err)
+		if err != nil {
+			reason := dbv1alpha1.ReasonVerifyingFirstRun
 			msg := err.Error()
 			var d destructiveErr
 			if errors.As(err, &d) {
-				reason = "FirstRunDestructive"
+				reason = dbv1alpha1.ReasonFirstRunDestructive
 				msg = err.Error() + "\n" +
 					"To prevent accidental drop of resources, first run of a schema must not contain destructive changes.\n" +
 					"Read more: https://atlasgo.io/integrations/kubernetes/#destructive-changes"
@@ -225,53 +440,421 @@ func (r *AtlasSchemaReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			return result(err)
 		}
 	}
+	var (
+		lintErr     error
+		destructive *destructiveErr
+	)
 	if shouldLint(managed) {
-		if err := r.lint(ctx, managed, devURL); err != nil {
-			setNotReady(sc, "LintPolicyError", err.Error())
-			r.recorder.Event(sc, corev1.EventTypeWarning, "LintPolicyError", err.Error())
+		lintErr = r.lint(ctx, managed, devURL)
+		if d, ok := r.netGuard.observe(ctx, lintErr); ok {
+			return ctrl.Result{RequeueAfter: d}, nil
+		}
+		if res, ok := r.schemaMaintenance(sc, lintErr); ok {
+			return res, nil
+		}
+		r.reportLint(ctx, sc, managed, lintErr)
+		var dErr destructiveErr
+		switch {
+		// Destructive plans are applied once approved by their hash.
+		case managed.policy.Review == "" && errors.As(lintErr, &dErr):
+			destructive = &dErr
+		// Plans failing the lint await approval under a review policy.
+		case lintErr != nil && (managed.policy.Review == "" || !lintFindings(lintErr)):
+			setNotReady(sc, dbv1alpha1.ReasonLintPolicyError, lintErr.Error())
+			r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonLintPolicyError, lintErr.Error())
+			return result(lintErr)
+		}
+	}
+	if _, err := approvalDeadline(sc); err != nil {
+		setNotReady(sc, dbv1alpha1.ReasonInvalidApprovalDeadline, err.Error())
+		r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonInvalidApprovalDeadline, err.Error())
+		return ctrl.Result{}, nil
+	}
+	// Dry-runs never apply, and their plans await no approval.
+	approval := !sc.Spec.DryRun && (requiresApproval(sc) || reviewRequired(managed, lintErr))
+	var bypass bool
+	if approval {
+		bypass, err = r.breakGlass(sc, managed)
+		if err != nil {
+			setNotReady(sc, dbv1alpha1.ReasonBreakGlassInvalid, err.Error())
 			return result(err)
 		}
+		// Expired plans are planned again only when the desired schema changes.
+		if approval = !bypass; approval && approvalExpired(sc, managed) {
+			return ctrl.Result{}, nil
+		}
+	}
+	// Plan the changes, and refuse to apply them if the database drifted
+	// since they were planned.
+	plan, err := r.plan(ctx, sc, managed, devURL)
+	err = refreshAzureToken(sc.Spec.Credentials, err)
+	if d, ok := r.netGuard.observe(ctx, err); ok {
+		return ctrl.Result{RequeueAfter: d}, nil
+	}
+	if res, ok := r.schemaMaintenance(sc, err); ok {
+		return res, nil
+	}
+	var drift *planDriftErr
+	if errors.As(err, &drift) {
+		setNotReady(sc, dbv1alpha1.ReasonPlanDrifted, err.Error())
+		r.recorder.Eventf(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonPlanDrifted, "The database changed after plan %s was computed. Planned %s instead", drift.stored.Hash, drift.planned.Hash)
+		return ctrl.Result{Requeue: true}, nil
+	}
+	if err != nil {
+		setNotReady(sc, dbv1alpha1.ReasonPlanningSchema, err.Error())
+		return result(err)
+	}
+	r.reportPlanned(ctx, sc, managed, plan)
+	// Plans replacing a drifted plan were not reviewed when the apply was
+	// decided, so they are applied only once approved.
+	if plan.DriftedFrom != "" && !sc.Spec.DryRun && !bypass {
+		approval = true
+	}
+	// Report the plan of a dry-run without applying it.
+	if sc.Spec.DryRun {
+		msg := fmt.Sprintf("Dry-run: %d statements would be applied", len(plan.Statements))
+		setNotReady(sc, dbv1alpha1.ReasonDryRun, msg)
+		r.recorder.Event(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonDryRun, msg)
+		return ctrl.Result{}, nil
+	}
+	// Refuse to apply the plan to a different database the URL was repointed to.
+	var tgErr *targetChangedErr
+	if err := r.checkTarget(sc, managed, plan); errors.As(err, &tgErr) {
+		awaitApproval(r.recorder, sc, &sc.Status.Conditions, dbv1alpha1.ReasonAwaitingTargetConfirmation, "Applying to target "+tgErr.target, err.Error())
+		setNotReady(sc, dbv1alpha1.ReasonTargetChanged, err.Error())
+		r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonTargetChanged, err.Error())
+		return ctrl.Result{}, nil
+	}
+	// Report drift of the database from the applied schema, and apply the plan
+	// only if the drift detection policy remediates it.
+	if res, done := r.checkDrift(ctx, sc, managed, plan); done {
+		return res, nil
+	}
+	// Apply only the changes of the approved Atlas Cloud plan.
+	if sc.Spec.Policy.Plan != nil {
+		err := r.checkCloudPlan(ctx, sc, plan)
+		var np *noApprovedPlanErr
+		if errors.As(err, &np) {
+			awaitApproval(r.recorder, sc, &sc.Status.Conditions, dbv1alpha1.ReasonAwaitingCloudApproval, "Plan "+plan.Hash, err.Error())
+			if c := meta.FindStatusCondition(sc.Status.Conditions, dbv1alpha1.SchemaReadyCond); c == nil || c.Reason != dbv1alpha1.ReasonNoApprovedPlan || c.Message != err.Error() {
+				r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonNoApprovedPlan, err.Error())
+			}
+			setNotReady(sc, dbv1alpha1.ReasonNoApprovedPlan, err.Error())
+			return ctrl.Result{RequeueAfter: cloudPlanRetry}, nil
+		}
+		if err != nil {
+			setNotReady(sc, dbv1alpha1.ReasonFetchingPlan, err.Error())
+			return result(err)
+		}
+	}
+	if destructive != nil {
+		if err := r.approveDestructive(sc, plan, destructive); err != nil {
+			awaitApproval(r.recorder, sc, &sc.Status.Conditions, dbv1alpha1.ReasonAwaitingDestructiveApproval, "Plan "+plan.Hash, err.Error())
+			setNotReady(sc, dbv1alpha1.ReasonLintPolicyError, err.Error())
+			r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonLintPolicyError, err.Error())
+			return result(err)
+		}
+	}
+	// The plan is no longer blocked by the approvals above, and the
+	// approval policy reports its own plans awaiting approval.
+	meta.RemoveStatusCondition(&sc.Status.Conditions, dbv1alpha1.PendingApprovalCond)
+	if approval {
+		res, approved, err := r.approve(ctx, sc, managed, plan)
+		if err != nil {
+			setNotReady(sc, dbv1alpha1.ReasonPlanningApproval, err.Error())
+			return result(err)
+		}
+		if !approved {
+			return res, nil
+		}
+	}
+	if managed.vitess != nil {
+		managed.migrationContext = fmt.Sprintf("atlas-operator:%s:%s:%d", sc.Namespace, sc.Name, time.Now().Unix())
+	}
+	// Apply the changes in the apply windows only.
+	if len(plan.Statements) > 0 {
+		var wErr *windowErr
+		if err := checkWindows(windows, time.Now()); errors.As(err, &wErr) {
+			setNotReady(sc, dbv1alpha1.ReasonOutsideApplyWindow, err.Error())
+			r.recorder.Event(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonOutsideApplyWindow, err.Error())
+			return ctrl.Result{RequeueAfter: wErr.retry(time.Now())}, nil
+		}
+	}
+	if err := checkHold(ctx, r, r.holdNamespace, sc); err != nil {
+		var hold *holdErr
+		if !errors.As(err, &hold) {
+			setNotReady(sc, dbv1alpha1.ReasonCheckingHold, err.Error())
+			return result(err)
+		}
+		setNotReady(sc, dbv1alpha1.ReasonHeld, err.Error())
+		r.recorder.Event(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonHeld, err.Error())
+		return ctrl.Result{RequeueAfter: hold.retry(time.Now())}, nil
+	}
+	release, err := r.applyLimiter.acquire(managed.url.Host)
+	if err != nil {
+		setNotReady(sc, dbv1alpha1.ReasonApplyBudgetExhausted, err.Error())
+		return ctrl.Result{RequeueAfter: budgetRetry}, nil
+	}
+	if err := r.markApplying(ctx, sc, managed); err != nil {
+		release()
+		setNotReady(sc, dbv1alpha1.ReasonMarkingApply, err.Error())
+		return result(err)
 	}
 	app, err := r.apply(ctx, managed, devURL)
+	release()
+	err = refreshAzureToken(sc.Spec.Credentials, err)
+	sc.Status.Applying, sc.Status.MigrationContext = nil, ""
+	if d, ok := r.netGuard.observe(ctx, err); ok {
+		return ctrl.Result{RequeueAfter: d}, nil
+	}
+	if res, ok := r.schemaMaintenance(sc, err); ok {
+		return res, nil
+	}
 	if err != nil {
-		setNotReady(sc, "ApplyingSchema", err.Error())
-		r.recorder.Event(sc, corev1.EventTypeWarning, "ApplyingSchema", err.Error())
+		setNotReady(sc, dbv1alpha1.ReasonApplyingSchema, err.Error())
+		r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonApplyingSchema, err.Error())
+		if isSQLErr(err) {
+			notifyApply(ctx, r, r.httpClient, r.recorder, sc, sc.Spec.Webhook, failedResult("AtlasSchema", sc, err))
+		}
 		return result(err)
 	}
+	if managed.migrationContext != "" && len(app.Changes.Applied) > 0 {
+		sc.Status.MigrationContext = managed.migrationContext
+		setNotReady(sc, dbv1alpha1.ReasonOnlineDDLRunning, fmt.Sprintf("submitted online DDL migrations with context %s", managed.migrationContext))
+		r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonOnlineDDLSubmitted, "Submitted online DDL migrations with context %s", managed.migrationContext)
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+	if pb != nil {
+		if err := r.completePreview(ctx, sc, managed, pb, app); err != nil {
+			setNotReady(sc, dbv1alpha1.ReasonPreviewBranch, err.Error())
+			r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonPreviewBranch, err.Error())
+			return result(err)
+		}
+	}
+	// Approvals of destructive changes authorize a single apply.
+	if destructive != nil {
+		if err := consumeAnnotation(ctx, r, sc, approveDestructiveAnnotation); err != nil {
+			log.Error(err, "failed to remove the approve-destructive annotation")
+		}
+	}
+	// The database may change between the plan and the apply too.
+	if app != nil && len(app.Changes.Applied) > 0 {
+		if planDiff(plan.Statements, app.Changes.Applied) != "" {
+			r.recorder.Eventf(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonPlanDrifted,
+				"The database changed after plan %s was computed. Applied %d statements instead of the %d planned", plan.Hash, len(app.Changes.Applied), len(plan.Statements))
+		}
+	}
+	sc.Status.Plan = nil
+	r.clearPlanned(ctx, sc)
+	// Applying the schema the database drifted from resolves the drift.
+	r.clearDrift(ctx, sc, sc.Status.ObservedHash == managed.hash())
+	maintenanceEnded(r.recorder, sc, &sc.Status.Conditions)
 	setReady(sc, managed, app)
-	r.recorder.Event(sc, corev1.EventTypeNormal, "Applied", "Applied schema")
-	return ctrl.Result{}, nil
+	r.recorder.Event(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonApplied, "Applied schema")
+	if app != nil && len(app.Changes.Applied) > 0 {
+		notifyApply(ctx, r, r.httpClient, r.recorder, sc, sc.Spec.Webhook, appliedResult("AtlasSchema", sc, "", []dbv1alpha1.AppliedChange{
+			{Time: metav1.Unix(sc.Status.LastApplied, 0), Statements: app.Changes.Applied},
+		}))
+	}
+	r.publishDocs(ctx, sc, managed, app)
+	var res ctrl.Result
+	// Check the Git ref periodically for new commits.
+	if g := sc.Spec.Schema.Git; g != nil {
+		res.RequeueAfter = 5 * time.Minute
+		if g.Interval != nil {
+			res.RequeueAfter = g.Interval.Duration
+		}
+	}
+	// Check the registry tag periodically for new versions.
+	if g := sc.Spec.Schema.Registry; g != nil && g.Version == "" {
+		res.RequeueAfter = 5 * time.Minute
+		if g.Interval != nil {
+			res.RequeueAfter = g.Interval.Duration
+		}
+	}
+	if next := r.refreshViews(ctx, sc, managed); next > 0 && (res.RequeueAfter == 0 || next < res.RequeueAfter) {
+		res.RequeueAfter = next
+	}
+	// Check the database for drift periodically.
+	if next := driftInterval(sc); next > 0 && (res.RequeueAfter == 0 || next < res.RequeueAfter) {
+		res.RequeueAfter = next
+	}
+	return res, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *AtlasSchemaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &dbv1alpha1.AtlasMigration{}, migrationDatabaseField, migrationDatabases); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &dbv1alpha1.AtlasSchema{}, schemaDatabaseField, schemaDatabases); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&dbv1alpha1.AtlasSchema{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		For(&dbv1alpha1.AtlasSchema{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			approvalChanged,
+			breakGlassChanged,
+			approveDestructiveChanged,
+			confirmTargetChanged,
+			bulkAnnotationsChanged,
+		))).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.maxConcurrent}).
 		Owns(&dbv1alpha1.AtlasSchema{}).
+		Owns(&dbv1alpha1.AtlasPlan{}).
+		Owns(&batchv1.Job{}).
 		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, r.configMapWatcher).
 		Watches(&source.Kind{Type: &corev1.Secret{}}, r.secretWatcher).
+		Watches(&source.Kind{Type: &dbv1alpha1.AtlasSchema{}}, r.schemaWatcher,
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &coordinationv1.Lease{}},
+			releaseHeld(mgr.GetClient(), r.holdNamespace, func() client.ObjectList { return &dbv1alpha1.AtlasSchemaList{} }),
+			builder.WithPredicates(holdReleased)).
 		Complete(r)
 }
 
 func (r *AtlasSchemaReconciler) watch(sc *dbv1alpha1.AtlasSchema) {
+	// Reconcilers built outside of the manager, e.g. by Replan, watch nothing.
+	if r.configMapWatcher == nil || r.secretWatcher == nil || r.schemaWatcher == nil {
+		return
+	}
 	if c := sc.Spec.Schema.ConfigMapKeyRef; c != nil {
+		r.configMapWatcher.Watch(
+			types.NamespacedName{Name: c.Name, Namespace: namespaceOr(sc.Spec.Schema.Namespace, sc.Namespace)},
+			sc.NamespacedName(),
+			c.Key,
+		)
+	}
+	if s := sc.Spec.Schema.SecretKeyRef; s != nil {
+		r.secretWatcher.Watch(
+			types.NamespacedName{Name: s.Name, Namespace: sc.Namespace},
+			sc.NamespacedName(),
+			s.Key,
+		)
+	}
+	for _, l := range sc.Spec.Schema.Layers {
+		if c := l.ConfigMapKeyRef; c != nil {
+			r.configMapWatcher.Watch(
+				types.NamespacedName{Name: c.Name, Namespace: sc.Namespace},
+				sc.NamespacedName(),
+				c.Key,
+			)
+		}
+	}
+	if s := sc.Spec.Schema.SchemaRef; s != nil {
+		r.schemaWatcher.Watch(
+			types.NamespacedName{Name: s.Name, Namespace: sc.Namespace},
+			sc.NamespacedName(),
+		)
+	}
+	if s := sc.Spec.AuthTokenFrom.SecretKeyRef; s != nil {
+		r.secretWatcher.Watch(
+			types.NamespacedName{Name: s.Name, Namespace: sc.Namespace},
+			sc.NamespacedName(),
+			s.Key,
+		)
+	}
+	if s := sc.Spec.TLSFrom.SecretRef; s != nil {
+		r.secretWatcher.Watch(
+			types.NamespacedName{Name: s.Name, Namespace: sc.Namespace},
+			sc.NamespacedName(),
+			tlsKeys[:]...,
+		)
+	}
+	if s := sc.Spec.DevURLFrom.SecretKeyRef; s != nil {
+		r.secretWatcher.Watch(
+			types.NamespacedName{Name: s.Name, Namespace: namespaceOr(sc.Spec.DevURLFrom.Namespace, sc.Namespace)},
+			sc.NamespacedName(),
+			s.Key,
+		)
+	}
+	for _, c := range sc.Spec.Policy.Lint.Rules {
 		r.configMapWatcher.Watch(
 			types.NamespacedName{Name: c.Name, Namespace: sc.Namespace},
 			sc.NamespacedName(),
+			c.Key,
 		)
 	}
-	if s := sc.Spec.URLFrom.SecretKeyRef; s != nil {
+	for _, v := range sc.Spec.Vars {
+		if s := v.ValueFrom.SecretKeyRef; s != nil {
+			r.secretWatcher.Watch(
+				types.NamespacedName{Name: s.Name, Namespace: sc.Namespace},
+				sc.NamespacedName(),
+				s.Key,
+			)
+		}
+		if c := v.ValueFrom.ConfigMapKeyRef; c != nil {
+			r.configMapWatcher.Watch(
+				types.NamespacedName{Name: c.Name, Namespace: sc.Namespace},
+				sc.NamespacedName(),
+				c.Key,
+			)
+		}
+	}
+	if c := sc.Spec.Schema.ConfigMapRef; c != nil {
+		r.configMapWatcher.Watch(
+			types.NamespacedName{Name: c.Name, Namespace: sc.Namespace},
+			sc.NamespacedName(),
+		)
+	}
+	if g := sc.Spec.Schema.Git; g != nil && g.PasswordFrom.SecretKeyRef != nil {
+		r.secretWatcher.Watch(
+			types.NamespacedName{Name: g.PasswordFrom.SecretKeyRef.Name, Namespace: sc.Namespace},
+			sc.NamespacedName(),
+			g.PasswordFrom.SecretKeyRef.Key,
+		)
+	}
+	if reg := sc.Spec.Schema.Registry; reg != nil && reg.TokenFrom.SecretKeyRef != nil {
+		r.secretWatcher.Watch(
+			types.NamespacedName{Name: reg.TokenFrom.SecretKeyRef.Name, Namespace: sc.Namespace},
+			sc.NamespacedName(),
+			reg.TokenFrom.SecretKeyRef.Key,
+		)
+	}
+	if s := sc.Spec.Schema.AuthHeaderFrom.SecretKeyRef; s != nil {
 		r.secretWatcher.Watch(
 			types.NamespacedName{Name: s.Name, Namespace: sc.Namespace},
 			sc.NamespacedName(),
+			s.Key,
+		)
+	}
+	if p := sc.Spec.Preview; p != nil && p.TokenFrom.SecretKeyRef != nil {
+		r.secretWatcher.Watch(
+			types.NamespacedName{Name: p.TokenFrom.SecretKeyRef.Name, Namespace: sc.Namespace},
+			sc.NamespacedName(),
+			p.TokenFrom.SecretKeyRef.Key,
+		)
+	}
+	if s := sc.Spec.URLFrom.SecretKeyRef; s != nil {
+		r.secretWatcher.Watch(
+			types.NamespacedName{Name: s.Name, Namespace: namespaceOr(sc.Spec.URLFrom.Namespace, sc.Namespace)},
+			sc.NamespacedName(),
+			s.Key,
 		)
 	}
 	if s := sc.Spec.Credentials.PasswordFrom.SecretKeyRef; s != nil {
 		r.secretWatcher.Watch(
 			types.NamespacedName{Name: s.Name, Namespace: sc.Namespace},
 			sc.NamespacedName(),
+			s.Key,
 		)
 	}
+	if t := sc.Spec.Credentials.TLS; t != nil {
+		if s := t.CAFrom.SecretKeyRef; s != nil {
+			r.secretWatcher.Watch(
+				types.NamespacedName{Name: s.Name, Namespace: sc.Namespace},
+				sc.NamespacedName(),
+				s.Key,
+			)
+		}
+		if c := t.CAFrom.ConfigMapKeyRef; c != nil {
+			r.configMapWatcher.Watch(
+				types.NamespacedName{Name: c.Name, Namespace: sc.Namespace},
+				sc.NamespacedName(),
+				c.Key,
+			)
+		}
+	}
 }
 
 // Clean up any resources created by the controller
@@ -280,12 +863,12 @@ func (r *AtlasSchemaReconciler) cleanUp(ctx context.Context, sc *dbv1alpha1.Atla
 	if err := r.List(ctx, pods, client.MatchingLabels(map[string]string{
 		"app.kubernetes.io/instance": sc.Name + devDBSuffix,
 	})); err != nil {
-		r.recorder.Eventf(sc, corev1.EventTypeWarning, "CleanUpDevDB", "Error listing devDB pods: %v", err)
+		r.recorder.Eventf(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonCleanUpDevDB, "Error listing devDB pods: %v", err)
 	}
 	for _, p := range pods.Items {
 		err := r.Delete(ctx, &p)
 		if err != nil {
-			r.recorder.Eventf(sc, corev1.EventTypeWarning, "CleanUpDevDB", "Error deleting devDB pod %s: %v", p.Name, err)
+			r.recorder.Eventf(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonCleanUpDevDB, "Error deleting devDB pod %s: %v", p.Name, err)
 		}
 	}
 
@@ -297,15 +880,24 @@ func (r *AtlasSchemaReconciler) url(ctx context.Context, sch *dbv1alpha1.AtlasSc
 	case s.URL != "":
 		us = s.URL
 	case s.URLFrom.SecretKeyRef != nil:
-		sec, err := getSecretValue(ctx, r, sch.Namespace, *s.URLFrom.SecretKeyRef)
+		ns, err := refNamespace(ctx, r, sch, "AtlasSchema", s.URLFrom.Namespace, "Secret", s.URLFrom.SecretKeyRef.Name)
 		if err != nil {
-			r.recorder.Eventf(sch, corev1.EventTypeWarning, "GetURL", "Error getting URL from secret %s: %v", s.URLFrom.SecretKeyRef.Name, err)
-			return nil, transient(err)
+			return nil, err
+		}
+		sec, err := urlFromSecret(ctx, r, ns, s.URLFrom)
+		if err != nil {
+			r.recorder.Eventf(sch, corev1.EventTypeWarning, dbv1alpha1.ReasonGetURL, "Error getting URL from secret %s: %v", s.URLFrom.SecretKeyRef.Name, err)
+			return nil, err
 		}
 		us = sec
 	case s.Credentials.Host != "":
+		if err := checkAzureAD(r.allowAzureAD, s.Credentials); err != nil {
+			return nil, err
+		}
 		if err := hydrateCredentials(ctx, &s.Credentials, r, sch.Namespace); err != nil {
-			r.recorder.Eventf(sch, corev1.EventTypeWarning, "GetPassword", "Error getting password from secret %s: %v", s.Credentials.PasswordFrom.SecretKeyRef.Name, err)
+			if p := s.Credentials.PasswordFrom.SecretKeyRef; p != nil {
+				r.recorder.Eventf(sch, corev1.EventTypeWarning, dbv1alpha1.ReasonGetPassword, "Error getting password from secret %s: %v", p.Name, err)
+			}
 			return nil, err
 		}
 		return s.Credentials.URL(), nil
@@ -316,87 +908,41 @@ func (r *AtlasSchemaReconciler) url(ctx context.Context, sch *dbv1alpha1.AtlasSc
 }
 
 func (r *AtlasSchemaReconciler) devDBDeployment(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed) (*v1.Deployment, error) {
-	d := &v1.Deployment{}
-	v := devDB{
-		Name:        sc.Name + devDBSuffix,
-		Namespace:   sc.Namespace,
-		Driver:      m.driver,
-		UID:         1000,
-		SchemaBound: m.schemaBound(),
-	}
-	switch m.driver {
-	case "postgres":
-		v.Port = 5432
-		v.UID = 999
-		v.DB = "postgres"
-	case "mysql":
-		v.Port = 3306
-		if v.SchemaBound {
-			v.DB = "dev"
-		}
-	}
-	var b bytes.Buffer
-	if err := tmpl.ExecuteTemplate(&b, "devdb.tmpl", &v); err != nil {
-		return nil, err
-	}
-	if err := yaml.NewYAMLToJSONDecoder(&b).Decode(d); err != nil {
+	if err := checkDevDBImage(r.allowedImages, m.devDB); err != nil {
 		return nil, err
 	}
-	if err := ctrl.SetControllerReference(sc, d, r.scheme); err != nil {
+	d, err := newDevDB(sc, r.scheme, sc.Name+devDBSuffix, m.devScheme(), m.schemaBound(), m.devDB)
+	if err != nil {
 		return nil, err
 	}
 	if err := r.Create(ctx, d); err != nil {
 		return nil, transient(err)
 	}
-	r.recorder.Eventf(sc, corev1.EventTypeNormal, "CreatedDevDB", "Created dev database deployment: %s", d.Name)
+	r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonCreatedDevDB, "Created dev database deployment: %s", d.Name)
 	return d, nil
 }
 
-func (r *AtlasSchemaReconciler) devURL(ctx context.Context, name, driver string) (string, error) {
-	if driver == "sqlite" {
+func (r *AtlasSchemaReconciler) devURL(ctx context.Context, name string, m *managed) (string, error) {
+	switch {
+	case m.devURL != "":
+		return m.devURL, nil
+	case m.driver == "sqlite":
 		return "sqlite://db?mode=memory", nil
 	}
-	pods := &corev1.PodList{}
-	if err := r.List(ctx, pods, client.MatchingLabels(map[string]string{
-		"app.kubernetes.io/instance": name + devDBSuffix,
-		"atlasgo.io/engine":          driver,
-	})); err != nil {
-		return "", transient(err)
-	}
-	if len(pods.Items) == 0 {
-		return "", transient(
-			errors.New("no pods found"),
-		)
-	}
-	idx := slices.IndexFunc(pods.Items, func(p corev1.Pod) bool {
-		return p.Status.Phase == corev1.PodRunning
-	})
-	if idx == -1 {
-		return "", transient(
-			errors.New("no running pods found"),
-		)
-	}
-	pod := pods.Items[idx]
-	ct, ok := pod.Annotations["atlasgo.io/conntmpl"]
-	if !ok {
-		// If the connection template is not found, there is an issue with the pod spec and the error
-		// is not transient.
-		return "", errors.New("no connection template label found")
+	u, err := devDBPodURL(ctx, r, name+devDBSuffix, m.driver)
+	if err != nil {
+		return "", err
 	}
-	return strings.ReplaceAll(
-		ct,
-		hostReplace,
-		fmt.Sprintf("%s:%d", pod.Status.PodIP, pod.Spec.Containers[0].Ports[0].ContainerPort),
-	), nil
+	return devScope(u, m)
 }
 
 func driver(scheme string) string {
 	switch {
-	case strings.HasPrefix(scheme, "mysql"):
+	case strings.HasPrefix(scheme, "mysql"), scheme == "mariadb":
 		return "mysql"
 	case strings.HasPrefix(scheme, "postgres"):
 		return "postgres"
-	case scheme == "sqlite":
+	case scheme == "sqlite", isLibSQL(scheme):
 		return "sqlite"
 	default:
 		return ""
@@ -404,13 +950,13 @@ func driver(scheme string) string {
 }
 
 func (r *AtlasSchemaReconciler) apply(ctx context.Context, des *managed, devURL string) (*atlas.SchemaApply, error) {
-	file, clean, err := atlas.TempFile(des.desired, des.ext)
+	file, clean, err := des.desiredURL()
 	if err != nil {
 		return nil, err
 	}
 	defer clean()
 	apply, err := r.cli.SchemaApply(ctx, &atlas.SchemaApplyParams{
-		URL:       des.url.String(),
+		URL:       des.applyURL(),
 		To:        file,
 		DevURL:    devURL,
 		Exclude:   des.exclude,
@@ -427,8 +973,12 @@ func (r *AtlasSchemaReconciler) apply(ctx context.Context, des *managed, devURL
 }
 
 func (d *devDB) ConnTmpl() string {
+	scheme := d.Scheme
+	if scheme == "" {
+		scheme = d.Driver
+	}
 	u := url.URL{
-		Scheme: d.Driver,
+		Scheme: scheme,
 		User:   url.UserPassword("root", "pass"),
 		Host:   hostReplace,
 		Path:   d.DB,
@@ -440,6 +990,90 @@ func (d *devDB) ConnTmpl() string {
 	return u.String()
 }
 
+// previewBranch returns the preview branch of the desired schema, creating it
+// if it does not exist, and points the managed URL to it. A nil branch is
+// returned if the branch is not ready yet.
+func (r *AtlasSchemaReconciler) previewBranch(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed) (*previewBranch, error) {
+	p := sc.Spec.Preview
+	if p.TokenFrom.SecretKeyRef == nil {
+		return nil, errors.New("preview.tokenFrom.secretKeyRef must be set")
+	}
+	token, err := getSecretValue(ctx, r, sc.Namespace, *p.TokenFrom.SecretKeyRef)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := r.branches(&branch.Config{
+		Provider:     p.Provider,
+		Token:        token,
+		Organization: p.Organization,
+		Project:      p.Project,
+		Database:     p.Database,
+		Role:         p.Role,
+		ParentBranch: p.ParentBranch,
+	})
+	if err != nil {
+		return nil, err
+	}
+	name := previewName(sc, m)
+	b, err := provider.Get(ctx, name)
+	if errors.Is(err, branch.ErrNotFound) {
+		b, err = provider.Create(ctx, name)
+		if err == nil {
+			r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonPreviewBranchCreated, "Created preview branch %s", name)
+		}
+	}
+	if err != nil {
+		return nil, transient(err)
+	}
+	if !b.Ready {
+		return nil, nil
+	}
+	u, err := provider.URL(ctx, b)
+	if err != nil {
+		return nil, transient(err)
+	}
+	if m.url, err = url.Parse(u); err != nil {
+		return nil, err
+	}
+	if m.url, err = withSession(m.url, sc.Spec.Session); err != nil {
+		return nil, err
+	}
+	return &previewBranch{provider: provider, branch: b}, nil
+}
+
+// completePreview reports the changes applied to the preview branch, and then
+// promotes or deletes the branch according to the preview policy.
+func (r *AtlasSchemaReconciler) completePreview(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed, pb *previewBranch, app *atlas.SchemaApply) error {
+	status := &dbv1alpha1.PreviewStatus{
+		Branch:  pb.branch.Name,
+		Outcome: "kept",
+	}
+	if !m.secret {
+		status.Diff = app.Changes.Applied
+	}
+	switch sc.Spec.Preview.OnComplete {
+	case "promote":
+		if err := pb.provider.Promote(ctx, pb.branch); err != nil {
+			return transient(err)
+		}
+		status.Outcome = "promoted"
+	case "delete":
+		if err := pb.provider.Delete(ctx, pb.branch); err != nil {
+			return transient(err)
+		}
+		status.Outcome = "deleted"
+	}
+	sc.Status.Preview = status
+	r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonPreviewApplied,
+		"Applied %d statements to preview branch %s (%s)", len(app.Changes.Applied), status.Branch, status.Outcome)
+	return nil
+}
+
+// previewName returns the name of the preview branch of the desired schema.
+func previewName(sc *dbv1alpha1.AtlasSchema, m *managed) string {
+	return fmt.Sprintf("atlas-%s-%s", strings.ReplaceAll(sc.Name, ".", "-"), m.hash()[:8])
+}
+
 // extractManaged extracts the info about the managed database and its desired state.
 func (r *AtlasSchemaReconciler) extractManaged(ctx context.Context, sc *dbv1alpha1.AtlasSchema) (*managed, error) {
 	var d managed
@@ -450,71 +1084,392 @@ func (r *AtlasSchemaReconciler) extractManaged(ctx context.Context, sc *dbv1alph
 	case sch.SQL != "":
 		d.desired = sch.SQL
 		d.ext = "sql"
+	case sch.SchemaRef != nil:
+		ref := &dbv1alpha1.AtlasSchema{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: sc.Namespace, Name: sch.SchemaRef.Name}, ref); err != nil {
+			return nil, transient(err)
+		}
+		if ref.Spec.Schema.SchemaRef != nil {
+			return nil, fmt.Errorf("atlasschema %s/%s must not reference another schema", sc.Namespace, ref.Name)
+		}
+		// Resolve the referenced schema against the target of this resource.
+		src := sc.DeepCopy()
+		src.Spec.Schema = ref.Spec.Schema
+		// Changes to the sources of the referenced schema trigger this resource as well.
+		r.watch(src)
+		return r.extractManaged(ctx, src)
 	case sch.ConfigMapKeyRef != nil:
+		ns, err := refNamespace(ctx, r, sc, "AtlasSchema", sch.Namespace, "ConfigMap", sch.ConfigMapKeyRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		if d.desired, d.ext, err = r.configMapKey(ctx, ns, sch.ConfigMapKeyRef); err != nil {
+			return nil, err
+		}
+	case sch.SecretKeyRef != nil:
+		if d.ext = fileExt(sch.SecretKeyRef.Key); d.ext == "" {
+			return nil, fmt.Errorf("unsupported secret key %s", sch.SecretKeyRef.Key)
+		}
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: sc.Namespace, Name: sch.SecretKeyRef.Name}, secret); err != nil {
+			return nil, transient(err)
+		}
+		content, ok := secret.Data[sch.SecretKeyRef.Key]
+		if !ok {
+			return nil, fmt.Errorf("secret %s/%s does not contain key %s", sc.Namespace, sch.SecretKeyRef.Name, sch.SecretKeyRef.Key)
+		}
+		d.desired, d.secret = string(content), true
+	case len(sch.Layers) > 0:
+		layers := make([]string, len(sch.Layers))
+		for i, l := range sch.Layers {
+			var ext string
+			switch {
+			case l.HCL != "":
+				layers[i], ext = l.HCL, "hcl"
+			case l.SQL != "":
+				layers[i], ext = l.SQL, "sql"
+			case l.ConfigMapKeyRef != nil:
+				var err error
+				if layers[i], ext, err = r.configMapKey(ctx, sc.Namespace, l.ConfigMapKeyRef); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("schema layer %d is empty", i)
+			}
+			if d.ext != "" && d.ext != ext {
+				return nil, fmt.Errorf("schema layer %d is %s, expected %s", i, ext, d.ext)
+			}
+			d.ext = ext
+		}
+		d.desired = strings.Join(layers, "\n")
+	case sch.ConfigMapRef != nil:
 		cm := &corev1.ConfigMap{}
 		if err := r.Get(ctx, types.NamespacedName{
 			Namespace: sc.Namespace,
-			Name:      sch.ConfigMapKeyRef.Name,
+			Name:      sch.ConfigMapRef.Name,
 		}, cm); err != nil {
 			return nil, transient(err)
 		}
-		var ok bool
-		k := sch.ConfigMapKeyRef.Key
-		d.desired, ok = cm.Data[k]
-		if !ok {
-			return nil, fmt.Errorf("configmap %s/%s does not contain key %s", sc.Namespace, sch.ConfigMapKeyRef.Name, k)
+		if len(cm.Data) == 0 {
+			return nil, fmt.Errorf("configmap %s/%s is empty", sc.Namespace, sch.ConfigMapRef.Name)
 		}
-		switch {
-		case strings.HasSuffix(k, ".hcl"):
-			d.ext = "hcl"
-		case strings.HasSuffix(k, ".sql"):
+		for k := range cm.Data {
+			switch ext := fileExt(k); {
+			case ext == "":
+				return nil, fmt.Errorf("unsupported configmap key %s", k)
+			case d.ext != "" && d.ext != ext:
+				return nil, fmt.Errorf("configmap %s/%s mixes .hcl and .sql keys", sc.Namespace, sch.ConfigMapRef.Name)
+			default:
+				d.ext = ext
+			}
+		}
+		d.files = cm.Data
+	case sch.Git != nil:
+		if d.ext = fileExt(sch.Git.Path); d.ext == "" {
+			return nil, fmt.Errorf("unsupported git path %s", sch.Git.Path)
+		}
+		params := &git.ReadFileParams{
+			Repo: sch.Git.Repo,
+			Ref:  sch.Git.Ref,
+			Path: sch.Git.Path,
+			User: sch.Git.User,
+		}
+		if s := sch.Git.PasswordFrom.SecretKeyRef; s != nil {
+			var err error
+			if params.Password, err = getSecretValue(ctx, r, sc.Namespace, *s); err != nil {
+				return nil, err
+			}
+		}
+		f, err := r.git.ReadFile(ctx, params)
+		if err != nil {
+			return nil, transient(err)
+		}
+		d.desired, d.commit = f.Content, f.Commit
+	case sch.URL != "":
+		su, err := url.Parse(sch.URL)
+		if err != nil {
+			return nil, err
+		}
+		if su.Scheme != "https" {
+			return nil, fmt.Errorf("schema url must use https, got %q", su.Scheme)
+		}
+		if d.ext = fileExt(su.Path); d.ext == "" {
+			return nil, fmt.Errorf("unsupported schema url path %s", su.Path)
+		}
+		var auth string
+		if s := sch.AuthHeaderFrom.SecretKeyRef; s != nil {
+			if auth, err = getSecretValue(ctx, r, sc.Namespace, *s); err != nil {
+				return nil, err
+			}
+		}
+		if d.desired, err = fetchURL(ctx, r.httpClient, sch.URL, auth); err != nil {
+			return nil, err
+		}
+		if err := verifyDigest(d.desired, sch.Digest); err != nil {
+			return nil, err
+		}
+	case sch.External != nil:
+		var err error
+		if d.desired, err = r.externalSchema(ctx, sc, sch.External); err != nil {
+			return nil, err
+		}
+		if d.ext = sch.External.Format; d.ext == "" {
 			d.ext = "sql"
-		default:
-			return nil, fmt.Errorf("unsupported configmap key %s", k)
 		}
+	case sch.Registry != nil:
+		if sch.Registry.TokenFrom.SecretKeyRef == nil {
+			return nil, errors.New("schema.registry.tokenFrom.secretKeyRef must be set")
+		}
+		// The schema is read by the CLI, so there is nothing to substitute into.
+		if len(sc.Spec.Vars) > 0 {
+			return nil, errors.New("vars cannot be set with schema.registry, the schema is read by the Atlas CLI")
+		}
+		token, err := getSecretValue(ctx, r, sc.Namespace, *sch.Registry.TokenFrom.SecretKeyRef)
+		if err != nil {
+			return nil, err
+		}
+		d.cloud = &cloud{URL: sch.Registry.URL, Token: token}
+		d.to = registryURL(sch.Registry)
 	default:
 		return nil, fmt.Errorf("no desired schema specified")
 	}
-	u, err := r.url(ctx, sc)
-	if err != nil {
+	if err := r.substituteVars(ctx, sc, &d); err != nil {
 		return nil, err
 	}
-	d.url = u
-	d.driver = driver(u.Scheme)
+	switch p := sc.Spec.Preview; {
+	case p == nil:
+		u, err := r.url(ctx, sc)
+		if err != nil {
+			return nil, err
+		}
+		if u, err = withSession(u, sc.Spec.Session); err != nil {
+			return nil, err
+		}
+		if u, err = withAuthToken(ctx, r, sc.Namespace, u, sc.Spec.AuthTokenFrom); err != nil {
+			return nil, err
+		}
+		if u, d.tlsHash, err = withClientTLS(ctx, r, sc, "AtlasSchema", u, sc.Spec.TLSFrom); err != nil {
+			return nil, err
+		}
+		d.url = u
+		d.driver = driver(u.Scheme)
+	// The URL of the preview branch is resolved when the branch is ready.
+	case p.Provider == branch.PlanetScale:
+		d.driver = "mysql"
+	case p.Provider == branch.Neon:
+		d.driver = "postgres"
+	default:
+		return nil, fmt.Errorf("unsupported preview provider %q", p.Provider)
+	}
+	var err error
+	if d.devURL, err = specDevURL(ctx, r, sc, "AtlasSchema", sc.Spec.DevURL, sc.Spec.DevURLFrom); err != nil {
+		return nil, err
+	}
+	d.devDB = sc.Spec.DevDB
 	d.exclude = sc.Spec.Exclude
 	d.policy = sc.Spec.Policy
+	for _, sel := range sc.Spec.Policy.Lint.Rules {
+		rule, ext, err := r.configMapKey(ctx, sc.Namespace, &sel)
+		if err != nil {
+			return nil, err
+		}
+		if ext != "hcl" {
+			return nil, fmt.Errorf("lint rules key %s must be an .hcl file", sel.Key)
+		}
+		d.rules = append(d.rules, rule)
+		// The other keys of the configmap are the files read by the rule.
+		cm := &corev1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: sc.Namespace, Name: sel.Name}, cm); err != nil {
+			return nil, transient(err)
+		}
+		for k, v := range cm.Data {
+			if k == sel.Key {
+				continue
+			}
+			if k == configFileName {
+				return nil, fmt.Errorf("lint rules configmap %s cannot contain key %s", sel.Name, k)
+			}
+			if f, ok := d.ruleFiles[k]; ok && f != v {
+				return nil, fmt.Errorf("lint rules file %s is set by more than one configmap", k)
+			}
+			if d.ruleFiles == nil {
+				d.ruleFiles = make(map[string]string)
+			}
+			d.ruleFiles[k] = v
+		}
+	}
 	d.schemas = sc.Spec.Schemas
+	if d.url != nil {
+		schemas, err := checkScope(d.url, d.schemas)
+		if err != nil {
+			return nil, err
+		}
+		d.schemas = schemas
+	}
+	if v := sc.Spec.Vitess; v != nil && v.DDLStrategy != "" && !ddlStrategy.MatchString(v.DDLStrategy) {
+		return nil, fmt.Errorf("vitess.ddlStrategy: invalid strategy %q", v.DDLStrategy)
+	}
+	d.vitess = sc.Spec.Vitess
 	return &d, nil
 }
 
+// ddlStrategy matches the online DDL strategies of Vitess and their flags. The
+// strategy is quoted in a session variable, so quotes are never accepted.
+var ddlStrategy = regexp.MustCompile(`^(vitess|online|gh-ost|pt-osc|direct|mysql)( [-a-z0-9=_ ]*)?$`)
+
+// applyURL returns the URL used for applying the schema. For Vitess targets,
+// it sets the session variables used to submit the changes as online DDL.
+func (d *managed) applyURL() string {
+	if d.vitess == nil || d.migrationContext == "" {
+		return d.url.String()
+	}
+	strategy := d.vitess.DDLStrategy
+	if strategy == "" {
+		strategy = "vitess"
+	}
+	u := *d.url
+	q := u.Query()
+	q.Set("ddl_strategy", "'"+strategy+"'")
+	q.Set("migration_context", "'"+d.migrationContext+"'")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// substituteVars substitutes the variables of the schema, referenced as ${name},
+// into its desired state. Since the hash covers the desired state, a change to
+// the value of a variable triggers a new apply.
+func (r *AtlasSchemaReconciler) substituteVars(ctx context.Context, sc *dbv1alpha1.AtlasSchema, d *managed) error {
+	if len(sc.Spec.Vars) == 0 {
+		return nil
+	}
+	pairs := make([]string, 0, 2*len(sc.Spec.Vars))
+	for _, v := range sc.Spec.Vars {
+		value, err := varValue(ctx, r, sc.Namespace, v)
+		if err != nil {
+			return err
+		}
+		pairs = append(pairs, "${"+v.Name+"}", value)
+	}
+	rp := strings.NewReplacer(pairs...)
+	d.desired = rp.Replace(d.desired)
+	if d.files != nil {
+		files := make(map[string]string, len(d.files))
+		for name, content := range d.files {
+			files[name] = rp.Replace(content)
+		}
+		d.files = files
+	}
+	return nil
+}
+
+// configMapKey returns the content of the given configmap key, and its schema format.
+func (r *AtlasSchemaReconciler) configMapKey(ctx context.Context, ns string, sel *corev1.ConfigMapKeySelector) (string, string, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ns, Name: sel.Name}, cm); err != nil {
+		return "", "", transient(err)
+	}
+	content, ok := cm.Data[sel.Key]
+	if !ok {
+		return "", "", fmt.Errorf("configmap %s/%s does not contain key %s", ns, sel.Name, sel.Key)
+	}
+	ext := fileExt(sel.Key)
+	if ext == "" {
+		return "", "", fmt.Errorf("unsupported configmap key %s", sel.Key)
+	}
+	return content, ext, nil
+}
+
+// registryURL returns the atlas:// URL of the schema in the Atlas Cloud registry.
+func registryURL(reg *dbv1alpha1.Registry) string {
+	q := url.Values{}
+	switch {
+	case reg.Version != "":
+		q.Set("version", reg.Version)
+	case reg.Tag != "":
+		q.Set("tag", reg.Tag)
+	}
+	u := &url.URL{Scheme: "atlas", Host: reg.Project, RawQuery: q.Encode()}
+	return u.String()
+}
+
+// fileExt returns the schema format of the given file name, or an empty
+// string if it is not supported.
+func fileExt(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".hcl"):
+		return "hcl"
+	case strings.HasSuffix(name, ".sql"):
+		return "sql"
+	default:
+		return ""
+	}
+}
+
+// desiredURL writes the desired schema to a temporary file, or to a temporary
+// directory for multi-file schemas, and returns its URL.
+func (d *managed) desiredURL() (string, func() error, error) {
+	if d.to != "" {
+		return d.to, func() error { return nil }, nil
+	}
+	if d.files == nil {
+		return atlas.TempFile(d.desired, d.ext)
+	}
+	dir, err := os.MkdirTemp("", "atlas-k8s-*")
+	if err != nil {
+		return "", nil, err
+	}
+	for name, content := range d.files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			os.RemoveAll(dir)
+			return "", nil, err
+		}
+	}
+	return "file://" + dir, func() error {
+		return os.RemoveAll(dir)
+	}, nil
+}
+
 // hash returns the sha256 hash of the desired.
 func (d *managed) hash() string {
 	h := sha256.New()
 	h.Write([]byte(d.desired))
+	h.Write([]byte(d.to))
+	names := make([]string, 0, len(d.files))
+	for name := range d.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte(d.files[name]))
+	}
+	h.Write([]byte(d.tlsHash))
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// devScheme returns the scheme of the dev database of the managed database.
+func (d *managed) devScheme() string {
+	if d.url == nil {
+		return d.driver
+	}
+	return devDBScheme(d.url)
+}
+
 func (d *managed) schemaBound() bool {
 	switch {
 	case d.driver == "sqlite":
 		return true
-	case d.driver == "postgres":
-		if d.url.Query().Get("search_path") != "" {
-			return true
-		}
-	case d.driver == "mysql":
-		if d.url.Path != "" {
-			return true
-		}
+	case d.url == nil || len(d.schemas) > 0:
+		return false
 	}
-	return false
+	return urlSchema(d.url) != ""
 }
 
 func setNotReady(sc *dbv1alpha1.AtlasSchema, reason, msg string) {
 	meta.SetStatusCondition(
 		&sc.Status.Conditions,
 		metav1.Condition{
-			Type:    schemaReadyCond,
+			Type:    dbv1alpha1.SchemaReadyCond,
 			Status:  metav1.ConditionFalse,
 			Reason:  reason,
 			Message: msg,
@@ -526,20 +1481,31 @@ func setReady(sc *dbv1alpha1.AtlasSchema, des *managed, apply *atlas.SchemaApply
 	var msg string
 	if j, err := json.Marshal(apply); err != nil {
 		msg = fmt.Sprintf("Error marshalling apply response: %v", err)
+	} else if des.secret {
+		msg = "The schema has been applied successfully"
 	} else {
 		msg = fmt.Sprintf("The schema has been applied successfully. Apply response: %s", j)
 	}
 	meta.SetStatusCondition(
 		&sc.Status.Conditions,
 		metav1.Condition{
-			Type:    schemaReadyCond,
+			Type:    dbv1alpha1.SchemaReadyCond,
 			Status:  metav1.ConditionTrue,
-			Reason:  "Applied",
+			Reason:  dbv1alpha1.ReasonApplied,
 			Message: msg,
 		},
 	)
 	sc.Status.ObservedHash = des.hash()
+	sc.Status.ObservedCommit = des.commit
+	sc.Status.ObservedTarget = targetID(des.url)
 	sc.Status.LastApplied = time.Now().Unix()
+	if apply != nil && len(apply.Changes.Applied) > 0 {
+		c := dbv1alpha1.AppliedChange{Time: metav1.Unix(sc.Status.LastApplied, 0)}
+		if !des.secret {
+			c.Statements = apply.Changes.Applied
+		}
+		sc.Status.History = dbv1alpha1.AppendHistory(sc.Status.History, c)
+	}
 }
 
 func (d destructiveErr) Error() string {
@@ -552,16 +1518,59 @@ func (d destructiveErr) Error() string {
 }
 
 // shouldLint reports if the schema has a policy that requires linting.
+// Analyzers set to warn require it too, as their findings are reported.
 func shouldLint(des *managed) bool {
-	return des.policy.Lint.Destructive.Error
+	return des.policy.Lint.Destructive.Error || len(policyAnalyzers(des.policy.Lint)) > 0 ||
+		len(des.rules) > 0 || des.policy.Lint.Report != nil ||
+		des.policy.Review == dbv1alpha1.ReviewWarning || des.policy.Review == dbv1alpha1.ReviewError
+}
+
+// confData is the data used to render the conf.tmpl template.
+type confData struct {
+	dbv1alpha1.Policy
+	// Rules are custom lint rule definitions rendered into the lint block.
+	Rules []string
 }
 
-func configFile(policy dbv1alpha1.Policy) (string, func() error, error) {
+// configFileName is the name of the config file, when it is written to a
+// directory with the files of the lint rules.
+const configFileName = "atlas.hcl"
+
+// configFile renders the Atlas config file of the schema. The cloud block is
+// set when the desired schema is read from the Atlas Cloud registry. The
+// files of the lint rules are resolved by the CLI relative to the config file,
+// so they are written to the same directory.
+func configFile(des *managed) (string, func() error, error) {
 	var buf bytes.Buffer
-	if err := tmpl.ExecuteTemplate(&buf, "conf.tmpl", policy); err != nil {
+	if des.cloud != nil {
+		if err := tmpl.ExecuteTemplate(&buf, "cloud.tmpl", des.cloud); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := tmpl.ExecuteTemplate(&buf, "conf.tmpl", confData{Policy: des.policy, Rules: des.rules}); err != nil {
 		return "", nil, err
 	}
-	return atlas.TempFile(buf.String(), "hcl")
+	if len(des.ruleFiles) == 0 {
+		return atlas.TempFile(buf.String(), "hcl")
+	}
+	dir, err := os.MkdirTemp("", "atlas-k8s-*")
+	if err != nil {
+		return "", nil, err
+	}
+	clean := func() error {
+		return os.RemoveAll(dir)
+	}
+	files := map[string]string{configFileName: buf.String()}
+	for name, content := range des.ruleFiles {
+		files[name] = content
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			clean()
+			return "", nil, err
+		}
+	}
+	return "file://" + filepath.Join(dir, configFileName), clean, nil
 }
 
 // transientErr is an error that should be retried.
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/azuread"
+)
+
+// azureTokenSource acquires the Azure AD tokens of credentials.azureAD.
+type azureTokenSource interface {
+	Token(ctx context.Context, cfg azuread.Config, scope string) (string, error)
+	Invalidate(cfg azuread.Config, scope string)
+}
+
+// azureTokens caches the tokens of all resources, as they are shared by the
+// resources of an identity.
+var azureTokens azureTokenSource = azuread.New()
+
+// azureConfig returns the identity of the credentials, defaulting to the
+// workload identity of the operator.
+func azureConfig(a *dbv1alpha1.AzureAD) azuread.Config {
+	cfg := azuread.ConfigFromEnv()
+	if a.ClientID != "" {
+		cfg.ClientID = a.ClientID
+	}
+	if a.TenantID != "" {
+		cfg.TenantID = a.TenantID
+	}
+	return cfg
+}
+
+// checkAzureAD returns an error if the credentials authenticate with Azure AD
+// tokens, and the operator does not allow it. Tokens are acquired with the
+// identity of the operator, so they are usable by the resources of any namespace.
+func checkAzureAD(allow bool, creds dbv1alpha1.Credentials) error {
+	if creds.AzureAD != nil && !allow {
+		return errors.New("credentials.azureAD is disabled, tokens are acquired with the identity of the operator and require starting it with --allow-azure-ad")
+	}
+	return nil
+}
+
+// azureToken sets the token as the password of the credentials. MySQL servers
+// accept it only as a cleartext password, which is sent over TLS only.
+func azureToken(ctx context.Context, creds *dbv1alpha1.Credentials) error {
+	d := driver(creds.Scheme)
+	if d != "postgres" && d != "mysql" {
+		return fmt.Errorf("credentials.azureAD is supported for postgres and mysql only, got %q", creds.Scheme)
+	}
+	if d == "mysql" {
+		if creds.Parameters["tls"] == "false" {
+			return errors.New("credentials.azureAD requires TLS on mysql, the tls parameter cannot be false")
+		}
+		params := make(map[string]string, len(creds.Parameters)+2)
+		for k, v := range creds.Parameters {
+			params[k] = v
+		}
+		if _, ok := params["tls"]; !ok {
+			params["tls"] = "true"
+		}
+		params["allowCleartextPasswords"] = "true"
+		creds.Parameters = params
+	}
+	token, err := azureTokens.Token(ctx, azureConfig(creds.AzureAD), azuread.ScopeOSSRDBMS)
+	if err != nil {
+		return transient(err)
+	}
+	creds.Password = token
+	return nil
+}
+
+// refreshAzureToken drops the cached token of the credentials if the database
+// refused it, and returns the error as transient, so the next attempt
+// authenticates with a new token.
+func refreshAzureToken(creds dbv1alpha1.Credentials, err error) error {
+	if err == nil || creds.AzureAD == nil || !isAuthErr(err) {
+		return err
+	}
+	azureTokens.Invalidate(azureConfig(creds.AzureAD), azuread.ScopeOSSRDBMS)
+	return transient(err)
+}
+
+// isAuthErr reports if the database refused the credentials, e.g. as their
+// token expired.
+func isAuthErr(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"password authentication failed",
+		"access denied for user",
+		"token is expired",
+		"token has expired",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/azuread"
+)
+
+type mockTokens struct {
+	issued      int
+	invalidated []string
+}
+
+func (m *mockTokens) Token(_ context.Context, cfg azuread.Config, scope string) (string, error) {
+	m.issued++
+	return fmt.Sprintf("%s-%d", cfg.ClientID, m.issued), nil
+}
+
+func (m *mockTokens) Invalidate(cfg azuread.Config, scope string) {
+	m.invalidated = append(m.invalidated, cfg.ClientID+" "+scope)
+}
+
+func TestReconcile_AzureAD(t *testing.T) {
+	tokens := &mockTokens{}
+	prev := azureTokens
+	azureTokens = tokens
+	t.Cleanup(func() { azureTokens = prev })
+	tt := newTest(t)
+	sc := conditionReconciling()
+	sc.Spec.URL = ""
+	sc.Spec.Credentials = dbv1alpha1.Credentials{
+		Scheme:     "postgres",
+		User:       "atlas-operator",
+		Host:       "db.postgres.database.azure.com",
+		Port:       5432,
+		Database:   "test",
+		Parameters: map[string]string{"sslmode": "require"},
+		AzureAD:    &dbv1alpha1.AzureAD{ClientID: "client"},
+	}
+	tt.k8s.put(sc)
+	tt.k8s.put(devDBUnbound())
+
+	// Tokens are acquired only if the operator allows it.
+	_, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.Zero(t, tokens.issued)
+	sc = tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema)
+	require.Contains(t, sc.Status.Conditions[0].Message, "credentials.azureAD is disabled")
+	tt.r.allowAzureAD = true
+
+	// The token is used as the password.
+	tt.k8s.put(devDBUnbound())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	runs := tt.mockCLI().applyRuns
+	u, err := url.Parse(runs[len(runs)-1].URL)
+	require.NoError(t, err)
+	pass, _ := u.User.Password()
+	require.Equal(t, fmt.Sprintf("client-%d", tokens.issued), pass)
+	require.Empty(t, tokens.invalidated)
+
+	// Refused tokens are invalidated, and the reconcile is retried.
+	tt.mockCLI().applyErr = errors.New(`pq: password authentication failed for user "atlas-operator"`)
+	sc = tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema)
+	sc.Status.ObservedHash = ""
+	tt.k8s.put(devDBUnbound())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	res, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.Equal(t, ctrl.Result{RequeueAfter: 5 * time.Second}, res)
+	require.Equal(t, []string{"client " + azuread.ScopeOSSRDBMS}, tokens.invalidated)
+}
+
+func TestAzureToken(t *testing.T) {
+	tokens := &mockTokens{}
+	prev := azureTokens
+	azureTokens = tokens
+	t.Cleanup(func() { azureTokens = prev })
+	ctx := context.Background()
+
+	// MySQL servers accept the token as a cleartext password over TLS.
+	creds := dbv1alpha1.Credentials{
+		Scheme:  "mysql",
+		Host:    "db.mysql.database.azure.com",
+		AzureAD: &dbv1alpha1.AzureAD{ClientID: "client"},
+	}
+	require.NoError(t, azureToken(ctx, &creds))
+	require.Equal(t, "client-1", creds.Password)
+	require.Equal(t, map[string]string{"tls": "true", "allowCleartextPasswords": "true"}, creds.Parameters)
+	creds.Parameters = map[string]string{"tls": "false"}
+	require.EqualError(t, azureToken(ctx, &creds), "credentials.azureAD requires TLS on mysql, the tls parameter cannot be false")
+
+	creds.Scheme = "sqlserver"
+	require.EqualError(t, azureToken(ctx, &creds), `credentials.azureAD is supported for postgres and mysql only, got "sqlserver"`)
+	require.EqualError(t, checkAzureAD(false, creds), "credentials.azureAD is disabled, tokens are acquired with the identity of the operator and require starting it with --allow-azure-ad")
+	require.NoError(t, checkAzureAD(true, creds))
+
+	require.True(t, isAuthErr(errors.New("Error 1045 (28000): Access denied for user 'atlas'@'10.0.0.1'")))
+	require.False(t, isAuthErr(errors.New(`pq: relation "t" does not exist`)))
+}
+
+func TestAtlasMigrationData_hashAzureAD(t *testing.T) {
+	md := atlasMigrationData{URL: "postgres://atlas:token-1@db:5432/app", azureAD: true}
+	h1, err := md.hash()
+	require.NoError(t, err)
+	// Refreshed tokens do not change the hash.
+	md.URL = "postgres://atlas:token-2@db:5432/app"
+	h2, err := md.hash()
+	require.NoError(t, err)
+	require.Equal(t, h1, h2)
+	// Passwords that are not tokens do.
+	md.azureAD = false
+	h3, err := md.hash()
+	require.NoError(t, err)
+	require.NotEqual(t, h2, h3)
+}
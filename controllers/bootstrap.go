@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"ariga.io/atlas/sql/migrate"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+)
+
+// passwordPlaceholder replaces the password of the database URL in the
+// generated Secret, so the manifests can be committed.
+const passwordPlaceholder = "CHANGE_ME"
+
+type (
+	// BootstrapCLI is the interface used to inspect the existing database.
+	BootstrapCLI interface {
+		SchemaInspect(ctx context.Context, data *atlas.SchemaInspectParams) (string, error)
+	}
+	// BootstrapOptions defines the resources generated for an existing database.
+	BootstrapOptions struct {
+		// Name and Namespace of the generated resources.
+		Name, Namespace string
+		// URL of the existing database.
+		URL string
+		// DevURL of a dev database used to normalize the inspected schema.
+		DevURL string
+		// Kind of the generated resource: AtlasSchema or AtlasMigration.
+		Kind string
+		// Format of the desired schema of an AtlasSchema: sql or hcl.
+		Format string
+		// Schemas and Exclude limit the inspected objects.
+		Schemas, Exclude []string
+		// Now is the time used to version the baseline migration file.
+		Now time.Time
+	}
+)
+
+// Bootstrap inspects an existing database and returns the resources that manage
+// it in its current state: a Secret template holding its URL, and an AtlasSchema
+// whose desired schema is the inspected schema, or an AtlasMigration whose
+// directory holds a single baseline file of the inspected schema.
+func Bootstrap(ctx context.Context, cli BootstrapCLI, opts BootstrapOptions) ([]client.Object, error) {
+	if opts.Name == "" {
+		return nil, errors.New("bootstrap: name is required")
+	}
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap: invalid url: %w", err)
+	}
+	format := opts.Format
+	if opts.Kind == "AtlasMigration" {
+		format = "sql"
+	}
+	inspected, err := cli.SchemaInspect(ctx, &atlas.SchemaInspectParams{
+		URL:     opts.URL,
+		DevURL:  opts.DevURL,
+		Format:  format,
+		Schema:  opts.Schemas,
+		Exclude: opts.Exclude,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap: inspecting the database: %w", err)
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), passwordPlaceholder)
+	}
+	secret := &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: opts.Name + "-db-credentials", Namespace: opts.Namespace},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: map[string]string{"url": u.String()},
+	}
+	urlFrom := dbv1alpha1.URLFrom{
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+			Key:                  "url",
+		},
+	}
+	switch opts.Kind {
+	case "", "AtlasSchema":
+		sc := &dbv1alpha1.AtlasSchema{
+			TypeMeta:   metav1.TypeMeta{APIVersion: dbv1alpha1.GroupVersion.String(), Kind: "AtlasSchema"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name, Namespace: opts.Namespace},
+			Spec: dbv1alpha1.AtlasSchemaSpec{
+				URLFrom: urlFrom,
+				Schemas: opts.Schemas,
+				Exclude: opts.Exclude,
+			},
+		}
+		switch format {
+		case "", "sql":
+			sc.Spec.Schema.SQL = inspected
+		case "hcl":
+			sc.Spec.Schema.HCL = inspected
+		default:
+			return nil, fmt.Errorf("bootstrap: unsupported format %q", format)
+		}
+		return []client.Object{secret, sc}, nil
+	case "AtlasMigration":
+		version := opts.Now.UTC().Format("20060102150405")
+		dir := &migrate.MemDir{}
+		if err := dir.WriteFile(version+"_baseline.sql", []byte(inspected)); err != nil {
+			return nil, err
+		}
+		sum, err := dir.Checksum()
+		if err != nil {
+			return nil, err
+		}
+		b, err := sum.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		cm := &corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name + "-migrations", Namespace: opts.Namespace},
+			Data: map[string]string{
+				version + "_baseline.sql": inspected,
+				migrate.HashFileName:      string(b),
+			},
+		}
+		am := &dbv1alpha1.AtlasMigration{
+			TypeMeta:   metav1.TypeMeta{APIVersion: dbv1alpha1.GroupVersion.String(), Kind: "AtlasMigration"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name, Namespace: opts.Namespace},
+			Spec: dbv1alpha1.AtlasMigrationSpec{
+				URLFrom:  urlFrom,
+				Dir:      dbv1alpha1.Dir{ConfigMapRef: &corev1.LocalObjectReference{Name: cm.Name}},
+				Baseline: version,
+				Exclude:  opts.Exclude,
+			},
+		}
+		return []client.Object{secret, cm, am}, nil
+	default:
+		return nil, fmt.Errorf("bootstrap: unsupported kind %q", opts.Kind)
+	}
+}
+
+// WriteManifests writes the objects as a multi-document YAML stream.
+func WriteManifests(w io.Writer, objs []client.Object) error {
+	for i, o := range objs {
+		m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+		if err != nil {
+			return err
+		}
+		// Manifests describe the desired state of the objects only.
+		unstructured.RemoveNestedField(m, "metadata", "creationTimestamp")
+		unstructured.RemoveNestedField(m, "status")
+		pruneEmpty(m)
+		b, err := yaml.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneEmpty removes the empty objects left by the zero values of optional
+// struct fields, e.g. "credentials: {}".
+func pruneEmpty(m map[string]interface{}) {
+	for k, v := range m {
+		if o, ok := v.(map[string]interface{}); ok {
+			if pruneEmpty(o); len(o) == 0 {
+				delete(m, k)
+			}
+		}
+	}
+}
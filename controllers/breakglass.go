@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const (
+	// breakGlassAnnotation holds the justification of an emergency apply
+	// that bypasses approval.
+	breakGlassAnnotation = "db.atlasgo.io/break-glass"
+	// breakGlassVerb is the RBAC verb required to set the break-glass annotation.
+	breakGlassVerb = "break-glass"
+	// BreakGlassWebhookPath is the path the break-glass webhook is served on.
+	BreakGlassWebhookPath = "/validate-db-atlasgo-io-v1alpha1-atlasschema-break-glass"
+)
+
+var breakGlassApplies = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "atlas_operator_break_glass_total",
+	Help: "Number of emergency applies that bypassed approval.",
+}, []string{"namespace", "name"})
+
+func init() {
+	metrics.Registry.MustRegister(breakGlassApplies)
+}
+
+// breakGlass reports if the approval of the desired schema is bypassed by the
+// break-glass annotation. A justification bypasses approval of the desired
+// schema it was first observed with only. Later changes require approval again,
+// or a new justification.
+func (r *AtlasSchemaReconciler) breakGlass(sc *dbv1alpha1.AtlasSchema, m *managed) (bool, error) {
+	j, ok := sc.Annotations[breakGlassAnnotation]
+	if !ok {
+		return false, nil
+	}
+	if strings.TrimSpace(j) == "" {
+		return false, fmt.Errorf("the %s annotation requires a justification", breakGlassAnnotation)
+	}
+	bg := sc.Status.BreakGlass
+	if bg == nil || bg.Justification != j {
+		bg = &dbv1alpha1.BreakGlassStatus{
+			Justification: j,
+			ObservedHash:  m.hash(),
+			UsedAt:        metav1.Now(),
+		}
+		sc.Status.BreakGlass = bg
+		sc.Status.Approval = nil
+		meta.RemoveStatusCondition(&sc.Status.Conditions, dbv1alpha1.PendingApprovalCond)
+		meta.RemoveStatusCondition(&sc.Status.Conditions, dbv1alpha1.PlanOutdatedCond)
+		breakGlassApplies.WithLabelValues(sc.Namespace, sc.Name).Inc()
+		r.recorder.Eventf(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonBreakGlass, "Approval bypassed for an emergency apply: %s", j)
+	}
+	return bg.ObservedHash == m.hash(), nil
+}
+
+// breakGlassChanged triggers a reconcile when the break-glass annotation changes.
+var breakGlassChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.ObjectNew.GetAnnotations()[breakGlassAnnotation] != e.ObjectOld.GetAnnotations()[breakGlassAnnotation]
+	},
+}
+
+// BreakGlassValidator validates the break-glass annotation of AtlasSchema
+// resources. Setting it is allowed only to users granted the "break-glass"
+// verb on the resource, and the justification is added to the audit log.
+// Likewise, setting spec.approvedHash is allowed only to users granted the
+// "approve" verb.
+type BreakGlassValidator struct {
+	client client.Client
+}
+
+// NewBreakGlassValidator returns a new BreakGlassValidator.
+func NewBreakGlassValidator(c client.Client) *BreakGlassValidator {
+	return &BreakGlassValidator{client: c}
+}
+
+//+kubebuilder:webhook:path=/validate-db-atlasgo-io-v1alpha1-atlasschema-break-glass,mutating=false,failurePolicy=fail,sideEffects=None,groups=db.atlasgo.io,resources=atlasschemas,verbs=create;update,versions=v1alpha1,name=break-glass.atlasgo.io,admissionReviewVersions=v1
+
+// Handle implements admission.Handler.
+func (v *BreakGlassValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var old, cur dbv1alpha1.AtlasSchema
+	if err := json.Unmarshal(req.Object.Raw, &cur); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if req.Operation == admissionv1.Update {
+		if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+	if h := cur.Spec.ApprovedHash; h != "" && h != old.Spec.ApprovedHash {
+		if resp := authorize(ctx, v.client, req, &cur, approveVerb); !resp.Allowed {
+			return resp
+		}
+	}
+	j, ok := cur.Annotations[breakGlassAnnotation]
+	if prev, had := old.Annotations[breakGlassAnnotation]; !ok || had && j == prev {
+		return admission.Allowed("")
+	}
+	if strings.TrimSpace(j) == "" {
+		return admission.Denied(fmt.Sprintf("the %s annotation requires a justification", breakGlassAnnotation))
+	}
+	if resp := authorize(ctx, v.client, req, &cur, breakGlassVerb); !resp.Allowed {
+		return resp
+	}
+	resp := admission.Allowed(fmt.Sprintf("break-glass by %s", req.UserInfo.Username))
+	resp.AuditAnnotations = map[string]string{"break-glass-justification": j}
+	return resp
+}
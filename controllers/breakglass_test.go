@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func TestReconcile_BreakGlass(t *testing.T) {
+	tt := newTest(t)
+	tt.mockCLI().plan = "ALTER TABLE `foo` ADD COLUMN `bar` int"
+	sc := conditionReconciling()
+	sc.Status.LastApplied = 1
+	sc.Spec.Approval = &dbv1alpha1.Approval{}
+	tt.k8s.put(sc)
+	tt.k8s.put(devDBReady())
+	schema := func() *dbv1alpha1.AtlasSchema {
+		return tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema)
+	}
+	_, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.EqualValues(t, "ApprovalPending", tt.cond().Reason)
+
+	// A justification is required.
+	schema().Annotations = map[string]string{breakGlassAnnotation: " "}
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.EqualValues(t, "BreakGlassInvalid", tt.cond().Reason)
+
+	// The break-glass annotation bypasses approval.
+	used := testutil.ToFloat64(breakGlassApplies.WithLabelValues("test", "my-atlas-schema"))
+	schema().Annotations = map[string]string{breakGlassAnnotation: "INC-123: hotfix for outage"}
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.EqualValues(t, metav1.ConditionTrue, tt.cond().Status)
+	require.EqualValues(t, "INC-123: hotfix for outage", schema().Status.BreakGlass.Justification)
+	require.Nil(t, schema().Status.Approval)
+	require.Contains(t, tt.events(), "Warning BreakGlass Approval bypassed for an emergency apply: INC-123: hotfix for outage")
+	require.EqualValues(t, used+1, testutil.ToFloat64(breakGlassApplies.WithLabelValues("test", "my-atlas-schema")))
+
+	// Later changes require approval again.
+	schema().Spec.Schema.SQL = "CREATE TABLE foo (id INT PRIMARY KEY, bar INT);"
+	tt.k8s.put(devDBReady())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.EqualValues(t, "ApprovalPending", tt.cond().Reason)
+	require.EqualValues(t, used+1, testutil.ToFloat64(breakGlassApplies.WithLabelValues("test", "my-atlas-schema")))
+}
+
+func TestBreakGlassValidator(t *testing.T) {
+	rv := &mockReviewer{allowed: map[string]bool{"oncall": true}}
+	v := NewBreakGlassValidator(rv)
+	withAnnotation := func(j ...string) runtime.RawExtension {
+		sc := conditionReconciling()
+		if len(j) > 0 {
+			sc.Annotations = map[string]string{breakGlassAnnotation: j[0]}
+		}
+		b, err := json.Marshal(sc)
+		require.NoError(t, err)
+		return runtime.RawExtension{Raw: b}
+	}
+	request := func(user string, old, cur runtime.RawExtension) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			UserInfo:  authenticationv1.UserInfo{Username: user},
+			OldObject: old,
+			Object:    cur,
+		}}
+	}
+
+	// Unchanged annotations are not reviewed.
+	resp := v.Handle(context.Background(), request("dev", withAnnotation(), withAnnotation()))
+	require.True(t, resp.Allowed)
+	resp = v.Handle(context.Background(), request("dev", withAnnotation("INC-1"), withAnnotation("INC-1")))
+	require.True(t, resp.Allowed)
+	require.Empty(t, rv.reviews)
+
+	resp = v.Handle(context.Background(), request("oncall", withAnnotation(), withAnnotation("")))
+	require.False(t, resp.Allowed)
+	require.EqualValues(t, "the db.atlasgo.io/break-glass annotation requires a justification", string(resp.Result.Reason))
+	resp = v.Handle(context.Background(), request("dev", withAnnotation(), withAnnotation("INC-1")))
+	require.False(t, resp.Allowed)
+	require.EqualValues(t, `user "dev" is not allowed to break-glass atlasschemas test/my-atlas-schema`, string(resp.Result.Reason))
+	resp = v.Handle(context.Background(), request("oncall", withAnnotation(), withAnnotation("INC-1")))
+	require.True(t, resp.Allowed)
+	require.EqualValues(t, map[string]string{"break-glass-justification": "INC-1"}, resp.AuditAnnotations)
+
+	// Approving a plan in the spec requires the "approve" verb.
+	withHash := func(h string) runtime.RawExtension {
+		sc := conditionReconciling()
+		sc.Spec.ApprovedHash = h
+		b, err := json.Marshal(sc)
+		require.NoError(t, err)
+		return runtime.RawExtension{Raw: b}
+	}
+	resp = v.Handle(context.Background(), request("dev", withHash(""), withHash("abc")))
+	require.False(t, resp.Allowed)
+	require.EqualValues(t, `user "dev" is not allowed to approve atlasschemas test/my-atlas-schema`, string(resp.Result.Reason))
+	require.Equal(t, approveVerb, rv.reviews[len(rv.reviews)-1].ResourceAttributes.Verb)
+	resp = v.Handle(context.Background(), request("oncall", withHash(""), withHash("abc")))
+	require.True(t, resp.Allowed)
+	// Clearing the approval is not reviewed.
+	n := len(rv.reviews)
+	resp = v.Handle(context.Background(), request("dev", withHash("abc"), withHash("")))
+	require.True(t, resp.Allowed)
+	require.Len(t, rv.reviews, n)
+}
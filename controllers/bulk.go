@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const (
+	// suspendAnnotation suspends the reconciles of a resource when set to "true".
+	suspendAnnotation = "db.atlasgo.io/suspend"
+	// reconcileRequestedAnnotation triggers a reconcile of a resource when its
+	// value changes, e.g. to the current time.
+	reconcileRequestedAnnotation = "db.atlasgo.io/reconcile-requested-at"
+)
+
+// Bulk actions.
+const (
+	BulkApprove  = "approve"
+	BulkSuspend  = "suspend"
+	BulkResume   = "resume"
+	BulkRerun    = "rerun"
+	BulkAnnotate = "annotate"
+)
+
+type (
+	// BulkOptions selects the resources a bulk action is applied to.
+	BulkOptions struct {
+		// Action to apply: approve, suspend, resume, rerun or annotate.
+		Action string
+		// Kinds of the selected resources: AtlasSchema and AtlasMigration.
+		// Both kinds if empty.
+		Kinds []string
+		// Namespace of the selected resources. All namespaces if empty.
+		Namespace string
+		// Selector of the labels of the selected resources. All resources if nil.
+		Selector labels.Selector
+		// Annotate and Remove are the annotations set and removed by the
+		// annotate action.
+		Annotate map[string]string
+		Remove   []string
+		// DryRun reports the results of the action without applying it.
+		DryRun bool
+		// Now is the time recorded by the rerun action.
+		Now time.Time
+	}
+	// BulkResult is the result of a bulk action on a resource.
+	BulkResult struct {
+		Kind      string `json:"kind"`
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+		// Result describes the change, or why the resource was skipped.
+		Result string `json:"result"`
+		// Changed reports if the resource was changed.
+		Changed bool `json:"changed"`
+	}
+)
+
+// suspended reports if the reconciles of the resource are suspended.
+func suspended(obj client.Object) bool {
+	return obj.GetAnnotations()[suspendAnnotation] == "true"
+}
+
+// bulkAnnotationsChanged triggers a reconcile when a resource is suspended,
+// resumed, or a reconcile is requested.
+var bulkAnnotationsChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		old, cur := e.ObjectOld.GetAnnotations(), e.ObjectNew.GetAnnotations()
+		return old[suspendAnnotation] != cur[suspendAnnotation] ||
+			old[reconcileRequestedAnnotation] != cur[reconcileRequestedAnnotation]
+	},
+}
+
+// Bulk applies an action to the AtlasSchema and AtlasMigration resources
+// selected by the options, and returns its result for each of them, ordered by
+// kind, namespace and name. Resources that fail are reported in their result,
+// and do not stop the action on the others.
+func Bulk(ctx context.Context, c client.Client, opts BulkOptions) ([]BulkResult, error) {
+	switch opts.Action {
+	case BulkApprove, BulkSuspend, BulkResume, BulkRerun:
+	case BulkAnnotate:
+		if len(opts.Annotate) == 0 && len(opts.Remove) == 0 {
+			return nil, fmt.Errorf("bulk: annotate requires annotations to set or remove")
+		}
+	default:
+		return nil, fmt.Errorf("bulk: unknown action %q", opts.Action)
+	}
+	kinds := opts.Kinds
+	if len(kinds) == 0 {
+		kinds = []string{"AtlasSchema", "AtlasMigration"}
+	}
+	var lo []client.ListOption
+	if opts.Namespace != "" {
+		lo = append(lo, client.InNamespace(opts.Namespace))
+	}
+	if opts.Selector != nil {
+		lo = append(lo, client.MatchingLabelsSelector{Selector: opts.Selector})
+	}
+	var objs []client.Object
+	for _, k := range kinds {
+		switch k {
+		case "AtlasSchema":
+			var l dbv1alpha1.AtlasSchemaList
+			if err := c.List(ctx, &l, lo...); err != nil {
+				return nil, err
+			}
+			for i := range l.Items {
+				objs = append(objs, &l.Items[i])
+			}
+		case "AtlasMigration":
+			var l dbv1alpha1.AtlasMigrationList
+			if err := c.List(ctx, &l, lo...); err != nil {
+				return nil, err
+			}
+			for i := range l.Items {
+				objs = append(objs, &l.Items[i])
+			}
+		default:
+			return nil, fmt.Errorf("bulk: unknown kind %q", k)
+		}
+	}
+	var failed int
+	results := make([]BulkResult, 0, len(objs))
+	for _, obj := range objs {
+		res := BulkResult{Kind: kindOf(obj), Namespace: obj.GetNamespace(), Name: obj.GetName()}
+		var err error
+		if res.Result, res.Changed, err = bulkApply(ctx, c, obj, opts); err != nil {
+			res.Result, res.Changed = "failed: "+err.Error(), false
+			failed++
+		}
+		results = append(results, res)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	if failed > 0 {
+		return results, fmt.Errorf("bulk: %s failed on %d of %d resources", opts.Action, failed, len(results))
+	}
+	return results, nil
+}
+
+// bulkApply applies the action of the options to the resource. Changes are
+// patched, so they do not conflict with the reconciles updating the resource.
+func bulkApply(ctx context.Context, c client.Client, obj client.Object, opts BulkOptions) (string, bool, error) {
+	if opts.Action == BulkApprove {
+		return bulkApprove(ctx, c, obj, opts.DryRun)
+	}
+	var (
+		set    = make(map[string]string)
+		remove []string
+		result string
+	)
+	switch opts.Action {
+	case BulkSuspend:
+		set[suspendAnnotation], result = "true", "suspended"
+	case BulkResume:
+		remove, result = []string{suspendAnnotation}, "resumed"
+	case BulkRerun:
+		set[reconcileRequestedAnnotation], result = opts.Now.UTC().Format(time.RFC3339), "reconcile requested"
+	case BulkAnnotate:
+		set, remove, result = opts.Annotate, opts.Remove, "annotated"
+	}
+	a := obj.GetAnnotations()
+	changed := false
+	for k, v := range set {
+		if cur, ok := a[k]; !ok || cur != v {
+			changed = true
+		}
+	}
+	for _, k := range remove {
+		if _, ok := a[k]; ok {
+			changed = true
+		}
+	}
+	if !changed {
+		return "unchanged", false, nil
+	}
+	if opts.DryRun {
+		return result + " (dry run)", true, nil
+	}
+	orig := obj.DeepCopyObject().(client.Object)
+	if a == nil {
+		a = make(map[string]string, len(set))
+	}
+	for k, v := range set {
+		a[k] = v
+	}
+	for _, k := range remove {
+		delete(a, k)
+	}
+	obj.SetAnnotations(a)
+	return result, true, c.Patch(ctx, obj, client.MergeFrom(orig))
+}
+
+// bulkApprove approves the plan of the resource awaiting approval, the same
+// way users approve it: in the status of an AtlasSchema, and in the spec of an
+// AtlasMigration.
+func bulkApprove(ctx context.Context, c client.Client, obj client.Object, dryRun bool) (string, bool, error) {
+	var (
+		hash   string
+		update func() error
+	)
+	switch o := obj.(type) {
+	case *dbv1alpha1.AtlasSchema:
+		a := o.Status.Approval
+		if a == nil || a.Expired || a.ApprovedPlan == a.PlanHash {
+			return "no plan awaiting approval", false, nil
+		}
+		hash = a.PlanHash
+		update = func() error {
+			orig := o.DeepCopy()
+			a.ApprovedPlan = hash
+			return c.Status().Patch(ctx, o, client.MergeFrom(orig))
+		}
+	case *dbv1alpha1.AtlasMigration:
+		a := o.Status.Approval
+		if a == nil || a.Approved || o.Spec.Approval == nil || o.Spec.Approval.ApprovedPlan == a.PlanHash {
+			return "no plan awaiting approval", false, nil
+		}
+		hash = a.PlanHash
+		update = func() error {
+			orig := o.DeepCopy()
+			o.Spec.Approval.ApprovedPlan = hash
+			return c.Patch(ctx, o, client.MergeFrom(orig))
+		}
+	}
+	result := "approved plan " + hash
+	if dryRun {
+		return result + " (dry run)", true, nil
+	}
+	return result, true, update()
+}
+
+// kindOf returns the kind of a managed resource.
+func kindOf(obj client.Object) string {
+	switch obj.(type) {
+	case *dbv1alpha1.AtlasSchema:
+		return "AtlasSchema"
+	case *dbv1alpha1.AtlasMigration:
+		return "AtlasMigration"
+	}
+	return ""
+}
+
+// ParseAnnotations parses the annotations of the annotate action, in the form
+// of "key=value" to set an annotation, and "key-" to remove it.
+func ParseAnnotations(args []string) (map[string]string, []string, error) {
+	set := make(map[string]string)
+	var remove []string
+	for _, a := range args {
+		switch k, v, ok := strings.Cut(a, "="); {
+		case ok && k != "":
+			set[k] = v
+		case !ok && strings.HasSuffix(a, "-") && len(a) > 1:
+			if k := strings.TrimSuffix(a, "-"); !slices.Contains(remove, k) {
+				remove = append(remove, k)
+			}
+		default:
+			return nil, nil, fmt.Errorf("invalid annotation %q, expected key=value or key-", a)
+		}
+	}
+	return set, remove, nil
+}
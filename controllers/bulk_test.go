@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func TestBulk(t *testing.T) {
+	tt := newTest(t)
+	objmeta := func(ns, name, team string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: ns, Name: name, Labels: map[string]string{"team": team}}
+	}
+	tt.k8s.put(&dbv1alpha1.AtlasSchema{
+		ObjectMeta: objmeta("staging", "orders", "payments"),
+		Status: dbv1alpha1.AtlasSchemaStatus{
+			Approval: &dbv1alpha1.ApprovalStatus{PlanHash: "aaaaaaaaaaaa"},
+		},
+	})
+	tt.k8s.put(&dbv1alpha1.AtlasSchema{ObjectMeta: objmeta("staging", "users", "identity")})
+	tt.k8s.put(&dbv1alpha1.AtlasMigration{
+		ObjectMeta: objmeta("staging", "ledger", "payments"),
+		Spec: dbv1alpha1.AtlasMigrationSpec{
+			Approval: &dbv1alpha1.MigrationApproval{},
+		},
+		Status: dbv1alpha1.AtlasMigrationStatus{
+			Approval: &dbv1alpha1.MigrationApprovalStatus{PlanHash: "bbbbbbbbbbbb"},
+		},
+	})
+	tt.k8s.put(&dbv1alpha1.AtlasMigration{ObjectMeta: objmeta("prod", "ledger", "payments")})
+	schema := func(ns, name string) *dbv1alpha1.AtlasSchema {
+		return tt.k8s.state[types.NamespacedName{Namespace: ns, Name: name}].(*dbv1alpha1.AtlasSchema)
+	}
+	migration := func(ns, name string) *dbv1alpha1.AtlasMigration {
+		return tt.k8s.state[types.NamespacedName{Namespace: ns, Name: name}].(*dbv1alpha1.AtlasMigration)
+	}
+	ctx := context.Background()
+	payments := labels.SelectorFromSet(labels.Set{"team": "payments"})
+
+	// Approve the plans awaiting approval in staging.
+	res, err := Bulk(ctx, tt.k8s, BulkOptions{Action: BulkApprove, Namespace: "staging"})
+	require.NoError(t, err)
+	require.Equal(t, []BulkResult{
+		{Kind: "AtlasMigration", Namespace: "staging", Name: "ledger", Result: "approved plan bbbbbbbbbbbb", Changed: true},
+		{Kind: "AtlasSchema", Namespace: "staging", Name: "orders", Result: "approved plan aaaaaaaaaaaa", Changed: true},
+		{Kind: "AtlasSchema", Namespace: "staging", Name: "users", Result: "no plan awaiting approval"},
+	}, res)
+	require.Equal(t, "aaaaaaaaaaaa", schema("staging", "orders").Status.Approval.ApprovedPlan)
+	require.Equal(t, "bbbbbbbbbbbb", migration("staging", "ledger").Spec.Approval.ApprovedPlan)
+
+	// Suspend the resources of a team in all namespaces, without changing them in a dry run.
+	res, err = Bulk(ctx, tt.k8s, BulkOptions{Action: BulkSuspend, Selector: payments, DryRun: true})
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+	require.Equal(t, "suspended (dry run)", res[0].Result)
+	require.False(t, suspended(migration("prod", "ledger")))
+	res, err = Bulk(ctx, tt.k8s, BulkOptions{Action: BulkSuspend, Selector: payments})
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+	require.True(t, suspended(migration("prod", "ledger")))
+	require.True(t, suspended(schema("staging", "orders")))
+	require.False(t, suspended(schema("staging", "users")))
+	res, err = Bulk(ctx, tt.k8s, BulkOptions{Action: BulkSuspend, Selector: payments, Kinds: []string{"AtlasSchema"}})
+	require.NoError(t, err)
+	require.Equal(t, []BulkResult{{Kind: "AtlasSchema", Namespace: "staging", Name: "orders", Result: "unchanged"}}, res)
+
+	// Resume, and request a reconcile of every resource.
+	_, err = Bulk(ctx, tt.k8s, BulkOptions{Action: BulkResume, Selector: payments})
+	require.NoError(t, err)
+	require.False(t, suspended(migration("prod", "ledger")))
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	res, err = Bulk(ctx, tt.k8s, BulkOptions{Action: BulkRerun, Now: now})
+	require.NoError(t, err)
+	require.Len(t, res, 4)
+	require.Equal(t, "2023-06-01T12:00:00Z", schema("staging", "users").Annotations[reconcileRequestedAnnotation])
+
+	// Set and remove arbitrary annotations.
+	set, remove, err := ParseAnnotations([]string{"owner=payments", reconcileRequestedAnnotation + "-"})
+	require.NoError(t, err)
+	_, err = Bulk(ctx, tt.k8s, BulkOptions{Action: BulkAnnotate, Namespace: "prod", Annotate: set, Remove: remove})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"owner": "payments"}, migration("prod", "ledger").Annotations)
+
+	// Resources that fail do not stop the action on the others.
+	tt.k8s.patchErr = map[types.NamespacedName]error{{Namespace: "prod", Name: "ledger"}: errors.New("the object has been modified")}
+	res, err = Bulk(ctx, tt.k8s, BulkOptions{Action: BulkSuspend, Selector: payments})
+	require.EqualError(t, err, "bulk: suspend failed on 1 of 3 resources")
+	require.Equal(t, BulkResult{Kind: "AtlasMigration", Namespace: "prod", Name: "ledger", Result: "failed: the object has been modified"}, res[0])
+	require.True(t, suspended(schema("staging", "orders")))
+	require.True(t, suspended(migration("staging", "ledger")))
+
+	_, _, err = ParseAnnotations([]string{"=value"})
+	require.EqualError(t, err, `invalid annotation "=value", expected key=value or key-`)
+	_, err = Bulk(ctx, tt.k8s, BulkOptions{Action: "delete"})
+	require.EqualError(t, err, `bulk: unknown action "delete"`)
+}
+
+func TestReconcile_Suspended(t *testing.T) {
+	tt := newTest(t)
+	sc := conditionReconciling()
+	sc.Annotations = map[string]string{suspendAnnotation: "true"}
+	tt.k8s.put(sc)
+	_, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.EqualValues(t, "Suspended", tt.cond().Reason)
+	require.Empty(t, tt.mockCLI().applyRuns)
+
+	mt := newMigrationTest(t)
+	mt.k8s.put(&dbv1alpha1.AtlasMigration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        migrationObjmeta().Name,
+			Namespace:   migrationObjmeta().Namespace,
+			Annotations: map[string]string{suspendAnnotation: "true"},
+		},
+		Spec: dbv1alpha1.AtlasMigrationSpec{URL: "sqlite://file?mode=memory"},
+		Status: dbv1alpha1.AtlasMigrationStatus{
+			Conditions: []metav1.Condition{{Type: "Ready", Status: metav1.ConditionFalse, Reason: "Reconciling"}},
+		},
+	})
+	_, err = mt.r.Reconcile(context.Background(), migrationReq())
+	require.NoError(t, err)
+	require.Equal(t, "Suspended", mt.status().Conditions[0].Reason)
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const (
+	bundleSuffix = "-bundle"
+	// bundleHashAnnotation holds the hash of the directory image a Job reads.
+	bundleHashAnnotation = "db.atlasgo.io/bundle-hash"
+	// defaultBundlePath is the directory of the migration files in the image.
+	defaultBundlePath = "/migrations"
+	// bundleScript prints the files of the directory as a base64 encoded tarball,
+	// and writes its checksum to the termination message of the container, so
+	// logs that were truncated or rotated are not read as the directory.
+	bundleScript = `cd "$BUNDLE_PATH" && tar -cf - . | sha256sum > /dev/termination-log && tar -cf - . 2>>/dev/termination-log | base64`
+)
+
+// bundleFiles reads the migration files bundled into a container image. The
+// image runs as a Job printing the files, and they are read from its logs
+// once it succeeds. The Job is kept while the image is unchanged, so it is
+// not pulled on every reconcile.
+func (r *AtlasMigrationReconciler) bundleFiles(ctx context.Context, am *dbv1alpha1.AtlasMigration, img *dbv1alpha1.DirImage) (map[string]string, error) {
+	if r.jobLogs == nil {
+		return nil, errors.New("directory images are not supported by this reconciler")
+	}
+	b, err := json.Marshal(img)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	h := hex.EncodeToString(sum[:])[:12]
+	key := types.NamespacedName{Namespace: am.Namespace, Name: am.Name + bundleSuffix}
+	job := &batchv1.Job{}
+	switch err := r.Get(ctx, key, job); {
+	case apierrors.IsNotFound(err):
+		if err := r.createBundleJob(ctx, am, img, key, h); err != nil {
+			return nil, err
+		}
+		return nil, transient(fmt.Errorf("waiting for directory image job %s", key.Name))
+	case err != nil:
+		return nil, transient(err)
+	case !metav1.IsControlledBy(job, am):
+		return nil, fmt.Errorf("job %s exists and is not owned by %s", key.Name, am.Name)
+	}
+	// The image changed. Delete the Job, and create it again on the next reconcile.
+	if job.Annotations[bundleHashAnnotation] != h {
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			return nil, transient(err)
+		}
+		return nil, transient(fmt.Errorf("replacing directory image job %s", key.Name))
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return nil, fmt.Errorf("directory image job %s failed: %s", key.Name, c.Message)
+		}
+	}
+	if job.Status.Succeeded == 0 {
+		return nil, transient(fmt.Errorf("waiting for directory image job %s", key.Name))
+	}
+	out, err := r.jobLogs.Logs(ctx, key.Namespace, key.Name)
+	if err != nil {
+		return nil, transient(err)
+	}
+	msg, err := r.jobLogs.Message(ctx, key.Namespace, key.Name)
+	if err != nil {
+		return nil, transient(err)
+	}
+	files, err := untar(out, msg)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory image %s: %w", img.Ref, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no migration files found in directory image %s", img.Ref)
+	}
+	return files, nil
+}
+
+func (r *AtlasMigrationReconciler) createBundleJob(ctx context.Context, am *dbv1alpha1.AtlasMigration, img *dbv1alpha1.DirImage, key types.NamespacedName, h string) error {
+	var backoff int32 = 2
+	p := img.Path
+	if p == "" {
+		p = defaultBundlePath
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        key.Name,
+			Namespace:   key.Namespace,
+			Annotations: map[string]string{bundleHashAnnotation: h},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoff,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy:    corev1.RestartPolicyNever,
+					ImagePullSecrets: img.ImagePullSecrets,
+					Containers: []corev1.Container{{
+						Name:    "bundle",
+						Image:   img.Ref,
+						Command: []string{"sh", "-c", bundleScript},
+						Env:     []corev1.EnvVar{{Name: "BUNDLE_PATH", Value: p}},
+					}},
+				},
+			},
+		},
+	}
+	if err := ctrl.SetControllerReference(am, job, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, job); err != nil {
+		return transient(err)
+	}
+	r.recorder.Eventf(am, corev1.EventTypeNormal, dbv1alpha1.ReasonCreatedDirectoryImageJob, "Created directory image job: %s", job.Name)
+	return nil
+}
+
+// untar returns the regular files at the root of the base64 encoded tarball,
+// after verifying it against the checksum written to the termination message.
+func untar(encoded, msg string) (map[string]string, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	if f := strings.Fields(msg); len(f) == 0 || f[0] != hex.EncodeToString(sum[:]) {
+		return nil, errors.New("the job output does not match its checksum, delete the job to run it again")
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(bytes.NewReader(b))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || strings.Contains(name, "/") {
+			continue
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tr); err != nil {
+			return nil, err
+		}
+		files[name] = buf.String()
+	}
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"ariga.io/atlas/sql/migrate"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+)
+
+// checkpointDirective matches the directive marking a checkpoint file in the
+// header of a migration file, e.g. "-- atlas:checkpoint" or "-- atlas:checkpoint v1".
+var checkpointDirective = regexp.MustCompile(`^--\s*atlas:checkpoint(?:\s|$)`)
+
+// isCheckpoint reports if the migration file is a checkpoint file. Directives
+// are read from the comment lines at the top of the file.
+func isCheckpoint(content string) bool {
+	for _, l := range strings.Split(content, "\n") {
+		l = strings.TrimSpace(l)
+		switch {
+		case l == "":
+			continue
+		case checkpointDirective.MatchString(l):
+			return true
+		case !strings.HasPrefix(l, "--") && !strings.HasPrefix(l, "#"):
+			return false
+		}
+	}
+	return false
+}
+
+// checkpoint accounts for the checkpoint files of the local migration
+// directory. A database that has no revisions starts from the latest
+// checkpoint, and the files before it are not applied. A database that has
+// revisions continues linearly, and checkpoint files are not applied. The
+// pending files of the status are narrowed to the files the CLI executes, and
+// the path taken by the database is returned. It returns nil if the directory
+// has no checkpoint files.
+func checkpoint(md atlasMigrationData, status *atlas.StatusReport) (*dbv1alpha1.CheckpointStatus, error) {
+	if md.Migration == nil {
+		return nil, nil
+	}
+	dir, err := migrate.NewLocalDir(strings.TrimPrefix(md.Migration.Dir, "file://"))
+	if err != nil {
+		return nil, err
+	}
+	files, err := dir.Files()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		latest      = -1
+		checkpoints = make(map[string]bool)
+	)
+	for i, f := range files {
+		if isCheckpoint(string(f.Bytes())) {
+			checkpoints[f.Version()] = true
+			latest = i
+		}
+	}
+	if latest == -1 {
+		return nil, nil
+	}
+	// Files from the latest checkpoint on are applied to databases that have no revisions.
+	fromLatest := make(map[string]bool, len(files)-latest)
+	for _, f := range files[latest:] {
+		fromLatest[f.Version()] = true
+	}
+	cs := &dbv1alpha1.CheckpointStatus{Version: files[latest].Version(), Path: dbv1alpha1.CheckpointPathLinear}
+	var applied []string
+	for _, rev := range status.Applied {
+		applied = append(applied, rev.Version)
+	}
+	switch {
+	case len(applied) == 0:
+		cs.Path = dbv1alpha1.CheckpointPathFromCheckpoint
+		if v := md.version; v != "" && !fromLatest[v] {
+			return nil, fmt.Errorf("version %s precedes checkpoint %s, the first version applied to new databases", v, cs.Version)
+		}
+		pending := status.Pending[:0:0]
+		for _, f := range status.Pending {
+			if fromLatest[f.Version] {
+				pending = append(pending, f)
+			}
+		}
+		status.Pending = pending
+	case checkpoints[applied[0]]:
+		// The database was created from a checkpoint.
+		cs.Path = dbv1alpha1.CheckpointPathFromCheckpoint
+		cs.Version = applied[0]
+		fallthrough
+	default:
+		pending := status.Pending[:0:0]
+		for _, f := range status.Pending {
+			if !checkpoints[f.Version] {
+				pending = append(pending, f)
+			}
+		}
+		status.Pending = pending
+	}
+	return cs, nil
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"ariga.io/atlas/sql/migrate"
+)
+
+// checksumErr is returned when the atlas.sum file of a directory does not
+// match the migration files it holds, e.g. after a file was edited by hand.
+type checksumErr struct {
+	msg string
+}
+
+func (e *checksumErr) Error() string {
+	return fmt.Sprintf("%s does not match the migration files: %s. Run 'atlas migrate hash' and update the directory",
+		migrate.HashFileName, e.msg)
+}
+
+// verifyChecksum checks the atlas.sum file of the directory against its files.
+// Directories without an atlas.sum file are left to the CLI.
+func verifyChecksum(files map[string]string) error {
+	sum, ok := files[migrate.HashFileName]
+	if !ok {
+		return nil
+	}
+	var expected migrate.HashFile
+	if err := expected.UnmarshalText([]byte(sum)); err != nil {
+		return &checksumErr{msg: fmt.Sprintf("the file is invalid (%v)", err)}
+	}
+	dir := &migrate.MemDir{}
+	for name, content := range files {
+		if strings.HasSuffix(name, ".sql") {
+			if err := dir.WriteFile(name, []byte(content)); err != nil {
+				return err
+			}
+		}
+	}
+	actual, err := dir.Checksum()
+	if err != nil {
+		return err
+	}
+	hashes := make(map[string]bool, len(actual))
+	for _, f := range actual {
+		hashes[f.N] = true
+	}
+	var msgs []string
+	for _, f := range expected {
+		if !hashes[f.N] {
+			msgs = append(msgs, fmt.Sprintf("file %s was removed", f.N))
+		}
+		delete(hashes, f.N)
+	}
+	for _, f := range actual {
+		if hashes[f.N] {
+			msgs = append(msgs, fmt.Sprintf("file %s was added", f.N))
+		}
+	}
+	// The hash of a file covers the files before it, so only the first
+	// mismatch points to a modified file.
+	if len(msgs) == 0 {
+		for i, f := range actual {
+			if i < len(expected) && f.H != expected[i].H {
+				msgs = append(msgs, fmt.Sprintf("file %s was modified", f.N))
+				break
+			}
+		}
+	}
+	if len(msgs) > 0 {
+		return &checksumErr{msg: strings.Join(msgs, ", ")}
+	}
+	return nil
+}
+
+// withChecksum returns the files of the directory with an atlas.sum file
+// computed from them. The given map is not modified.
+func withChecksum(files map[string]string) (map[string]string, error) {
+	dir := &migrate.MemDir{}
+	for name, content := range files {
+		if err := dir.WriteFile(name, []byte(content)); err != nil {
+			return nil, err
+		}
+	}
+	sum, err := dir.Checksum()
+	if err != nil {
+		return nil, err
+	}
+	b, err := sum.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(files)+1)
+	for name, content := range files {
+		out[name] = content
+	}
+	out[migrate.HashFileName] = string(b)
+	return out, nil
+}
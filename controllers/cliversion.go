@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const (
+	// minCLIVersion is the minimum version of the Atlas CLI supported by the operator.
+	minCLIVersion = "v0.10.0"
+	// execOrderCLIVersion is the minimum version supporting the --exec-order flag.
+	execOrderCLIVersion = "v0.12.0"
+	// checkpointCLIVersion is the minimum version supporting checkpoint files.
+	checkpointCLIVersion = "v0.13.0"
+)
+
+// cliRequirement is a feature that requires a minimum version of the CLI.
+type cliRequirement struct {
+	feature, version string
+}
+
+// incompatibleCLIErr is returned when the Atlas CLI is older than the
+// versions required by the operator or the migration directory.
+type incompatibleCLIErr struct {
+	version string
+	unmet   []cliRequirement
+}
+
+func (e *incompatibleCLIErr) Error() string {
+	parts := make([]string, len(e.unmet))
+	for i, r := range e.unmet {
+		parts[i] = fmt.Sprintf("%s requires %s or later", r.feature, r.version)
+	}
+	return fmt.Sprintf("atlas CLI %s is not supported: %s. Upgrade the CLI", e.version, strings.Join(parts, "; "))
+}
+
+// cliRequirements returns the CLI versions required to apply the directory.
+func cliRequirements(md atlasMigrationData, cp *dbv1alpha1.CheckpointStatus) []cliRequirement {
+	reqs := []cliRequirement{{feature: "the operator", version: minCLIVersion}}
+	if md.execOrder != "" && md.execOrder != "linear" {
+		reqs = append(reqs, cliRequirement{feature: fmt.Sprintf("execOrder %q", md.execOrder), version: execOrderCLIVersion})
+	}
+	if cp != nil {
+		reqs = append(reqs, cliRequirement{feature: "checkpoint files", version: checkpointCLIVersion})
+	}
+	return reqs
+}
+
+// checkCLI checks the version of the CLI against the requirements of the
+// directory. Versions that cannot be resolved, or are not valid semantic
+// versions, e.g. of development builds, are not checked.
+func (r *AtlasMigrationReconciler) checkCLI(ctx context.Context, md atlasMigrationData, cp *dbv1alpha1.CheckpointStatus) error {
+	v := r.cliVersion.get(ctx, r.CLI)
+	if !semver.IsValid(v) {
+		log.FromContext(ctx).Info("skipping the version check of the atlas CLI", "version", v)
+		return nil
+	}
+	var unmet []cliRequirement
+	for _, req := range cliRequirements(md, cp) {
+		if semver.Compare(v, req.version) < 0 {
+			unmet = append(unmet, req)
+		}
+	}
+	if len(unmet) > 0 {
+		return &incompatibleCLIErr{version: v, unmet: unmet}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/cloudapi"
+)
+
+const (
+	// cloudTokenInterval is the interval in which Atlas Cloud tokens are checked.
+	cloudTokenInterval = time.Hour
+	// cloudTokenExpiry is the time before the expiry of a token it is reported.
+	cloudTokenExpiry = 7 * 24 * time.Hour
+)
+
+// TokenChecker is the interface used to check Atlas Cloud tokens.
+type TokenChecker interface {
+	Check(ctx context.Context, p *cloudapi.CheckParams) (*cloudapi.TokenStatus, error)
+}
+
+// cloudTokenErr is returned when the Atlas Cloud token cannot be used to fetch
+// the remote migration directory.
+type cloudTokenErr struct {
+	reason, msg string
+}
+
+func (e *cloudTokenErr) Error() string {
+	return e.msg
+}
+
+// checkCloudToken checks the Atlas Cloud token of a remote migration directory
+// once per cloudTokenInterval, and reports its health in the CloudTokenHealthy
+// condition. Tokens about to expire or low on quota are reported without
+// blocking the reconcile. It returns a *cloudTokenErr if the token is invalid,
+// expired or has no access to the directory, as fetching the directory would fail.
+func (r *AtlasMigrationReconciler) checkCloudToken(ctx context.Context, am *dbv1alpha1.AtlasMigration, md atlasMigrationData) error {
+	if md.Cloud == nil || md.Cloud.RemoteDir == nil {
+		meta.RemoveStatusCondition(&am.Status.Conditions, dbv1alpha1.CloudTokenCond)
+		am.Status.CloudToken = nil
+		return nil
+	}
+	// Healthy tokens are not checked again before the interval passed.
+	if st := am.Status.CloudToken; st != nil && time.Since(st.CheckedAt.Time) < cloudTokenInterval &&
+		meta.IsStatusConditionTrue(am.Status.Conditions, dbv1alpha1.CloudTokenCond) {
+		return nil
+	}
+	ts, err := r.TokenChecker.Check(ctx, &cloudapi.CheckParams{
+		URL:   md.Cloud.URL,
+		Token: md.Cloud.Token,
+		Dir:   md.Cloud.RemoteDir.Name,
+		Tag:   md.Cloud.RemoteDir.Tag,
+	})
+	st := &dbv1alpha1.CloudTokenStatus{CheckedAt: metav1.Now()}
+	if ts != nil {
+		if ts.ExpiresAt != nil {
+			exp := metav1.NewTime(*ts.ExpiresAt)
+			st.ExpiresAt = &exp
+		}
+		if ts.RateLimit > 0 && ts.RateRemaining >= 0 {
+			st.RateLimit, st.RateRemaining = ts.RateLimit, ts.RateRemaining
+		}
+	}
+	am.Status.CloudToken = st
+	cond := func(status metav1.ConditionStatus, reason, msg string) {
+		meta.SetStatusCondition(&am.Status.Conditions, metav1.Condition{
+			Type:    dbv1alpha1.CloudTokenCond,
+			Status:  status,
+			Reason:  reason,
+			Message: msg,
+		})
+	}
+	switch {
+	case errors.Is(err, cloudapi.ErrUnauthorized):
+		cond(metav1.ConditionFalse, dbv1alpha1.ReasonTokenInvalid, err.Error())
+		return &cloudTokenErr{reason: dbv1alpha1.ReasonCloudTokenInvalid, msg: err.Error()}
+	case errors.Is(err, cloudapi.ErrForbidden):
+		cond(metav1.ConditionFalse, dbv1alpha1.ReasonNoDirectoryAccess, err.Error())
+		return &cloudTokenErr{reason: dbv1alpha1.ReasonCloudTokenInvalid, msg: err.Error()}
+	case err != nil:
+		// The directory is fetched anyway, and reports its own errors.
+		cond(metav1.ConditionUnknown, dbv1alpha1.ReasonCheckFailed, err.Error())
+		return nil
+	case st.ExpiresAt != nil && st.ExpiresAt.Before(&st.CheckedAt):
+		msg := fmt.Sprintf("the token expired at %s", st.ExpiresAt.UTC().Format(time.RFC3339))
+		cond(metav1.ConditionFalse, dbv1alpha1.ReasonTokenExpired, msg)
+		return &cloudTokenErr{reason: dbv1alpha1.ReasonCloudTokenInvalid, msg: msg}
+	case st.ExpiresAt != nil && st.ExpiresAt.Sub(st.CheckedAt.Time) < cloudTokenExpiry:
+		msg := fmt.Sprintf("the token expires at %s", st.ExpiresAt.UTC().Format(time.RFC3339))
+		cond(metav1.ConditionFalse, dbv1alpha1.ReasonTokenExpiring, msg)
+		r.recorder.Event(am, corev1.EventTypeWarning, dbv1alpha1.ReasonCloudTokenExpiring, msg)
+	case st.RateLimit > 0 && st.RateRemaining*10 < st.RateLimit:
+		msg := fmt.Sprintf("%d of %d requests left in the current rate-limit window", st.RateRemaining, st.RateLimit)
+		cond(metav1.ConditionFalse, dbv1alpha1.ReasonRateLimitLow, msg)
+		r.recorder.Event(am, corev1.EventTypeWarning, dbv1alpha1.ReasonCloudRateLimitLow, msg)
+	default:
+		cond(metav1.ConditionTrue, dbv1alpha1.ReasonHealthy, "The token has access to the remote directory")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/cloudapi"
+)
+
+type mockTokenChecker struct {
+	status *cloudapi.TokenStatus
+	err    error
+	checks int
+}
+
+func (m *mockTokenChecker) Check(context.Context, *cloudapi.CheckParams) (*cloudapi.TokenStatus, error) {
+	m.checks++
+	return m.status, m.err
+}
+
+func TestCheckCloudToken(t *testing.T) {
+	tt := newMigrationTest(t)
+	checker := &mockTokenChecker{status: &cloudapi.TokenStatus{RateLimit: -1, RateRemaining: -1}}
+	tt.r.TokenChecker = checker
+	am := &dbv1alpha1.AtlasMigration{ObjectMeta: migrationObjmeta()}
+	md := atlasMigrationData{
+		Cloud: &cloud{Token: "token", RemoteDir: &remoteDir{Name: "app"}},
+	}
+	cond := func() *metav1.Condition {
+		return meta.FindStatusCondition(am.Status.Conditions, dbv1alpha1.CloudTokenCond)
+	}
+
+	require.NoError(t, tt.r.checkCloudToken(context.Background(), am, md))
+	require.Equal(t, "Healthy", cond().Reason)
+	require.Equal(t, 1, checker.checks)
+
+	// Healthy tokens are checked once per interval.
+	require.NoError(t, tt.r.checkCloudToken(context.Background(), am, md))
+	require.Equal(t, 1, checker.checks)
+	am.Status.CloudToken.CheckedAt = metav1.NewTime(time.Now().Add(-2 * cloudTokenInterval))
+
+	// Low quota and expiring tokens are reported without blocking.
+	checker.status = &cloudapi.TokenStatus{RateLimit: 100, RateRemaining: 5}
+	require.NoError(t, tt.r.checkCloudToken(context.Background(), am, md))
+	require.Equal(t, "RateLimitLow", cond().Reason)
+	require.Equal(t, 5, am.Status.CloudToken.RateRemaining)
+	exp := time.Now().Add(time.Hour)
+	checker.status = &cloudapi.TokenStatus{ExpiresAt: &exp, RateLimit: -1, RateRemaining: -1}
+	require.NoError(t, tt.r.checkCloudToken(context.Background(), am, md))
+	require.Equal(t, "TokenExpiring", cond().Reason)
+	require.Equal(t, []string{
+		"Warning CloudRateLimitLow 5 of 100 requests left in the current rate-limit window",
+		"Warning CloudTokenExpiring the token expires at " + exp.UTC().Format(time.RFC3339),
+	}, tt.events())
+
+	// Invalid and expired tokens block the reconcile.
+	exp = time.Now().Add(-time.Hour)
+	err := tt.r.checkCloudToken(context.Background(), am, md)
+	var tErr *cloudTokenErr
+	require.ErrorAs(t, err, &tErr)
+	require.Equal(t, "TokenExpired", cond().Reason)
+	checker.err = cloudapi.ErrUnauthorized
+	require.ErrorAs(t, tt.r.checkCloudToken(context.Background(), am, md), &tErr)
+	require.Equal(t, "TokenInvalid", cond().Reason)
+	checker.err = cloudapi.ErrForbidden
+	require.ErrorAs(t, tt.r.checkCloudToken(context.Background(), am, md), &tErr)
+	require.Equal(t, "NoDirectoryAccess", cond().Reason)
+
+	// Failing checks do not block the reconcile.
+	checker.err = errors.New("connection refused")
+	require.NoError(t, tt.r.checkCloudToken(context.Background(), am, md))
+	require.Equal(t, metav1.ConditionUnknown, cond().Status)
+
+	// The condition is removed with the remote directory.
+	require.NoError(t, tt.r.checkCloudToken(context.Background(), am, atlasMigrationData{}))
+	require.Nil(t, cond())
+	require.Nil(t, am.Status.CloudToken)
+}
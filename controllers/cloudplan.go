@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ariga.io/atlas/sql/migrate"
+	"golang.org/x/exp/slices"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/cloudapi"
+)
+
+// cloudPlanRetry is how often the plan of a schema is fetched again while it
+// is not approved.
+const cloudPlanRetry = time.Minute
+
+// PlanFetcher is the interface used to fetch schema plans from Atlas Cloud.
+type PlanFetcher interface {
+	SchemaPlan(ctx context.Context, p *cloudapi.PlanParams) (*cloudapi.SchemaPlan, error)
+}
+
+// noApprovedPlanErr is returned when the planned changes do not match an
+// approved Atlas Cloud plan.
+type noApprovedPlanErr struct {
+	msg string
+}
+
+func (e *noApprovedPlanErr) Error() string {
+	return e.msg
+}
+
+// checkCloudPlan verifies that the planned changes are the statements of the
+// approved Atlas Cloud plan referenced by the policy of the schema.
+func (r *AtlasSchemaReconciler) checkCloudPlan(ctx context.Context, sc *dbv1alpha1.AtlasSchema, plan *dbv1alpha1.SchemaPlan) error {
+	p := sc.Spec.Policy.Plan
+	planned := planStmts(plan.Statements)
+	if len(planned) == 0 {
+		return nil
+	}
+	if p.TokenFrom.SecretKeyRef == nil {
+		return errors.New("policy.plan.tokenFrom.secretKeyRef must be set")
+	}
+	token, err := getSecretValue(ctx, r, sc.Namespace, *p.TokenFrom.SecretKeyRef)
+	if err != nil {
+		return err
+	}
+	cp, err := r.cloudPlans.SchemaPlan(ctx, &cloudapi.PlanParams{Token: token, Ref: p.Ref})
+	switch {
+	case errors.Is(err, cloudapi.ErrPlanNotFound):
+		return &noApprovedPlanErr{msg: fmt.Sprintf("plan %s does not exist", p.Ref)}
+	case errors.Is(err, cloudapi.ErrUnauthorized):
+		return err
+	case err != nil:
+		return transient(err)
+	case cp.Status != cloudapi.PlanApproved:
+		return &noApprovedPlanErr{msg: fmt.Sprintf("plan %s is %s and was not approved", p.Ref, strings.ToLower(cp.Status))}
+	}
+	stmts, err := migrate.Stmts(cp.Migration)
+	if err != nil {
+		return fmt.Errorf("parsing plan %s: %w", p.Ref, err)
+	}
+	approved := make([]string, len(stmts))
+	for i, s := range stmts {
+		approved[i] = s.Text
+	}
+	if approved = planStmts(approved); !slices.Equal(approved, planned) {
+		return &noApprovedPlanErr{msg: fmt.Sprintf("the planned changes do not match the approved plan %s:\n%s", p.Ref, planDiff(approved, planned))}
+	}
+	return nil
+}
+
+// planStmts returns the non-empty statements of a plan, without trailing
+// semicolons, so plans can be compared regardless of their formatting.
+func planStmts(stmts []string) []string {
+	var out []string
+	for _, s := range stmts {
+		if s = strings.TrimSuffix(strings.TrimSpace(s), ";"); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
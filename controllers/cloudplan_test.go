@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/cloudapi"
+)
+
+type mockPlanFetcher struct {
+	plan   *cloudapi.SchemaPlan
+	err    error
+	params []*cloudapi.PlanParams
+}
+
+func (m *mockPlanFetcher) SchemaPlan(_ context.Context, p *cloudapi.PlanParams) (*cloudapi.SchemaPlan, error) {
+	m.params = append(m.params, p)
+	return m.plan, m.err
+}
+
+func TestReconcile_CloudPlan(t *testing.T) {
+	const stmt = "ALTER TABLE `foo` ADD COLUMN `bar` int"
+	for _, tc := range []struct {
+		name    string
+		plan    *cloudapi.SchemaPlan
+		err     error
+		reason  string
+		message string
+	}{
+		{
+			name: "approved",
+			plan: &cloudapi.SchemaPlan{Status: cloudapi.PlanApproved, Migration: "-- Add column bar.\n" + stmt + ";\n"},
+		},
+		{
+			name:    "pending",
+			plan:    &cloudapi.SchemaPlan{Status: "PENDING", Migration: stmt + ";\n"},
+			reason:  "NoApprovedPlan",
+			message: "plan atlas://app/plans/add-bar is pending and was not approved",
+		},
+		{
+			name:   "mismatch",
+			plan:   &cloudapi.SchemaPlan{Status: cloudapi.PlanApproved, Migration: "ALTER TABLE `foo` ADD COLUMN `baz` int;\n"},
+			reason: "NoApprovedPlan",
+			message: "the planned changes do not match the approved plan atlas://app/plans/add-bar:\n" +
+				"- ALTER TABLE `foo` ADD COLUMN `baz` int\n" +
+				"+ ALTER TABLE `foo` ADD COLUMN `bar` int",
+		},
+		{
+			name:    "not found",
+			err:     cloudapi.ErrPlanNotFound,
+			reason:  "NoApprovedPlan",
+			message: "plan atlas://app/plans/add-bar does not exist",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tt := newTest(t)
+			fetcher := &mockPlanFetcher{plan: tc.plan, err: tc.err}
+			tt.r.cloudPlans = fetcher
+			tt.mockCLI().plan = stmt
+			tt.k8s.put(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "atlas-cloud", Namespace: "test"},
+				Data:       map[string][]byte{"token": []byte("cloud-token")},
+			})
+			sc := conditionReconciling()
+			sc.Status.LastApplied = 1
+			sc.Spec.Policy.Plan = &dbv1alpha1.PlanPolicy{
+				Ref: "atlas://app/plans/add-bar",
+				TokenFrom: dbv1alpha1.TokenFrom{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "atlas-cloud"},
+						Key:                  "token",
+					},
+				},
+			}
+			tt.k8s.put(sc)
+			tt.k8s.put(devDBReady())
+			res, err := tt.r.Reconcile(context.Background(), req())
+			require.NoError(t, err)
+			require.Equal(t, []*cloudapi.PlanParams{{Token: "cloud-token", Ref: "atlas://app/plans/add-bar"}}, fetcher.params)
+			var applies int
+			for _, r := range tt.mockCLI().applyRuns {
+				if !r.DryRun {
+					applies++
+				}
+			}
+			if tc.reason == "" {
+				require.Equal(t, 1, applies)
+				require.EqualValues(t, metav1.ConditionTrue, tt.cond().Status)
+				return
+			}
+			require.Zero(t, applies)
+			require.Equal(t, time.Minute, res.RequeueAfter)
+			require.EqualValues(t, tc.reason, tt.cond().Reason)
+			require.Equal(t, tc.message, tt.cond().Message)
+			require.Equal(t, []string{"Normal ApprovalPending Plan 937c85af99b3 is awaiting approval", "Warning NoApprovedPlan " + tc.message}, tt.events())
+			pending := meta.FindStatusCondition(tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema).Status.Conditions, dbv1alpha1.PendingApprovalCond)
+			require.NotNil(t, pending)
+			require.Equal(t, "AwaitingCloudApproval", pending.Reason)
+			require.Equal(t, tc.message, pending.Message)
+
+			// The event is not repeated while the plan is not approved.
+			tt.k8s.put(devDBReady())
+			_, err = tt.r.Reconcile(context.Background(), req())
+			require.NoError(t, err)
+			require.Empty(t, tt.events())
+		})
+	}
+}
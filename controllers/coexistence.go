@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+)
+
+// coexistenceInterval is the interval in which read-only schemas are checked
+// for drift, and conflicts with AtlasMigration resources are checked again.
+const coexistenceInterval = 5 * time.Minute
+
+// coexist resolves which resource writes to the database of the schema. It
+// reports true if the schema must not be applied, and the reconcile ends with
+// the returned result.
+func (r *AtlasSchemaReconciler) coexist(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed, devURL string) (ctrl.Result, bool, error) {
+	migrations, err := r.migrationsOf(ctx, m)
+	if err != nil {
+		setNotReady(sc, dbv1alpha1.ReasonListingMigrations, err.Error())
+		res, err := result(err)
+		return res, true, err
+	}
+	switch sc.Spec.Coexistence {
+	case "":
+		if len(migrations) == 0 {
+			return ctrl.Result{}, false, nil
+		}
+		msg := fmt.Sprintf("the database is also managed by AtlasMigration %s. "+
+			"Set spec.coexistence to %q or %q to make this schema read-only",
+			migrations[0].NamespacedName(), dbv1alpha1.CoexistenceReadOnlySchema, dbv1alpha1.CoexistenceMigrationOwnsDDL)
+		setNotReady(sc, dbv1alpha1.ReasonCoexistenceConflict, msg)
+		r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonCoexistenceConflict, msg)
+		return ctrl.Result{RequeueAfter: coexistenceInterval}, true, nil
+	case dbv1alpha1.CoexistenceMigrationOwnsDDL:
+		// Drift is expected until the pending migrations are applied.
+		for _, am := range migrations {
+			if !am.IsReady() {
+				setNotReady(sc, dbv1alpha1.ReasonWaitingForMigrations, fmt.Sprintf("waiting for AtlasMigration %s to be ready", am.NamespacedName()))
+				return ctrl.Result{RequeueAfter: 30 * time.Second}, true, nil
+			}
+		}
+	}
+	return r.drift(ctx, sc, m, devURL)
+}
+
+// drift plans the changes needed to bring the database to the desired schema
+// without applying them, and reports them as drift.
+func (r *AtlasSchemaReconciler) drift(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed, devURL string) (ctrl.Result, bool, error) {
+	desired, clean, err := m.desiredURL()
+	if err != nil {
+		setNotReady(sc, dbv1alpha1.ReasonPlanningDrift, err.Error())
+		return ctrl.Result{}, true, nil
+	}
+	defer clean()
+	dry, err := r.cli.SchemaApply(ctx, &atlas.SchemaApplyParams{
+		DryRun:    true,
+		URL:       m.url.String(),
+		To:        desired,
+		DevURL:    devURL,
+		Exclude:   m.exclude,
+		ConfigURL: m.configfile,
+		Schema:    m.schemas,
+	})
+	if err != nil {
+		setNotReady(sc, dbv1alpha1.ReasonPlanningDrift, err.Error())
+		res, err := result(transient(err))
+		return res, true, err
+	}
+	if pending := dry.Changes.Pending; len(pending) > 0 {
+		msg := fmt.Sprintf("the database drifted from the desired schema, %d statements are needed to bring it in sync", len(pending))
+		if !m.secret {
+			msg += ":\n" + strings.Join(pending, "\n")
+		}
+		if c := meta.FindStatusCondition(sc.Status.Conditions, dbv1alpha1.SchemaReadyCond); c == nil || c.Reason != dbv1alpha1.ReasonSchemaDrift {
+			r.recorder.Eventf(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonSchemaDrift, "The database drifted from the desired schema by %d statements", len(pending))
+		}
+		setNotReady(sc, dbv1alpha1.ReasonSchemaDrift, msg)
+		return ctrl.Result{RequeueAfter: coexistenceInterval}, true, nil
+	}
+	meta.SetStatusCondition(&sc.Status.Conditions, metav1.Condition{
+		Type:    dbv1alpha1.SchemaReadyCond,
+		Status:  metav1.ConditionTrue,
+		Reason:  dbv1alpha1.ReasonInSync,
+		Message: "The database matches the desired schema",
+	})
+	sc.Status.ObservedHash = m.hash()
+	sc.Status.ObservedCommit = m.commit
+	return ctrl.Result{RequeueAfter: coexistenceInterval}, true, nil
+}
+
+// migrationDatabaseField indexes the AtlasMigration resources by the
+// databases they target, so schemas find the migrations of their database
+// without resolving the URLs of all migrations of the cluster.
+const migrationDatabaseField = ".spec.database"
+
+// unresolvedDatabase is the index value of migrations whose URL is read from
+// a secret, and is resolved only when a schema looks them up.
+const unresolvedDatabase = "?"
+
+// migrationDatabases returns the index values of the migration: the server it
+// connects to, and the database on that server. Migrations connecting to the
+// whole server have an empty database.
+func migrationDatabases(obj client.Object) []string {
+	am, ok := obj.(*dbv1alpha1.AtlasMigration)
+	if !ok {
+		return nil
+	}
+	if am.Spec.URL == "" && am.Spec.Credentials.Host == "" {
+		if am.Spec.URLFrom.SecretKeyRef != nil {
+			return []string{unresolvedDatabase}
+		}
+		return nil
+	}
+	u, err := specURL(am)
+	if err != nil {
+		return nil
+	}
+	server, db := databaseKey(u)
+	return []string{server, server + "/" + db}
+}
+
+// databaseKey returns the server the URL connects to, and its database.
+func databaseKey(u *url.URL) (string, string) {
+	return driver(u.Scheme) + "://" + strings.ToLower(u.Host), strings.Trim(u.Opaque+u.Path, "/")
+}
+
+// databaseKeys returns the index values of the resources sharing the database
+// of the URL. URLs connecting to the whole server share the databases of all
+// resources on the server, and resources connecting to the whole server share
+// all of its databases. Resources whose URL is read from a secret are resolved
+// by the caller.
+func databaseKeys(u *url.URL) []string {
+	server, db := databaseKey(u)
+	if db == "" {
+		return []string{server, unresolvedDatabase}
+	}
+	return []string{server + "/" + db, server + "/", unresolvedDatabase}
+}
+
+// specURL returns the URL of the migration set in its spec, without the
+// credentials read from secrets, which do not change the database.
+func specURL(am *dbv1alpha1.AtlasMigration) (*url.URL, error) {
+	if am.Spec.URL != "" {
+		return url.Parse(am.Spec.URL)
+	}
+	return am.Spec.Credentials.URL(), nil
+}
+
+// migrationsOf returns the AtlasMigration resources targeting the named schemas
+// of the database managed by the schema. Migrations whose URL cannot be resolved
+// are skipped, as they cannot be applied.
+func (r *AtlasSchemaReconciler) migrationsOf(ctx context.Context, m *managed) ([]dbv1alpha1.AtlasMigration, error) {
+	var migrations []dbv1alpha1.AtlasMigration
+	for _, k := range databaseKeys(m.url) {
+		var list dbv1alpha1.AtlasMigrationList
+		if err := r.List(ctx, &list, client.MatchingFields{migrationDatabaseField: k}); err != nil {
+			return nil, transient(err)
+		}
+		for _, am := range list.Items {
+			u, err := specURL(&am)
+			if k == unresolvedDatabase {
+				var mu string
+				if mu, err = migrationURL(ctx, r, &am); err == nil {
+					u, err = url.Parse(mu)
+				}
+			}
+			if err != nil || !sameDatabase(m.url, u) {
+				continue
+			}
+			if _, ok := intersect(managedSchemas(m.url, m.schemas), managedSchemas(u, nil)); ok {
+				migrations = append(migrations, am)
+			}
+		}
+	}
+	return migrations, nil
+}
+
+// sameDatabase reports if the URLs connect to the same database, regardless of
+// the credentials, the parameters, or the schema they are scoped to. A URL that
+// connects to the whole server shares the databases of all other URLs.
+func sameDatabase(a, b *url.URL) bool {
+	da, db := strings.Trim(a.Opaque+a.Path, "/"), strings.Trim(b.Opaque+b.Path, "/")
+	return driver(a.Scheme) == driver(b.Scheme) && strings.EqualFold(a.Host, b.Host) &&
+		(da == db || da == "" || db == "")
+}
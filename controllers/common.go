@@ -2,12 +2,105 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
 
 	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/jsonpath"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// importStatusAnnotation holds a status exported from another resource, e.g. with
+// "kubectl get atlasschema <name> -o jsonpath='{.status}'". It is used to adopt the
+// history of a database whose managing resource moved between clusters or namespaces.
+const importStatusAnnotation = "db.atlasgo.io/import-status"
+
+// maxStatusSQL is the size of the SQL scripts reported in the status.
+const maxStatusSQL = 4 << 10
+
+// maxFetchSize is the size of the largest resource read by fetchURL.
+const maxFetchSize = 8 << 20
+
+// sqlString matches the string literals of SQL statements, with their quotes
+// escaped by doubling them or by backslashes.
+var sqlString = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'`)
+
+type (
+	// importedSchemaStatus holds the fields of an exported AtlasSchema status
+	// adopted by the new resource. Approvals, plans and the apply history are
+	// never imported, as anyone annotating the resource could forge them.
+	importedSchemaStatus struct {
+		ObservedHash   string `json:"observed_hash"`
+		LastApplied    int64  `json:"last_applied"`
+		ObservedTarget string `json:"observedTarget,omitempty"`
+	}
+	// importedMigrationStatus holds the fields of an exported AtlasMigration
+	// status adopted by the new resource.
+	importedMigrationStatus struct {
+		LastAppliedVersion string `json:"lastAppliedVersion,omitempty"`
+		ObservedHash       string `json:"observed_hash"`
+		LastApplied        int64  `json:"lastApplied"`
+		RevisionsSchema    string `json:"revisionsSchema,omitempty"`
+		ObservedTarget     string `json:"observedTarget,omitempty"`
+	}
+)
+
+// importStatus decodes the status exported to the import annotation of obj
+// into v, and reports if the annotation was set.
+func importStatus(obj metav1.Object, v any) (bool, error) {
+	a, ok := obj.GetAnnotations()[importStatusAnnotation]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(a), v); err != nil {
+		return false, fmt.Errorf("decoding %s annotation: %w", importStatusAnnotation, err)
+	}
+	return true, nil
+}
+
+// importSchemaStatus adopts the status exported to the import annotation of
+// the schema, and reports if the annotation was set.
+func importSchemaStatus(sc *dbv1alpha1.AtlasSchema) (bool, error) {
+	var v importedSchemaStatus
+	ok, err := importStatus(sc, &v)
+	if !ok || err != nil {
+		return ok, err
+	}
+	sc.Status.ObservedHash = v.ObservedHash
+	sc.Status.LastApplied = v.LastApplied
+	sc.Status.ObservedTarget = v.ObservedTarget
+	return true, nil
+}
+
+// importMigrationStatus adopts the status exported to the import annotation
+// of the migration, and reports if the annotation was set.
+func importMigrationStatus(am *dbv1alpha1.AtlasMigration) (bool, error) {
+	var v importedMigrationStatus
+	ok, err := importStatus(am, &v)
+	if !ok || err != nil {
+		return ok, err
+	}
+	am.Status.LastAppliedVersion = v.LastAppliedVersion
+	am.Status.ObservedHash = v.ObservedHash
+	am.Status.LastApplied = v.LastApplied
+	am.Status.RevisionsSchema = v.RevisionsSchema
+	am.Status.ObservedTarget = v.ObservedTarget
+	return true, nil
+}
+
 // getSecretValue gets the value of the given secret key selector.
 func getSecretValue(
 	ctx context.Context,
@@ -23,7 +116,157 @@ func getSecretValue(
 	return us, nil
 }
 
-// hydrateCredentials hydrates the credentials with the password from the secret.
+// urlFromSecret gets the URL referenced by the given URLFrom, extracting it
+// from a JSON value if a JSONPath is set.
+func urlFromSecret(ctx context.Context, r client.Reader, ns string, from dbv1alpha1.URLFrom) (string, error) {
+	v, err := getSecretValue(ctx, r, ns, *from.SecretKeyRef)
+	if err != nil || from.JSONPath == "" {
+		return v, err
+	}
+	return extractJSONPath(v, from.JSONPath)
+}
+
+// extractJSONPath executes the JSONPath template on the JSON value. The error
+// does not include the value, as it holds secrets. Templates building the URL
+// from the fields of the object escape them, so fields holding reserved
+// characters, e.g. a password with an '@', do not change the URL structure.
+// A template of a single field, e.g. "{.url}", extracts it as is.
+func extractJSONPath(value, expr string) (string, error) {
+	jp := jsonpath.New("urlFrom")
+	if err := jp.Parse(expr); err != nil {
+		return "", fmt.Errorf("invalid urlFrom.jsonPath: %w", err)
+	}
+	var v any
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return "", errors.New("urlFrom.jsonPath is set, but the secret value is not a JSON object")
+	}
+	if t := strings.TrimSpace(expr); strings.Count(t, "{") != 1 || !strings.HasPrefix(t, "{") || !strings.HasSuffix(t, "}") {
+		v = escapeFields(v)
+	}
+	var b strings.Builder
+	if err := jp.Execute(&b, v); err != nil {
+		return "", fmt.Errorf("urlFrom.jsonPath: %w", err)
+	}
+	return b.String(), nil
+}
+
+// escapeFields returns the JSON value with its strings percent-encoded, so they
+// are valid in any component of a URL.
+func escapeFields(v any) any {
+	switch v := v.(type) {
+	case string:
+		return strings.ReplaceAll(url.QueryEscape(v), "+", "%20")
+	case map[string]any:
+		for k, f := range v {
+			v[k] = escapeFields(f)
+		}
+	case []any:
+		for i, f := range v {
+			v[i] = escapeFields(f)
+		}
+	}
+	return v
+}
+
+//+kubebuilder:rbac:groups=db.atlasgo.io,resources=atlasreferencegrants,verbs=get;list;watch
+
+// refNamespace returns the namespace of an object of kind toKind referenced by
+// obj, a resource of kind fromKind. References to another namespace must be
+// allowed by an AtlasReferenceGrant in the referenced namespace.
+func refNamespace(
+	ctx context.Context,
+	r client.Reader,
+	obj client.Object,
+	fromKind, ns, toKind, name string,
+) (string, error) {
+	if ns == "" || ns == obj.GetNamespace() {
+		return obj.GetNamespace(), nil
+	}
+	var grants dbv1alpha1.AtlasReferenceGrantList
+	if err := r.List(ctx, &grants, client.InNamespace(ns)); err != nil {
+		return "", transient(err)
+	}
+	for i := range grants.Items {
+		if grants.Items[i].Allows(fromKind, obj.GetNamespace(), toKind, name) {
+			return ns, nil
+		}
+	}
+	// The grant may be created later on.
+	return "", transient(fmt.Errorf(
+		"no AtlasReferenceGrant in namespace %s allows %s in namespace %s to reference %s %s",
+		ns, fromKind, obj.GetNamespace(), toKind, name,
+	))
+}
+
+// namespaceOr returns ns, or def if ns is empty.
+func namespaceOr(ns, def string) string {
+	if ns != "" {
+		return ns
+	}
+	return def
+}
+
+// varValue returns the value of the variable, read from its secret or
+// configmap key if it references one.
+func varValue(ctx context.Context, r client.Reader, ns string, v dbv1alpha1.Var) (string, error) {
+	switch from := v.ValueFrom; {
+	case from.SecretKeyRef != nil:
+		return getSecretValue(ctx, r, ns, *from.SecretKeyRef)
+	case from.ConfigMapKeyRef != nil:
+		return getConfigMapValue(ctx, r, ns, *from.ConfigMapKeyRef)
+	}
+	return v.Value, nil
+}
+
+// getConfigMapValue gets the value of the given configmap key selector.
+func getConfigMapValue(
+	ctx context.Context,
+	r client.Reader,
+	ns string,
+	selector corev1.ConfigMapKeySelector,
+) (string, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: ns, Name: selector.Name}, cm); err != nil {
+		return "", transient(err)
+	}
+	v, ok := cm.Data[selector.Key]
+	if !ok {
+		return "", fmt.Errorf("configmap %s/%s does not contain key %s", ns, selector.Name, selector.Key)
+	}
+	return v, nil
+}
+
+// getOwnedConfigMap gets the ConfigMap with the given name in the namespace
+// of the owner, and fails if it exists but is not controlled by the owner, so
+// resources never write to or delete ConfigMaps they did not create.
+func getOwnedConfigMap(ctx context.Context, r client.Reader, owner client.Object, name string, cm *corev1.ConfigMap) error {
+	if err := r.Get(ctx, client.ObjectKey{Namespace: owner.GetNamespace(), Name: name}, cm); err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(cm, owner) {
+		return fmt.Errorf("configmap %s/%s exists and is not owned by %s", owner.GetNamespace(), name, owner.GetName())
+	}
+	return nil
+}
+
+// deleteOwnedConfigMap deletes the ConfigMap with the given name, if it is
+// controlled by the owner.
+func deleteOwnedConfigMap(ctx context.Context, c client.Client, owner client.Object, name string) error {
+	cm := &corev1.ConfigMap{}
+	switch err := getOwnedConfigMap(ctx, c, owner, name, cm); {
+	case apierrors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+	if err := c.Delete(ctx, cm); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// hydrateCredentials hydrates the credentials with the password from the secret,
+// and the CA bundle of their TLS settings.
 func hydrateCredentials(ctx context.Context, creds *dbv1alpha1.Credentials, r client.Reader, ns string) error {
 	if creds.PasswordFrom.SecretKeyRef != nil {
 		sec, err := getSecretValue(ctx, r, ns, *creds.PasswordFrom.SecretKeyRef)
@@ -32,5 +275,135 @@ func hydrateCredentials(ctx context.Context, creds *dbv1alpha1.Credentials, r cl
 		}
 		creds.Password = sec
 	}
+	if creds.AzureAD != nil {
+		if err := azureToken(ctx, creds); err != nil {
+			return err
+		}
+	}
+	if creds.TLS != nil {
+		if err := withCA(ctx, r, ns, creds); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// fetchURL returns the body of the resource at the given URL. If auth is set,
+// it is sent as the value of the Authorization header. Resources larger than
+// maxFetchSize are rejected, and redirects are followed to https URLs only,
+// as the header is kept on redirects to the same host.
+func fetchURL(ctx context.Context, c *http.Client, u, auth string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	var redirectErr error
+	hc := *c
+	hc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		switch {
+		case req.URL.Scheme != "https":
+			redirectErr = fmt.Errorf("fetching %s: redirect to %s is not allowed, only https redirects are followed", u, req.URL.Redacted())
+		case len(via) >= 10:
+			redirectErr = fmt.Errorf("fetching %s: stopped after 10 redirects", u)
+		}
+		return redirectErr
+	}
+	resp, err := hc.Do(req)
+	if redirectErr != nil {
+		return "", redirectErr
+	}
+	if err != nil {
+		return "", transient(err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return "", transient(fmt.Errorf("fetching %s: unexpected status %s", u, resp.Status))
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("fetching %s: unexpected status %s", u, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		return "", transient(err)
+	}
+	if len(b) > maxFetchSize {
+		return "", fmt.Errorf("fetching %s: the resource exceeds %d bytes", u, maxFetchSize)
+	}
+	return string(b), nil
+}
+
+// verifyDigest verifies the content matches the given digest, in the form
+// "sha256:<hex>". An empty digest is not verified.
+func verifyDigest(content, digest string) error {
+	if digest == "" {
+		return nil
+	}
+	algo, want, ok := strings.Cut(digest, ":")
+	if !ok || algo != "sha256" {
+		return fmt.Errorf("unsupported digest %q, expected sha256:<hex>", digest)
+	}
+	h := sha256.Sum256([]byte(content))
+	if got := hex.EncodeToString(h[:]); !strings.EqualFold(got, want) {
+		return fmt.Errorf("digest mismatch: expected sha256:%s, got sha256:%s", want, got)
+	}
+	return nil
+}
+
+// transitions returns the transitions of the conditions from before to after.
+// A condition transitions when it is added, or when its status or reason changes.
+func transitions(before, after []metav1.Condition) (t []dbv1alpha1.ConditionTransition) {
+	for _, c := range after {
+		if p := meta.FindStatusCondition(before, c.Type); p != nil && p.Status == c.Status && p.Reason == c.Reason {
+			continue
+		}
+		msg := c.Message
+		if len(msg) > dbv1alpha1.MaxTransitionMessage {
+			msg = msg[:dbv1alpha1.MaxTransitionMessage]
+		}
+		t = append(t, dbv1alpha1.ConditionTransition{
+			Time:    metav1.Now(),
+			Type:    c.Type,
+			Status:  c.Status,
+			Reason:  c.Reason,
+			Message: msg,
+		})
+	}
+	return t
+}
+
+// sqlScript renders the statements as a SQL script. If max is positive, only
+// the statements fitting in max bytes are kept, and it reports if others were
+// omitted.
+func sqlScript(stmts []string, max int) (string, bool) {
+	var b strings.Builder
+	for _, s := range stmts {
+		s += ";\n"
+		if max > 0 && b.Len()+len(s) > max {
+			// Cut the first statement if it does not fit alone.
+			if b.Len() == 0 {
+				n := max - 1
+				for n > 0 && !utf8.RuneStart(s[n]) {
+					n--
+				}
+				b.WriteString(s[:n] + "\n")
+			}
+			return b.String(), true
+		}
+		b.WriteString(s)
+	}
+	return b.String(), false
+}
+
+// redactedScript renders the statements as a SQL script like sqlScript, with
+// their string literals redacted as they may hold secrets, e.g. the passwords
+// of users.
+func redactedScript(stmts []string, max int) (string, bool) {
+	redacted := make([]string, len(stmts))
+	for i, s := range stmts {
+		redacted[i] = sqlString.ReplaceAllString(s, "'xxxxx'")
+	}
+	return sqlScript(redacted, max)
+}
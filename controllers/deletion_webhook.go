@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const (
+	// DeletionWebhookPath is the path the deletion webhook is served on.
+	DeletionWebhookPath = "/validate-db-atlasgo-io-v1alpha1-atlasmigration-deletion"
+	// forceDeleteAnnotation allows deleting an AtlasMigration while its
+	// migrations are applied, e.g. if the operator that started the apply is gone.
+	forceDeleteAnnotation = "db.atlasgo.io/force-delete"
+)
+
+// DeletionValidator validates the deletion of AtlasMigration resources. It
+// rejects deleting a resource whose migrations are being applied, as the
+// directory would disappear from the cluster half-applied, and warns about
+// deleting a resource with migrations that are not applied yet.
+type DeletionValidator struct{}
+
+// NewDeletionValidator returns a new DeletionValidator.
+func NewDeletionValidator() *DeletionValidator {
+	return &DeletionValidator{}
+}
+
+//+kubebuilder:webhook:path=/validate-db-atlasgo-io-v1alpha1-atlasmigration-deletion,mutating=false,failurePolicy=ignore,sideEffects=None,groups=db.atlasgo.io,resources=atlasmigrations,verbs=delete,versions=v1alpha1,name=deletion.atlasgo.io,admissionReviewVersions=v1
+
+// Handle implements admission.Handler.
+func (v *DeletionValidator) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Delete {
+		return admission.Allowed("")
+	}
+	var am dbv1alpha1.AtlasMigration
+	if err := json.Unmarshal(req.OldObject.Raw, &am); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if a := am.Status.Applying; a != nil && am.Annotations[forceDeleteAnnotation] != "true" {
+		return admission.Denied(fmt.Sprintf(
+			"migrations of atlasmigrations %s/%s are being applied by %s since %s. Retry once the apply is done, or set the %s annotation to \"true\" to delete it anyway",
+			am.Namespace, am.Name, a.Holder, a.StartedAt.UTC().Format(time.RFC3339), forceDeleteAnnotation,
+		))
+	}
+	return admission.Allowed("").WithWarnings(pendingWarnings(&am)...)
+}
+
+// pendingWarnings returns the warnings of deleting a migration resource
+// with migrations that are not applied yet.
+func pendingWarnings(am *dbv1alpha1.AtlasMigration) []string {
+	var warns []string
+	if a := am.Status.Applying; a != nil {
+		warns = append(warns, fmt.Sprintf("migrations are being applied by %s, and the database may be left half-migrated", a.Holder))
+	}
+	if a := am.Status.Approval; a != nil && !a.Approved && len(a.Files) > 0 {
+		warns = append(warns, fmt.Sprintf("%d migration files awaiting approval will not be applied", len(a.Files)))
+	}
+	if c := meta.FindStatusCondition(am.Status.Conditions, dbv1alpha1.MigrateReadyCond); c != nil && c.Reason == dbv1alpha1.ReasonBatchApplied {
+		warns = append(warns, fmt.Sprintf("the migration directory is partially applied: %s", c.Message))
+	}
+	return warns
+}
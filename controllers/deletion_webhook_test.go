@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func TestDeletionValidator(t *testing.T) {
+	v := NewDeletionValidator()
+	del := func(am *dbv1alpha1.AtlasMigration) admission.Response {
+		b, err := json.Marshal(am)
+		require.NoError(t, err)
+		return v.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Delete,
+			OldObject: runtime.RawExtension{Raw: b},
+		}})
+	}
+	am := &dbv1alpha1.AtlasMigration{ObjectMeta: migrationObjmeta()}
+
+	// Applied resources are deleted without warnings.
+	resp := del(am)
+	require.True(t, resp.Allowed)
+	require.Empty(t, resp.Warnings)
+
+	// Resources with pending migrations are deleted with warnings.
+	am.Status.Approval = &dbv1alpha1.MigrationApprovalStatus{Files: []dbv1alpha1.ApprovalFile{{Version: "1"}, {Version: "2"}}}
+	am.Status.Conditions = []metav1.Condition{{Type: "Ready", Status: metav1.ConditionFalse, Reason: "BatchApplied", Message: "Applied 2 of 5 files"}}
+	resp = del(am)
+	require.True(t, resp.Allowed)
+	require.Equal(t, []string{
+		"2 migration files awaiting approval will not be applied",
+		"the migration directory is partially applied: Applied 2 of 5 files",
+	}, resp.Warnings)
+
+	// Resources with an apply in flight are not deleted.
+	am.Status.Approval, am.Status.Conditions = nil, nil
+	am.Status.Applying = &dbv1alpha1.ApplyingStatus{
+		Holder:    "operator-0",
+		StartedAt: metav1.NewTime(time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)),
+	}
+	resp = del(am)
+	require.False(t, resp.Allowed)
+	require.EqualValues(t, `migrations of atlasmigrations default/atlas-migration are being applied by operator-0 since 2023-06-01T12:00:00Z. Retry once the apply is done, or set the db.atlasgo.io/force-delete annotation to "true" to delete it anyway`, string(resp.Result.Reason))
+
+	// Unless forced.
+	am.Annotations = map[string]string{forceDeleteAnnotation: "true"}
+	resp = del(am)
+	require.True(t, resp.Allowed)
+	require.Equal(t, []string{"migrations are being applied by operator-0, and the database may be left half-migrated"}, resp.Warnings)
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"ariga.io/atlas/sql/migrate"
+	"golang.org/x/exp/slices"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+)
+
+// approveDestructiveAnnotation approves applying the destructive statements of
+// the pending files up to the version it holds, or of the schema plan with the
+// hash it holds. It approves a single apply, and is removed once it ran.
+const approveDestructiveAnnotation = "db.atlasgo.io/approve-destructive"
+
+var (
+	// destructiveStmt matches the statements that drop data: DROP TABLE, DROP SCHEMA,
+	// DROP DATABASE and TRUNCATE. ALTER TABLE is captured, as it drops data only if
+	// one of its DROP clauses drops a column.
+	destructiveStmt = regexp.MustCompile(`(?is)^\s*(?:DROP\s+(?:TABLE|SCHEMA|DATABASE)\b|TRUNCATE\b|(ALTER\s+TABLE\b))`)
+	// dropClause matches the DROP clauses of an ALTER TABLE statement. COLUMN is
+	// optional, e.g. in ALTER TABLE t DROP c, so the name of the dropped object
+	// is captured, and is a column unless it is one of dropKeywords.
+	dropClause = regexp.MustCompile(`(?is)\bDROP\s+(?:COLUMN\b|(\w+))`)
+	// dropKeywords start the DROP clauses of ALTER TABLE that drop no data.
+	dropKeywords = []string{
+		"CHECK", "CONSTRAINT", "DEFAULT", "EXPRESSION", "FOREIGN", "FULLTEXT", "IDENTITY",
+		"INDEX", "KEY", "NOT", "PARTITION", "PERIOD", "PRIMARY", "SPATIAL", "SYSTEM", "UNIQUE",
+	}
+)
+
+// isDestructive reports if the statement drops data.
+func isDestructive(stmt string) bool {
+	m := destructiveStmt.FindStringSubmatch(stmt)
+	if m == nil || m[1] == "" {
+		return m != nil
+	}
+	for _, c := range dropClause.FindAllStringSubmatch(stmt, -1) {
+		if c[1] == "" || !slices.Contains(dropKeywords, strings.ToUpper(c[1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// destructiveMigrationErr is returned when pending files hold destructive
+// statements that were not approved.
+type destructiveMigrationErr struct {
+	// version of the last destructive file.
+	version string
+	stmts   []string
+	// expired is the time the approvals of the migration expired, if they did.
+	expired time.Time
+}
+
+func (e *destructiveMigrationErr) Error() string {
+	return fmt.Sprintf("pending migration files hold destructive statements:\n- %s\nApply them by setting the %s annotation to %q%s",
+		strings.Join(e.stmts, "\n- "), approveDestructiveAnnotation, e.version, expiredNote(e.expired))
+}
+
+// destructivePlanErr is returned when the plan of a schema holds destructive
+// changes that were not approved.
+type destructivePlanErr struct {
+	hash string
+	lint *destructiveErr
+	// expired is the time the approvals of the schema expired, if they did.
+	expired time.Time
+}
+
+func (e *destructivePlanErr) Error() string {
+	return fmt.Sprintf("%sApply plan %s once by setting the %s annotation to %q%s",
+		e.lint.Error(), e.hash, approveDestructiveAnnotation, e.hash, expiredNote(e.expired))
+}
+
+// approveDestructive returns a *destructivePlanErr unless the approve-destructive
+// annotation of the schema holds the hash of the plan.
+func (r *AtlasSchemaReconciler) approveDestructive(sc *dbv1alpha1.AtlasSchema, plan *dbv1alpha1.SchemaPlan, lint *destructiveErr) error {
+	expired := approvalsExpired(sc)
+	if sc.Annotations[approveDestructiveAnnotation] != plan.Hash || !expired.IsZero() {
+		return &destructivePlanErr{hash: plan.Hash, lint: lint, expired: expired}
+	}
+	r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonDestructiveApproved,
+		"Destructive changes of plan %s were approved by the %s annotation", plan.Hash, approveDestructiveAnnotation)
+	return nil
+}
+
+// consumeAnnotation removes an annotation approving a single run, e.g. the
+// approve-destructive and confirm-down annotations, once the run it approved
+// completed, so it cannot approve later runs. A copy is updated, to keep the
+// status set by the running reconcile.
+func consumeAnnotation(ctx context.Context, c client.Client, obj client.Object, name string) error {
+	if _, ok := obj.GetAnnotations()[name]; !ok {
+		return nil
+	}
+	cp := obj.DeepCopyObject().(client.Object)
+	a := cp.GetAnnotations()
+	delete(a, name)
+	cp.SetAnnotations(a)
+	if err := c.Update(ctx, cp); err != nil {
+		return err
+	}
+	obj.SetAnnotations(cp.GetAnnotations())
+	obj.SetResourceVersion(cp.GetResourceVersion())
+	return nil
+}
+
+// approveDestructiveChanged triggers a reconcile when the approve-destructive annotation changes.
+var approveDestructiveChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.ObjectNew.GetAnnotations()[approveDestructiveAnnotation] != e.ObjectOld.GetAnnotations()[approveDestructiveAnnotation]
+	},
+}
+
+// checkDestructive scans the pending files for destructive statements. It
+// returns a *destructiveMigrationErr unless the approve-destructive annotation
+// is set to the version of the last destructive file.
+func checkDestructive(md atlasMigrationData, pending []atlas.File) error {
+	if md.Migration == nil {
+		return errors.New("policy.denyDestructive requires a configmap or local migration directory")
+	}
+	dir, err := migrate.NewLocalDir(strings.TrimPrefix(md.Migration.Dir, "file://"))
+	if err != nil {
+		return err
+	}
+	files, err := dir.Files()
+	if err != nil {
+		return err
+	}
+	versions := make(map[string]bool, len(pending))
+	for _, f := range pending {
+		versions[f.Version] = true
+	}
+	dErr := &destructiveMigrationErr{}
+	for _, f := range files {
+		if !versions[f.Version()] {
+			continue
+		}
+		stmts, err := f.StmtDecls()
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", f.Name(), err)
+		}
+		for _, s := range stmts {
+			if isDestructive(s.Text) {
+				dErr.version = f.Version()
+				dErr.stmts = append(dErr.stmts, fmt.Sprintf("%s: %s", f.Name(), strings.TrimSpace(s.Text)))
+			}
+		}
+	}
+	if len(dErr.stmts) == 0 || md.approveDestructive == dErr.version {
+		return nil
+	}
+	dErr.expired = md.approvalsExpired
+	return dErr
+}
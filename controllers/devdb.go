@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const (
+	// lastUsedAnnotation records when the ephemeral dev database of an
+	// AtlasMigration was last used.
+	lastUsedAnnotation = "atlasgo.io/last-used"
+	// migrationDevDBSuffix names the ephemeral dev databases of AtlasMigration
+	// resources, apart from the dev databases of AtlasSchema resources.
+	migrationDevDBSuffix = "-atlas-migration-dev-db"
+)
+
+// devDBImages are the default images of the dev databases run by the
+// operator, by the scheme of their URL.
+var devDBImages = map[string]string{
+	"mysql":    "mysql:8",
+	"mariadb":  "mariadb:11",
+	"postgres": "postgres:15",
+}
+
+// devDBScheme returns the scheme of the dev database of a target URL. MariaDB
+// targets are planned on MariaDB, as their dialect differs from MySQL.
+func devDBScheme(u *url.URL) string {
+	if u.Scheme == "mariadb" {
+		return u.Scheme
+	}
+	return driver(u.Scheme)
+}
+
+// devDBImage returns the image of the dev database with the given scheme.
+func devDBImage(scheme string, cfg *dbv1alpha1.DevDB) string {
+	if cfg != nil && cfg.Image != "" {
+		return cfg.Image
+	}
+	return devDBImages[scheme]
+}
+
+// checkDevDBImage fails if the dev database runs an image set by the resource
+// that the operator does not allow. The default images are always allowed.
+func checkDevDBImage(allowed []string, cfg *dbv1alpha1.DevDB) error {
+	if cfg == nil || cfg.Image == "" || imageAllowed(allowed, cfg.Image) {
+		return nil
+	}
+	return fmt.Errorf("dev database image %q is not allowed, the operator must be started with --allowed-images matching it", cfg.Image)
+}
+
+// newDevDB renders the Deployment of a dev database with the given scheme,
+// owned by the resource.
+func newDevDB(owner client.Object, s *runtime.Scheme, name, scheme string, schemaBound bool, cfg *dbv1alpha1.DevDB) (*v1.Deployment, error) {
+	v := devDB{
+		Name:        name,
+		Namespace:   owner.GetNamespace(),
+		Driver:      driver(scheme),
+		Scheme:      scheme,
+		Image:       devDBImage(scheme, cfg),
+		UID:         1000,
+		SchemaBound: schemaBound,
+	}
+	switch v.Driver {
+	case "postgres":
+		v.Port = 5432
+		v.UID = 999
+		v.DB = "postgres"
+	case "mysql":
+		v.Port = 3306
+		if scheme == "mariadb" {
+			v.UID = 999
+		}
+		if v.SchemaBound {
+			v.DB = "dev"
+		}
+	default:
+		return nil, fmt.Errorf("no dev database for %q URLs, set devURL", scheme)
+	}
+	var b bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&b, "devdb.tmpl", &v); err != nil {
+		return nil, err
+	}
+	d := &v1.Deployment{}
+	if err := yaml.NewYAMLToJSONDecoder(&b).Decode(d); err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		d.Spec.Template.Spec.Containers[0].Resources = cfg.Resources
+	}
+	if err := ctrl.SetControllerReference(owner, d, s); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// devDBPodURL returns the URL of the running pod of a dev database. Errors
+// of dev databases that are not running yet are transient.
+func devDBPodURL(ctx context.Context, r client.Reader, name, driver string) (string, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.MatchingLabels(map[string]string{
+		"app.kubernetes.io/instance": name,
+		"atlasgo.io/engine":          driver,
+	})); err != nil {
+		return "", transient(err)
+	}
+	if len(pods.Items) == 0 {
+		return "", transient(
+			errors.New("no pods found"),
+		)
+	}
+	idx := slices.IndexFunc(pods.Items, func(p corev1.Pod) bool {
+		return p.Status.Phase == corev1.PodRunning
+	})
+	if idx == -1 {
+		return "", transient(
+			errors.New("no running pods found"),
+		)
+	}
+	pod := pods.Items[idx]
+	ct, ok := pod.Annotations["atlasgo.io/conntmpl"]
+	if !ok {
+		// If the connection template is not found, there is an issue with the pod spec and the error
+		// is not transient.
+		return "", errors.New("no connection template label found")
+	}
+	return strings.ReplaceAll(
+		ct,
+		hostReplace,
+		fmt.Sprintf("%s:%d", pod.Status.PodIP, pod.Spec.Containers[0].Ports[0].ContainerPort),
+	), nil
+}
+
+// devDBPendingErr is returned when the dev database of a reconcile is not
+// running yet.
+type devDBPendingErr struct {
+	name string
+}
+
+func (e *devDBPendingErr) Error() string {
+	return fmt.Sprintf("waiting for the dev database %s to run", e.name)
+}
+
+// ephemeralDevDB is the dev database an AtlasMigration runs when a reconcile
+// requires one and devURL is not set. It is created on first use, and deleted
+// once it is unused for the TTL of spec.devDB.
+type ephemeralDevDB struct {
+	r      *AtlasMigrationReconciler
+	owner  *dbv1alpha1.AtlasMigration
+	target *url.URL
+	cfg    *dbv1alpha1.DevDB
+	// url is set once the dev database was used by the reconcile, and pending
+	// while the reconcile waits for it to run.
+	url     string
+	pending bool
+}
+
+func (e *ephemeralDevDB) key() types.NamespacedName {
+	return types.NamespacedName{Name: e.owner.Name + migrationDevDBSuffix, Namespace: e.owner.Namespace}
+}
+
+// URL returns the URL of the dev database, and creates it if it does not
+// exist. A *devDBPendingErr is returned until it runs.
+func (e *ephemeralDevDB) URL(ctx context.Context) (string, error) {
+	scheme := devDBScheme(e.target)
+	switch {
+	case e.url != "":
+		return e.url, nil
+	case scheme == "sqlite":
+		return "sqlite://db?mode=memory", nil
+	}
+	m := &managed{driver: driver(scheme), url: e.target}
+	d := &v1.Deployment{}
+	switch err := e.r.Get(ctx, e.key(), d); {
+	case apierrors.IsNotFound(err):
+		if err := checkDevDBImage(e.r.allowedImages, e.cfg); err != nil {
+			return "", err
+		}
+		d, err := newDevDB(e.owner, e.r.Scheme, e.key().Name, scheme, m.schemaBound(), e.cfg)
+		if err != nil {
+			return "", err
+		}
+		if err := e.r.Create(ctx, d); err != nil {
+			return "", transient(err)
+		}
+		e.r.recorder.Eventf(e.owner, corev1.EventTypeNormal, dbv1alpha1.ReasonCreatedDevDB, "Created dev database deployment: %s", d.Name)
+		e.pending = true
+		return "", &devDBPendingErr{name: d.Name}
+	case err != nil:
+		return "", transient(err)
+	case !metav1.IsControlledBy(d, e.owner):
+		return "", fmt.Errorf("deployment %s exists and is not owned by %s", d.Name, e.owner.Name)
+	}
+	u, err := devDBPodURL(ctx, e.r, d.Name, m.driver)
+	switch {
+	case isTransient(err):
+		e.pending = true
+		return "", &devDBPendingErr{name: d.Name}
+	case err != nil:
+		return "", err
+	}
+	if e.url, err = devScope(u, m); err != nil {
+		return "", err
+	}
+	return e.url, nil
+}
+
+// dev returns the URL of the dev database of the migration, and runs an
+// ephemeral one if devURL is not set.
+func (d atlasMigrationData) dev(ctx context.Context) (string, error) {
+	if d.devURL != "" || d.devDB == nil {
+		return d.devURL, nil
+	}
+	return d.devDB.URL(ctx)
+}
+
+// release deletes the dev database once it is unused for its TTL, and
+// returns the result of the reconcile requeued for its deletion otherwise.
+func (e *ephemeralDevDB) release(ctx context.Context, res ctrl.Result) ctrl.Result {
+	d := &v1.Deployment{}
+	if e.pending || e.r.Get(ctx, e.key(), d) != nil || !metav1.IsControlledBy(d, e.owner) {
+		return res
+	}
+	var ttl time.Duration
+	if e.cfg != nil && e.cfg.TTL != nil {
+		ttl = e.cfg.TTL.Duration
+	}
+	now := time.Now()
+	last := now
+	if e.url == "" {
+		// Dev databases without a valid record of their last use are expired.
+		last, _ = time.Parse(time.RFC3339, d.Annotations[lastUsedAnnotation])
+	}
+	if idle := now.Sub(last); idle < ttl {
+		if e.url != "" {
+			if d.Annotations == nil {
+				d.Annotations = make(map[string]string)
+			}
+			d.Annotations[lastUsedAnnotation] = now.Format(time.RFC3339)
+			if err := e.r.Update(ctx, d); err != nil {
+				log.FromContext(ctx).Error(err, "failed to record the use of the dev database")
+			}
+		}
+		if wait := ttl - idle; !res.Requeue && (res.RequeueAfter == 0 || wait < res.RequeueAfter) {
+			res.RequeueAfter = wait
+		}
+		return res
+	}
+	if err := e.r.Delete(ctx, d); err != nil && !apierrors.IsNotFound(err) {
+		e.r.recorder.Eventf(e.owner, corev1.EventTypeWarning, dbv1alpha1.ReasonCleanUpDevDB, "Error deleting dev database deployment %s: %v", d.Name, err)
+	}
+	return res
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// specDevURL returns the URL of the dedicated dev database set in the spec of
+// a resource, either inline or as a secret key reference. An empty URL means
+// the resource does not define a dev database.
+func specDevURL(ctx context.Context, r client.Reader, obj client.Object, kind, devURL string, from dbv1alpha1.URLFrom) (string, error) {
+	switch {
+	case devURL != "":
+		return devURL, nil
+	case from.SecretKeyRef != nil:
+		ns, err := refNamespace(ctx, r, obj, kind, from.Namespace, "Secret", from.SecretKeyRef.Name)
+		if err != nil {
+			return "", err
+		}
+		return urlFromSecret(ctx, r, ns, from)
+	}
+	return "", nil
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// mermaidIdent matches the characters that are not allowed in Mermaid entity
+// names and attribute types.
+var mermaidIdent = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// schemaDiagram renders the ER diagram of the inspected schemas in the Mermaid
+// or DOT format. Tables are qualified by their schema when the diagram holds
+// more than one schema.
+func schemaDiagram(format string, realm inspectedRealm) ([]byte, error) {
+	switch format {
+	case "mermaid":
+		return mermaidDiagram(realm), nil
+	case "dot":
+		return dotDiagram(realm), nil
+	default:
+		return nil, fmt.Errorf("unsupported diagram format %q", format)
+	}
+}
+
+// tableName returns the name of the table in the diagram. Tables referenced by
+// foreign keys without a schema belong to the schema of the referencing table.
+func (r inspectedRealm) tableName(schema, table string) string {
+	if len(r.Schemas) < 2 {
+		return table
+	}
+	if strings.Contains(table, ".") {
+		return table
+	}
+	return schema + "." + table
+}
+
+func mermaidDiagram(realm inspectedRealm) []byte {
+	var (
+		b     strings.Builder
+		ident = func(s string) string { return strings.Trim(mermaidIdent.ReplaceAllString(s, "_"), "_") }
+	)
+	b.WriteString("erDiagram\n")
+	for _, s := range realm.Schemas {
+		for _, t := range s.Tables {
+			keys := tableKeys(t)
+			fmt.Fprintf(&b, "  %s {\n", ident(realm.tableName(s.Name, t.Name)))
+			for _, c := range t.Columns {
+				fmt.Fprintf(&b, "    %s %s", ident(c.Type), ident(c.Name))
+				if k := keys[c.Name]; k != "" {
+					fmt.Fprintf(&b, " %s", k)
+				}
+				b.WriteString("\n")
+			}
+			b.WriteString("  }\n")
+		}
+	}
+	for _, s := range realm.Schemas {
+		for _, t := range s.Tables {
+			for _, fk := range t.ForeignKeys {
+				fmt.Fprintf(&b, "  %s ||--o{ %s : %q\n",
+					ident(realm.tableName(s.Name, fk.References.Table)), ident(realm.tableName(s.Name, t.Name)), fk.Name)
+			}
+		}
+	}
+	return []byte(b.String())
+}
+
+func dotDiagram(realm inspectedRealm) []byte {
+	var b strings.Builder
+	b.WriteString("digraph schema {\n  rankdir=LR;\n  node [shape=plaintext];\n")
+	for _, s := range realm.Schemas {
+		for _, t := range s.Tables {
+			name := realm.tableName(s.Name, t.Name)
+			keys := tableKeys(t)
+			fmt.Fprintf(&b, "  %q [label=<<table border=\"0\" cellborder=\"1\" cellspacing=\"0\"><tr><td><b>%s</b></td></tr>",
+				name, html.EscapeString(name))
+			for _, c := range t.Columns {
+				label := html.EscapeString(c.Name + " " + c.Type)
+				if k := keys[c.Name]; k != "" {
+					label += " (" + k + ")"
+				}
+				fmt.Fprintf(&b, "<tr><td port=%q align=\"left\">%s</td></tr>", c.Name, label)
+			}
+			b.WriteString("</table>>];\n")
+		}
+	}
+	for _, s := range realm.Schemas {
+		for _, t := range s.Tables {
+			for _, fk := range t.ForeignKeys {
+				fmt.Fprintf(&b, "  %q -> %q [label=%q];\n",
+					realm.tableName(s.Name, t.Name), realm.tableName(s.Name, fk.References.Table), fk.Name)
+			}
+		}
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// tableKeys returns the key markers of the columns of the table: PK for the
+// columns of the primary key, and FK for the columns of foreign keys.
+func tableKeys(t inspectedTable) map[string]string {
+	keys := make(map[string]string)
+	if pk := t.PrimaryKey; pk != nil {
+		for _, p := range pk.Parts {
+			keys[p.Column] = "PK"
+		}
+	}
+	for _, fk := range t.ForeignKeys {
+		for _, c := range fk.Columns {
+			if keys[c] == "PK" {
+				keys[c] = "PK, FK"
+			} else {
+				keys[c] = "FK"
+			}
+		}
+	}
+	return keys
+}
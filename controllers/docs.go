@@ -0,0 +1,313 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+)
+
+// htmlDocs renders the HTML documentation of schemas, escaping their names.
+var htmlDocs = htmltemplate.Must(htmltemplate.ParseFS(tmpls, "templates/docs.html.tmpl"))
+
+type (
+	// inspectedRealm is the JSON output of the 'schema inspect' command.
+	inspectedRealm struct {
+		Schemas []inspectedSchema `json:"schemas"`
+	}
+	inspectedSchema struct {
+		Name   string           `json:"name"`
+		Tables []inspectedTable `json:"tables"`
+	}
+	inspectedTable struct {
+		Name    string `json:"name"`
+		Columns []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+			Null bool   `json:"null"`
+		} `json:"columns"`
+		PrimaryKey  *inspectedIndex       `json:"primary_key"`
+		Indexes     []inspectedIndex      `json:"indexes"`
+		ForeignKeys []inspectedForeignKey `json:"foreign_keys"`
+	}
+	inspectedIndex struct {
+		Name   string          `json:"name"`
+		Unique bool            `json:"unique"`
+		Parts  []inspectedPart `json:"parts"`
+	}
+	inspectedPart struct {
+		Column string `json:"column"`
+		Expr   string `json:"expr"`
+	}
+	inspectedForeignKey struct {
+		Name       string   `json:"name"`
+		Columns    []string `json:"columns"`
+		References struct {
+			Table   string   `json:"table"`
+			Columns []string `json:"columns"`
+		} `json:"references"`
+	}
+)
+
+// docsKeysAnnotation holds the comma-separated keys of the docs ConfigMap
+// written by the operator. Other keys are kept as is.
+const docsKeysAnnotation = "db.atlasgo.io/docs-keys"
+
+// docsKeys are the ConfigMap keys holding the documentation and the
+// diagram, by format.
+var docsKeys = map[string]string{
+	"markdown": "schema.md",
+	"html":     "schema.html",
+	"mermaid":  "schema.mmd",
+	"dot":      "schema.dot",
+}
+
+// Label returns the column or the expression of the index part.
+func (p inspectedPart) Label() string {
+	if p.Column != "" {
+		return p.Column
+	}
+	return p.Expr
+}
+
+// publishDocs generates the documentation of the applied schema, and publishes
+// it to the destinations set in the spec. The documentation is generated again
+// only when changes were applied, or the destinations changed. Failing to
+// publish it does not fail the reconcile, and is recorded as a warning event.
+// The documentation of schemas read from secrets is not published, as it
+// describes the secret schema.
+func (r *AtlasSchemaReconciler) publishDocs(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed, app *atlas.SchemaApply) {
+	d := sc.Spec.Docs
+	if d == nil {
+		sc.Status.Docs = nil
+		return
+	}
+	if m.secret {
+		sc.Status.Docs = nil
+		r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonSchemaDocsError, "The documentation of schemas read from secrets is not published")
+		return
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(b)
+	h := hex.EncodeToString(sum[:])
+	if s := sc.Status.Docs; s != nil && s.SpecHash == h && (app == nil || len(app.Changes.Applied) == 0) {
+		return
+	}
+	if err := r.exportDocs(ctx, sc, m, h); err != nil {
+		r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonSchemaDocsError, err.Error())
+	}
+}
+
+// exportDocs renders the documentation of the inspected schema and exports it.
+func (r *AtlasSchemaReconciler) exportDocs(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed, h string) error {
+	d := sc.Spec.Docs
+	format := d.Format
+	if format == "" {
+		format = "markdown"
+	}
+	out, err := r.cli.SchemaInspect(ctx, &atlas.SchemaInspectParams{
+		URL:     m.url.String(),
+		Format:  "json",
+		Schema:  m.schemas,
+		Exclude: m.exclude,
+	})
+	if err != nil {
+		return fmt.Errorf("inspecting the schema: %w", err)
+	}
+	var realm inspectedRealm
+	if err := json.Unmarshal([]byte(out), &realm); err != nil {
+		return fmt.Errorf("decoding the inspected schema: %w", err)
+	}
+	docs, err := schemaDocs(fmt.Sprintf("Schema of %s/%s", sc.Namespace, sc.Name), format, realm)
+	if err != nil {
+		return err
+	}
+	data := map[string]string{docsKeys[format]: string(docs)}
+	var diagram []byte
+	if d.Diagram != "" {
+		if diagram, err = schemaDiagram(d.Diagram, realm); err != nil {
+			return err
+		}
+		data[docsKeys[d.Diagram]] = string(diagram)
+	}
+	status := &dbv1alpha1.DocsStatus{GeneratedAt: metav1.Now(), SpecHash: h}
+	if d.ConfigMap {
+		name := docsName(sc)
+		if err := r.storeDocs(ctx, sc, name, data); err != nil {
+			return err
+		}
+		status.ConfigMap = name
+	}
+	if d.URL != "" {
+		ct := "text/markdown; charset=utf-8"
+		if format == "html" {
+			ct = "text/html; charset=utf-8"
+		}
+		if err := putDocs(ctx, r.uploadClient, d.URL, ct, docs); err != nil {
+			return err
+		}
+	}
+	if d.DiagramURL != "" && diagram != nil {
+		if err := putDocs(ctx, r.uploadClient, d.DiagramURL, "text/plain; charset=utf-8", diagram); err != nil {
+			return err
+		}
+	}
+	sc.Status.Docs = status
+	return nil
+}
+
+// schemaDocs renders the documentation of the inspected schemas.
+func schemaDocs(title, format string, realm inspectedRealm) ([]byte, error) {
+	data := struct {
+		Title string
+		inspectedRealm
+	}{title, realm}
+	var buf bytes.Buffer
+	switch format {
+	case "markdown":
+		if err := tmpl.ExecuteTemplate(&buf, "docs.md.tmpl", data); err != nil {
+			return nil, err
+		}
+	case "html":
+		if err := htmlDocs.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported docs format %q", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// docsName returns the name of the ConfigMap holding the documentation of the schema.
+func docsName(sc *dbv1alpha1.AtlasSchema) string {
+	if n := sc.Spec.Docs.Name; n != "" {
+		return n
+	}
+	return sc.Name + "-docs"
+}
+
+// storeDocs stores the documentation in the ConfigMap with the given name,
+// owned by the schema. The keys it wrote for other formats are removed, and
+// keys it did not write are kept.
+func (r *AtlasSchemaReconciler) storeDocs(ctx context.Context, sc *dbv1alpha1.AtlasSchema, name string, data map[string]string) error {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	cm := &corev1.ConfigMap{}
+	switch err := getOwnedConfigMap(ctx, r, sc, name, cm); {
+	case apierrors.IsNotFound(err):
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   sc.Namespace,
+				Annotations: map[string]string{docsKeysAnnotation: strings.Join(keys, ",")},
+			},
+			Data: data,
+		}
+		if err := ctrl.SetControllerReference(sc, cm, r.scheme); err != nil {
+			return err
+		}
+		return r.Create(ctx, cm)
+	case err != nil:
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	if prev := cm.Annotations[docsKeysAnnotation]; prev != "" {
+		for _, k := range strings.Split(prev, ",") {
+			delete(cm.Data, k)
+		}
+	}
+	for k, v := range data {
+		cm.Data[k] = v
+	}
+	if cm.Annotations == nil {
+		cm.Annotations = make(map[string]string)
+	}
+	cm.Annotations[docsKeysAnnotation] = strings.Join(keys, ",")
+	return r.Update(ctx, cm)
+}
+
+// errPrivateAddr is returned when an upload URL resolves to a non-public address.
+var errPrivateAddr = errors.New("uploading to private, loopback or link-local addresses is not allowed")
+
+// newUploadClient returns the HTTP client uploading the documentation. It
+// connects only to public addresses, so the URLs set by the resources cannot
+// reach the services of the cluster or the metadata endpoints of the cloud,
+// including through hosts resolving to such addresses.
+func newUploadClient() *http.Client {
+	d := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return errPrivateAddr
+			}
+			return nil
+		},
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = d.DialContext
+	return &http.Client{Timeout: 30 * time.Second, Transport: t}
+}
+
+// publicIP reports if the address is routable on the internet.
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsUnspecified() && !ip.IsMulticast() && !ip.IsInterfaceLocalMulticast()
+}
+
+// putDocs uploads the documentation to the URL with a PUT request, e.g. a
+// pre-signed URL of an object storage bucket.
+func putDocs(ctx context.Context, hc *http.Client, u, contentType string, docs []byte) error {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if pu.Scheme != "https" {
+		return fmt.Errorf("docs url must use https, got %q", pu.Scheme)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(docs))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("uploading schema docs: unexpected status %s", resp.Status)
+	}
+	return nil
+}
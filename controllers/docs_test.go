@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const inspectedJSON = `{"schemas":[{"name":"test","tables":[
+{"name":"orgs","columns":[{"name":"id","type":"int"}],"primary_key":{"parts":[{"column":"id"}]}},
+{"name":"users","columns":[{"name":"id","type":"int"},{"name":"email","type":"varchar(255)","null":true},{"name":"org_id","type":"int"}],
+"primary_key":{"parts":[{"column":"id"}]},
+"indexes":[{"name":"email_lower","unique":true,"parts":[{"expr":"lower(email)"}]}],
+"foreign_keys":[{"name":"users_org","columns":["org_id"],"references":{"table":"orgs","columns":["id"]}}]}
+]}]}`
+
+func TestReconcile_Docs(t *testing.T) {
+	var (
+		received    []string
+		contentType string
+	)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPut, r.Method)
+		contentType = r.Header.Get("Content-Type")
+		b, _ := io.ReadAll(r.Body)
+		received = append(received, string(b))
+	}))
+	defer srv.Close()
+	tt := newTest(t)
+	tt.r.uploadClient = srv.Client()
+	sc := conditionReconciling()
+	sc.Spec.Docs = &dbv1alpha1.SchemaDocs{ConfigMap: true, URL: srv.URL}
+	tt.k8s.put(sc)
+	tt.k8s.put(devDBReady())
+	tt.mockCLI().inspect = inspectedJSON
+	_, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Namespace: "test", Name: "my-atlas-schema-docs"}
+	cm := tt.k8s.state[key].(*corev1.ConfigMap)
+	require.Equal(t, "my-atlas-schema", cm.OwnerReferences[0].Name)
+	require.Equal(t, "# Schema of test/my-atlas-schema\n\n"+
+		"## test\n\n"+
+		"### orgs\n\n"+
+		"| Column | Type | Nullable |\n|--------|------|----------|\n"+
+		"| id | int | no |\n\n"+
+		"Primary key: `id`\n\n"+
+		"### users\n\n"+
+		"| Column | Type | Nullable |\n|--------|------|----------|\n"+
+		"| id | int | no |\n"+
+		"| email | varchar(255) | yes |\n"+
+		"| org_id | int | no |\n\n"+
+		"Primary key: `id`\n\n"+
+		"Indexes:\n\n"+
+		"- `email_lower` (unique): `lower(email)`\n\n"+
+		"Foreign keys:\n\n"+
+		"- `users_org`: `org_id` → `orgs` (`id`)\n", cm.Data["schema.md"])
+	require.Equal(t, []string{cm.Data["schema.md"]}, received)
+	require.Equal(t, "text/markdown; charset=utf-8", contentType)
+	sc = tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema)
+	require.Equal(t, "my-atlas-schema-docs", sc.Status.Docs.ConfigMap)
+
+	// The docs are not generated again if nothing changed.
+	tt.k8s.put(devDBReady())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+
+	// Changing the format replaces the docs.
+	tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema).Spec.Docs.Format = "html"
+	tt.k8s.put(devDBReady())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.Len(t, received, 2)
+	require.Equal(t, "text/html; charset=utf-8", contentType)
+	cm = tt.k8s.state[key].(*corev1.ConfigMap)
+	require.NotContains(t, cm.Data, "schema.md")
+	require.Equal(t, "schema.html", cm.Annotations[docsKeysAnnotation])
+	require.Contains(t, cm.Data["schema.html"], "<tr><td>email</td><td>varchar(255)</td><td>yes</td></tr>")
+	require.Contains(t, cm.Data["schema.html"], "<code>org_id</code> &rarr; <code>orgs</code>")
+
+	// The diagram is stored alongside the docs, and keys not written by the
+	// operator are kept.
+	cm.Data["README"] = "generated by the operator"
+	tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema).Spec.Docs.Diagram = "mermaid"
+	tt.k8s.put(devDBReady())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	cm = tt.k8s.state[key].(*corev1.ConfigMap)
+	require.Contains(t, cm.Data, "schema.html")
+	require.Contains(t, cm.Data["schema.mmd"], "orgs ||--o{ users : \"users_org\"")
+	require.Equal(t, "generated by the operator", cm.Data["README"])
+
+	// Failing to publish the docs does not block the reconcile.
+	srv.Close()
+	tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema).Spec.Docs.Format = "markdown"
+	tt.k8s.put(devDBReady())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	events := tt.events()
+	require.Contains(t, events[len(events)-1], "Warning SchemaDocsError")
+}
+
+func TestStoreDocs_owned(t *testing.T) {
+	tt := newTest(t)
+	sc := conditionReconciling()
+	tt.k8s.put(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "test"},
+		Data:       map[string]string{"config.yaml": "debug: false"},
+	})
+	// ConfigMaps not owned by the schema are never written.
+	err := tt.r.storeDocs(context.Background(), sc, "app-config", map[string]string{"schema.md": "# Schema"})
+	require.EqualError(t, err, "configmap test/app-config exists and is not owned by my-atlas-schema")
+	cm := tt.k8s.state[types.NamespacedName{Namespace: "test", Name: "app-config"}].(*corev1.ConfigMap)
+	require.Equal(t, map[string]string{"config.yaml": "debug: false"}, cm.Data)
+}
+
+func TestPutDocs(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+	ctx := context.Background()
+	require.EqualError(t, putDocs(ctx, srv.Client(), "http://docs.internal/schema.md", "text/markdown", nil), `docs url must use https, got "http"`)
+	// The upload client connects only to public addresses.
+	err := putDocs(ctx, newUploadClient(), srv.URL, "text/markdown", nil)
+	require.ErrorIs(t, err, errPrivateAddr)
+	for ip, public := range map[string]bool{
+		"8.8.8.8":         true,
+		"127.0.0.1":       false,
+		"10.0.0.1":        false,
+		"169.254.169.254": false,
+		"::1":             false,
+		"fd00::1":         false,
+		"0.0.0.0":         false,
+	} {
+		require.Equal(t, public, publicIP(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestSchemaDocs_escape(t *testing.T) {
+	b, err := schemaDocs("<docs>", "html", inspectedRealm{Schemas: []inspectedSchema{{Name: "<script>"}}})
+	require.NoError(t, err)
+	require.Contains(t, string(b), "<h2>&lt;script&gt;</h2>")
+	require.Contains(t, string(b), "<title>&lt;docs&gt;</title>")
+	_, err = schemaDocs("docs", "pdf", inspectedRealm{})
+	require.EqualError(t, err, `unsupported docs format "pdf"`)
+}
+
+func TestSchemaDiagram(t *testing.T) {
+	var realm inspectedRealm
+	require.NoError(t, json.Unmarshal([]byte(inspectedJSON), &realm))
+	b, err := schemaDiagram("mermaid", realm)
+	require.NoError(t, err)
+	require.Equal(t, "erDiagram\n"+
+		"  orgs {\n    int id PK\n  }\n"+
+		"  users {\n    int id PK\n    varchar_255 email\n    int org_id FK\n  }\n"+
+		"  orgs ||--o{ users : \"users_org\"\n", string(b))
+	b, err = schemaDiagram("dot", realm)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `<tr><td port="org_id" align="left">org_id int (FK)</td></tr>`)
+	require.Contains(t, string(b), `"users" -> "orgs" [label="users_org"];`)
+	_, err = schemaDiagram("svg", realm)
+	require.EqualError(t, err, `unsupported diagram format "svg"`)
+}
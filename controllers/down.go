@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+)
+
+// confirmDownAnnotation confirms reverting the database to the version it
+// holds. It must match the version of the resource for the revert to run.
+const confirmDownAnnotation = "db.atlasgo.io/confirm-down"
+
+// downErr is returned when a revert of applied migrations is not allowed.
+type downErr struct {
+	reason string
+	msg    string
+}
+
+func (e *downErr) Error() string {
+	return e.msg
+}
+
+// confirmDownChanged triggers a reconcile when the confirm-down annotation changes.
+var confirmDownChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.ObjectNew.GetAnnotations()[confirmDownAnnotation] != e.ObjectOld.GetAnnotations()[confirmDownAnnotation]
+	},
+}
+
+// checkDown returns a downErr if reverting the database from its current
+// version to the target version is not allowed by the policy of the resource.
+func checkDown(md atlasMigrationData, current string) error {
+	switch {
+	case !md.allowDown:
+		return &downErr{
+			reason: dbv1alpha1.ReasonDownNotAllowed,
+			msg:    fmt.Sprintf("version %s is lower than the current version %s, and policy.allowDown is not set", md.version, current),
+		}
+	case md.confirmDown != md.version:
+		return &downErr{
+			reason: dbv1alpha1.ReasonDownNotConfirmed,
+			msg: fmt.Sprintf("reverting version %s to %s requires the %s annotation set to %q%s",
+				current, md.version, confirmDownAnnotation, md.version, expiredNote(md.approvalsExpired)),
+		}
+	case md.devURL == "" && md.devDB == nil:
+		return errors.New("devURL is required for migrating down")
+	}
+	return nil
+}
+
+// down reverts the applied migrations down to the target version.
+func (r *AtlasMigrationReconciler) down(ctx context.Context, md atlasMigrationData, atlasHCL, hash string) (dbv1alpha1.AtlasMigrationStatus, error) {
+	devURL, err := md.dev(ctx)
+	if err != nil {
+		return dbv1alpha1.AtlasMigrationStatus{}, err
+	}
+	u, dir := md.overrides()
+	report, err := r.CLI.Down(ctx, &atlas.DownParams{
+		Env:       md.EnvName,
+		ConfigURL: atlasHCL,
+		URL:       u,
+		DirURL:    dir,
+		DevURL:    devURL,
+		ToVersion: md.version,
+		Vars:      md.vars,
+	})
+	if err != nil {
+		return dbv1alpha1.AtlasMigrationStatus{}, transient(err)
+	}
+	if report.Error != "" {
+		err = errors.New(report.Error)
+		if !isSQLErr(err) {
+			err = transient(err)
+		}
+		return dbv1alpha1.AtlasMigrationStatus{}, err
+	}
+	history := make([]dbv1alpha1.AppliedChange, 0, len(report.Reverted))
+	for _, f := range report.Reverted {
+		history = append(history, dbv1alpha1.AppliedChange{
+			Time:       metav1.NewTime(f.End),
+			Version:    f.Version,
+			Statements: f.Applied,
+		})
+	}
+	return dbv1alpha1.AtlasMigrationStatus{
+		ObservedHash:       hash,
+		LastApplied:        report.End.Unix(),
+		LastAppliedVersion: report.Target,
+		History:            history,
+	}, nil
+}
@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// defaultDriftInterval is the interval between two checks for drift, if the
+// drift detection of the schema does not set one.
+const defaultDriftInterval = 10 * time.Minute
+
+// driftKey is the ConfigMap key holding the full diff of the drift.
+const driftKey = "drift.sql"
+
+var (
+	schemaDrifts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "atlas_operator_schema_drift_total",
+		Help: "Number of times a database was detected to drift from its applied schema.",
+	}, []string{"namespace", "name"})
+	schemaDrifted = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "atlas_schema_drifted",
+		Help: "Whether the database of the schema drifted from its applied schema (1) or not (0). Set for schemas with drift detection only.",
+	}, []string{"namespace", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(schemaDrifts, schemaDrifted)
+}
+
+// driftInterval returns the interval between two checks for drift of the
+// schema, or zero if drift detection is not enabled.
+func driftInterval(sc *dbv1alpha1.AtlasSchema) time.Duration {
+	switch d := sc.Spec.DriftDetection; {
+	case d == nil:
+		return 0
+	case d.Interval != nil:
+		return d.Interval.Duration
+	default:
+		return defaultDriftInterval
+	}
+}
+
+// checkDrift detects drift of the database from the applied schema: changes
+// planned while the desired schema is unchanged since it was applied. The
+// drift is remediated according to the drift detection policy of the schema.
+// It reports true if the plan must not be applied, and the reconcile ends
+// with the returned result.
+func (r *AtlasSchemaReconciler) checkDrift(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed, plan *dbv1alpha1.SchemaPlan) (ctrl.Result, bool) {
+	dd := sc.Spec.DriftDetection
+	drifted := dd != nil && len(plan.Statements) > 0 && sc.Status.ObservedHash == m.hash() &&
+		meta.IsStatusConditionTrue(sc.Status.Conditions, dbv1alpha1.SchemaReadyCond)
+	if !drifted {
+		// Drift is resolved if the database is back in sync with the schema it
+		// drifted from, and not if the desired schema changed.
+		r.clearDrift(ctx, sc, len(plan.Statements) == 0 && sc.Status.ObservedHash == m.hash())
+		meta.RemoveStatusCondition(&sc.Status.Conditions, dbv1alpha1.DriftedCond)
+		return ctrl.Result{}, false
+	}
+	remediate := driftRemediation(dd)
+	// Drift is notified once, when it is first detected.
+	if sc.Status.Drift == nil {
+		sc.Status.Drift = &dbv1alpha1.DriftStatus{DetectedAt: metav1.Now()}
+		schemaDrifted.WithLabelValues(sc.Namespace, sc.Name).Set(1)
+		if remediate != dbv1alpha1.RemediateNone {
+			schemaDrifts.WithLabelValues(sc.Namespace, sc.Name).Inc()
+			msg := fmt.Sprintf("The database drifted from the applied schema by %d statements: %s", len(plan.Statements), driftSummary(plan.Statements))
+			if remediate == dbv1alpha1.RemediateApply {
+				msg += ". Applying the desired schema again"
+			}
+			r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonSchemaDrift, msg)
+		}
+	}
+	d := sc.Status.Drift
+	d.Statements = len(plan.Statements)
+	d.Diff, d.Truncated = redactedScript(plan.Statements, maxStatusSQL)
+	if m.secret {
+		d.Diff, d.Truncated = "", true
+	}
+	d.ConfigMap = ""
+	if dd.ConfigMap && !m.secret {
+		name := driftName(sc)
+		full, _ := redactedScript(plan.Statements, 0)
+		if err := r.storeScript(ctx, sc, name, driftKey, full); err != nil {
+			r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonSchemaDriftError, err.Error())
+		} else {
+			d.ConfigMap = name
+		}
+	}
+	// The drift is reported in the status until the plan is applied.
+	if remediate == dbv1alpha1.RemediateApply {
+		meta.RemoveStatusCondition(&sc.Status.Conditions, dbv1alpha1.DriftedCond)
+		return ctrl.Result{}, false
+	}
+	// The plan is not applied, and is planned again by the next check.
+	sc.Status.Plan = nil
+	r.clearPlanned(ctx, sc)
+	msg := fmt.Sprintf("the database drifted from the applied schema, %d statements are needed to bring it in sync:\n%s", d.Statements, d.Diff)
+	switch {
+	case m.secret:
+		msg = fmt.Sprintf("the database drifted from the applied schema, %d statements are needed to bring it in sync", d.Statements)
+	case d.Truncated && d.ConfigMap != "":
+		msg += fmt.Sprintf("-- truncated, the full diff is stored in ConfigMap %s", d.ConfigMap)
+	case d.Truncated:
+		msg += "-- truncated"
+	}
+	meta.SetStatusCondition(&sc.Status.Conditions, metav1.Condition{
+		Type:    dbv1alpha1.DriftedCond,
+		Status:  metav1.ConditionTrue,
+		Reason:  dbv1alpha1.ReasonSchemaDrift,
+		Message: msg,
+	})
+	return ctrl.Result{RequeueAfter: driftInterval(sc)}, true
+}
+
+// clearDrift removes the drift from the status of the schema, and deletes
+// the ConfigMap holding its diff. The resolution of a drift is notified like
+// its detection. Drift cleared without being resolved, e.g. as the desired
+// schema changed, is not notified.
+func (r *AtlasSchemaReconciler) clearDrift(ctx context.Context, sc *dbv1alpha1.AtlasSchema, resolved bool) {
+	dd, d := sc.Spec.DriftDetection, sc.Status.Drift
+	if dd == nil {
+		schemaDrifted.DeleteLabelValues(sc.Namespace, sc.Name)
+	} else {
+		schemaDrifted.WithLabelValues(sc.Namespace, sc.Name).Set(0)
+	}
+	if d == nil {
+		return
+	}
+	if d.ConfigMap != "" {
+		if err := deleteOwnedConfigMap(ctx, r, sc, d.ConfigMap); err != nil {
+			log.FromContext(ctx).Error(err, "failed to delete the drift ConfigMap", "name", d.ConfigMap)
+		}
+	}
+	if resolved && dd != nil && driftRemediation(dd) != dbv1alpha1.RemediateNone {
+		r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonSchemaDriftResolved,
+			"The drift of the database detected at %s was resolved", d.DetectedAt.UTC().Format(time.RFC3339))
+	}
+	sc.Status.Drift = nil
+}
+
+// driftRemediation returns the remediation of the drift detection.
+func driftRemediation(dd *dbv1alpha1.DriftDetection) string {
+	if dd.Remediate == "" {
+		return dbv1alpha1.RemediateNotify
+	}
+	return dd.Remediate
+}
+
+// driftSummary summarizes the statements by their kind, e.g.
+// "2 ALTER TABLE, 1 CREATE INDEX", in the order the kinds first appear.
+func driftSummary(stmts []string) string {
+	var (
+		kinds  []string
+		counts = make(map[string]int)
+	)
+	for _, s := range stmts {
+		f := strings.Fields(s)
+		if len(f) > 2 {
+			f = f[:2]
+		}
+		k := strings.ToUpper(strings.Join(f, " "))
+		if counts[k] == 0 {
+			kinds = append(kinds, k)
+		}
+		counts[k]++
+	}
+	parts := make([]string, len(kinds))
+	for i, k := range kinds {
+		parts[i] = fmt.Sprintf("%d %s", counts[k], k)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// driftName returns the name of the ConfigMap holding the diff of the drift.
+func driftName(sc *dbv1alpha1.AtlasSchema) string {
+	if n := sc.Spec.DriftDetection.Name; n != "" {
+		return n
+	}
+	return sc.Name + "-drift"
+}
+
+// storeScript stores the SQL script under the key of the ConfigMap with the
+// given name, owned by the schema. ConfigMaps of other owners are not changed.
+func (r *AtlasSchemaReconciler) storeScript(ctx context.Context, sc *dbv1alpha1.AtlasSchema, name, key, script string) error {
+	cm := &corev1.ConfigMap{}
+	switch err := getOwnedConfigMap(ctx, r, sc, name, cm); {
+	case apierrors.IsNotFound(err):
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: sc.Namespace},
+			Data:       map[string]string{key: script},
+		}
+		if err := ctrl.SetControllerReference(sc, cm, r.scheme); err != nil {
+			return err
+		}
+		return r.Create(ctx, cm)
+	case err != nil:
+		return err
+	}
+	if cm.Data[key] == script {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[key] = script
+	return r.Update(ctx, cm)
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func TestDriftInterval(t *testing.T) {
+	sc := conditionReconciling()
+	require.Zero(t, driftInterval(sc))
+	sc.Spec.DriftDetection = &dbv1alpha1.DriftDetection{}
+	require.Equal(t, defaultDriftInterval, driftInterval(sc))
+	sc.Spec.DriftDetection.Interval = &metav1.Duration{Duration: time.Minute}
+	require.Equal(t, time.Minute, driftInterval(sc))
+}
+
+func TestReconcile_Drift(t *testing.T) {
+	tt := newTest(t)
+	tt.mockCLI().plan = "ALTER TABLE `foo` ADD COLUMN `bar` int"
+	sc := conditionReconciling()
+	m, err := tt.r.extractManaged(context.Background(), sc)
+	require.NoError(t, err)
+	sc.Spec.DriftDetection = &dbv1alpha1.DriftDetection{Remediate: dbv1alpha1.RemediateNone}
+	sc.Status.LastApplied = 1
+	sc.Status.ObservedHash = m.hash()
+	sc.Status.Conditions[0].Status = metav1.ConditionTrue
+	tt.k8s.put(sc)
+	tt.k8s.put(devDBReady())
+	schema := func() *dbv1alpha1.AtlasSchema {
+		return tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema)
+	}
+	applied := func() (n int) {
+		for _, p := range tt.mockCLI().applyRuns {
+			if !p.DryRun {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Drift is reported in the status only.
+	res, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.Equal(t, defaultDriftInterval, res.RequeueAfter)
+	require.EqualValues(t, metav1.ConditionTrue, tt.cond().Status)
+	cond := meta.FindStatusCondition(schema().Status.Conditions, dbv1alpha1.DriftedCond)
+	require.NotNil(t, cond)
+	require.Equal(t, "SchemaDrift", cond.Reason)
+	require.Equal(t, 1, schema().Status.Drift.Statements)
+	require.Equal(t, "ALTER TABLE `foo` ADD COLUMN `bar` int;\n", schema().Status.Drift.Diff)
+	require.False(t, schema().Status.Drift.Truncated)
+	require.Nil(t, schema().Status.Plan)
+	require.Zero(t, applied())
+	require.Empty(t, tt.events())
+	require.EqualValues(t, 1, testutil.ToFloat64(schemaDrifted.WithLabelValues("test", "my-atlas-schema")))
+
+	// Drift is notified once, when it is first detected.
+	schema().Spec.DriftDetection.Remediate = dbv1alpha1.RemediateNotify
+	schema().Status.Drift = nil
+	tt.k8s.put(devDBReady())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.Equal(t, []string{"Warning SchemaDrift The database drifted from the applied schema by 1 statements: 1 ALTER TABLE"}, tt.events())
+	tt.k8s.put(devDBReady())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.Empty(t, tt.events())
+	require.Zero(t, applied())
+
+	// Drift is remediated by applying the desired schema again, which resolves it.
+	schema().Spec.DriftDetection.Remediate = dbv1alpha1.RemediateApply
+	schema().Status.Drift = &dbv1alpha1.DriftStatus{DetectedAt: metav1.NewTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))}
+	tt.k8s.put(devDBReady())
+	res, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.Equal(t, defaultDriftInterval, res.RequeueAfter)
+	require.Equal(t, 1, applied())
+	require.Equal(t, []string{
+		"Normal SchemaDriftResolved The drift of the database detected at 2024-01-02T03:04:05Z was resolved",
+		"Normal Applied Applied schema",
+	}, tt.events())
+	require.Zero(t, testutil.ToFloat64(schemaDrifted.WithLabelValues("test", "my-atlas-schema")))
+	require.EqualValues(t, metav1.ConditionTrue, tt.cond().Status)
+	require.Nil(t, schema().Status.Drift)
+	require.Nil(t, meta.FindStatusCondition(schema().Status.Conditions, dbv1alpha1.DriftedCond))
+
+	// Changing the desired schema clears the drift without resolving it.
+	schema().Status.Drift = &dbv1alpha1.DriftStatus{DetectedAt: metav1.Now()}
+	schema().Status.ObservedHash = "changed"
+	for i := 0; i < 2; i++ {
+		tt.k8s.put(devDBReady())
+		_, err = tt.r.Reconcile(context.Background(), req())
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, applied())
+	require.Equal(t, []string{"Normal Applied Applied schema"}, tt.events())
+	require.Nil(t, schema().Status.Drift)
+}
+
+func TestRedactedScript(t *testing.T) {
+	stmts := []string{
+		"CREATE USER 'app'@'%' IDENTIFIED BY 's3cr3t'",
+		"ALTER TABLE `users` ADD COLUMN `name` varchar(255) NOT NULL DEFAULT 'it''s'",
+		`ALTER TABLE users ALTER COLUMN name SET DEFAULT 'it\'s'`,
+	}
+	diff, truncated := redactedScript(stmts, 0)
+	require.False(t, truncated)
+	require.Equal(t, "CREATE USER 'xxxxx'@'xxxxx' IDENTIFIED BY 'xxxxx';\n"+
+		"ALTER TABLE `users` ADD COLUMN `name` varchar(255) NOT NULL DEFAULT 'xxxxx';\n"+
+		"ALTER TABLE users ALTER COLUMN name SET DEFAULT 'xxxxx';\n", diff)
+
+	// Statements that do not fit are omitted.
+	diff, truncated = redactedScript(stmts, 60)
+	require.True(t, truncated)
+	require.Equal(t, "CREATE USER 'xxxxx'@'xxxxx' IDENTIFIED BY 'xxxxx';\n", diff)
+	// The first statement is cut if it does not fit alone.
+	diff, truncated = redactedScript(stmts, 12)
+	require.True(t, truncated)
+	require.Equal(t, "CREATE USER\n", diff)
+}
+
+func TestReconcile_DriftConfigMap(t *testing.T) {
+	tt := newTest(t)
+	var stmts []string
+	for i := 0; i < 200; i++ {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE `foo` ADD COLUMN `c%d` varchar(255) NOT NULL DEFAULT 'secret'", i))
+	}
+	tt.mockCLI().plan = strings.Join(stmts, ";\n")
+	sc := conditionReconciling()
+	m, err := tt.r.extractManaged(context.Background(), sc)
+	require.NoError(t, err)
+	sc.Spec.DriftDetection = &dbv1alpha1.DriftDetection{Remediate: dbv1alpha1.RemediateNone, ConfigMap: true}
+	sc.Status.LastApplied = 1
+	sc.Status.ObservedHash = m.hash()
+	sc.Status.Conditions[0].Status = metav1.ConditionTrue
+	tt.k8s.put(sc)
+	tt.k8s.put(devDBReady())
+	schema := func() *dbv1alpha1.AtlasSchema {
+		return tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema)
+	}
+
+	// The status holds a truncated diff, and the ConfigMap the full one.
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	d := schema().Status.Drift
+	require.NotNil(t, d)
+	require.True(t, d.Truncated)
+	require.LessOrEqual(t, len(d.Diff), maxStatusSQL)
+	require.NotContains(t, d.Diff, "secret")
+	require.Equal(t, "my-atlas-schema-drift", d.ConfigMap)
+	cm, ok := tt.k8s.state[types.NamespacedName{Namespace: "test", Name: d.ConfigMap}].(*corev1.ConfigMap)
+	require.True(t, ok)
+	require.Equal(t, 200, strings.Count(cm.Data[driftKey], ";\n"))
+	require.NotContains(t, cm.Data[driftKey], "secret")
+	cond := meta.FindStatusCondition(schema().Status.Conditions, dbv1alpha1.DriftedCond)
+	require.NotNil(t, cond)
+	require.True(t, strings.HasSuffix(cond.Message, "-- truncated, the full diff is stored in ConfigMap my-atlas-schema-drift"))
+
+	// The ConfigMap is deleted once the drift is resolved.
+	tt.r.clearDrift(context.Background(), schema(), true)
+	require.Nil(t, schema().Status.Drift)
+	_, ok = tt.k8s.state[types.NamespacedName{Namespace: "test", Name: "my-atlas-schema-drift"}]
+	require.False(t, ok)
+
+	// ConfigMaps of other owners are neither overwritten nor deleted.
+	other := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-atlas-schema-drift", Namespace: "test"},
+		Data:       map[string]string{"app.conf": "debug=false"},
+	}
+	tt.k8s.put(other)
+	err = tt.r.storeScript(context.Background(), schema(), other.Name, driftKey, "DROP TABLE t;\n")
+	require.EqualError(t, err, "configmap test/my-atlas-schema-drift exists and is not owned by my-atlas-schema")
+	schema().Status.Drift = &dbv1alpha1.DriftStatus{ConfigMap: other.Name}
+	tt.r.clearDrift(context.Background(), schema(), true)
+	require.Equal(t, other.Data, tt.k8s.state[types.NamespacedName{Namespace: "test", Name: other.Name}].(*corev1.ConfigMap).Data)
+}
+
+func TestDriftSummary(t *testing.T) {
+	require.Equal(t, "2 ALTER TABLE, 1 CREATE INDEX", driftSummary([]string{
+		"ALTER TABLE `foo` ADD COLUMN `bar` int",
+		"create index `idx` on `foo` (`bar`)",
+		"ALTER TABLE `foo` DROP COLUMN `baz`",
+	}))
+}
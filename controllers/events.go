@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// eventLevels holds the events policy required to emit the events of the
+// given reasons. Unlisted warning events are always emitted, and unlisted
+// normal events, such as those of status checks, require the "normal" policy.
+var eventLevels = map[string]string{
+	// Outcomes of applies.
+	dbv1alpha1.ReasonApplied:             dbv1alpha1.EventsMinimal,
+	dbv1alpha1.ReasonBatchApplied:        dbv1alpha1.EventsMinimal,
+	dbv1alpha1.ReasonDryRun:              dbv1alpha1.EventsMinimal,
+	dbv1alpha1.ReasonApprovalPending:     dbv1alpha1.EventsMinimal,
+	dbv1alpha1.ReasonApproved:            dbv1alpha1.EventsMinimal,
+	dbv1alpha1.ReasonDestructiveApproved: dbv1alpha1.EventsMinimal,
+	dbv1alpha1.ReasonApplyRecovered:      dbv1alpha1.EventsMinimal,
+	dbv1alpha1.ReasonOnlineDDLSubmitted:  dbv1alpha1.EventsMinimal,
+	dbv1alpha1.ReasonPreviewApplied:      dbv1alpha1.EventsMinimal,
+	dbv1alpha1.ReasonRevisionsMoved:      dbv1alpha1.EventsMinimal,
+	dbv1alpha1.ReasonStatusImported:      dbv1alpha1.EventsMinimal,
+	// Details of applies and lints.
+	dbv1alpha1.ReasonFileApplied:    dbv1alpha1.EventsVerbose,
+	dbv1alpha1.ReasonLintDiagnostic: dbv1alpha1.EventsVerbose,
+}
+
+// eventRecorder drops the events that are more detailed than the events
+// policy of the object they are recorded on.
+type eventRecorder struct {
+	record.EventRecorder
+}
+
+// newEventRecorder returns an EventRecorder that follows the events policy
+// of the resources.
+func newEventRecorder(r record.EventRecorder) record.EventRecorder {
+	return &eventRecorder{EventRecorder: r}
+}
+
+// Event implements record.EventRecorder.
+func (r *eventRecorder) Event(obj runtime.Object, eventtype, reason, message string) {
+	if emitEvent(obj, eventtype, reason) {
+		r.EventRecorder.Event(obj, eventtype, reason, message)
+	}
+}
+
+// Eventf implements record.EventRecorder.
+func (r *eventRecorder) Eventf(obj runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	if emitEvent(obj, eventtype, reason) {
+		r.EventRecorder.Eventf(obj, eventtype, reason, messageFmt, args...)
+	}
+}
+
+// AnnotatedEventf implements record.EventRecorder.
+func (r *eventRecorder) AnnotatedEventf(obj runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	if emitEvent(obj, eventtype, reason) {
+		r.EventRecorder.AnnotatedEventf(obj, annotations, eventtype, reason, messageFmt, args...)
+	}
+}
+
+// emitEvent reports if an event of the given type and reason is emitted for
+// the object, according to its events policy.
+func emitEvent(obj runtime.Object, eventtype, reason string) bool {
+	level, ok := eventLevels[reason]
+	switch {
+	case ok:
+	case eventtype == corev1.EventTypeWarning:
+		level = dbv1alpha1.EventsMinimal
+	default:
+		level = dbv1alpha1.EventsNormal
+	}
+	return eventRank(level) <= eventRank(eventsPolicy(obj))
+}
+
+// eventsPolicy returns the events policy of the object.
+func eventsPolicy(obj runtime.Object) string {
+	switch o := obj.(type) {
+	case *dbv1alpha1.AtlasSchema:
+		return o.Spec.EventsPolicy
+	case *dbv1alpha1.AtlasMigration:
+		return o.Spec.EventsPolicy
+	}
+	return ""
+}
+
+// eventRank orders the events policies from the least to the most detailed.
+func eventRank(p string) int {
+	switch p {
+	case dbv1alpha1.EventsMinimal:
+		return 0
+	case dbv1alpha1.EventsVerbose:
+		return 2
+	}
+	return 1
+}
+
+// fileEvents records an event for each applied migration file.
+func fileEvents(rec record.EventRecorder, obj runtime.Object, changes []dbv1alpha1.AppliedChange) {
+	for _, c := range changes {
+		rec.Eventf(obj, corev1.EventTypeNormal, dbv1alpha1.ReasonFileApplied, "Version %s applied: %d statements", c.Version, len(c.Statements))
+	}
+}
+
+// lintEvents records an event for each finding of a lint. Findings failing
+// the lint are recorded as warnings.
+func lintEvents(rec record.EventRecorder, obj runtime.Object, lint *dbv1alpha1.LintStatus) {
+	if lint == nil {
+		return
+	}
+	for _, f := range lint.Files {
+		if f.Error != "" {
+			rec.Eventf(obj, corev1.EventTypeWarning, dbv1alpha1.ReasonLintDiagnostic, "%s: %s", f.Name, f.Error)
+		}
+		for _, d := range f.Diagnostics {
+			typ := corev1.EventTypeNormal
+			if d.Severity == "error" {
+				typ = corev1.EventTypeWarning
+			}
+			rec.Eventf(obj, typ, dbv1alpha1.ReasonLintDiagnostic, "%s: %s (%s)", f.Name, d.Text, d.Code)
+		}
+	}
+}
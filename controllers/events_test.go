@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func TestEventRecorder(t *testing.T) {
+	rec := newEventRecorder(record.NewFakeRecorder(100))
+	am := &dbv1alpha1.AtlasMigration{ObjectMeta: migrationObjmeta()}
+	emit := func() {
+		rec.Event(am, corev1.EventTypeWarning, "LintFailed", "failed")
+		rec.Event(am, corev1.EventTypeNormal, "Held", "held")
+		fileEvents(rec, am, []dbv1alpha1.AppliedChange{{Version: "1", Statements: []string{"CREATE TABLE t1 (id int)"}}})
+		lintEvents(rec, am, &dbv1alpha1.LintStatus{Files: []dbv1alpha1.LintFile{{
+			Name:        "1.sql",
+			Diagnostics: []dbv1alpha1.LintDiagnostic{{Code: "DS102", Severity: "error", Text: "Dropping table"}},
+		}}})
+		rec.Event(am, corev1.EventTypeNormal, "Applied", "Version 1 applied")
+	}
+
+	// The default policy emits the events of status checks.
+	emit()
+	require.Equal(t, []string{
+		"Warning LintFailed failed",
+		"Normal Held held",
+		"Normal Applied Version 1 applied",
+	}, events(rec))
+
+	am.Spec.EventsPolicy = dbv1alpha1.EventsMinimal
+	emit()
+	require.Equal(t, []string{
+		"Warning LintFailed failed",
+		"Normal Applied Version 1 applied",
+	}, events(rec))
+
+	am.Spec.EventsPolicy = dbv1alpha1.EventsVerbose
+	emit()
+	require.Equal(t, []string{
+		"Warning LintFailed failed",
+		"Normal Held held",
+		"Normal FileApplied Version 1 applied: 1 statements",
+		"Warning LintDiagnostic 1.sql: Dropping table (DS102)",
+		"Normal Applied Version 1 applied",
+	}, events(rec))
+}
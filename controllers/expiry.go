@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// approvalDeadlineAnnotation sets the time, in RFC 3339 format, after which
+// the approvals recorded on a resource are discarded: spec.approvedHash,
+// spec.approval.approvedPlan, status.approval, and the approve-destructive,
+// confirm-down and confirm-target annotations.
+const approvalDeadlineAnnotation = "db.atlasgo.io/approval-expires"
+
+// approvalDeadline returns the deadline of the approvals of the resource, or
+// the zero time if they never expire.
+func approvalDeadline(obj client.Object) (time.Time, error) {
+	v, ok := obj.GetAnnotations()[approvalDeadlineAnnotation]
+	if !ok {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s annotation: %w", approvalDeadlineAnnotation, err)
+	}
+	return t, nil
+}
+
+// approvalsExpired returns the deadline of the approvals of the resource if
+// it passed, and the zero time otherwise. Invalid deadlines are validated by
+// the reconcilers before the approvals are checked.
+func approvalsExpired(obj client.Object) time.Time {
+	t, err := approvalDeadline(obj)
+	if err != nil || t.IsZero() || time.Now().Before(t) {
+		return time.Time{}
+	}
+	return t
+}
+
+// expiredNote explains why the approvals of a change were discarded, if they
+// expired at the given time.
+func expiredNote(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf(". Approvals expired at %s and were discarded. Set the %s annotation to a later time to approve again",
+		t.UTC().Format(time.RFC3339), approvalDeadlineAnnotation)
+}
+
+// planApprovalExpired reports if the approval of the plan passed its deadline.
+func planApprovalExpired(plan *dbv1alpha1.AtlasPlan) bool {
+	s := plan.Status
+	return s.Phase == dbv1alpha1.PlanApproved && s.ExpiresAt != nil && !time.Now().Before(s.ExpiresAt.Time)
+}
+
+// expirePlanApproval returns a plan whose approval expired to review.
+func expirePlanApproval(ctx context.Context, c client.Client, plan *dbv1alpha1.AtlasPlan) error {
+	msg := fmt.Sprintf("The approval expired at %s", plan.Status.ExpiresAt.UTC().Format(time.RFC3339))
+	if r := plan.Status.Reviewer; r != "" {
+		msg = fmt.Sprintf("The approval by %s expired at %s", r, plan.Status.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	plan.Status = dbv1alpha1.AtlasPlanStatus{Phase: dbv1alpha1.PlanPending, Message: msg}
+	if err := c.Status().Update(ctx, plan); err != nil {
+		return transient(err)
+	}
+	return nil
+}
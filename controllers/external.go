@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const (
+	externalSuffix = "-external-schema"
+	// externalHashAnnotation holds the hash of the external schema definition a Job runs.
+	externalHashAnnotation = "db.atlasgo.io/external-schema-hash"
+	// externalScript runs the program with its standard error written to the
+	// termination message of the container, so the logs hold only the schema.
+	externalScript = `exec "$@" 2>/dev/termination-log`
+)
+
+type (
+	// JobLogReader is the interface used to read the output of completed Jobs.
+	JobLogReader interface {
+		Logs(ctx context.Context, namespace, job string) (string, error)
+		// Message returns the termination message of the succeeded pod of the Job.
+		Message(ctx context.Context, namespace, job string) (string, error)
+	}
+	// jobLogs reads the output of Jobs from the logs of their pods.
+	jobLogs struct {
+		cs kubernetes.Interface
+	}
+)
+
+// Logs returns the logs of the succeeded pod of the given Job.
+func (l *jobLogs) Logs(ctx context.Context, namespace, job string) (string, error) {
+	p, err := l.succeeded(ctx, namespace, job)
+	if err != nil {
+		return "", err
+	}
+	rc, err := l.cs.CoreV1().Pods(namespace).GetLogs(p.Name, &corev1.PodLogOptions{}).Stream(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Message returns the termination message of the succeeded pod of the given Job.
+func (l *jobLogs) Message(ctx context.Context, namespace, job string) (string, error) {
+	p, err := l.succeeded(ctx, namespace, job)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range p.Status.ContainerStatuses {
+		if t := s.State.Terminated; t != nil {
+			return t.Message, nil
+		}
+	}
+	return "", nil
+}
+
+// succeeded returns the succeeded pod of the given Job.
+func (l *jobLogs) succeeded(ctx context.Context, namespace, job string) (*corev1.Pod, error) {
+	pods, err := l.cs.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "job-name=" + job,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range pods.Items {
+		if p.Status.Phase == corev1.PodSucceeded {
+			return &pods.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no succeeded pod found for job %s/%s", namespace, job)
+}
+
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=core,resources=pods/log,verbs=get
+
+// externalSchema runs the external schema program of the resource as a Job,
+// and returns the schema it printed once the Job succeeds. The Job is kept
+// while its definition is unchanged, so the program is not run on every reconcile.
+func (r *AtlasSchemaReconciler) externalSchema(ctx context.Context, sc *dbv1alpha1.AtlasSchema, ext *dbv1alpha1.ExternalSchema) (string, error) {
+	if r.jobLogs == nil {
+		return "", errors.New("external schemas are not supported by this reconciler")
+	}
+	if !imageAllowed(r.allowedImages, ext.Image) {
+		return "", fmt.Errorf("image %q is not allowed, the operator must be started with --allowed-images matching it", ext.Image)
+	}
+	b, err := json.Marshal(ext)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	h := hex.EncodeToString(sum[:])[:12]
+	key := types.NamespacedName{Namespace: sc.Namespace, Name: sc.Name + externalSuffix}
+	job := &batchv1.Job{}
+	switch err := r.Get(ctx, key, job); {
+	case apierrors.IsNotFound(err):
+		if err := r.createExternalJob(ctx, sc, ext, key, h); err != nil {
+			return "", err
+		}
+		return "", transient(fmt.Errorf("waiting for external schema job %s", key.Name))
+	case err != nil:
+		return "", transient(err)
+	}
+	// The definition changed. Delete the Job, and create it again on the next reconcile.
+	if job.Annotations[externalHashAnnotation] != h {
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			return "", transient(err)
+		}
+		return "", transient(fmt.Errorf("replacing external schema job %s", key.Name))
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return "", fmt.Errorf("external schema job %s failed: %s", key.Name, c.Message)
+		}
+	}
+	if job.Status.Succeeded == 0 {
+		return "", transient(fmt.Errorf("waiting for external schema job %s", key.Name))
+	}
+	out, err := r.jobLogs.Logs(ctx, key.Namespace, key.Name)
+	if err != nil {
+		return "", transient(err)
+	}
+	return out, nil
+}
+
+func (r *AtlasSchemaReconciler) createExternalJob(ctx context.Context, sc *dbv1alpha1.AtlasSchema, ext *dbv1alpha1.ExternalSchema, key types.NamespacedName, h string) error {
+	var backoff int32 = 2
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        key.Name,
+			Namespace:   key.Namespace,
+			Annotations: map[string]string{externalHashAnnotation: h},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoff,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:  "schema",
+						Image: ext.Image,
+						// The image entrypoint is not used, the program is run by the shell of the image.
+						Command:                  []string{"sh", "-c", externalScript, "sh"},
+						Args:                     ext.Command,
+						Env:                      ext.Env,
+						TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+					}},
+				},
+			},
+		},
+	}
+	if err := ctrl.SetControllerReference(sc, job, r.scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, job); err != nil {
+		return transient(err)
+	}
+	r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonCreatedExternalSchemaJob, "Created external schema job: %s", job.Name)
+	return nil
+}
+
+// imageAllowed reports if the image matches one of the allowed prefixes. A
+// prefix matches the images of its repository or registry path, e.g.
+// "ghcr.io/org/" or "ghcr.io/org/app", but not "ghcr.io/org/app-other".
+func imageAllowed(allowed []string, image string) bool {
+	for _, p := range allowed {
+		switch {
+		case p == "" || !strings.HasPrefix(image, p):
+		case len(image) == len(p), strings.HasSuffix(p, "/"), strings.ContainsRune(":@", rune(image[len(p)])):
+			return true
+		}
+	}
+	return false
+}
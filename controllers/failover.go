@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// Applies are marked in the status of the resources before they start, and the
+// marker is cleared by the same reconcile once the apply ends. Reconciles of a
+// resource never run concurrently, and only the leader reconciles, so a marker
+// found when a reconcile starts belongs to an apply interrupted by a restart or
+// a failover of the operator.
+
+// markApplying records the applying marker of the schema before it is applied.
+// The migration context of Vitess applies is recorded with it, so migrations
+// submitted by an interrupted apply are awaited instead of submitted again.
+func (r *AtlasSchemaReconciler) markApplying(ctx context.Context, sc *dbv1alpha1.AtlasSchema, des *managed) error {
+	sc.Status.Applying = &dbv1alpha1.ApplyingStatus{Holder: r.identity, StartedAt: metav1.Now()}
+	sc.Status.MigrationContext = des.migrationContext
+	if err := r.Status().Update(ctx, sc); err != nil {
+		return transient(err)
+	}
+	return nil
+}
+
+// recoverApply adopts an interrupted apply of the schema. Schema applies are
+// declarative, so the changes left are planned again against the current
+// state of the database.
+func (r *AtlasSchemaReconciler) recoverApply(sc *dbv1alpha1.AtlasSchema) {
+	m := sc.Status.Applying
+	sc.Status.Applying = nil
+	r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonApplyRecovered,
+		"Recovered the apply started by %s at %s. The remaining changes are planned again", m.Holder, m.StartedAt.UTC().Format(time.RFC3339))
+}
+
+// markApplying records the applying marker of the migration before it is applied.
+func (r *AtlasMigrationReconciler) markApplying(ctx context.Context, am *dbv1alpha1.AtlasMigration) error {
+	am.Status.Applying = &dbv1alpha1.ApplyingStatus{Holder: r.identity, StartedAt: metav1.Now()}
+	if err := r.Status().Update(ctx, am); err != nil {
+		return transient(err)
+	}
+	return nil
+}
+
+// recoverApply adopts an interrupted apply of the migration. Applied files are
+// recorded in the revisions table, so the pending files are applied by the next
+// apply. A file that was partially applied cannot be resumed safely, and the
+// marker is kept until it is resolved manually, e.g. with "atlas migrate set".
+func (r *AtlasMigrationReconciler) recoverApply(ctx context.Context, am *dbv1alpha1.AtlasMigration, md atlasMigrationData) error {
+	atlasHCL, cleanUp, err := md.render()
+	if err != nil {
+		return err
+	}
+	defer cleanUp()
+	status, err := r.CLI.Status(ctx, md.statusParams(atlasHCL))
+	if err != nil {
+		return transient(err)
+	}
+	m := am.Status.Applying
+	if status.Error != "" {
+		return fmt.Errorf("the apply started by %s at %s was interrupted, leaving a partially applied migration: %s",
+			m.Holder, m.StartedAt.UTC().Format(time.RFC3339), status.Error)
+	}
+	am.Status.Applying = nil
+	r.recorder.Eventf(am, corev1.EventTypeNormal, dbv1alpha1.ReasonApplyRecovered,
+		"Recovered the apply started by %s at %s at version %s", m.Holder, m.StartedAt.UTC().Format(time.RFC3339), status.Current)
+	return nil
+}
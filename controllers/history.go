@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ariga.io/atlas/sql/migrate"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+)
+
+// historyErr is returned when migration files that were already applied to
+// the database were modified or removed from the directory.
+type historyErr struct {
+	modified, removed []string
+}
+
+func (e *historyErr) Error() string {
+	var parts []string
+	if len(e.modified) > 0 {
+		parts = append(parts, fmt.Sprintf("modified versions: %s", strings.Join(e.modified, ", ")))
+	}
+	if len(e.removed) > 0 {
+		parts = append(parts, fmt.Sprintf("removed versions: %s", strings.Join(e.removed, ", ")))
+	}
+	return fmt.Sprintf("applied migration files changed since they were applied (%s). "+
+		"Restore the files, or revert the versions before changing them", strings.Join(parts, "; "))
+}
+
+// reason returns the reason of the HistoryDiverged condition.
+func (e *historyErr) reason() string {
+	switch {
+	case len(e.modified) > 0 && len(e.removed) > 0:
+		return dbv1alpha1.ReasonFilesModifiedAndRemoved
+	case len(e.modified) > 0:
+		return dbv1alpha1.ReasonFilesModified
+	default:
+		return dbv1alpha1.ReasonFilesRemoved
+	}
+}
+
+// checkHistory compares the revisions applied to the database with the files
+// of the migration directory. It returns a *historyErr if applied files were
+// removed, or modified since the hashes recorded in the status. Otherwise, it
+// returns the hashes of the applied files to record. Files of remote
+// directories are not hashed, and only their removal is detected.
+func checkHistory(md atlasMigrationData, status *atlas.StatusReport) (map[string]string, error) {
+	var (
+		hashes   map[string]string
+		recorded map[string]string
+		err      error
+	)
+	if md.owner != nil {
+		recorded = md.owner.Status.AppliedFiles
+	}
+	if md.Migration != nil {
+		if hashes, err = dirHashes(md); err != nil {
+			return nil, err
+		}
+	} else if len(status.Available) > 0 {
+		hashes = make(map[string]string, len(status.Available))
+		for _, f := range status.Available {
+			hashes[f.Version] = ""
+		}
+	} else {
+		return nil, nil
+	}
+	var (
+		hErr    historyErr
+		applied = make(map[string]string, len(status.Applied))
+	)
+	for _, rev := range status.Applied {
+		// Baseline revisions have no file.
+		if strings.Contains(strings.ToLower(rev.Type), "baseline") || rev.Version == md.Baseline {
+			continue
+		}
+		h, ok := hashes[rev.Version]
+		switch prev, seen := recorded[rev.Version]; {
+		case !ok:
+			hErr.removed = append(hErr.removed, rev.Version)
+		case seen && h != "" && prev != h:
+			hErr.modified = append(hErr.modified, rev.Version)
+		case h != "":
+			applied[rev.Version] = h
+		}
+	}
+	if len(hErr.modified) > 0 || len(hErr.removed) > 0 {
+		sort.Strings(hErr.modified)
+		sort.Strings(hErr.removed)
+		return nil, &hErr
+	}
+	if len(applied) == 0 {
+		return nil, nil
+	}
+	return applied, nil
+}
+
+// appliedHashes returns the hashes of the applied files, including the given
+// versions applied by the current reconcile.
+func appliedHashes(md atlasMigrationData, prev map[string]string, versions []string) (map[string]string, error) {
+	if md.Migration == nil || len(versions) == 0 {
+		return prev, nil
+	}
+	hashes, err := dirHashes(md)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]string, len(prev)+len(versions))
+	for v, h := range prev {
+		applied[v] = h
+	}
+	for _, v := range versions {
+		if h, ok := hashes[v]; ok {
+			applied[v] = h
+		}
+	}
+	return applied, nil
+}
+
+// dirHashes returns the hashes of the files of the local migration directory by version.
+func dirHashes(md atlasMigrationData) (map[string]string, error) {
+	dir, err := migrate.NewLocalDir(strings.TrimPrefix(md.Migration.Dir, "file://"))
+	if err != nil {
+		return nil, err
+	}
+	files, err := dir.Files()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]string, len(files))
+	for _, f := range files {
+		sum := sha256.Sum256(f.Bytes())
+		hashes[f.Version()] = base64.StdEncoding.EncodeToString(sum[:])
+	}
+	return hashes, nil
+}
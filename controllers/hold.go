@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// holdLabel marks the Leases holding the applies of the resources in their
+	// namespace, e.g. during a coordinated release of several services.
+	holdLabel = "db.atlasgo.io/hold"
+	// holdSelectorAnnotation holds the label selector of the resources held by
+	// a Lease. All resources of the namespace are held if it is not set.
+	holdSelectorAnnotation = "db.atlasgo.io/hold-selector"
+	// holdNamespacesAnnotation holds the comma-separated namespaces held by a
+	// Lease of the hold namespace of the operator. All namespaces are held if it
+	// is not set.
+	holdNamespacesAnnotation = "db.atlasgo.io/hold-namespaces"
+	// holdRetry is the maximum delay before checking a hold again.
+	holdRetry = 30 * time.Second
+)
+
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch
+
+// HoldLeaseSelector selects the Leases holding applies. The cache of the
+// manager must be restricted to them, as the reconcilers watch Leases, and the
+// cluster holds many others, e.g. the heartbeats of the nodes.
+func HoldLeaseSelector() labels.Selector {
+	return labels.SelectorFromSet(labels.Set{holdLabel: "true"})
+}
+
+// holdErr is returned when the applies of a resource are held by a Lease.
+type holdErr struct {
+	lease, holder string
+	// expires is the time the hold expires, zero if it does not expire.
+	expires time.Time
+}
+
+func (e *holdErr) Error() string {
+	msg := fmt.Sprintf("applies are held by Lease %s", e.lease)
+	if e.holder != "" {
+		msg += fmt.Sprintf(" of %s", e.holder)
+	}
+	if !e.expires.IsZero() {
+		msg += fmt.Sprintf(" until %s", e.expires.UTC().Format(time.RFC3339))
+	}
+	return msg
+}
+
+// retry returns the delay before checking the hold again.
+func (e *holdErr) retry(now time.Time) time.Duration {
+	if d := e.expires.Sub(now); !e.expires.IsZero() && d < holdRetry {
+		return d
+	}
+	return holdRetry
+}
+
+// checkHold returns a *holdErr if an active Lease holds the applies of the
+// given resource. Leases are looked up in the namespace of the resource, and
+// in the hold namespace of the operator, if set. A Lease is released by
+// deleting it, or by not renewing it within its duration.
+func checkHold(ctx context.Context, c client.Reader, holdNS string, obj client.Object) error {
+	namespaces := []string{obj.GetNamespace()}
+	if holdNS != "" && holdNS != obj.GetNamespace() {
+		namespaces = append(namespaces, holdNS)
+	}
+	now := time.Now()
+	for _, ns := range namespaces {
+		var leases coordinationv1.LeaseList
+		if err := c.List(ctx, &leases, client.InNamespace(ns), client.MatchingLabelsSelector{Selector: HoldLeaseSelector()}); err != nil {
+			return transient(err)
+		}
+		for i := range leases.Items {
+			l := &leases.Items[i]
+			expires, active := leaseExpiry(l, now)
+			if !active || !holds(ctx, l, obj, ns != obj.GetNamespace()) {
+				continue
+			}
+			e := &holdErr{lease: fmt.Sprintf("%s/%s", l.Namespace, l.Name), expires: expires}
+			if l.Spec.HolderIdentity != nil {
+				e.holder = *l.Spec.HolderIdentity
+			}
+			return e
+		}
+	}
+	return nil
+}
+
+// leaseExpiry returns the expiry time of the Lease, and reports if it is
+// still active. Leases without a duration do not expire.
+func leaseExpiry(l *coordinationv1.Lease, now time.Time) (time.Time, bool) {
+	if l.Spec.LeaseDurationSeconds == nil {
+		return time.Time{}, true
+	}
+	start := l.CreationTimestamp.Time
+	switch {
+	case l.Spec.RenewTime != nil:
+		start = l.Spec.RenewTime.Time
+	case l.Spec.AcquireTime != nil:
+		start = l.Spec.AcquireTime.Time
+	}
+	expires := start.Add(time.Duration(*l.Spec.LeaseDurationSeconds) * time.Second)
+	return expires, now.Before(expires)
+}
+
+// holds reports if the Lease holds the given resource. Leases of the hold
+// namespace match the namespaces they list. Invalid selectors hold all
+// resources, to fail on the safe side.
+func holds(ctx context.Context, l *coordinationv1.Lease, obj client.Object, global bool) bool {
+	if nss, ok := l.Annotations[holdNamespacesAnnotation]; global && ok {
+		var found bool
+		for _, ns := range strings.Split(nss, ",") {
+			if strings.TrimSpace(ns) == obj.GetNamespace() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	s, ok := l.Annotations[holdSelectorAnnotation]
+	if !ok {
+		return true
+	}
+	sel, err := labels.Parse(s)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "invalid hold selector, holding all resources", "lease", l.Name)
+		return true
+	}
+	return sel.Matches(labels.Set(obj.GetLabels()))
+}
+
+// holdReleased passes the deletion of hold Leases, to resume the held
+// resources without waiting for the next check. Expired holds are noticed
+// by the requeue of the held resources.
+var holdReleased = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	UpdateFunc:  func(event.UpdateEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return e.Object.GetLabels()[holdLabel] == "true"
+	},
+}
+
+// releaseHeld returns a handler enqueuing the resources that may be held by a
+// released Lease: the resources of its namespace, or all resources for Leases
+// of the hold namespace.
+func releaseHeld(c client.Reader, holdNS string, newList func() client.ObjectList) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(l client.Object) []reconcile.Request {
+		var opts []client.ListOption
+		if l.GetNamespace() != holdNS {
+			opts = append(opts, client.InNamespace(l.GetNamespace()))
+		}
+		list := newList()
+		if err := c.List(context.Background(), list, opts...); err != nil {
+			return nil
+		}
+		objs, err := meta.ExtractList(list)
+		if err != nil {
+			return nil
+		}
+		reqs := make([]reconcile.Request, 0, len(objs))
+		for _, o := range objs {
+			if o, ok := o.(client.Object); ok {
+				reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+			}
+		}
+		return reqs
+	})
+}
@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// janitorInterval is the time between two runs of the janitor.
+const janitorInterval = time.Hour
+
+// tempPatterns match the temporary files and directories created by the
+// reconcilers, relative to the temporary directory. The certificate files of
+// tlsFrom and credentials.tls are rewritten by every reconcile, so only the
+// files no resource uses anymore are pruned.
+var tempPatterns = []string{"atlas-k8s-*", "atlas-git-*", "migrations*", "run-*", "atlas-tls/*/*/*/*", "atlas-ca/*"}
+
+// Janitor prunes the artifacts left behind by the reconcilers once they are
+// no longer used, and the retention period has passed:
+//
+//   - The statements of approval plans that expired.
+//   - External schema Jobs of resources that no longer use an external schema.
+//   - Lint report ConfigMaps of schemas and migrations that no longer export to them.
+//   - Temporary files and directories left by interrupted reconciles.
+type Janitor struct {
+	client.Client
+	// Retention is how long unused artifacts are kept.
+	Retention time.Duration
+	// TempDir holds the temporary files of the reconcilers. Defaults to os.TempDir().
+	TempDir string
+	now     func() time.Time
+}
+
+var _ manager.Runnable = (*Janitor)(nil)
+
+// NewJanitor returns a Janitor pruning the artifacts unused for longer than retention.
+func NewJanitor(mgr manager.Manager, retention time.Duration) *Janitor {
+	return &Janitor{
+		Client:    mgr.GetClient(),
+		Retention: retention,
+		TempDir:   os.TempDir(),
+		now:       time.Now,
+	}
+}
+
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=delete
+
+// Start runs the janitor until the context is done. It implements manager.Runnable,
+// and runs only on the leader.
+func (j *Janitor) Start(ctx context.Context) error {
+	t := time.NewTicker(janitorInterval)
+	defer t.Stop()
+	for {
+		j.Prune(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Prune runs a single pass of the janitor. Failures are logged, and the
+// artifacts are pruned by the next pass.
+func (j *Janitor) Prune(ctx context.Context) {
+	l := log.FromContext(ctx).WithName("janitor")
+	deadline := j.now().Add(-j.Retention)
+	for _, p := range []struct {
+		name  string
+		prune func(context.Context, time.Time) (int, error)
+	}{
+		{"expired plans", j.pruneExpiredPlans},
+		{"external schema jobs", j.pruneExternalJobs},
+		{"lint report configmaps", j.pruneLintReports},
+		{"temporary files", j.pruneTempFiles},
+	} {
+		n, err := p.prune(ctx, deadline)
+		if err != nil {
+			l.Error(err, "failed to prune "+p.name)
+		}
+		if n > 0 {
+			l.Info("pruned "+p.name, "count", n)
+		}
+	}
+}
+
+// pruneExpiredPlans drops the statements of the plans that expired before the
+// deadline. The plan hash is kept, so the expired plan stays rejected.
+func (j *Janitor) pruneExpiredPlans(ctx context.Context, deadline time.Time) (int, error) {
+	var list dbv1alpha1.AtlasSchemaList
+	if err := j.List(ctx, &list); err != nil {
+		return 0, err
+	}
+	var n int
+	for i := range list.Items {
+		sc := &list.Items[i]
+		a := sc.Status.Approval
+		if a == nil || !a.Expired || len(a.Plan) == 0 || !a.PlannedAt.Time.Before(deadline) {
+			continue
+		}
+		a.Plan = nil
+		if err := j.Status().Update(ctx, sc); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// pruneExternalJobs deletes the external schema Jobs that finished before the
+// deadline, and whose resource no longer uses an external schema.
+func (j *Janitor) pruneExternalJobs(ctx context.Context, deadline time.Time) (int, error) {
+	var list batchv1.JobList
+	if err := j.List(ctx, &list); err != nil {
+		return 0, err
+	}
+	var n int
+	for i := range list.Items {
+		job := &list.Items[i]
+		if _, ok := job.Annotations[externalHashAnnotation]; !ok || !jobFinishedBefore(job, deadline) {
+			continue
+		}
+		o, owned, err := j.owner(ctx, job)
+		if err != nil {
+			return n, err
+		}
+		if sc, ok := o.(*dbv1alpha1.AtlasSchema); !owned || o != nil && (!ok || sc.Spec.Schema.External != nil) {
+			continue
+		}
+		if err := j.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// pruneLintReports deletes the lint report ConfigMaps last updated before the
+// deadline, whose resource no longer exports its lint reports to them.
+func (j *Janitor) pruneLintReports(ctx context.Context, deadline time.Time) (int, error) {
+	var list corev1.ConfigMapList
+	if err := j.List(ctx, &list); err != nil {
+		return 0, err
+	}
+	var n int
+	for i := range list.Items {
+		cm := &list.Items[i]
+		if _, ok := cm.Data[sarifKey]; !ok || !lastUpdate(cm).Before(deadline) {
+			continue
+		}
+		o, owned, err := j.owner(ctx, cm)
+		if err != nil {
+			return n, err
+		}
+		if !owned || o != nil && exportsLint(o, cm.Name) {
+			continue
+		}
+		if err := j.Delete(ctx, cm); err != nil && !apierrors.IsNotFound(err) {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// pruneTempFiles removes the temporary files and directories of the
+// reconcilers last modified before the deadline.
+func (j *Janitor) pruneTempFiles(_ context.Context, deadline time.Time) (int, error) {
+	var n int
+	for _, p := range tempPatterns {
+		matches, err := filepath.Glob(filepath.Join(j.TempDir, p))
+		if err != nil {
+			return n, err
+		}
+		for _, m := range matches {
+			fi, err := os.Lstat(m)
+			if err != nil || !fi.ModTime().Before(deadline) {
+				continue
+			}
+			if err := os.RemoveAll(m); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// owner returns the AtlasSchema or AtlasMigration controlling the object. It
+// reports false if the object is not controlled by either, and returns a nil
+// owner if it was deleted.
+func (j *Janitor) owner(ctx context.Context, obj client.Object) (client.Object, bool, error) {
+	ref := metav1.GetControllerOf(obj)
+	if ref == nil || !strings.HasPrefix(ref.APIVersion, dbv1alpha1.GroupVersion.Group+"/") {
+		return nil, false, nil
+	}
+	var o client.Object
+	switch ref.Kind {
+	case "AtlasSchema":
+		o = &dbv1alpha1.AtlasSchema{}
+	case "AtlasMigration":
+		o = &dbv1alpha1.AtlasMigration{}
+	default:
+		return nil, false, nil
+	}
+	switch err := j.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: ref.Name}, o); {
+	case apierrors.IsNotFound(err):
+		return nil, true, nil
+	case err != nil:
+		return nil, false, err
+	}
+	if o.GetUID() != ref.UID {
+		return nil, true, nil
+	}
+	return o, true, nil
+}
+
+// exportsLint reports if the resource stores its lint reports in the ConfigMap.
+func exportsLint(o client.Object, name string) bool {
+	var rp *dbv1alpha1.LintReport
+	switch o := o.(type) {
+	case *dbv1alpha1.AtlasSchema:
+		rp = o.Spec.Policy.Lint.Report
+	case *dbv1alpha1.AtlasMigration:
+		if o.Spec.Lint != nil {
+			rp = o.Spec.Lint.Report
+		}
+	}
+	return rp != nil && rp.ConfigMap && lintReportName(o, rp) == name
+}
+
+// jobFinishedBefore reports if the Job succeeded or failed before the deadline.
+func jobFinishedBefore(job *batchv1.Job, deadline time.Time) bool {
+	if t := job.Status.CompletionTime; t != nil {
+		return t.Time.Before(deadline)
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return c.LastTransitionTime.Time.Before(deadline)
+		}
+	}
+	return false
+}
+
+// lastUpdate returns the time the object was last updated, according to its managed fields.
+func lastUpdate(obj client.Object) time.Time {
+	t := obj.GetCreationTimestamp().Time
+	for _, f := range obj.GetManagedFields() {
+		if f.Time != nil && f.Time.After(t) {
+			t = f.Time.Time
+		}
+	}
+	return t
+}
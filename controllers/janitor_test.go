@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func TestJanitor(t *testing.T) {
+	tt := newTest(t)
+	now := time.Date(2023, 6, 2, 12, 0, 0, 0, time.UTC)
+	old := metav1.NewTime(now.Add(-48 * time.Hour))
+	recent := metav1.NewTime(now.Add(-time.Hour))
+	j := &Janitor{Client: tt.k8s, Retention: 24 * time.Hour, TempDir: t.TempDir(), now: func() time.Time { return now }}
+	owned := func(sc *dbv1alpha1.AtlasSchema) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Namespace:         sc.Namespace,
+			CreationTimestamp: old,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: dbv1alpha1.GroupVersion.String(),
+				Kind:       "AtlasSchema",
+				Name:       sc.Name,
+				UID:        sc.UID,
+				Controller: pointer.Bool(true),
+			}},
+		}
+	}
+	schema := func(name string) *dbv1alpha1.AtlasSchema {
+		return &dbv1alpha1.AtlasSchema{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", UID: types.UID(name)}}
+	}
+	job := func(sc *dbv1alpha1.AtlasSchema, completed metav1.Time) *batchv1.Job {
+		job := &batchv1.Job{ObjectMeta: owned(sc), Status: batchv1.JobStatus{CompletionTime: &completed}}
+		job.Name = sc.Name + externalSuffix
+		job.Annotations = map[string]string{externalHashAnnotation: "hash"}
+		return job
+	}
+	lint := func(sc *dbv1alpha1.AtlasSchema) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{ObjectMeta: owned(sc), Data: map[string]string{sarifKey: "{}"}}
+		cm.Name = sc.Name + "-lint"
+		return cm
+	}
+
+	// The expired plan is pruned, the pending plan is kept.
+	expired, pending := schema("expired"), schema("pending")
+	expired.Status.Approval = &dbv1alpha1.ApprovalStatus{PlanHash: "h1", Plan: []string{"DROP TABLE t"}, PlannedAt: old, Expired: true}
+	pending.Status.Approval = &dbv1alpha1.ApprovalStatus{PlanHash: "h2", Plan: []string{"DROP TABLE t"}, PlannedAt: old}
+	// The job of a schema that is no longer external is pruned once the retention passed.
+	external, sql, recentSQL := schema("external"), schema("sql"), schema("recent-sql")
+	external.Spec.Schema.External = &dbv1alpha1.ExternalSchema{Image: "schema"}
+	// The lint report of a schema that no longer exports it is pruned.
+	reported, unreported := schema("reported"), schema("unreported")
+	reported.Spec.Policy.Lint.Report = &dbv1alpha1.LintReport{ConfigMap: true}
+	// The report of a schema that stores it in another ConfigMap is pruned.
+	renamed := schema("renamed")
+	renamed.Spec.Policy.Lint.Report = &dbv1alpha1.LintReport{ConfigMap: true, Name: "renamed-sarif"}
+	foreign := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "foreign", Namespace: "test", CreationTimestamp: old},
+		Data:       map[string]string{sarifKey: "{}"},
+	}
+	for _, sc := range []*dbv1alpha1.AtlasSchema{expired, pending, external, sql, recentSQL, reported, unreported, renamed} {
+		tt.k8s.put(sc)
+	}
+	tt.k8s.put(job(external, old))
+	tt.k8s.put(job(sql, old))
+	tt.k8s.put(job(recentSQL, recent))
+	tt.k8s.put(lint(reported))
+	tt.k8s.put(lint(unreported))
+	tt.k8s.put(lint(renamed))
+	tt.k8s.put(foreign)
+	// The lint report of a migration that no longer exports it is pruned.
+	migration := func(name string, rp *dbv1alpha1.LintReport) *dbv1alpha1.AtlasMigration {
+		am := &dbv1alpha1.AtlasMigration{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", UID: types.UID(name)}}
+		if rp != nil {
+			am.Spec.Lint = &dbv1alpha1.MigrationLint{Report: rp}
+		}
+		tt.k8s.put(am)
+		cm := &corev1.ConfigMap{ObjectMeta: owned(schema(name)), Data: map[string]string{sarifKey: "{}"}}
+		cm.Name = name + "-lint"
+		cm.OwnerReferences[0].Kind = "AtlasMigration"
+		tt.k8s.put(cm)
+		return am
+	}
+	migration("migration-reported", &dbv1alpha1.LintReport{ConfigMap: true})
+	migration("migration-unreported", &dbv1alpha1.LintReport{})
+	migration("migration-unlinted", nil)
+	// Temporary files older than the retention are removed.
+	for _, name := range []string{"atlas-k8s-old", "atlas-k8s-recent", "unrelated"} {
+		require.NoError(t, os.Mkdir(filepath.Join(j.TempDir, name), 0755))
+		require.NoError(t, os.Chtimes(filepath.Join(j.TempDir, name), old.Time, old.Time))
+	}
+	require.NoError(t, os.Chtimes(filepath.Join(j.TempDir, "atlas-k8s-recent"), recent.Time, recent.Time))
+	certs := filepath.Join(j.TempDir, "atlas-tls", "AtlasSchema", "test")
+	for _, name := range []string{"old", "recent"} {
+		f := filepath.Join(certs, name, "tls.key")
+		require.NoError(t, os.MkdirAll(filepath.Dir(f), 0700))
+		require.NoError(t, os.WriteFile(f, []byte("key"), 0600))
+		require.NoError(t, os.Chtimes(f, old.Time, old.Time))
+	}
+	require.NoError(t, os.Chtimes(filepath.Join(certs, "recent", "tls.key"), recent.Time, recent.Time))
+	ca := filepath.Join(j.TempDir, "atlas-ca", "0123.pem")
+	require.NoError(t, os.MkdirAll(filepath.Dir(ca), 0700))
+	require.NoError(t, os.WriteFile(ca, []byte("bundle"), 0600))
+	require.NoError(t, os.Chtimes(ca, old.Time, old.Time))
+
+	j.Prune(context.Background())
+	key := func(name string) types.NamespacedName {
+		return types.NamespacedName{Namespace: "test", Name: name}
+	}
+	require.Nil(t, tt.k8s.state[key("expired")].(*dbv1alpha1.AtlasSchema).Status.Approval.Plan)
+	require.Equal(t, "h1", tt.k8s.state[key("expired")].(*dbv1alpha1.AtlasSchema).Status.Approval.PlanHash)
+	require.NotNil(t, tt.k8s.state[key("pending")].(*dbv1alpha1.AtlasSchema).Status.Approval.Plan)
+	require.Contains(t, tt.k8s.state, key("external"+externalSuffix))
+	require.NotContains(t, tt.k8s.state, key("sql"+externalSuffix))
+	require.Contains(t, tt.k8s.state, key("recent-sql"+externalSuffix))
+	require.Contains(t, tt.k8s.state, key("reported-lint"))
+	require.NotContains(t, tt.k8s.state, key("unreported-lint"))
+	require.NotContains(t, tt.k8s.state, key("renamed-lint"))
+	require.Contains(t, tt.k8s.state, key("foreign"))
+	require.Contains(t, tt.k8s.state, key("migration-reported-lint"))
+	require.NotContains(t, tt.k8s.state, key("migration-unreported-lint"))
+	require.NotContains(t, tt.k8s.state, key("migration-unlinted-lint"))
+	entries, err := os.ReadDir(j.TempDir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	require.Equal(t, []string{"atlas-ca", "atlas-k8s-recent", "atlas-tls", "unrelated"}, names)
+	require.NoFileExists(t, ca)
+	require.NoFileExists(t, filepath.Join(certs, "old", "tls.key"))
+	require.FileExists(t, filepath.Join(certs, "recent", "tls.key"))
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// authTokenParam is the query parameter libSQL URLs carry the auth token in.
+const authTokenParam = "authToken"
+
+// isLibSQL reports if the URL scheme connects to a remote libSQL database,
+// e.g. Turso. They speak the SQLite dialect.
+func isLibSQL(scheme string) bool {
+	return scheme == "libsql" || strings.HasPrefix(scheme, "libsql+")
+}
+
+// withAuthToken returns the libSQL URL with the auth token read from the
+// referenced secret key. The secret is read on every reconcile, so tokens
+// rotated by updating the secret are used by the next run.
+func withAuthToken(ctx context.Context, r client.Reader, ns string, u *url.URL, from dbv1alpha1.TokenFrom) (*url.URL, error) {
+	s := from.SecretKeyRef
+	if s == nil {
+		return u, nil
+	}
+	if !isLibSQL(u.Scheme) {
+		return nil, fmt.Errorf("authTokenFrom is supported for libsql URLs only, got %q", u.Scheme)
+	}
+	q := u.Query()
+	if q.Has(authTokenParam) {
+		return nil, fmt.Errorf("%s is already set by the URL", authTokenParam)
+	}
+	token, err := getSecretValue(ctx, r, ns, *s)
+	if err != nil {
+		return nil, err
+	}
+	if token = strings.TrimSpace(token); token == "" {
+		return nil, fmt.Errorf("secret %s/%s does not contain key %s", ns, s.Name, s.Key)
+	}
+	q.Set(authTokenParam, token)
+	t := *u
+	t.RawQuery = q.Encode()
+	return &t, nil
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// budgetRetry is the delay before retrying an apply deferred by the host budget.
+const budgetRetry = 10 * time.Second
+
+type (
+	// HostLimiter limits the number of schema changes applied concurrently to
+	// the same database server, across all the logical databases it hosts.
+	// It is shared by the AtlasSchema and AtlasMigration reconcilers.
+	HostLimiter struct {
+		max     int
+		mu      sync.Mutex
+		running map[string]int
+	}
+	// Options configures the reconcilers.
+	Options struct {
+		// MaxConcurrentReconciles is the number of resources each controller reconciles concurrently.
+		MaxConcurrentReconciles int
+		// ApplyLimiter limits the concurrent applies per database server. Unlimited if nil.
+		ApplyLimiter *HostLimiter
+		// Identity of the operator instance, recorded in the applying markers.
+		Identity string
+		// WatchDedupWindow is the minimum time between two reconciles of a
+		// resource triggered by changes of the Secrets and ConfigMaps it references.
+		WatchDedupWindow time.Duration
+		// HoldNamespace is the namespace of the Leases holding the applies of
+		// resources across namespaces. Cluster-wide holds are disabled if empty.
+		HoldNamespace string
+		// NetworkGuard backs off all reconciles while the networking of the
+		// cluster is broken. Disabled if nil.
+		NetworkGuard *NetworkGuard
+		// Version of the operator, recorded in the status of the resources.
+		Version string
+		// AllowProjectFiles allows AtlasMigration resources to use their own
+		// atlas.hcl project files, which are evaluated by the operator.
+		AllowProjectFiles bool
+		// AllowedImages are the images the resources may run as Jobs in their
+		// namespace, e.g. external schema programs. Matched by prefix, and
+		// nothing is allowed if empty.
+		AllowedImages []string
+		// AllowAzureAD allows the resources to authenticate with Azure AD
+		// tokens, acquired with the identity of the operator.
+		AllowAzureAD bool
+	}
+	// budgetErr is returned when the apply budget of a database server is exhausted.
+	budgetErr struct {
+		host string
+	}
+)
+
+// NewHostLimiter returns a HostLimiter allowing max concurrent applies per
+// database server. A non-positive max disables the limit.
+func NewHostLimiter(max int) *HostLimiter {
+	return &HostLimiter{max: max, running: make(map[string]int)}
+}
+
+// acquire reserves an apply slot on the given host. It returns a budgetErr if
+// the budget of the host is exhausted, or a function that releases the slot.
+func (l *HostLimiter) acquire(host string) (func(), error) {
+	if l == nil || l.max <= 0 || host == "" {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running[host] >= l.max {
+		return nil, &budgetErr{host: host}
+	}
+	l.running[host]++
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.running[host]--; l.running[host] == 0 {
+			delete(l.running, host)
+		}
+	}, nil
+}
+
+func (e *budgetErr) Error() string {
+	return fmt.Sprintf("apply budget of database server %s is exhausted. Retrying in %s", e.host, budgetRetry)
+}
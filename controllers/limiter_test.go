@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestHostLimiter(t *testing.T) {
+	l := NewHostLimiter(2)
+	r1, err := l.acquire("rds.example.com:3306")
+	require.NoError(t, err)
+	r2, err := l.acquire("rds.example.com:3306")
+	require.NoError(t, err)
+	_, err = l.acquire("rds.example.com:3306")
+	require.EqualError(t, err, "apply budget of database server rds.example.com:3306 is exhausted. Retrying in 10s")
+
+	// Other servers have their own budget.
+	r3, err := l.acquire("other.example.com:3306")
+	require.NoError(t, err)
+	r3()
+
+	// Released slots are reused.
+	r1()
+	r1, err = l.acquire("rds.example.com:3306")
+	require.NoError(t, err)
+	r1()
+	r2()
+	require.Empty(t, l.running)
+
+	// Unlimited.
+	var nl *HostLimiter
+	_, err = nl.acquire("rds.example.com:3306")
+	require.NoError(t, err)
+}
+
+func TestReconcile_ApplyBudget(t *testing.T) {
+	tt := newTest(t)
+	tt.r.applyLimiter = NewHostLimiter(1)
+	release, err := tt.r.applyLimiter.acquire("localhost:3306")
+	require.NoError(t, err)
+	tt.k8s.put(conditionReconciling())
+	tt.k8s.put(devDBReady())
+	resp, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.EqualValues(t, ctrl.Result{RequeueAfter: budgetRetry}, resp)
+	require.EqualValues(t, "ApplyBudgetExhausted", tt.cond().Reason)
+	for _, run := range tt.mockCLI().applyRuns {
+		require.True(t, run.DryRun)
+	}
+
+	release()
+	resp, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.EqualValues(t, ctrl.Result{}, resp)
+	require.Empty(t, tt.r.applyLimiter.running)
+}
@@ -1,22 +1,23 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"ariga.io/atlas/sql/sqlcheck"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
 	"github.com/ariga/atlas-operator/internal/atlas"
 )
 
 func (r *AtlasSchemaReconciler) lint(ctx context.Context, des *managed, devURL string, vars ...atlas.Vars) error {
-	var buf bytes.Buffer
-	if err := tmpl.ExecuteTemplate(&buf, "conf.tmpl", des.policy); err != nil {
-		return err
-	}
-	lintcfg, cleancfg, err := atlas.TempFile(buf.String(), "hcl")
+	lintcfg, cleancfg, err := configFile(des)
 	if err != nil {
 		return err
 	}
@@ -38,7 +39,7 @@ func (r *AtlasSchemaReconciler) lint(ctx context.Context, des *managed, devURL s
 	if err := os.WriteFile(filepath.Join(tmpdir, "1.sql"), []byte(ins), 0644); err != nil {
 		return err
 	}
-	desired, clean, err := atlas.TempFile(des.desired, des.ext)
+	desired, clean, err := des.desiredURL()
 	if err != nil {
 		return err
 	}
@@ -48,12 +49,13 @@ func (r *AtlasSchemaReconciler) lint(ctx context.Context, des *managed, devURL s
 		vv = vars[0]
 	}
 	dry, err := r.cli.SchemaApply(ctx, &atlas.SchemaApplyParams{
-		DryRun:  true,
-		URL:     des.url.String(),
-		To:      desired,
-		DevURL:  devURL,
-		Exclude: des.exclude,
-		Schema:  des.schemas,
+		DryRun:    true,
+		URL:       des.url.String(),
+		To:        desired,
+		DevURL:    devURL,
+		Exclude:   des.exclude,
+		ConfigURL: des.configfile,
+		Schema:    des.schemas,
 	})
 	if isSQLErr(err) {
 		return err
@@ -78,12 +80,111 @@ func (r *AtlasSchemaReconciler) lint(ctx context.Context, des *managed, devURL s
 	if err != nil {
 		return transient(err)
 	}
+	// A non-nil empty report marks a lint without findings.
+	des.lintFiles = append([]*atlas.FileReport{}, lint.Files...)
 	if diags := destructive(lint.Files); len(diags) > 0 {
 		return destructiveErr{diags: diags}
 	}
+	if analyzers := policyErrorOn(des.policy.Lint); len(analyzers) > 0 {
+		if diags := analyzerDiags(lint.Files, analyzers); len(diags) > 0 {
+			return analyzerErr{diags: diags}
+		}
+	}
+	if len(des.rules) > 0 {
+		if diags := failing(lint.Files); len(diags) > 0 {
+			return ruleErr{diags: diags}
+		}
+	}
 	return nil
 }
 
+// ruleErr is returned when custom lint rules report diagnostics.
+type ruleErr struct {
+	diags []sqlcheck.Diagnostic
+}
+
+func (e ruleErr) Error() string {
+	var buf strings.Builder
+	buf.WriteString("lint rules failed:\n")
+	for _, diag := range e.diags {
+		buf.WriteString("- " + diag.Text + "\n")
+	}
+	return buf.String()
+}
+
+// analyzerErr is returned when an analyzer set to error by the lint policy
+// reports diagnostics.
+type analyzerErr struct {
+	diags []sqlcheck.Diagnostic
+}
+
+func (e analyzerErr) Error() string {
+	var buf strings.Builder
+	buf.WriteString("lint policy failed:\n")
+	for _, diag := range e.diags {
+		buf.WriteString(fmt.Sprintf("- %s (%s)\n", diag.Text, diag.Code))
+	}
+	return buf.String()
+}
+
+// policyAnalyzers returns the analyzers configured by the lint policy, other
+// than destructive which is always configured.
+func policyAnalyzers(l dbv1alpha1.Lint) map[dbv1alpha1.LintAnalyzer]*dbv1alpha1.CheckConfig {
+	analyzers := make(map[dbv1alpha1.LintAnalyzer]*dbv1alpha1.CheckConfig)
+	for a, c := range map[dbv1alpha1.LintAnalyzer]*dbv1alpha1.CheckConfig{
+		"data_depend":      l.DataDepend,
+		"concurrent_index": l.ConcurrentIndex,
+		"incompatible":     l.Incompatible,
+		"condrop":          l.ConDrop,
+	} {
+		if c != nil {
+			analyzers[a] = c
+		}
+	}
+	if l.Naming != nil {
+		analyzers["naming"] = &dbv1alpha1.CheckConfig{Error: l.Naming.Error}
+	}
+	return analyzers
+}
+
+// policyErrorOn returns the analyzers set to error by the lint policy, other
+// than destructive which is checked on its own.
+func policyErrorOn(l dbv1alpha1.Lint) (analyzers []dbv1alpha1.LintAnalyzer) {
+	for a, c := range policyAnalyzers(l) {
+		if c.Error {
+			analyzers = append(analyzers, a)
+		}
+	}
+	return analyzers
+}
+
+// analyzerDiags returns the diagnostics of the given analyzers.
+func analyzerDiags(files []*atlas.FileReport, analyzers []dbv1alpha1.LintAnalyzer) (diags []sqlcheck.Diagnostic) {
+	for _, f := range files {
+		for _, r := range f.Reports {
+			for _, d := range r.Diagnostics {
+				if errorOn(analyzers, d.Code) {
+					diags = append(diags, d)
+				}
+			}
+		}
+	}
+	return
+}
+
+// failing returns the diagnostics of the files that failed the lint.
+func failing(files []*atlas.FileReport) (diags []sqlcheck.Diagnostic) {
+	for _, f := range files {
+		if f.Error == "" {
+			continue
+		}
+		for _, r := range f.Reports {
+			diags = append(diags, r.Diagnostics...)
+		}
+	}
+	return
+}
+
 func (r *AtlasSchemaReconciler) verifyFirstRun(ctx context.Context, des *managed, devURL string) error {
 	return r.lint(ctx, des, devURL, atlas.Vars{
 		"lint_destructive": "true",
@@ -105,3 +206,117 @@ func destructive(files []*atlas.FileReport) (checks []sqlcheck.Diagnostic) {
 	}
 	return
 }
+
+// analyzerCodes maps the Atlas analyzers to the prefix of their diagnostic codes.
+var analyzerCodes = map[dbv1alpha1.LintAnalyzer]string{
+	"destructive":      "DS",
+	"data_depend":      "MF",
+	"concurrent_index": "PG1",
+	"incompatible":     "BC",
+	"naming":           "NM",
+	"condrop":          "CD",
+}
+
+// migrateLintErr is returned when the pending migration files fail the lint.
+type migrateLintErr struct {
+	failures []string
+	report   *dbv1alpha1.LintStatus
+}
+
+func (e *migrateLintErr) Error() string {
+	return "migration files failed the lint:\n- " + strings.Join(e.failures, "\n- ")
+}
+
+// lint runs "atlas migrate lint" on the last files of the migration directory,
+// the pending files by default, and returns its findings. It returns a
+// *migrateLintErr if Atlas reports an error, or a diagnostic of the analyzers
+// in lint.errorOn.
+func (r *AtlasMigrationReconciler) lint(ctx context.Context, md atlasMigrationData, pending int) (*dbv1alpha1.LintStatus, error) {
+	l := md.lint
+	devURL := l.DevURL
+	if devURL == "" {
+		var err error
+		if devURL, err = md.dev(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if devURL == "" {
+		return nil, errors.New("lint requires lint.devURL or devURL to be set")
+	}
+	if md.Migration == nil {
+		return nil, errors.New("lint requires a configmap or local migration directory")
+	}
+	latest := uint64(l.Latest)
+	if latest == 0 {
+		latest = uint64(pending)
+	}
+	report, err := r.CLI.Lint(ctx, &atlas.LintParams{
+		DevURL: devURL,
+		DirURL: md.Migration.Dir,
+		Latest: latest,
+	})
+	if err != nil {
+		return nil, transient(err)
+	}
+	st := lintStatus(report.Files, l.ErrorOn)
+	// Failing to export the report does not block the apply.
+	if l.Report != nil {
+		if err := r.exportLint(ctx, md.owner, l, report.Files); err != nil {
+			r.recorder.Event(md.owner, corev1.EventTypeWarning, dbv1alpha1.ReasonLintReportError, err.Error())
+		}
+	}
+	var failures []string
+	for _, f := range st.Files {
+		reported := false
+		for _, d := range f.Diagnostics {
+			if d.Severity == "error" {
+				failures = append(failures, fmt.Sprintf("%s: %s (%s)", f.Name, d.Text, d.Code))
+				reported = true
+			}
+		}
+		if f.Error != "" && !reported {
+			failures = append(failures, fmt.Sprintf("%s: %s", f.Name, f.Error))
+		}
+	}
+	if len(failures) > 0 {
+		return nil, &migrateLintErr{failures: failures, report: st}
+	}
+	return st, nil
+}
+
+// lintStatus returns the status of the lint findings. Diagnostics of files
+// that failed the lint, and of the given analyzers, are reported as errors.
+func lintStatus(files []*atlas.FileReport, analyzers []dbv1alpha1.LintAnalyzer) *dbv1alpha1.LintStatus {
+	st := &dbv1alpha1.LintStatus{Time: metav1.Now()}
+	for _, f := range files {
+		lf := dbv1alpha1.LintFile{Name: f.Name, Error: f.Error}
+		for _, r := range f.Reports {
+			for _, d := range r.Diagnostics {
+				severity := "warning"
+				if f.Error != "" || errorOn(analyzers, d.Code) {
+					severity = "error"
+				}
+				lf.Diagnostics = append(lf.Diagnostics, dbv1alpha1.LintDiagnostic{
+					Code:     d.Code,
+					Severity: severity,
+					Text:     d.Text,
+					Pos:      d.Pos,
+				})
+			}
+		}
+		if lf.Error != "" || len(lf.Diagnostics) > 0 {
+			st.Files = append(st.Files, lf)
+		}
+	}
+	return st
+}
+
+// errorOn reports if the diagnostic code belongs to one of the analyzers.
+func errorOn(analyzers []dbv1alpha1.LintAnalyzer, code string) bool {
+	for _, a := range analyzers {
+		if p, ok := analyzerCodes[a]; ok && strings.HasPrefix(code, p) {
+			return true
+		}
+	}
+	return false
+}
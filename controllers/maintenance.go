@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const (
+	// maintenanceInterval is the interval in which a target database under
+	// maintenance is checked again.
+	maintenanceInterval = 5 * time.Minute
+)
+
+// maintenanceErrs are the messages of errors reporting a database under
+// maintenance, by the reason of the TargetUnderMaintenance condition.
+var maintenanceErrs = []struct {
+	reason string
+	msgs   []string
+}{
+	{
+		reason: dbv1alpha1.ReasonInRecovery,
+		msgs: []string{
+			"the database system is in recovery mode", // PostgreSQL
+			"during recovery",                         // PostgreSQL hot standby
+			"is being recovered",                      // SQL Server
+			"in the middle of a restore",              // SQL Server
+		},
+	},
+	{
+		reason: dbv1alpha1.ReasonReadOnly,
+		msgs: []string{
+			"read-only transaction",     // PostgreSQL
+			"--read-only option",        // MySQL
+			"--super-read-only option",  // MySQL
+			"the database is read-only", // SQL Server
+			"is in readonly mode",       // ClickHouse
+		},
+	},
+	{
+		reason: dbv1alpha1.ReasonRestarting,
+		msgs: []string{
+			"the database system is starting up",   // PostgreSQL
+			"the database system is shutting down", // PostgreSQL
+			"server shutdown in progress",          // MySQL
+		},
+	},
+}
+
+// maintenanceReason returns the reason of the TargetUnderMaintenance condition
+// if the error was caused by a target database under maintenance.
+func maintenanceReason(err error) (string, bool) {
+	if err == nil {
+		return "", false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range maintenanceErrs {
+		for _, s := range m.msgs {
+			if strings.Contains(msg, s) {
+				return m.reason, true
+			}
+		}
+	}
+	return "", false
+}
+
+// underMaintenance reports if the error was caused by a target database under
+// maintenance. If so, it sets the TargetUnderMaintenance condition, records an
+// event when the maintenance is first observed, and returns the result polling
+// the database at a reduced rate. The error is not returned to avoid retrying
+// with backoff and flooding the logs until the database is writable again.
+func underMaintenance(rec record.EventRecorder, obj client.Object, conds *[]metav1.Condition, err error) (ctrl.Result, bool) {
+	reason, ok := maintenanceReason(err)
+	if !ok {
+		return ctrl.Result{}, false
+	}
+	if c := meta.FindStatusCondition(*conds, dbv1alpha1.TargetUnderMaintenanceCond); c == nil || c.Status != metav1.ConditionTrue || c.Reason != reason {
+		rec.Eventf(obj, corev1.EventTypeNormal, dbv1alpha1.TargetUnderMaintenanceCond,
+			"The target database is under maintenance (%s). Checking again every %s", reason, maintenanceInterval)
+	}
+	meta.SetStatusCondition(conds, metav1.Condition{
+		Type:    dbv1alpha1.TargetUnderMaintenanceCond,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: err.Error(),
+	})
+	return ctrl.Result{RequeueAfter: maintenanceInterval}, true
+}
+
+// maintenanceEnded removes the TargetUnderMaintenance condition once the
+// target database was written, and records an event if it was set.
+func maintenanceEnded(rec record.EventRecorder, obj client.Object, conds *[]metav1.Condition) {
+	if meta.FindStatusCondition(*conds, dbv1alpha1.TargetUnderMaintenanceCond) == nil {
+		return
+	}
+	meta.RemoveStatusCondition(conds, dbv1alpha1.TargetUnderMaintenanceCond)
+	rec.Event(obj, corev1.EventTypeNormal, dbv1alpha1.ReasonMaintenanceEnded, "The target database is writable again")
+}
+
+// schemaMaintenance handles a target database under maintenance while
+// reconciling the schema.
+func (r *AtlasSchemaReconciler) schemaMaintenance(sc *dbv1alpha1.AtlasSchema, err error) (ctrl.Result, bool) {
+	res, ok := underMaintenance(r.recorder, sc, &sc.Status.Conditions, err)
+	if ok {
+		setNotReady(sc, dbv1alpha1.TargetUnderMaintenanceCond, err.Error())
+	}
+	return res, ok
+}
+
+// migrationMaintenance handles a target database under maintenance while
+// reconciling the migration.
+func (r *AtlasMigrationReconciler) migrationMaintenance(am *dbv1alpha1.AtlasMigration, err error) (ctrl.Result, bool) {
+	res, ok := underMaintenance(r.recorder, am, &am.Status.Conditions, err)
+	if ok {
+		am.SetNotReady(dbv1alpha1.TargetUnderMaintenanceCond, strings.TrimSpace(err.Error()))
+	}
+	return res, ok
+}
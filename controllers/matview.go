@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// SQLExecutor is the interface used to run statements against the target database.
+type SQLExecutor interface {
+	Exec(ctx context.Context, url, stmt string) error
+}
+
+// refreshViews refreshes the materialized views of the schema whose interval
+// elapsed since their last refresh attempt, and records the refreshes in the
+// status. It returns the time until the next view is due, or zero if the
+// schema has no materialized views.
+func (r *AtlasSchemaReconciler) refreshViews(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed) time.Duration {
+	views := sc.Spec.MaterializedViews
+	if len(views) == 0 {
+		sc.Status.MaterializedViews = nil
+		return 0
+	}
+	prev := make(map[string]dbv1alpha1.MaterializedViewStatus, len(sc.Status.MaterializedViews))
+	for _, s := range sc.Status.MaterializedViews {
+		prev[s.Name] = s
+	}
+	var (
+		next   time.Duration
+		now    = time.Now()
+		status = make([]dbv1alpha1.MaterializedViewStatus, 0, len(views))
+	)
+	for _, v := range views {
+		s, ok := prev[v.Name]
+		if due := s.LastAttemptTime.Add(v.Interval.Duration); ok && now.Before(due) {
+			status = append(status, s)
+			if d := due.Sub(now); next == 0 || d < next {
+				next = d
+			}
+			continue
+		}
+		s = dbv1alpha1.MaterializedViewStatus{
+			Name:            v.Name,
+			LastRefreshTime: s.LastRefreshTime,
+			LastAttemptTime: metav1.NewTime(now),
+		}
+		if err := r.refreshView(ctx, m, v); err != nil {
+			s.Error = err.Error()
+			r.recorder.Eventf(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonRefreshingMaterializedView, "Refreshing materialized view %s: %v", v.Name, err)
+		} else {
+			s.LastRefreshTime = &s.LastAttemptTime
+		}
+		status = append(status, s)
+		if next == 0 || v.Interval.Duration < next {
+			next = v.Interval.Duration
+		}
+	}
+	sc.Status.MaterializedViews = status
+	return next
+}
+
+// refreshView runs the REFRESH MATERIALIZED VIEW statement of the view.
+func (r *AtlasSchemaReconciler) refreshView(ctx context.Context, m *managed, v dbv1alpha1.MaterializedView) error {
+	if m.driver != "postgres" {
+		return fmt.Errorf("materialized views are not supported by driver %q", m.driver)
+	}
+	if r.sqlExec == nil {
+		return fmt.Errorf("materialized views are not supported by this reconciler")
+	}
+	stmt := "REFRESH MATERIALIZED VIEW "
+	if v.Concurrently {
+		stmt += "CONCURRENTLY "
+	}
+	return r.sqlExec.Exec(ctx, m.url.String(), stmt+v.Name)
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+)
+
+// migrationApprovalErr is returned when the pending migration files were not
+// approved.
+type migrationApprovalErr struct {
+	status *dbv1alpha1.MigrationApprovalStatus
+	// planned reports if the plan was computed by this reconcile.
+	planned bool
+	// plan is the name of the AtlasPlan created for review, if any.
+	plan string
+	// expired is the time the approvals of the migration expired, if they did.
+	expired time.Time
+}
+
+func (e *migrationApprovalErr) Error() string {
+	versions := make([]string, len(e.status.Files))
+	for i, f := range e.status.Files {
+		versions[i] = f.Version
+	}
+	if e.plan != "" {
+		return fmt.Sprintf("pending migration files %s are awaiting approval. Approve the AtlasPlan %s, or set spec.approval.approvedPlan to %q to approve them%s",
+			strings.Join(versions, ", "), e.plan, e.status.PlanHash, expiredNote(e.expired))
+	}
+	return fmt.Sprintf("pending migration files %s are awaiting approval. Set spec.approval.approvedPlan to %q to approve them%s",
+		strings.Join(versions, ", "), e.status.PlanHash, expiredNote(e.expired))
+}
+
+// reviewPlan creates the AtlasPlan of the pending files that were not
+// approved. It returns the approved status of the files if the plan was
+// approved, a *planRejectedErr if it was rejected, or the given error while
+// it awaits review.
+func (r *AtlasMigrationReconciler) reviewPlan(ctx context.Context, md atlasMigrationData, aErr *migrationApprovalErr, lint *dbv1alpha1.LintStatus) (*dbv1alpha1.MigrationApprovalStatus, error) {
+	if md.owner == nil {
+		return nil, aErr
+	}
+	spec, err := migrationPlan(md, aErr.status, lint)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := reviewPlan(ctx, r.Client, r.Scheme, md.owner, spec)
+	if err != nil {
+		return nil, err
+	}
+	if planApprovalExpired(plan) {
+		if err := expirePlanApproval(ctx, r.Client, plan); err != nil {
+			return nil, err
+		}
+	}
+	switch plan.Status.Phase {
+	case dbv1alpha1.PlanApproved:
+		a := aErr.status
+		a.Approved, a.ExpiresAt = true, plan.Status.ExpiresAt
+		return a, nil
+	case dbv1alpha1.PlanRejected:
+		return nil, &planRejectedErr{plan: plan}
+	}
+	aErr.plan = plan.Name
+	return nil, aErr
+}
+
+// checkApproval checks that the pending files were approved. The files
+// remaining from an approved plan, e.g. when it is applied in batches, stay
+// approved. It returns the plan of the pending files, or a
+// *migrationApprovalErr if it was not approved.
+func checkApproval(md atlasMigrationData, pending []atlas.File) (*dbv1alpha1.MigrationApprovalStatus, error) {
+	if md.Migration == nil {
+		return nil, errors.New("spec.approval requires a configmap or local migration directory")
+	}
+	hashes, err := dirHashes(md)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]dbv1alpha1.ApprovalFile, len(pending))
+	for i, f := range pending {
+		files[i] = dbv1alpha1.ApprovalFile{Version: f.Version, Hash: hashes[f.Version]}
+	}
+	var prev *dbv1alpha1.MigrationApprovalStatus
+	if md.owner != nil {
+		prev = md.owner.Status.Approval
+	}
+	// Approvals are discarded after their deadline, even if some of the
+	// approved files were already applied.
+	if prev != nil && prev.Approved && approvedFiles(prev, files) && md.approvalsExpired.IsZero() &&
+		(prev.ExpiresAt == nil || time.Now().Before(prev.ExpiresAt.Time)) {
+		return prev, nil
+	}
+	a := &dbv1alpha1.MigrationApprovalStatus{
+		PlanHash:  approvalHash(files),
+		Files:     files,
+		PlannedAt: metav1.Now(),
+	}
+	planned := prev == nil || prev.PlanHash != a.PlanHash
+	if !planned {
+		a.PlannedAt = prev.PlannedAt
+	}
+	if md.approvedPlan != a.PlanHash {
+		return nil, &migrationApprovalErr{status: a, planned: planned, expired: md.approvalsExpired}
+	}
+	a.Approved = true
+	return a, nil
+}
+
+// approvedFiles reports if all files are part of the approved plan.
+func approvedFiles(a *dbv1alpha1.MigrationApprovalStatus, files []dbv1alpha1.ApprovalFile) bool {
+	approved := make(map[dbv1alpha1.ApprovalFile]bool, len(a.Files))
+	for _, f := range a.Files {
+		approved[f] = true
+	}
+	for _, f := range files {
+		if !approved[f] {
+			return false
+		}
+	}
+	return true
+}
+
+// approvalHash returns the hash identifying the plan of the given files.
+func approvalHash(files []dbv1alpha1.ApprovalFile) string {
+	h := sha256.New()
+	for _, f := range files {
+		h.Write([]byte(f.Version))
+		h.Write([]byte(f.Hash))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
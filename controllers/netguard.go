@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// networkCheckTTL is how long the result of a healthy network check is
+	// reused, to avoid dialing the endpoint for each unreachable database.
+	networkCheckTTL = 10 * time.Second
+	// networkDialTimeout is the timeout of dialing the known-good endpoint.
+	networkDialTimeout = 5 * time.Second
+)
+
+// NetworkGuard distinguishes unreachable databases from broken networking of
+// the cluster. When a database is unreachable, it dials a known-good endpoint,
+// e.g. the Kubernetes API service. If the endpoint is unreachable too, all
+// reconciles back off without changing the status of the resources, instead
+// of marking them failed and notifying their owners. It is shared by the
+// AtlasSchema and AtlasMigration reconcilers.
+type NetworkGuard struct {
+	addr     string
+	backoff  time.Duration
+	dial     func(ctx context.Context, network, addr string) (net.Conn, error)
+	mu       sync.Mutex
+	until    time.Time // end of the backoff, zero if the networking is healthy
+	checked  time.Time // time of the last healthy check
+	checking bool      // a check is in flight
+}
+
+// NewNetworkGuard returns a NetworkGuard dialing the given TCP address, and
+// backing off for the given duration when it is unreachable.
+func NewNetworkGuard(addr string, backoff time.Duration) *NetworkGuard {
+	d := &net.Dialer{Timeout: networkDialTimeout}
+	return &NetworkGuard{addr: addr, backoff: backoff, dial: d.DialContext}
+}
+
+// degraded returns the remaining backoff if the networking of the cluster was
+// found broken, and reports if reconciles should back off.
+func (g *NetworkGuard) degraded() (time.Duration, bool) {
+	if g == nil {
+		return 0, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if d := time.Until(g.until); d > 0 {
+		return d, true
+	}
+	return 0, false
+}
+
+// observe checks the networking of the cluster if the given error reports an
+// unreachable database. It returns the backoff and true if the known-good
+// endpoint is unreachable too. The endpoint is dialed by a single reconcile at
+// a time, without holding the lock, and the reconciles observing errors while
+// it is dialed are not held back.
+func (g *NetworkGuard) observe(ctx context.Context, err error) (time.Duration, bool) {
+	if g == nil || g.addr == "" || !isUnreachable(err) {
+		return 0, false
+	}
+	g.mu.Lock()
+	now := time.Now()
+	if d := g.until.Sub(now); d > 0 {
+		g.mu.Unlock()
+		return d, true
+	}
+	if g.checking || now.Sub(g.checked) < networkCheckTTL {
+		g.mu.Unlock()
+		return 0, false
+	}
+	g.checking = true
+	g.mu.Unlock()
+	conn, dErr := g.dial(ctx, "tcp", g.addr)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.checking = false
+	if dErr == nil {
+		conn.Close()
+		g.checked, g.until = now, time.Time{}
+		return 0, false
+	}
+	g.until = time.Now().Add(g.backoff)
+	log.FromContext(ctx).Error(dErr, "cluster networking is degraded, backing off all reconciles",
+		"endpoint", g.addr, "backoff", g.backoff, "cause", err.Error())
+	return g.backoff, true
+}
+
+// unreachableErrs are the messages of errors reporting an unreachable host,
+// as printed by the Go net package and reported by the CLI. Errors of hosts
+// that were reached, e.g. timeouts of reads, are not matched.
+var unreachableErrs = []string{
+	"dial tcp",
+	"no such host",
+	"connection refused",
+	"network is unreachable",
+	"no route to host",
+	"server misbehaving",
+	"temporary failure in name resolution",
+}
+
+// isUnreachable reports if the error was caused by an unreachable host: the
+// host could not be resolved, or dialing it failed.
+func isUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var (
+		dnsErr *net.DNSError
+		opErr  *net.OpError
+	)
+	if errors.As(err, &dnsErr) || errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range unreachableErrs {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
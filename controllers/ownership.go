@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// schemaDatabaseField indexes the AtlasSchema resources by the databases they
+// manage, so schemas find the other schemas of their database without
+// resolving the URLs of all schemas of the namespace.
+const schemaDatabaseField = ".spec.database"
+
+// schemaDatabases returns the index values of the schema, like migrationDatabases.
+// Preview schemas manage their own branches, and are not indexed.
+func schemaDatabases(obj client.Object) []string {
+	sc, ok := obj.(*dbv1alpha1.AtlasSchema)
+	if !ok || sc.Spec.Preview != nil {
+		return nil
+	}
+	var u *url.URL
+	switch s := sc.Spec; {
+	case s.URL != "":
+		var err error
+		if u, err = url.Parse(s.URL); err != nil {
+			return nil
+		}
+	case s.URLFrom.SecretKeyRef != nil:
+		return []string{unresolvedDatabase}
+	case s.Credentials.Host != "":
+		u = s.Credentials.URL()
+	default:
+		return nil
+	}
+	server, db := databaseKey(u)
+	return []string{server, server + "/" + db}
+}
+
+// databaseURL returns the URL of the database managed by the schema, without
+// the credentials read from secrets, which do not change the database. Unlike
+// url, it records no events and acquires no tokens, as it resolves the URLs
+// of other resources.
+func (r *AtlasSchemaReconciler) databaseURL(ctx context.Context, sc *dbv1alpha1.AtlasSchema) (*url.URL, error) {
+	switch s := sc.Spec; {
+	case s.URL != "":
+		return url.Parse(s.URL)
+	case s.URLFrom.SecretKeyRef != nil:
+		ns, err := refNamespace(ctx, r, sc, "AtlasSchema", s.URLFrom.Namespace, "Secret", s.URLFrom.SecretKeyRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		us, err := urlFromSecret(ctx, r, ns, s.URLFrom)
+		if err != nil {
+			return nil, err
+		}
+		return url.Parse(us)
+	case s.Credentials.Host != "":
+		return s.Credentials.URL(), nil
+	default:
+		return nil, errors.New("no url specified")
+	}
+}
+
+// overlap returns the AtlasSchema that owns a named schema managed by the
+// given schema too, and the named schemas they share, or nil if the schema
+// owns all the named schemas it manages. Of two resources of the namespace
+// managing the same named schema, the one created first owns it. Resources of
+// other namespaces do not take ownership, so tenants cannot lock each other out.
+func (r *AtlasSchemaReconciler) overlap(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed) (*dbv1alpha1.AtlasSchema, []string, error) {
+	if m.url == nil {
+		return nil, nil, nil
+	}
+	owned := managedSchemas(m.url, m.schemas)
+	for _, k := range databaseKeys(m.url) {
+		var list dbv1alpha1.AtlasSchemaList
+		if err := r.List(ctx, &list, client.InNamespace(sc.Namespace), client.MatchingFields{schemaDatabaseField: k}); err != nil {
+			return nil, nil, transient(err)
+		}
+		for i := range list.Items {
+			o := &list.Items[i]
+			// Resources created later, including the schema itself, do not own its named schemas.
+			if !createdBefore(o, sc) {
+				continue
+			}
+			u, err := r.databaseURL(ctx, o)
+			if err != nil || !sameDatabase(m.url, u) {
+				continue
+			}
+			if shared, ok := intersect(owned, managedSchemas(u, o.Spec.Schemas)); ok {
+				return o, shared, nil
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// overlapMessage describes the named schemas shared with their owner.
+func overlapMessage(owner *dbv1alpha1.AtlasSchema, shared []string) string {
+	if len(shared) == 0 {
+		return fmt.Sprintf("the database is managed as a whole by AtlasSchema %s. "+
+			"Set spec.schemas on both resources to split the named schemas they manage", owner.NamespacedName())
+	}
+	return fmt.Sprintf("schemas %s are managed by AtlasSchema %s. "+
+		"Named schemas of a database can be managed by a single resource", strings.Join(shared, ", "), owner.NamespacedName())
+}
+
+// managedSchemas returns the named schemas managed through the URL, or nil if
+// the URL manages all the schemas of the database.
+func managedSchemas(u *url.URL, schemas []string) []string {
+	if len(schemas) > 0 {
+		return schemas
+	}
+	if s := urlSchema(u); s != "" {
+		return []string{s}
+	}
+	return nil
+}
+
+// intersect reports if the sets of named schemas overlap, and returns the
+// schemas they share. A nil set holds all schemas.
+func intersect(a, b []string) ([]string, bool) {
+	switch {
+	case a == nil && b == nil:
+		return nil, true
+	case a == nil:
+		return b, true
+	case b == nil:
+		return a, true
+	}
+	var shared []string
+	for _, s := range a {
+		if slices.Contains(b, s) {
+			shared = append(shared, s)
+		}
+	}
+	return shared, len(shared) > 0
+}
+
+// createdBefore reports if a was created before b. Resources created in the
+// same second are ordered by their namespaced name.
+func createdBefore(a, b *dbv1alpha1.AtlasSchema) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.NamespacedName().String() < b.NamespacedName().String()
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"ariga.io/atlas/sql/migrate"
+	"golang.org/x/exp/slices"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=db.atlasgo.io,resources=atlasplans,verbs=get;list;watch;create
+//+kubebuilder:rbac:groups=db.atlasgo.io,resources=atlasplans/status,verbs=get;update;patch
+
+// planObject matches the statements that create, alter or drop a database
+// object, capturing the type and the name of the object.
+var planObject = regexp.MustCompile("(?is)^\\s*(?:CREATE|ALTER|DROP|RENAME)\\s+(?:OR\\s+REPLACE\\s+)?(?:UNIQUE\\s+)?" +
+	"(TABLE|MATERIALIZED\\s+VIEW|VIEW|INDEX|SCHEMA|DATABASE|FUNCTION|PROCEDURE|TRIGGER|TYPE|SEQUENCE)\\s+" +
+	"(?:IF\\s+(?:NOT\\s+)?EXISTS\\s+)?([^\\s(;]+)")
+
+// planRejectedErr is returned when the plan of the pending changes was rejected.
+type planRejectedErr struct {
+	plan *dbv1alpha1.AtlasPlan
+}
+
+func (e *planRejectedErr) Error() string {
+	msg := fmt.Sprintf("plan %s was rejected", e.plan.Name)
+	if r := e.plan.Status.Reviewer; r != "" {
+		msg += " by " + r
+	}
+	if m := e.plan.Status.Message; m != "" {
+		msg += ": " + m
+	}
+	return msg + ". It is planned again on the next change"
+}
+
+// reviewPlan returns the AtlasPlan of the given changes, and creates it for
+// review if it does not exist. Existing plans are honoured only if they are
+// owned by the resource and hold the same changes, so a plan created by a
+// user, e.g. approved with other statements, does not approve the changes.
+func reviewPlan(ctx context.Context, c client.Client, scheme *runtime.Scheme, owner client.Object, spec dbv1alpha1.AtlasPlanSpec) (*dbv1alpha1.AtlasPlan, error) {
+	plan := &dbv1alpha1.AtlasPlan{}
+	key := types.NamespacedName{Namespace: owner.GetNamespace(), Name: planName(owner.GetName(), spec.Hash)}
+	switch err := c.Get(ctx, key, plan); {
+	case err == nil && !metav1.IsControlledBy(plan, owner):
+		return nil, fmt.Errorf("AtlasPlan %s exists and is not owned by %s", key.Name, owner.GetName())
+	case err == nil && (plan.Spec.Hash != spec.Hash || plan.Spec.SQL != spec.SQL || !slices.Equal(plan.Spec.Files, spec.Files)):
+		return nil, fmt.Errorf("AtlasPlan %s does not hold the planned changes, delete it to review them again", key.Name)
+	case err == nil:
+		return plan, nil
+	case !apierrors.IsNotFound(err):
+		return nil, transient(err)
+	}
+	plan = &dbv1alpha1.AtlasPlan{
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		Spec:       spec,
+	}
+	if err := ctrl.SetControllerReference(owner, plan, scheme); err != nil {
+		return nil, err
+	}
+	if err := c.Create(ctx, plan); err != nil {
+		return nil, transient(err)
+	}
+	plan.Status.Phase = dbv1alpha1.PlanPending
+	if err := c.Status().Update(ctx, plan); err != nil {
+		return nil, transient(err)
+	}
+	return plan, nil
+}
+
+// planName returns the name of the AtlasPlan with the given hash.
+func planName(owner, hash string) string {
+	if max := 253 - len(hash) - 1; len(owner) > max {
+		owner = owner[:max]
+	}
+	return owner + "-" + hash
+}
+
+// planObjects returns the database objects affected by the statements,
+// e.g. "table users".
+func planObjects(stmts []string) []string {
+	seen := make(map[string]bool)
+	for _, s := range stmts {
+		m := planObject.FindStringSubmatch(s)
+		if m == nil {
+			continue
+		}
+		kind := strings.ToLower(strings.Join(strings.Fields(m[1]), " "))
+		seen[kind+" "+strings.Trim(m[2], "`\"[]")] = true
+	}
+	objs := make([]string, 0, len(seen))
+	for o := range seen {
+		objs = append(objs, o)
+	}
+	sort.Strings(objs)
+	return objs
+}
+
+// schemaPlan returns the plan of the pending statements of a schema.
+func schemaPlan(sc *dbv1alpha1.AtlasSchema, hash string, stmts []string) dbv1alpha1.AtlasPlanSpec {
+	spec := dbv1alpha1.AtlasPlanSpec{
+		Hash:    hash,
+		Owner:   dbv1alpha1.PlanOwner{Kind: "AtlasSchema", Name: sc.Name},
+		SQL:     strings.Join(stmts, "\n"),
+		Objects: planObjects(stmts),
+	}
+	if sc.Status.Lint != nil {
+		spec.Lint = sc.Status.Lint.Files
+	}
+	return spec
+}
+
+// migrationPlan returns the plan of the pending files of a migration.
+func migrationPlan(md atlasMigrationData, a *dbv1alpha1.MigrationApprovalStatus, lint *dbv1alpha1.LintStatus) (dbv1alpha1.AtlasPlanSpec, error) {
+	spec := dbv1alpha1.AtlasPlanSpec{
+		Hash:  a.PlanHash,
+		Owner: dbv1alpha1.PlanOwner{Kind: "AtlasMigration", Name: md.owner.Name},
+	}
+	dir, err := migrate.NewLocalDir(strings.TrimPrefix(md.Migration.Dir, "file://"))
+	if err != nil {
+		return spec, err
+	}
+	files, err := dir.Files()
+	if err != nil {
+		return spec, err
+	}
+	pending := make(map[string]bool, len(a.Files))
+	for _, f := range a.Files {
+		pending[f.Version] = true
+	}
+	var (
+		b     strings.Builder
+		stmts []string
+	)
+	for _, f := range files {
+		if !pending[f.Version()] {
+			continue
+		}
+		spec.Files = append(spec.Files, f.Version())
+		fmt.Fprintf(&b, "-- %s\n%s\n", f.Name(), strings.TrimSpace(string(f.Bytes())))
+		decls, err := f.StmtDecls()
+		if err != nil {
+			return spec, fmt.Errorf("parsing %s: %w", f.Name(), err)
+		}
+		for _, s := range decls {
+			stmts = append(stmts, s.Text)
+		}
+	}
+	spec.SQL, spec.Objects = b.String(), planObjects(stmts)
+	if lint != nil {
+		spec.Lint = lint.Files
+	}
+	return spec, nil
+}
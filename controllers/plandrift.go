@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// outdatePlan invalidates the plan awaiting approval that was replaced by the
+// plan with the given hash, and reports the changes between the two plans.
+// Approvals of the outdated plan do not carry over to the new plan.
+func (r *AtlasSchemaReconciler) outdatePlan(ctx context.Context, sc *dbv1alpha1.AtlasSchema, old *dbv1alpha1.ApprovalStatus, m *managed, h string, stmts []string) {
+	reason, cause := dbv1alpha1.ReasonDatabaseChanged, "the database changed"
+	if old.ObservedHash != m.hash() {
+		reason, cause = dbv1alpha1.ReasonDesiredSchemaChanged, "the desired schema changed"
+	}
+	msg := fmt.Sprintf("plan %s was replaced by plan %s, as %s", old.PlanHash, h, cause)
+	approved := approvals(old, old.PlanHash) > 0 || sc.Spec.ApprovedHash == old.PlanHash
+	plan := &dbv1alpha1.AtlasPlan{}
+	switch err := r.Get(ctx, types.NamespacedName{Namespace: sc.Namespace, Name: planName(sc.Name, old.PlanHash)}, plan); {
+	case err == nil && plan.Status.Phase != dbv1alpha1.PlanRejected:
+		approved = approved || plan.Status.Phase == dbv1alpha1.PlanApproved
+		plan.Status.Phase, plan.Status.Message = dbv1alpha1.PlanOutdated, msg
+		if err := r.Status().Update(ctx, plan); err != nil {
+			log.FromContext(ctx).Error(err, "failed to mark plan as outdated", "plan", plan.Name)
+		}
+	case err != nil && !apierrors.IsNotFound(err):
+		log.FromContext(ctx).Error(err, "failed to get outdated plan", "plan", plan.Name)
+	}
+	if approved {
+		msg += ". Its approvals were invalidated"
+	}
+	r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonPlanOutdated, msg)
+	if diff := planDiff(old.Plan, stmts); diff != "" {
+		msg += ":\n" + diff
+	}
+	meta.SetStatusCondition(&sc.Status.Conditions, metav1.Condition{
+		Type:    dbv1alpha1.PlanOutdatedCond,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: msg,
+	})
+}
+
+// planDiff returns the statements removed from the old plan, prefixed by "-",
+// and the statements added to the new plan, prefixed by "+". As it is reported
+// in condition messages, lines not fitting in maxStatusSQL bytes are omitted.
+func planDiff(old, cur []string) string {
+	var lines []string
+	for _, s := range old {
+		if !slices.Contains(cur, s) {
+			lines = append(lines, "- "+s)
+		}
+	}
+	for _, s := range cur {
+		if !slices.Contains(old, s) {
+			lines = append(lines, "+ "+s)
+		}
+	}
+	var b strings.Builder
+	for i, l := range lines {
+		if b.Len()+len(l) > maxStatusSQL {
+			fmt.Fprintf(&b, "-- truncated, %d more lines", len(lines)-i)
+			break
+		}
+		b.WriteString(l + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
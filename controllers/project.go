@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+	"github.com/ariga/atlas-operator/internal/git"
+)
+
+// project loads the Atlas project file of the migration, and the values of
+// the variables passed to it.
+func (r *AtlasMigrationReconciler) project(ctx context.Context, am *dbv1alpha1.AtlasMigration, md *atlasMigrationData) error {
+	if !r.allowProject {
+		return errors.New("project files are disabled, they are evaluated by the operator and require starting it with --allow-project-files")
+	}
+	p := am.Spec.Project
+	if p.Env == "" {
+		return errors.New("project.env is required")
+	}
+	// The generated config is not used with a project file, so the fields it
+	// renders must be set in the env of the project file instead.
+	switch s := am.Spec; {
+	case len(s.Exclude) > 0:
+		return errors.New("exclude cannot be set with a project file, set it in the env of the project file")
+	case s.Baseline != "":
+		return errors.New("baseline cannot be set with a project file, set it in the env of the project file")
+	case s.RevisionsSchema != "":
+		return errors.New("revisionsSchema cannot be set with a project file, set it in the env of the project file")
+	case s.Cloud != (dbv1alpha1.Cloud{}):
+		return errors.New("cloud cannot be set with a project file, set it in the project file")
+	case s.TxMode != "":
+		return errors.New("txMode cannot be set with a project file, set it in the env of the project file")
+	}
+	var err error
+	switch {
+	case p.ConfigMapKeyRef != nil && p.Git != nil:
+		return errors.New("cannot define both configMapKeyRef and git for the project file")
+	case p.ConfigMapKeyRef != nil:
+		if md.project, err = getConfigMapValue(ctx, r, am.Namespace, *p.ConfigMapKeyRef); err != nil {
+			return err
+		}
+	case p.Git != nil:
+		params := &git.ReadFileParams{
+			Repo: p.Git.Repo,
+			Ref:  p.Git.Ref,
+			Path: p.Git.Path,
+			User: p.Git.User,
+		}
+		if s := p.Git.PasswordFrom.SecretKeyRef; s != nil {
+			if params.Password, err = getSecretValue(ctx, r, am.Namespace, *s); err != nil {
+				return err
+			}
+		}
+		f, err := r.git.ReadFile(ctx, params)
+		if err != nil {
+			return transient(err)
+		}
+		md.project = f.Content
+	default:
+		return errors.New("project requires a configMapKeyRef or git project file")
+	}
+	md.EnvName = p.Env
+	md.vars = make(atlas.Vars, len(p.Vars))
+	for _, v := range p.Vars {
+		if md.vars[v.Name], err = varValue(ctx, r, am.Namespace, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchProject watches the configmap holding the project file, and the
+// secrets and configmaps referenced by its variables.
+func (r *AtlasMigrationReconciler) watchProject(am dbv1alpha1.AtlasMigration, p *dbv1alpha1.Project) {
+	if c := p.ConfigMapKeyRef; c != nil {
+		r.configMapWatcher.Watch(
+			types.NamespacedName{Name: c.Name, Namespace: am.Namespace},
+			am.NamespacedName(),
+			c.Key,
+		)
+	}
+	for _, v := range p.Vars {
+		if s := v.ValueFrom.SecretKeyRef; s != nil {
+			r.secretWatcher.Watch(
+				types.NamespacedName{Name: s.Name, Namespace: am.Namespace},
+				am.NamespacedName(),
+				s.Key,
+			)
+		}
+		if c := v.ValueFrom.ConfigMapKeyRef; c != nil {
+			r.configMapWatcher.Watch(
+				types.NamespacedName{Name: c.Name, Namespace: am.Namespace},
+				am.NamespacedName(),
+				c.Key,
+			)
+		}
+	}
+}
+
+// overrides returns the URL and the migration directory passed to the CLI
+// when a project file is used. They take precedence over the ones of the
+// selected env, and are empty if not set by the spec. The generated config
+// sets them otherwise.
+func (amd atlasMigrationData) overrides() (u, dir string) {
+	if amd.project == "" {
+		return "", ""
+	}
+	if amd.Migration != nil {
+		dir = amd.Migration.Dir
+	}
+	return amd.URL, dir
+}
+
+// statusParams returns the parameters of the 'migrate status' command.
+func (amd atlasMigrationData) statusParams(atlasHCL string) *atlas.StatusParams {
+	u, dir := amd.overrides()
+	return &atlas.StatusParams{
+		Env:       amd.EnvName,
+		ConfigURL: atlasHCL,
+		URL:       u,
+		DirURL:    dir,
+		Vars:      amd.vars,
+	}
+}
+
+// applyParams returns the parameters of the 'migrate apply' command.
+func (amd atlasMigrationData) applyParams(atlasHCL string) *atlas.ApplyParams {
+	u, dir := amd.overrides()
+	return &atlas.ApplyParams{
+		Env:        amd.EnvName,
+		ConfigURL:  atlasHCL,
+		URL:        u,
+		DirURL:     dir,
+		AllowDirty: amd.allowDirty,
+		ExecOrder:  amd.execOrder,
+		TxMode:     amd.txMode,
+		Vars:       amd.vars,
+	}
+}
+
+// hashVars writes the variables passed to the project file, sorted by name.
+func (amd atlasMigrationData) hashVars(h io.Writer) {
+	names := make([]string, 0, len(amd.vars))
+	for n := range amd.vars {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		h.Write([]byte(n))
+		h.Write([]byte(amd.vars[n]))
+	}
+}
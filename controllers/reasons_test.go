@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestReasonConstants ensures the reasons of conditions and events are the
+// stable constants of the API package, and not string literals that may
+// change silently between releases.
+func TestReasonConstants(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		require.NoError(t, err)
+		ast.Inspect(f, func(n ast.Node) bool {
+			var reason ast.Expr
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				reason = reasonArg(n)
+			case *ast.KeyValueExpr:
+				if k, ok := n.Key.(*ast.Ident); ok && (k.Name == "Reason" || k.Name == "reason") {
+					reason = n.Value
+				}
+			}
+			if lit, ok := reason.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				t.Errorf("%s: reason %s is not a constant of the API package", fset.Position(lit.Pos()), lit.Value)
+			}
+			return true
+		})
+	}
+}
+
+// reasonArg returns the reason argument of calls setting conditions or
+// recording events.
+func reasonArg(call *ast.CallExpr) ast.Expr {
+	var name string
+	switch fn := call.Fun.(type) {
+	case *ast.Ident:
+		name = fn.Name
+	case *ast.SelectorExpr:
+		name = fn.Sel.Name
+	}
+	i := map[string]int{
+		"setNotReady":     1,
+		"SetNotReady":     0,
+		"SetReady":        0,
+		"awaitApproval":   3,
+		"Event":           2,
+		"Eventf":          2,
+		"AnnotatedEventf": 3,
+	}
+	if n, ok := i[name]; ok && n < len(call.Args) {
+		return call.Args[n]
+	}
+	return nil
+}
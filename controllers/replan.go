@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+	"github.com/ariga/atlas-operator/internal/git"
+)
+
+type (
+	// ReplanCLI is the interface used to re-plan the managed resources.
+	ReplanCLI interface {
+		CLI
+		MigrateCLI
+	}
+	// PlanReport reports the plan of a managed resource computed in check-only mode.
+	PlanReport struct {
+		Kind      string `json:"kind"`
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+		// Changes are the pending statements of an AtlasSchema,
+		// or the pending versions of an AtlasMigration.
+		Changes []string `json:"changes,omitempty"`
+		// Changed reports if the resource was in sync with its desired state,
+		// but its plan is no longer empty.
+		Changed bool   `json:"changed"`
+		Error   string `json:"error,omitempty"`
+	}
+)
+
+// Replan re-plans every managed resource in check-only mode and reports its
+// pending changes. No change is applied to the target databases. It is used
+// to validate an upgrade of the operator or the Atlas CLI before any real apply.
+func Replan(ctx context.Context, c client.Client, cli ReplanCLI) ([]PlanReport, error) {
+	var (
+		reports []PlanReport
+		schemas dbv1alpha1.AtlasSchemaList
+		migs    dbv1alpha1.AtlasMigrationList
+	)
+	if err := c.List(ctx, &schemas); err != nil {
+		return nil, err
+	}
+	sr := &AtlasSchemaReconciler{
+		Client:     c,
+		cli:        cli,
+		git:        git.NewClient("git"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		recorder:   nopRecorder{},
+	}
+	for i := range schemas.Items {
+		sc := &schemas.Items[i]
+		rep := PlanReport{Kind: "AtlasSchema", Namespace: sc.Namespace, Name: sc.Name}
+		changes, err := sr.replan(ctx, sc)
+		if err != nil {
+			rep.Error = err.Error()
+		}
+		rep.Changes = changes
+		rep.Changed = meta.IsStatusConditionTrue(sc.Status.Conditions, dbv1alpha1.SchemaReadyCond) && len(changes) > 0
+		reports = append(reports, rep)
+	}
+	if err := c.List(ctx, &migs); err != nil {
+		return nil, err
+	}
+	mr := &AtlasMigrationReconciler{Client: c, CLI: cli, git: git.NewClient("git"), recorder: nopRecorder{}}
+	for i := range migs.Items {
+		am := migs.Items[i]
+		rep := PlanReport{Kind: "AtlasMigration", Namespace: am.Namespace, Name: am.Name}
+		changes, err := mr.replan(ctx, am)
+		if err != nil {
+			rep.Error = err.Error()
+		}
+		rep.Changes = changes
+		rep.Changed = am.IsReady() && len(changes) > 0
+		reports = append(reports, rep)
+	}
+	return reports, nil
+}
+
+// nopRecorder discards the events of the reconcilers run by Replan, as
+// re-planning leaves the resources as they are.
+type nopRecorder struct{}
+
+func (nopRecorder) Event(runtime.Object, string, string, string) {}
+
+func (nopRecorder) Eventf(runtime.Object, string, string, string, ...interface{}) {}
+
+func (nopRecorder) AnnotatedEventf(runtime.Object, map[string]string, string, string, string, ...interface{}) {
+}
+
+// replan returns the statements that would be executed to bring the
+// database in sync with the desired schema.
+func (r *AtlasSchemaReconciler) replan(ctx context.Context, sc *dbv1alpha1.AtlasSchema) ([]string, error) {
+	if sc.Spec.Preview != nil {
+		return nil, errors.New("preview resources are not re-planned")
+	}
+	managed, err := r.extractManaged(ctx, sc)
+	if err != nil {
+		return nil, err
+	}
+	devURL, err := r.devURL(ctx, sc.Name, managed)
+	if err != nil {
+		return nil, fmt.Errorf("getting dev database: %w", err)
+	}
+	desired, clean, err := managed.desiredURL()
+	if err != nil {
+		return nil, err
+	}
+	defer clean()
+	conf, cleanconf, err := configFile(managed)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanconf()
+	dry, err := r.cli.SchemaApply(ctx, &atlas.SchemaApplyParams{
+		DryRun:    true,
+		URL:       managed.url.String(),
+		To:        desired,
+		DevURL:    devURL,
+		Exclude:   managed.exclude,
+		ConfigURL: conf,
+		Schema:    managed.schemas,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dry.Changes.Pending, nil
+}
+
+// replan returns the versions of the migration files pending to be applied.
+func (r *AtlasMigrationReconciler) replan(ctx context.Context, am dbv1alpha1.AtlasMigration) ([]string, error) {
+	md, cleanUp, err := r.extractMigrationData(ctx, am)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanUp()
+	atlasHCL, clean, err := md.render()
+	if err != nil {
+		return nil, err
+	}
+	defer clean()
+	status, err := r.CLI.Status(ctx, md.statusParams(atlasHCL))
+	if err != nil {
+		return nil, err
+	}
+	// Only the migrations up to the target version are applied.
+	amount, _ := status.Amount(md.version)
+	versions := make([]string, 0, amount)
+	for _, f := range status.Pending[:amount] {
+		versions = append(versions, f.Version)
+	}
+	return versions, nil
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+)
+
+type mockReplanCLI struct {
+	*mockCLI
+	*mockMigrateCLI
+}
+
+func (m mockReplanCLI) Lint(ctx context.Context, params *atlas.LintParams) (*atlas.SummaryReport, error) {
+	return m.mockMigrateCLI.Lint(ctx, params)
+}
+
+func (m mockReplanCLI) Version(ctx context.Context) (string, error) {
+	return m.mockMigrateCLI.Version(ctx)
+}
+
+func TestReplan(t *testing.T) {
+	m := &mockClient{state: map[client.ObjectKey]client.Object{}}
+	cli := mockReplanCLI{
+		mockCLI: &mockCLI{plan: "ALTER TABLE `foo` MODIFY COLUMN `id` bigint NOT NULL"},
+		mockMigrateCLI: &mockMigrateCLI{
+			status: &atlas.StatusReport{Pending: []atlas.File{{Version: "20230412003626"}}},
+		},
+	}
+	sc := conditionReconciling()
+	sc.Status.Conditions[0].Status = metav1.ConditionTrue
+	m.put(sc)
+	m.put(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-configmap", Namespace: "default"},
+		Data:       map[string]string{"20230412003626_create_foo.sql": "CREATE TABLE foo (id INT PRIMARY KEY);"},
+	})
+	m.put(&dbv1alpha1.AtlasMigration{
+		ObjectMeta: metav1.ObjectMeta{Name: "atlas-migration", Namespace: "default"},
+		Spec: dbv1alpha1.AtlasMigrationSpec{
+			URL: "sqlite://file2/?mode=memory",
+			Dir: dbv1alpha1.Dir{ConfigMapRef: &corev1.LocalObjectReference{Name: "my-configmap"}},
+		},
+	})
+	reports, err := Replan(context.Background(), m, cli)
+	require.NoError(t, err)
+	require.EqualValues(t, []PlanReport{
+		{
+			Kind:      "AtlasSchema",
+			Namespace: "test",
+			Name:      "my-atlas-schema",
+			Changes:   []string{cli.plan},
+			Changed:   true,
+		},
+		{
+			Kind:      "AtlasMigration",
+			Namespace: "default",
+			Name:      "atlas-migration",
+			Changes:   []string{"20230412003626"},
+		},
+	}, reports)
+	// Nothing was applied.
+	require.True(t, cli.mockCLI.applyRuns[0].DryRun)
+	require.Empty(t, cli.mockMigrateCLI.applyRuns)
+}
+
+func TestReplan_SchemaRef(t *testing.T) {
+	m := &mockClient{state: map[client.ObjectKey]client.Object{}}
+	cli := mockReplanCLI{mockCLI: &mockCLI{}, mockMigrateCLI: &mockMigrateCLI{}}
+	src := conditionReconciling()
+	src.Spec.Schema = dbv1alpha1.Schema{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "schema"},
+		Key:                  "schema.sql",
+	}}
+	m.put(src)
+	m.put(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "schema", Namespace: src.Namespace},
+		Data:       map[string]string{"schema.sql": "CREATE TABLE t (c int);"},
+	})
+	ref := conditionReconciling()
+	ref.Name = "ref"
+	ref.Spec.Schema = dbv1alpha1.Schema{SchemaRef: &corev1.LocalObjectReference{Name: src.Name}}
+	m.put(ref)
+	// Resolving references of reconcilers without watchers does not panic.
+	reports, err := Replan(context.Background(), m, cli)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	for _, r := range reports {
+		require.Empty(t, r.Error)
+	}
+}
+
+func TestReplan_MissingSecret(t *testing.T) {
+	m := &mockClient{state: map[client.ObjectKey]client.Object{}}
+	cli := mockReplanCLI{mockCLI: &mockCLI{}, mockMigrateCLI: &mockMigrateCLI{}}
+	sc := conditionReconciling()
+	sc.Spec.URL = ""
+	sc.Spec.URLFrom = dbv1alpha1.URLFrom{
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "db-creds"},
+			Key:                  "url",
+		},
+	}
+	m.put(sc)
+	// Misconfigured resources are reported, and record no events.
+	reports, err := Replan(context.Background(), m, cli)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	require.Equal(t, "AtlasSchema", reports[0].Kind)
+	require.Contains(t, reports[0].Error, "db-creds")
+	require.Empty(t, cli.mockCLI.applyRuns)
+}
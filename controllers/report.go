@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+type (
+	// ReportOptions selects the changes included in a change report.
+	ReportOptions struct {
+		// Namespaces to include. All namespaces if empty.
+		Namespaces []string
+		// Since and Until bound the time the changes were applied, [Since, Until).
+		// Unbounded if zero.
+		Since, Until time.Time
+	}
+	// ReportEntry is a change applied by a managed resource.
+	ReportEntry struct {
+		Time       time.Time `json:"time"`
+		Kind       string    `json:"kind"`
+		Namespace  string    `json:"namespace"`
+		Name       string    `json:"name"`
+		Version    string    `json:"version,omitempty"`
+		Statements []string  `json:"statements,omitempty"`
+	}
+)
+
+// ChangeReport returns the changes applied by the managed resources within
+// the time range of the options, ordered by time. Changes are read from the
+// history kept in the status of the resources, so a report covers the most
+// recent dbv1alpha1.MaxHistory changes of each resource.
+func ChangeReport(ctx context.Context, c client.Reader, opts ReportOptions) ([]ReportEntry, error) {
+	var (
+		entries []ReportEntry
+		schemas dbv1alpha1.AtlasSchemaList
+		migs    dbv1alpha1.AtlasMigrationList
+	)
+	add := func(kind string, obj metav1.Object, h []dbv1alpha1.AppliedChange) {
+		if len(opts.Namespaces) > 0 && !slices.Contains(opts.Namespaces, obj.GetNamespace()) {
+			return
+		}
+		for _, ch := range h {
+			t := ch.Time.Time
+			if !opts.Since.IsZero() && t.Before(opts.Since) || !opts.Until.IsZero() && !t.Before(opts.Until) {
+				continue
+			}
+			entries = append(entries, ReportEntry{
+				Time:       t.UTC(),
+				Kind:       kind,
+				Namespace:  obj.GetNamespace(),
+				Name:       obj.GetName(),
+				Version:    ch.Version,
+				Statements: ch.Statements,
+			})
+		}
+	}
+	if err := c.List(ctx, &schemas); err != nil {
+		return nil, err
+	}
+	for i := range schemas.Items {
+		add("AtlasSchema", &schemas.Items[i], schemas.Items[i].Status.History)
+	}
+	if err := c.List(ctx, &migs); err != nil {
+		return nil, err
+	}
+	for i := range migs.Items {
+		add("AtlasMigration", &migs.Items[i], migs.Items[i].Status.History)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].Time.Equal(entries[j].Time) {
+			return entries[i].Time.Before(entries[j].Time)
+		}
+		if entries[i].Namespace != entries[j].Namespace {
+			return entries[i].Namespace < entries[j].Namespace
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// WriteReport writes the entries of a change report in the given format,
+// "json" or "csv".
+func WriteReport(w io.Writer, entries []ReportEntry, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if entries == nil {
+			entries = []ReportEntry{}
+		}
+		return enc.Encode(entries)
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"time", "kind", "namespace", "name", "version", "statements"}); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := cw.Write([]string{
+				e.Time.Format(time.RFC3339), e.Kind, e.Namespace, e.Name, e.Version, strings.Join(e.Statements, ";\n"),
+			}); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+}
+
+// StoreReportConfigMap stores the report under the key "report.<format>" of
+// the given ConfigMap, creating it if it does not exist.
+func StoreReportConfigMap(ctx context.Context, c client.Client, key types.NamespacedName, format string, report []byte) error {
+	cm := &corev1.ConfigMap{}
+	switch err := c.Get(ctx, key, cm); {
+	case apierrors.IsNotFound(err):
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Data:       map[string]string{"report." + format: string(report)},
+		}
+		return c.Create(ctx, cm)
+	case err != nil:
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data["report."+format] = string(report)
+	return c.Update(ctx, cm)
+}
+
+// UploadReport uploads the report with an HTTP PUT request, e.g. to a
+// pre-signed URL of an object storage bucket.
+func UploadReport(ctx context.Context, hc *http.Client, url, format string, report []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(report))
+	if err != nil {
+		return err
+	}
+	ct := "application/json"
+	if format == "csv" {
+		ct = "text/csv"
+	}
+	req.Header.Set("Content-Type", ct)
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("uploading report: unexpected status %s", resp.Status)
+	}
+	return nil
+}
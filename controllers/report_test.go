@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func TestChangeReport(t *testing.T) {
+	tt := newTest(t)
+	day := func(d int) metav1.Time {
+		return metav1.NewTime(time.Date(2023, 5, d, 12, 0, 0, 0, time.UTC))
+	}
+	tt.k8s.put(&dbv1alpha1.AtlasSchema{
+		ObjectMeta: metav1.ObjectMeta{Name: "users", Namespace: "app"},
+		Status: dbv1alpha1.AtlasSchemaStatus{History: []dbv1alpha1.AppliedChange{
+			{Time: day(1), Statements: []string{"CREATE TABLE users (id int)"}},
+			{Time: day(10), Statements: []string{"ALTER TABLE users ADD name text", "CREATE INDEX i ON users (name)"}},
+		}},
+	})
+	tt.k8s.put(&dbv1alpha1.AtlasMigration{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "app"},
+		Status: dbv1alpha1.AtlasMigrationStatus{History: []dbv1alpha1.AppliedChange{
+			{Time: day(5), Version: "20230505", Statements: []string{"CREATE TABLE orders (id int)"}},
+		}},
+	})
+	tt.k8s.put(&dbv1alpha1.AtlasSchema{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"},
+		Status: dbv1alpha1.AtlasSchemaStatus{History: []dbv1alpha1.AppliedChange{
+			{Time: day(6), Statements: []string{"CREATE TABLE t (id int)"}},
+		}},
+	})
+	entries, err := ChangeReport(context.Background(), tt.k8s, ReportOptions{
+		Namespaces: []string{"app"},
+		Since:      day(2).Time,
+		Until:      day(11).Time,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.EqualValues(t, "orders", entries[0].Name)
+	require.EqualValues(t, "users", entries[1].Name)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteReport(&buf, entries, "csv"))
+	require.EqualValues(t, `time,kind,namespace,name,version,statements
+2023-05-05T12:00:00Z,AtlasMigration,app,orders,20230505,CREATE TABLE orders (id int)
+2023-05-10T12:00:00Z,AtlasSchema,app,users,,"ALTER TABLE users ADD name text;
+CREATE INDEX i ON users (name)"
+`, buf.String())
+	buf.Reset()
+	require.NoError(t, WriteReport(&buf, nil, "json"))
+	require.EqualValues(t, "[]\n", buf.String())
+	require.EqualError(t, WriteReport(&buf, nil, "xml"), `unsupported report format "xml"`)
+}
+
+func TestStoreReport(t *testing.T) {
+	tt := newTest(t)
+	key := types.NamespacedName{Namespace: "audit", Name: "changes"}
+	require.NoError(t, StoreReportConfigMap(context.Background(), tt.k8s, key, "json", []byte("[]")))
+	require.NoError(t, StoreReportConfigMap(context.Background(), tt.k8s, key, "csv", []byte("time")))
+	cm := tt.k8s.state[key].(*corev1.ConfigMap)
+	require.EqualValues(t, map[string]string{"report.json": "[]", "report.csv": "time"}, cm.Data)
+
+	var body, ct string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPut, r.Method)
+		b, _ := io.ReadAll(r.Body)
+		body, ct = string(b), r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+	require.NoError(t, UploadReport(context.Background(), srv.Client(), srv.URL+"/report.csv", "csv", []byte("time")))
+	require.Equal(t, "time", body)
+	require.Equal(t, "text/csv", ct)
+}
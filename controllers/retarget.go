@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// confirmTargetAnnotation confirms applying changes to the database the URL of
+// a resource was repointed to. It must match the target reported by the
+// TargetChanged condition for the apply to run.
+const confirmTargetAnnotation = "db.atlasgo.io/confirm-target"
+
+// targetChangedErr is returned when the URL of a resource points to a database
+// other than the one the changes were last applied to, and the state of the
+// new database differs from the state of the previous one.
+type targetChangedErr struct {
+	target string
+	msg    string
+	// expired is the time the approvals of the resource expired, if they did.
+	expired time.Time
+}
+
+func (e *targetChangedErr) Error() string {
+	return fmt.Sprintf("%s. Apply to the new database by setting the %s annotation to %q%s",
+		e.msg, confirmTargetAnnotation, e.target, expiredNote(e.expired))
+}
+
+// confirmTargetChanged triggers a reconcile when the confirm-target annotation changes.
+var confirmTargetChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.ObjectNew.GetAnnotations()[confirmTargetAnnotation] != e.ObjectOld.GetAnnotations()[confirmTargetAnnotation]
+	},
+}
+
+// targetID returns the identifier of the database the URL points to. The
+// credentials and the parameters of the URL are omitted, except for the schema
+// it is bound to, so rotating the credentials does not change the target.
+func targetID(u *url.URL) string {
+	h := sha256.Sum256([]byte(u.Scheme + "://" + u.Host + u.Path + "#" + urlSchema(u)))
+	return hex.EncodeToString(h[:])[:12]
+}
+
+// targetName returns the host and the path of the URL, to name the database
+// in messages without its credentials.
+func targetName(u *url.URL) string {
+	return u.Host + u.Path
+}
+
+// checkTarget returns a *targetChangedErr if the URL of the schema points to a
+// database other than the one it was last applied to, and the plan computed
+// against the new database holds changes that were not confirmed. A repointed
+// URL is expected to reach a database in the same state, e.g. after a failover
+// or a rotation of the credentials, and plans holding changes indicate it
+// points to a different database.
+func (r *AtlasSchemaReconciler) checkTarget(sc *dbv1alpha1.AtlasSchema, m *managed, plan *dbv1alpha1.SchemaPlan) error {
+	prev, cur := sc.Status.ObservedTarget, targetID(m.url)
+	if prev == "" || prev == cur || sc.Spec.Preview != nil || len(plan.Statements) == 0 {
+		return nil
+	}
+	expired := approvalsExpired(sc)
+	if sc.Annotations[confirmTargetAnnotation] != cur || !expired.IsZero() {
+		return &targetChangedErr{
+			target:  cur,
+			msg:     fmt.Sprintf("the URL now points to a different database (%s), and plan %s would change it with %d statements", targetName(m.url), plan.Hash, len(plan.Statements)),
+			expired: expired,
+		}
+	}
+	r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonTargetConfirmed,
+		"Applying to the new database %s was confirmed by the %s annotation", targetName(m.url), confirmTargetAnnotation)
+	return nil
+}
+
+// target returns the identifier of the target database, or an empty string if
+// the URL of the database is not known.
+func (d *atlasMigrationData) target() string {
+	u, err := url.Parse(d.URL)
+	if d.URL == "" || err != nil {
+		return ""
+	}
+	return targetID(u)
+}
+
+// checkTarget returns a *targetChangedErr if the URL points to a database other
+// than the one the migrations were last applied to, and the current version of
+// the new database differs from the last applied version, unless it was confirmed.
+func checkTarget(md atlasMigrationData, current string) error {
+	cur := md.target()
+	if md.observedTarget == "" || cur == "" || md.observedTarget == cur || current == md.observedVersion || md.confirmTarget == cur {
+		return nil
+	}
+	u, err := url.Parse(md.URL)
+	if err != nil {
+		return err
+	}
+	prev := md.observedVersion
+	if current == "" {
+		current = "none"
+	}
+	if prev == "" {
+		prev = "none"
+	}
+	return &targetChangedErr{
+		target:  cur,
+		msg:     fmt.Sprintf("the URL now points to a different database (%s) at version %s, and the previous one is at version %s", targetName(u), current, prev),
+		expired: md.approvalsExpired,
+	}
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const (
+	// moveRevisionsAnnotation moves the revisions table to the schema set by
+	// spec.revisionsSchema. Set to "dry-run" to report the statements of the
+	// move without running them, or to the name of the target schema to run it.
+	moveRevisionsAnnotation = "db.atlasgo.io/move-revisions"
+	// moveRevisionsDryRun simulates the move of the revisions table.
+	moveRevisionsDryRun = "dry-run"
+	// revisionsTable is the name of the table Atlas records the applied migrations in.
+	revisionsTable = "atlas_schema_revisions"
+)
+
+// revisionsMovedErr is returned when spec.revisionsSchema no longer matches
+// the schema that holds the revisions table of the database.
+type revisionsMovedErr struct {
+	reason string
+	msg    string
+}
+
+func (e *revisionsMovedErr) Error() string {
+	return e.msg
+}
+
+// moveRevisionsChanged triggers a reconcile when the move-revisions annotation changes.
+var moveRevisionsChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.ObjectNew.GetAnnotations()[moveRevisionsAnnotation] != e.ObjectOld.GetAnnotations()[moveRevisionsAnnotation]
+	},
+}
+
+// revisionsSchema returns the schema Atlas keeps the revisions table in: the
+// one set by spec.revisionsSchema, the schema the URL is bound to, or the
+// default atlas_schema_revisions schema.
+func revisionsSchema(u *url.URL, schema string) string {
+	if schema != "" {
+		return schema
+	}
+	if s := urlSchema(u); s != "" {
+		return s
+	}
+	return revisionsTable
+}
+
+// revisionsSchema returns the schema of the revisions table of the target
+// database, or an empty string if the URL of the database is not known.
+func (d *atlasMigrationData) revisionsSchema() string {
+	u, err := url.Parse(d.URL)
+	if d.URL == "" || err != nil {
+		return ""
+	}
+	return revisionsSchema(u, d.RevisionsSchema)
+}
+
+// revisionsMove holds the steps of moving the revisions table between schemas.
+// The steps are idempotent, so a move interrupted at any step is completed by
+// running it again.
+type revisionsMove struct {
+	from, to string
+	// existsFrom queries if the table exists in the source schema.
+	existsFrom string
+	// copy creates the table in the target schema and copies the rows to it.
+	copy []string
+	// count queries the number of rows in the source and the target tables.
+	countFrom, countTo string
+	// cleanup drops the table in the source schema.
+	cleanup string
+}
+
+// planRevisionsMove returns the statements moving the revisions table from
+// one schema to another on the database of the given URL.
+func planRevisionsMove(u *url.URL, from, to string) (*revisionsMove, error) {
+	var (
+		q, lit func(string) string
+		m      = &revisionsMove{from: from, to: to}
+		src    string
+		dst    string
+		insert string
+	)
+	switch driver(u.Scheme) {
+	case "mysql":
+		q = func(s string) string { return "`" + strings.ReplaceAll(s, "`", "``") + "`" }
+		lit = func(s string) string {
+			return "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(s) + "'"
+		}
+		src, dst = q(from)+"."+q(revisionsTable), q(to)+"."+q(revisionsTable)
+		m.copy = append(m.copy,
+			fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", q(to)),
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s LIKE %s", dst, src),
+		)
+		insert = fmt.Sprintf("INSERT IGNORE INTO %s SELECT * FROM %s", dst, src)
+	case "postgres":
+		q = func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `""`) + `"` }
+		lit = func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+		src, dst = q(from)+"."+q(revisionsTable), q(to)+"."+q(revisionsTable)
+		m.copy = append(m.copy,
+			fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", q(to)),
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING ALL)", dst, src),
+		)
+		insert = fmt.Sprintf("INSERT INTO %s SELECT * FROM %s ON CONFLICT DO NOTHING", dst, src)
+	default:
+		return nil, fmt.Errorf("moving the revisions table is not supported for driver %q", driver(u.Scheme))
+	}
+	m.copy = append(m.copy, insert)
+	m.existsFrom = fmt.Sprintf("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = %s AND table_name = %s", lit(from), lit(revisionsTable))
+	m.countFrom = "SELECT COUNT(*) FROM " + src
+	m.countTo = "SELECT COUNT(*) FROM " + dst
+	m.cleanup = "DROP TABLE IF EXISTS " + src
+	return m, nil
+}
+
+// String returns the statements of the move, one per line.
+func (m *revisionsMove) String() string {
+	return strings.Join(append(append([]string(nil), m.copy...), m.cleanup), ";\n") + ";"
+}
+
+// checkRevisions guards the revisions table against a change of
+// spec.revisionsSchema. Pointing Atlas to a schema without the table makes it
+// ignore the applied history, and therefore, the change is refused until the
+// table is moved with the move-revisions annotation: copied to the target
+// schema, verified, switched to, and removed from the source schema. A URL
+// pointing to another database, or schema, is not a move of the table, and
+// is guarded by the target check instead.
+func (r *AtlasMigrationReconciler) checkRevisions(ctx context.Context, am *dbv1alpha1.AtlasMigration, md atlasMigrationData) error {
+	from, to := am.Status.RevisionsSchema, md.revisionsSchema()
+	if from == "" || to == "" || from == to || md.observedTarget != "" && md.observedTarget != md.target() {
+		return nil
+	}
+	u, err := url.Parse(md.URL)
+	if err != nil {
+		return err
+	}
+	m, err := planRevisionsMove(u, from, to)
+	if err != nil {
+		return &revisionsMovedErr{
+			reason: dbv1alpha1.ReasonRevisionsSchemaChanged,
+			msg:    fmt.Sprintf("the revisions table is in schema %q, and spec.revisionsSchema points to %q: %s", from, to, err),
+		}
+	}
+	switch v := am.Annotations[moveRevisionsAnnotation]; v {
+	case "":
+		return &revisionsMovedErr{
+			reason: dbv1alpha1.ReasonRevisionsSchemaChanged,
+			msg: fmt.Sprintf("the revisions table is in schema %q, and spec.revisionsSchema points to %q. Set the %s annotation to %q to simulate moving it, or to %q to move it",
+				from, to, moveRevisionsAnnotation, moveRevisionsDryRun, to),
+		}
+	case moveRevisionsDryRun:
+		return &revisionsMovedErr{
+			reason: dbv1alpha1.ReasonRevisionsMovePlanned,
+			msg:    fmt.Sprintf("moving the revisions table from schema %q to %q runs:\n%s", from, to, m),
+		}
+	case to:
+		if r.sqlExec == nil {
+			return errors.New("no SQL executor configured for moving the revisions table")
+		}
+		if err := r.moveRevisions(ctx, md.URL, m); err != nil {
+			return err
+		}
+		am.Status.RevisionsSchema = to
+		r.recorder.Eventf(am, corev1.EventTypeNormal, dbv1alpha1.ReasonRevisionsMoved, "Moved the revisions table from schema %q to %q", from, to)
+		return nil
+	default:
+		return &revisionsMovedErr{
+			reason: dbv1alpha1.ReasonRevisionsSchemaChanged,
+			msg:    fmt.Sprintf("the %s annotation must be %q or %q, got %q", moveRevisionsAnnotation, moveRevisionsDryRun, to, v),
+		}
+	}
+}
+
+// moveRevisions copies the revisions table to the target schema, verifies both
+// tables hold the same rows, and drops the source table. A source table that
+// no longer exists was dropped by an earlier attempt, after it was verified.
+func (r *AtlasMigrationReconciler) moveRevisions(ctx context.Context, u string, m *revisionsMove) error {
+	switch n, err := r.Prober.Value(ctx, u, m.existsFrom); {
+	case err != nil:
+		return transient(fmt.Errorf("looking up the revisions table in schema %q: %w", m.from, err))
+	case n == 0:
+		return nil
+	}
+	for _, s := range m.copy {
+		if err := r.sqlExec.Exec(ctx, u, s); err != nil {
+			return fmt.Errorf("copying the revisions table to schema %q: %w", m.to, err)
+		}
+	}
+	n1, err := r.Prober.Value(ctx, u, m.countFrom)
+	if err != nil {
+		return transient(fmt.Errorf("counting the revisions in schema %q: %w", m.from, err))
+	}
+	n2, err := r.Prober.Value(ctx, u, m.countTo)
+	if err != nil {
+		return transient(fmt.Errorf("counting the revisions in schema %q: %w", m.to, err))
+	}
+	if n1 != n2 {
+		return fmt.Errorf("verifying the revisions table: schema %q holds %v rows, and schema %q holds %v", m.from, n1, m.to, n2)
+	}
+	if err := r.sqlExec.Exec(ctx, u, m.cleanup); err != nil {
+		return fmt.Errorf("dropping the revisions table from schema %q: %w", m.from, err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// RiskWebhookPath is the path the risk webhook is served on.
+const RiskWebhookPath = "/validate-db-atlasgo-io-v1alpha1-atlasschema-risk"
+
+var (
+	// sqlTable matches the table names of CREATE TABLE statements.
+	sqlTable = regexp.MustCompile("(?i)\\bCREATE\\s+(?:TEMPORARY\\s+)?TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?([`\"\\[\\]\\w.]+)")
+	// hclTable matches the labels of table blocks.
+	hclTable = regexp.MustCompile(`(?m)^\s*table\s+"([^"]+)"(?:\s+"[^"]*")?\s*\{`)
+	// unquote removes the identifier quotes of table names.
+	unquote = strings.NewReplacer("`", "", `"`, "", "[", "", "]", "")
+)
+
+// RiskValidator warns about updates to AtlasSchema resources that may lead to
+// destructive changes, such as a table removed from the inline schema. It never
+// rejects a request: the warnings are shown by kubectl before the operator
+// plans the change.
+type RiskValidator struct{}
+
+// NewRiskValidator returns a new RiskValidator.
+func NewRiskValidator() *RiskValidator {
+	return &RiskValidator{}
+}
+
+//+kubebuilder:webhook:path=/validate-db-atlasgo-io-v1alpha1-atlasschema-risk,mutating=false,failurePolicy=ignore,sideEffects=None,groups=db.atlasgo.io,resources=atlasschemas,verbs=update,versions=v1alpha1,name=risk.atlasgo.io,admissionReviewVersions=v1
+
+// Handle implements admission.Handler.
+func (v *RiskValidator) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+	var old, cur dbv1alpha1.AtlasSchema
+	if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if err := json.Unmarshal(req.Object.Raw, &cur); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	return admission.Allowed("").WithWarnings(risks(&old, &cur)...)
+}
+
+// risks returns the warnings of a spec update that may drop objects from the
+// target database.
+func risks(old, cur *dbv1alpha1.AtlasSchema) []string {
+	var warns []string
+	if !cur.Spec.Policy.Diff.Skip.DropTable {
+		if dropped := droppedTables(old.Spec.Schema, cur.Spec.Schema); len(dropped) > 0 {
+			warns = append(warns, fmt.Sprintf("tables removed from the desired schema will be dropped from the database: %s",
+				strings.Join(dropped, ", ")))
+		}
+	}
+	var included []string
+	for _, p := range old.Spec.Exclude {
+		if !slices.Contains(cur.Spec.Exclude, p) {
+			included = append(included, p)
+		}
+	}
+	if len(included) > 0 {
+		warns = append(warns, fmt.Sprintf("objects no longer excluded will be dropped from the database if they are not in the desired schema: %s",
+			strings.Join(included, ", ")))
+	}
+	if old.Spec.Policy.Lint.Destructive.Error && !cur.Spec.Policy.Lint.Destructive.Error {
+		warns = append(warns, "destructive changes are no longer rejected by the lint policy")
+	}
+	return warns
+}
+
+// droppedTables returns the tables defined inline by the old schema and not by
+// the new one. Schemas loaded from ConfigMaps or external sources are not
+// compared, as their content is not part of the request.
+func droppedTables(old, cur dbv1alpha1.Schema) []string {
+	prev := inlineTables(old)
+	if len(prev) == 0 {
+		return nil
+	}
+	if cur.SQL == "" && cur.HCL == "" {
+		return nil
+	}
+	next := inlineTables(cur)
+	var dropped []string
+	for t := range prev {
+		if !next[t] {
+			dropped = append(dropped, t)
+		}
+	}
+	sort.Strings(dropped)
+	return dropped
+}
+
+// inlineTables returns the names of the tables defined by the inline schema.
+func inlineTables(s dbv1alpha1.Schema) map[string]bool {
+	var matches [][]string
+	switch {
+	case s.SQL != "":
+		matches = sqlTable.FindAllStringSubmatch(s.SQL, -1)
+	case s.HCL != "":
+		matches = hclTable.FindAllStringSubmatch(s.HCL, -1)
+	}
+	tables := make(map[string]bool, len(matches))
+	for _, m := range matches {
+		tables[strings.ToLower(unquote.Replace(m[1]))] = true
+	}
+	return tables
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func TestRiskValidator(t *testing.T) {
+	v := NewRiskValidator()
+	raw := func(sc *dbv1alpha1.AtlasSchema) runtime.RawExtension {
+		b, err := json.Marshal(sc)
+		require.NoError(t, err)
+		return runtime.RawExtension{Raw: b}
+	}
+	update := func(old, cur *dbv1alpha1.AtlasSchema) admission.Response {
+		return v.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			OldObject: raw(old),
+			Object:    raw(cur),
+		}})
+	}
+	old := conditionReconciling()
+	old.Spec.Schema.SQL = "CREATE TABLE `users` (id int);\ncreate table if not exists orders (id int);"
+	old.Spec.Exclude = []string{"tmp_*"}
+	old.Spec.Policy.Lint.Destructive.Error = true
+
+	// Adding a table is safe.
+	cur := old.DeepCopy()
+	cur.Spec.Schema.SQL += "\nCREATE TABLE items (id int);"
+	resp := update(old, cur)
+	require.True(t, resp.Allowed)
+	require.Empty(t, resp.Warnings)
+
+	// Removing a table, an excluded pattern and the destructive lint error are reported.
+	cur = old.DeepCopy()
+	cur.Spec.Schema.SQL = "CREATE TABLE users (id int);"
+	cur.Spec.Exclude = nil
+	cur.Spec.Policy.Lint.Destructive.Error = false
+	resp = update(old, cur)
+	require.True(t, resp.Allowed)
+	require.Equal(t, []string{
+		"tables removed from the desired schema will be dropped from the database: orders",
+		"objects no longer excluded will be dropped from the database if they are not in the desired schema: tmp_*",
+		"destructive changes are no longer rejected by the lint policy",
+	}, resp.Warnings)
+
+	// Skipped drops are not reported.
+	cur = old.DeepCopy()
+	cur.Spec.Schema.SQL = "CREATE TABLE users (id int);"
+	cur.Spec.Policy.Diff.Skip.DropTable = true
+	require.Empty(t, update(old, cur).Warnings)
+
+	// Tables are compared across HCL and SQL schemas.
+	cur = old.DeepCopy()
+	cur.Spec.Schema.SQL = ""
+	cur.Spec.Schema.HCL = "table \"users\" {\n  schema = schema.public\n}\nschema \"public\" {}"
+	require.Equal(t, []string{
+		"tables removed from the desired schema will be dropped from the database: orders",
+	}, update(old, cur).Warnings)
+
+	// Schemas moved out of the resource are not compared.
+	cur = old.DeepCopy()
+	cur.Spec.Schema.SQL = ""
+	cur.Spec.Schema.ConfigMapKeyRef = &corev1.ConfigMapKeySelector{Key: "schema.sql"}
+	require.Empty(t, update(old, cur).Warnings)
+}
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+)
+
+// sarifKey is the ConfigMap key holding the SARIF report of the lint findings.
+const sarifKey = "lint.sarif"
+
+type (
+	// sarifLog is the root object of a SARIF 2.1.0 report.
+	sarifLog struct {
+		Version string     `json:"version"`
+		Schema  string     `json:"$schema"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules,omitempty"`
+	}
+	sarifRule struct {
+		ID               string       `json:"id"`
+		ShortDescription sarifMessage `json:"shortDescription"`
+		HelpURI          string       `json:"helpUri"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           sarifRegion           `json:"region"`
+	}
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+	sarifRegion struct {
+		CharOffset int `json:"charOffset"`
+	}
+)
+
+// sarifReport returns the SARIF report of the findings of the lint files. The
+// findings of files that failed the lint, or of the given analyzers, are
+// reported as errors, and others as warnings. The location of a finding is the
+// URI returned for its file.
+func sarifReport(files []*atlas.FileReport, analyzers []dbv1alpha1.LintAnalyzer, uri func(*atlas.FileReport) string) ([]byte, error) {
+	var (
+		rules   = make(map[string]string)
+		results = []sarifResult{}
+	)
+	for _, f := range files {
+		for _, r := range f.Reports {
+			for _, d := range r.Diagnostics {
+				if _, ok := rules[d.Code]; !ok {
+					rules[d.Code] = r.Text
+				}
+				level := "warning"
+				if f.Error != "" || errorOn(analyzers, d.Code) {
+					level = "error"
+				}
+				results = append(results, sarifResult{
+					RuleID:  d.Code,
+					Level:   level,
+					Message: sarifMessage{Text: d.Text},
+					Locations: []sarifLocation{{
+						PhysicalLocation: sarifPhysicalLocation{
+							ArtifactLocation: sarifArtifactLocation{URI: uri(f)},
+							Region:           sarifRegion{CharOffset: d.Pos},
+						},
+					}},
+				})
+			}
+		}
+	}
+	driver := sarifDriver{Name: "atlas", InformationURI: "https://atlasgo.io/lint/analyzers"}
+	for id, text := range rules {
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:               id,
+			ShortDescription: sarifMessage{Text: text},
+			HelpURI:          "https://atlasgo.io/lint/analyzers#" + id,
+		})
+	}
+	sort.Slice(driver.Rules, func(i, j int) bool {
+		return driver.Rules[i].ID < driver.Rules[j].ID
+	})
+	return json.MarshalIndent(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}, "", "  ")
+}
+
+// reportLint records the findings of the most recent lint of the schema in its
+// status, and exports its SARIF report. Both report the findings failing the
+// lint as errors. Failing to export the report does not block the apply, and
+// is recorded as a warning event.
+func (r *AtlasSchemaReconciler) reportLint(ctx context.Context, sc *dbv1alpha1.AtlasSchema, des *managed, lintErr error) {
+	errorOn := lintErrorOn(des.policy.Lint, lintErr)
+	if des.lintFiles != nil {
+		sc.Status.Lint = lintStatus(des.lintFiles, errorOn)
+		// The linted file is the planned change of the schema.
+		for i := range sc.Status.Lint.Files {
+			sc.Status.Lint.Files[i].Name = fmt.Sprintf("%s/%s.sql", sc.Namespace, sc.Name)
+		}
+		lintEvents(r.recorder, sc, sc.Status.Lint)
+	}
+	if err := r.exportLint(ctx, sc, des, errorOn); err != nil {
+		r.recorder.Event(sc, corev1.EventTypeWarning, dbv1alpha1.ReasonLintReportError, err.Error())
+	}
+}
+
+// exportLint exports the SARIF report of the most recent lint of the schema
+// to the destinations set in its lint policy. The findings of the analyzers
+// are reported as errors.
+func (r *AtlasSchemaReconciler) exportLint(ctx context.Context, sc *dbv1alpha1.AtlasSchema, des *managed, analyzers []dbv1alpha1.LintAnalyzer) error {
+	rp := des.policy.Lint.Report
+	if rp == nil || des.lintFiles == nil {
+		return nil
+	}
+	// The linted file is the planned change of the schema.
+	uri := fmt.Sprintf("%s/%s.sql", sc.Namespace, sc.Name)
+	report, err := sarifReport(des.lintFiles, analyzers, func(*atlas.FileReport) string { return uri })
+	if err != nil {
+		return err
+	}
+	if rp.ConfigMap {
+		name := lintReportName(sc, rp)
+		if err := storeSARIF(ctx, r.Client, r.scheme, sc, name, report); err != nil {
+			return err
+		}
+		sc.Status.LintReport = name
+	}
+	return postSARIF(ctx, r.httpClient, rp, report)
+}
+
+// lintErrorOn returns the analyzers whose findings fail the lint of a schema:
+// the analyzers set to error by the lint policy, and destructive if the lint
+// failed on destructive changes, e.g. on the first run of the schema.
+func lintErrorOn(l dbv1alpha1.Lint, lintErr error) []dbv1alpha1.LintAnalyzer {
+	analyzers := policyErrorOn(l)
+	var d destructiveErr
+	if errors.As(lintErr, &d) {
+		analyzers = append(analyzers, "destructive")
+	}
+	return analyzers
+}
+
+// exportLint exports the SARIF report of the lint of the migration files to
+// the destinations set in the lint of the migration.
+func (r *AtlasMigrationReconciler) exportLint(ctx context.Context, am *dbv1alpha1.AtlasMigration, l *dbv1alpha1.MigrationLint, files []*atlas.FileReport) error {
+	rp := l.Report
+	report, err := sarifReport(files, l.ErrorOn, func(f *atlas.FileReport) string { return f.Name })
+	if err != nil {
+		return err
+	}
+	if rp.ConfigMap {
+		if err := storeSARIF(ctx, r.Client, r.Scheme, am, lintReportName(am, rp), report); err != nil {
+			return err
+		}
+	}
+	return postSARIF(ctx, r.httpClient, rp, report)
+}
+
+// lintReportName returns the name of the ConfigMap holding the SARIF report of the resource.
+func lintReportName(obj client.Object, rp *dbv1alpha1.LintReport) string {
+	if rp.Name != "" {
+		return rp.Name
+	}
+	return obj.GetName() + "-lint"
+}
+
+// storeSARIF stores the report in the ConfigMap with the given name, owned by
+// the resource. ConfigMaps of the name not owned by the resource are not written.
+func storeSARIF(ctx context.Context, c client.Client, scheme *runtime.Scheme, owner client.Object, name string, report []byte) error {
+	cm := &corev1.ConfigMap{}
+	switch err := getOwnedConfigMap(ctx, c, owner, name, cm); {
+	case apierrors.IsNotFound(err):
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: owner.GetNamespace()},
+			Data:       map[string]string{sarifKey: string(report)},
+		}
+		if err := ctrl.SetControllerReference(owner, cm, scheme); err != nil {
+			return err
+		}
+		return c.Create(ctx, cm)
+	case err != nil:
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[sarifKey] = string(report)
+	return c.Update(ctx, cm)
+}
+
+// postSARIF sends the report to the URL of the lint report, if set.
+func postSARIF(ctx context.Context, hc *http.Client, rp *dbv1alpha1.LintReport, report []byte) error {
+	if rp.URL == "" {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rp.URL, bytes.NewReader(report))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/sarif+json")
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("sending lint report: unexpected status %s", resp.Status)
+	}
+	return nil
+}
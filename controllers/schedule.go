@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+const (
+	// plannedWindows is the number of apply windows reported in the status.
+	plannedWindows = 3
+	// cronHorizon bounds the search for the next start of a schedule. Four
+	// years cover schedules of February 29.
+	cronHorizon = 4*366 + 1
+)
+
+type (
+	// cronSchedule is a parsed cron expression. Each field holds the set of
+	// values it matches.
+	cronSchedule struct {
+		minute, hour, dom, month, dow [61]bool
+		// domAny and dowAny report if the day fields are "*". If both day
+		// fields are restricted, a day matching either of them matches.
+		domAny, dowAny bool
+	}
+	// applyWindow is a parsed dbv1alpha1.ApplyWindow.
+	applyWindow struct {
+		cron     *cronSchedule
+		duration time.Duration
+		loc      *time.Location
+	}
+	// windowErr is returned when changes are planned outside the apply windows.
+	windowErr struct {
+		next time.Time
+	}
+)
+
+func (e *windowErr) Error() string {
+	return fmt.Sprintf("changes are applied in the apply windows only, the next window opens at %s", e.next.UTC().Format(time.RFC3339))
+}
+
+// retry returns the delay before the next window opens.
+func (e *windowErr) retry(now time.Time) time.Duration {
+	if d := e.next.Sub(now); d > 0 {
+		return d
+	}
+	return time.Second
+}
+
+// cronField defines the range and the names of the values of a cron field.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDOM    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: []string{"", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}}
+	// Sunday is both 0 and 7.
+	cronDOW = cronField{name: "day of week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}}
+	// cronShorthands maps the supported shorthands to their expressions.
+	cronShorthands = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// parseCron parses a cron expression of five fields: minute, hour, day of
+// month, month and day of week. Fields are lists of values, ranges ("1-5")
+// and steps ("*/15" or "8-18/2"). Months and days of week may be named,
+// e.g. "JAN" or "MON".
+func parseCron(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if s, ok := cronShorthands[expr]; ok {
+		expr = s
+	}
+	fs := strings.Fields(expr)
+	if len(fs) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fs))
+	}
+	c := &cronSchedule{domAny: fs[2] == "*", dowAny: fs[4] == "*"}
+	for i, f := range []struct {
+		def cronField
+		set *[61]bool
+	}{
+		{cronMinute, &c.minute},
+		{cronHour, &c.hour},
+		{cronDOM, &c.dom},
+		{cronMonth, &c.month},
+		{cronDOW, &c.dow},
+	} {
+		if err := f.def.parse(fs[i], f.set); err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+	}
+	if c.dow[7] {
+		c.dow[0] = true
+	}
+	return c, nil
+}
+
+// parse sets the values matched by the field expression s.
+func (f cronField) parse(s string, set *[61]bool) error {
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if r, st, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(st)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid step %q of the %s field", st, f.name)
+			}
+			rng, step = r, n
+		}
+		lo, hi := f.min, f.max
+		switch l, h, ok := strings.Cut(rng, "-"); {
+		case rng == "*":
+		case ok:
+			var err error
+			if lo, err = f.value(l); err != nil {
+				return err
+			}
+			if hi, err = f.value(h); err != nil {
+				return err
+			}
+			if lo > hi {
+				return fmt.Errorf("invalid range %q of the %s field", rng, f.name)
+			}
+		default:
+			v, err := f.value(rng)
+			if err != nil {
+				return err
+			}
+			lo, hi = v, v
+			// "5/15" starts at 5 and runs to the end of the range.
+			if step > 1 {
+				hi = f.max
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+// value parses a single value of the field, either a number or a name.
+func (f cronField) value(s string) (int, error) {
+	for i, n := range f.names {
+		if n != "" && strings.EqualFold(s, n) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q of the %s field, expected %d-%d", s, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// matchDay reports if the schedule matches the given day.
+func (c *cronSchedule) matchDay(t time.Time) bool {
+	if !c.month[t.Month()] {
+		return false
+	}
+	dom, dow := c.dom[t.Day()], c.dow[t.Weekday()]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// next returns the first start of the schedule after t, in the location of
+// loc, or the zero time if there is none within the search horizon. Starts
+// at local times skipped by a daylight saving time transition are moved
+// forward by the length of the transition, and starts at local times
+// repeated by a transition occur once.
+func (c *cronSchedule) next(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	for i := 0; i < cronHorizon; i, day = i+1, day.AddDate(0, 0, 1) {
+		if !c.matchDay(day) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if !c.hour[h] {
+				continue
+			}
+			for m := 0; m < 60; m++ {
+				if !c.minute[m] {
+					continue
+				}
+				s := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, loc)
+				// time.Date may resolve a local time skipped by a transition
+				// with the offset after it, i.e. before the transition. Move
+				// it past the transition. Repeated local times resolve to
+				// one of their instants.
+				if d := time.Duration(h-s.Hour())*time.Hour + time.Duration(m-s.Minute())*time.Minute; s.Day() == day.Day() && d > 0 {
+					s = s.Add(d)
+				}
+				if s.After(t) {
+					return s
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// parseWindows parses the apply windows of a resource.
+func parseWindows(ws []dbv1alpha1.ApplyWindow) ([]*applyWindow, error) {
+	parsed := make([]*applyWindow, 0, len(ws))
+	for i, w := range ws {
+		c, err := parseCron(w.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("applyWindows[%d]: %w", i, err)
+		}
+		if w.Duration.Duration <= 0 {
+			return nil, fmt.Errorf("applyWindows[%d]: duration must be positive", i)
+		}
+		loc := time.UTC
+		if w.TimeZone != "" {
+			if loc, err = time.LoadLocation(w.TimeZone); err != nil {
+				return nil, fmt.Errorf("applyWindows[%d]: unknown time zone %q", i, w.TimeZone)
+			}
+		}
+		// Schedules of days that do not exist, e.g. "0 0 30 2 *", never open.
+		if c.next(time.Now(), loc).IsZero() {
+			return nil, fmt.Errorf("applyWindows[%d]: schedule %q never opens", i, w.Schedule)
+		}
+		parsed = append(parsed, &applyWindow{cron: c, duration: w.Duration.Duration, loc: loc})
+	}
+	return parsed, nil
+}
+
+// open returns the end of the window open at t, if there is one.
+func (w *applyWindow) open(t time.Time) (time.Time, bool) {
+	var end time.Time
+	for s := w.cron.next(t.Add(-w.duration), w.loc); !s.IsZero() && !s.After(t); s = w.cron.next(s, w.loc) {
+		end = s.Add(w.duration)
+	}
+	return end, !end.IsZero()
+}
+
+// upcoming returns the window open at t, if there is one, and the next n
+// windows of the schedule.
+func (w *applyWindow) upcoming(t time.Time, n int) []dbv1alpha1.PlannedWindow {
+	var planned []dbv1alpha1.PlannedWindow
+	s := w.cron.next(t.Add(-w.duration), w.loc)
+	for ; !s.IsZero() && len(planned) < n; s = w.cron.next(s, w.loc) {
+		planned = append(planned, dbv1alpha1.PlannedWindow{
+			Start: metav1.NewTime(s),
+			End:   metav1.NewTime(s.Add(w.duration)),
+		})
+	}
+	return planned
+}
+
+// checkWindows returns a *windowErr if none of the apply windows is open at
+// t. Changes may be applied at any time if there are no windows.
+func checkWindows(ws []*applyWindow, t time.Time) error {
+	if len(ws) == 0 {
+		return nil
+	}
+	var next time.Time
+	for _, w := range ws {
+		if _, ok := w.open(t); ok {
+			return nil
+		}
+		if s := w.cron.next(t, w.loc); !s.IsZero() && (next.IsZero() || s.Before(next)) {
+			next = s
+		}
+	}
+	return &windowErr{next: next}
+}
+
+// plannedApplyWindows returns the open and the next apply windows at t, ordered
+// by their start.
+func plannedApplyWindows(ws []*applyWindow, t time.Time) []dbv1alpha1.PlannedWindow {
+	var planned []dbv1alpha1.PlannedWindow
+	for _, w := range ws {
+		planned = append(planned, w.upcoming(t, plannedWindows)...)
+	}
+	sort.SliceStable(planned, func(i, j int) bool {
+		return planned[i].Start.Before(&planned[j].Start)
+	})
+	if len(planned) > plannedWindows {
+		planned = planned[:plannedWindows]
+	}
+	for i := range planned {
+		planned[i].Start, planned[i].End = metav1.NewTime(planned[i].Start.UTC()), metav1.NewTime(planned[i].End.UTC())
+	}
+	return planned
+}
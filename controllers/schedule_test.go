@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, tt := range []struct {
+		expr, err string
+	}{
+		{expr: "0 2 * * *"},
+		{expr: "*/15 8-18/2 1,15 JAN-MAR mon-fri"},
+		{expr: "@weekly"},
+		{expr: "0 2 * *", err: `invalid cron expression "0 2 * *": expected 5 fields, got 4`},
+		{expr: "60 2 * * *", err: `invalid cron expression "60 2 * * *": invalid value "60" of the minute field, expected 0-59`},
+		{expr: "0 18-8 * * *", err: `invalid cron expression "0 18-8 * * *": invalid range "18-8" of the hour field`},
+		{expr: "*/0 * * * *", err: `invalid cron expression "*/0 * * * *": invalid step "0" of the minute field`},
+		{expr: "0 0 * FOO *", err: `invalid cron expression "0 0 * FOO *": invalid value "FOO" of the month field, expected 1-12`},
+	} {
+		_, err := parseCron(tt.expr)
+		if tt.err == "" {
+			require.NoError(t, err, tt.expr)
+		} else {
+			require.EqualError(t, err, tt.err)
+		}
+	}
+	// Sunday is both 0 and 7.
+	c, err := parseCron("0 0 * * 7")
+	require.NoError(t, err)
+	require.True(t, c.dow[time.Sunday])
+}
+
+func TestCronNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	c, err := parseCron("30 2 * * *")
+	require.NoError(t, err)
+
+	// Starts are in the time zone of the window.
+	s := c.next(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), ny)
+	require.Equal(t, time.Date(2024, 3, 2, 7, 30, 0, 0, time.UTC), s.UTC())
+	// 02:30 is skipped when the clocks spring forward, and the window starts at 03:30 instead.
+	s = c.next(time.Date(2024, 3, 10, 0, 0, 0, 0, ny), ny)
+	require.Equal(t, time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC), s.UTC())
+	require.Equal(t, 3, s.Hour())
+	s = c.next(s, ny)
+	require.Equal(t, time.Date(2024, 3, 11, 6, 30, 0, 0, time.UTC), s.UTC())
+
+	// 01:30 is repeated when the clocks fall back, and the window starts once.
+	c, err = parseCron("30 1 * * *")
+	require.NoError(t, err)
+	s = c.next(time.Date(2024, 11, 3, 0, 0, 0, 0, ny), ny)
+	require.Equal(t, 3, s.Day())
+	require.Equal(t, 4, c.next(s, ny).Day())
+
+	// Day of month or day of week.
+	c, err = parseCron("0 0 13 * FRI")
+	require.NoError(t, err)
+	s = c.next(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+	require.Equal(t, time.Date(2024, 9, 6, 0, 0, 0, 0, time.UTC), s)
+	s = c.next(time.Date(2024, 9, 10, 0, 0, 0, 0, time.UTC), time.UTC)
+	require.Equal(t, time.Date(2024, 9, 13, 0, 0, 0, 0, time.UTC), s)
+}
+
+func TestParseWindows(t *testing.T) {
+	_, err := parseWindows([]dbv1alpha1.ApplyWindow{
+		{Schedule: "0 2 * * *", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Europe/Berlin"},
+		{Schedule: "0 2 * * *", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Mars/Olympus"},
+	})
+	require.EqualError(t, err, `applyWindows[1]: unknown time zone "Mars/Olympus"`)
+	_, err = parseWindows([]dbv1alpha1.ApplyWindow{{Schedule: "0 2 * * *"}})
+	require.EqualError(t, err, "applyWindows[0]: duration must be positive")
+	_, err = parseWindows([]dbv1alpha1.ApplyWindow{{Schedule: "0 0 30 2 *", Duration: metav1.Duration{Duration: time.Hour}}})
+	require.EqualError(t, err, `applyWindows[0]: schedule "0 0 30 2 *" never opens`)
+	_, err = parseWindows([]dbv1alpha1.ApplyWindow{{Schedule: "0 0 29 2 *", Duration: metav1.Duration{Duration: time.Hour}}})
+	require.NoError(t, err)
+}
+
+func TestCheckWindows(t *testing.T) {
+	ws, err := parseWindows([]dbv1alpha1.ApplyWindow{
+		// Weeknights, 22:00-02:00 in Berlin.
+		{Schedule: "0 22 * * MON-FRI", Duration: metav1.Duration{Duration: 4 * time.Hour}, TimeZone: "Europe/Berlin"},
+		// Saturdays, 06:00-08:00 UTC.
+		{Schedule: "0 6 * * SAT", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, checkWindows(nil, time.Now()))
+
+	// Tuesday 21:30 UTC is 23:30 in Berlin.
+	now := time.Date(2024, 7, 9, 21, 30, 0, 0, time.UTC)
+	require.NoError(t, checkWindows(ws, now))
+	// Windows started the day before are still open.
+	require.NoError(t, checkWindows(ws, time.Date(2024, 7, 10, 23, 30, 0, 0, time.UTC)))
+
+	// Saturday noon waits for Monday night.
+	now = time.Date(2024, 7, 13, 12, 0, 0, 0, time.UTC)
+	err = checkWindows(ws, now)
+	require.EqualError(t, err, "changes are applied in the apply windows only, the next window opens at 2024-07-15T20:00:00Z")
+	require.Equal(t, 56*time.Hour, err.(*windowErr).retry(now))
+
+	// The open window is reported first.
+	planned := plannedApplyWindows(ws, time.Date(2024, 7, 12, 23, 0, 0, 0, time.UTC))
+	require.Equal(t, []dbv1alpha1.PlannedWindow{
+		{Start: metav1.NewTime(time.Date(2024, 7, 12, 20, 0, 0, 0, time.UTC)), End: metav1.NewTime(time.Date(2024, 7, 13, 0, 0, 0, 0, time.UTC))},
+		{Start: metav1.NewTime(time.Date(2024, 7, 13, 6, 0, 0, 0, time.UTC)), End: metav1.NewTime(time.Date(2024, 7, 13, 8, 0, 0, 0, time.UTC))},
+		{Start: metav1.NewTime(time.Date(2024, 7, 15, 20, 0, 0, 0, time.UTC)), End: metav1.NewTime(time.Date(2024, 7, 16, 0, 0, 0, 0, time.UTC))},
+	}, planned)
+}
+
+func TestReconcile_ApplyWindows(t *testing.T) {
+	tt := newTest(t)
+	tt.mockCLI().plan = "ALTER TABLE `foo` ADD COLUMN `bar` int"
+	sc := conditionReconciling()
+	// The window of New Year's Eve is closed for the most part of the year.
+	sc.Spec.ApplyWindows = []dbv1alpha1.ApplyWindow{{Schedule: "59 23 31 12 *", Duration: metav1.Duration{Duration: time.Minute}}}
+	tt.k8s.put(sc)
+	tt.k8s.put(devDBReady())
+
+	// Changes wait for the next window.
+	res, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.Positive(t, res.RequeueAfter)
+	require.EqualValues(t, metav1.ConditionFalse, tt.cond().Status)
+	require.Equal(t, "OutsideApplyWindow", tt.cond().Reason)
+	require.Len(t, tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema).Status.ApplyWindows, plannedWindows)
+	require.Empty(t, tt.mockCLI().applied)
+
+	// Invalid windows are reported.
+	sc = tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema)
+	sc.Spec.ApplyWindows[0].TimeZone = "Mars/Olympus"
+	tt.events()
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.Equal(t, "InvalidApplyWindow", tt.cond().Reason)
+	require.Equal(t, []string{`Warning InvalidApplyWindow applyWindows[0]: unknown time zone "Mars/Olympus"`}, tt.events())
+
+	// Changes are applied in open windows.
+	sc.Spec.ApplyWindows[0] = dbv1alpha1.ApplyWindow{Schedule: "* * * * *", Duration: metav1.Duration{Duration: time.Hour}}
+	tt.k8s.put(devDBReady())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.EqualValues(t, metav1.ConditionTrue, tt.cond().Status)
+}
+
+func TestScheduleValidator(t *testing.T) {
+	v := NewScheduleValidator()
+	create := func(obj any) admission.Response {
+		b, err := json.Marshal(obj)
+		require.NoError(t, err)
+		return v.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: b},
+		}})
+	}
+	sc := conditionReconciling()
+	sc.Spec.ApplyWindows = []dbv1alpha1.ApplyWindow{{Schedule: "0 2 * * *", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Asia/Tokyo"}}
+	require.True(t, create(sc).Allowed)
+
+	am := &dbv1alpha1.AtlasMigration{}
+	am.Spec.ApplyWindows = []dbv1alpha1.ApplyWindow{{Schedule: "0 25 * * *", Duration: metav1.Duration{Duration: time.Hour}}}
+	resp := create(am)
+	require.False(t, resp.Allowed)
+	require.EqualValues(t, `applyWindows[0]: invalid cron expression "0 25 * * *": invalid value "25" of the hour field, expected 0-23`, string(resp.Result.Reason))
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// ScheduleWebhookPath is the path the schedule webhook is served on.
+const ScheduleWebhookPath = "/validate-db-atlasgo-io-v1alpha1-schedule"
+
+// ScheduleValidator rejects AtlasSchema and AtlasMigration resources with
+// invalid apply windows, such as malformed cron expressions or unknown time
+// zones, instead of reporting them on the status after they are stored.
+type ScheduleValidator struct{}
+
+// NewScheduleValidator returns a new ScheduleValidator.
+func NewScheduleValidator() *ScheduleValidator {
+	return &ScheduleValidator{}
+}
+
+//+kubebuilder:webhook:path=/validate-db-atlasgo-io-v1alpha1-schedule,mutating=false,failurePolicy=ignore,sideEffects=None,groups=db.atlasgo.io,resources=atlasschemas;atlasmigrations,verbs=create;update,versions=v1alpha1,name=schedule.atlasgo.io,admissionReviewVersions=v1
+
+// Handle implements admission.Handler.
+func (v *ScheduleValidator) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+	// Both resources define the apply windows at the same path.
+	var obj struct {
+		Spec struct {
+			ApplyWindows []dbv1alpha1.ApplyWindow `json:"applyWindows"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if _, err := parseWindows(obj.Spec.ApplyWindows); err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
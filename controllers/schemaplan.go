@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+	"github.com/ariga/atlas-operator/internal/atlas"
+)
+
+// plannedKey is the ConfigMap key holding the full SQL of the planned statements.
+const plannedKey = "plan.sql"
+
+// planDriftErr is returned when the database changed after the changes were
+// planned for the same desired schema, so applying the desired schema would
+// run different statements than the stored plan.
+type planDriftErr struct {
+	stored, planned *dbv1alpha1.SchemaPlan
+}
+
+func (e *planDriftErr) Error() string {
+	msg := fmt.Sprintf("the database changed after plan %s was computed. Plan %s replaces it and is applied once approved",
+		e.stored.Hash, e.planned.Hash)
+	if diff := planDiff(e.stored.Statements, e.planned.Statements); diff != "" && !e.stored.Truncated {
+		msg += ":\n" + diff
+	}
+	return msg
+}
+
+// plan computes the changes to apply to the database and stores them in
+// status.plan. It returns a *planDriftErr if they differ from the stored plan
+// of the same desired schema, as the database changed since it was planned,
+// and the new plan is stored as replacing it, to be applied once approved.
+func (r *AtlasSchemaReconciler) plan(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed, devURL string) (*dbv1alpha1.SchemaPlan, error) {
+	desired, clean, err := m.desiredURL()
+	if err != nil {
+		return nil, err
+	}
+	defer clean()
+	dry, err := r.cli.SchemaApply(ctx, &atlas.SchemaApplyParams{
+		DryRun:    true,
+		URL:       m.url.String(),
+		To:        desired,
+		DevURL:    devURL,
+		Exclude:   m.exclude,
+		ConfigURL: m.configfile,
+		Schema:    m.schemas,
+	})
+	if isSQLErr(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, transient(err)
+	}
+	planned := &dbv1alpha1.SchemaPlan{
+		Hash:         planHash(m, dry.Changes.Pending),
+		ObservedHash: m.hash(),
+		Statements:   dry.Changes.Pending,
+		PlannedAt:    metav1.Now(),
+	}
+	stored := sc.Status.Plan
+	if stored != nil && stored.Hash == planned.Hash {
+		planned.PlannedAt, planned.DriftedFrom = stored.PlannedAt, stored.DriftedFrom
+		return planned, nil
+	}
+	if stored != nil && stored.ObservedHash == planned.ObservedHash {
+		planned.DriftedFrom = stored.Hash
+		sc.Status.Plan = statusPlan(planned, m.secret)
+		return nil, &planDriftErr{stored: stored, planned: planned}
+	}
+	sc.Status.Plan = statusPlan(planned, m.secret)
+	return planned, nil
+}
+
+// statusPlan returns the plan stored in the status, with the statements that
+// do not fit in maxStatusSQL omitted, or all of them if the desired schema is
+// read from a secret. Its hash still identifies all of them.
+func statusPlan(plan *dbv1alpha1.SchemaPlan, secret bool) *dbv1alpha1.SchemaPlan {
+	p := *plan
+	p.Statements = nil
+	n := 0
+	for _, s := range plan.Statements {
+		if n += len(s); secret || n > maxStatusSQL {
+			p.Truncated = true
+			break
+		}
+		p.Statements = append(p.Statements, s)
+	}
+	return &p
+}
+
+// reportPlanned previews the statements of the plan in status.planned, with
+// their string literals redacted. Statements that do not fit in the status
+// are stored in full, and not redacted, in a ConfigMap owned by the schema.
+// Only the number of statements is reported for schemas read from secrets.
+func (r *AtlasSchemaReconciler) reportPlanned(ctx context.Context, sc *dbv1alpha1.AtlasSchema, m *managed, plan *dbv1alpha1.SchemaPlan) {
+	if len(plan.Statements) == 0 {
+		r.clearPlanned(ctx, sc)
+		return
+	}
+	if p := sc.Status.Planned; p != nil && p.PlanHash == plan.Hash && (!p.Truncated || p.ConfigMap != "") {
+		return
+	}
+	p := &dbv1alpha1.PlannedStatus{PlanHash: plan.Hash, Statements: len(plan.Statements)}
+	if m.secret {
+		p.Truncated = true
+	} else {
+		p.SQL, p.Truncated = redactedScript(plan.Statements, maxStatusSQL)
+	}
+	if p.Truncated && !m.secret {
+		name := sc.Name + "-plan"
+		// Reviewers read the statements to run as they are, so the ConfigMap is not redacted.
+		full, _ := sqlScript(plan.Statements, 0)
+		if err := r.storeScript(ctx, sc, name, plannedKey, full); err != nil {
+			log.FromContext(ctx).Error(err, "failed to store the planned statements", "name", name)
+		} else {
+			p.ConfigMap = name
+		}
+	}
+	if old := sc.Status.Planned; old != nil && old.ConfigMap != "" && p.ConfigMap == "" {
+		r.clearPlanned(ctx, sc)
+	}
+	sc.Status.Planned = p
+}
+
+// clearPlanned removes the preview of the planned statements from the status
+// of the schema, and deletes the ConfigMap holding them.
+func (r *AtlasSchemaReconciler) clearPlanned(ctx context.Context, sc *dbv1alpha1.AtlasSchema) {
+	p := sc.Status.Planned
+	if p == nil {
+		return
+	}
+	if p.ConfigMap != "" {
+		if err := deleteOwnedConfigMap(ctx, r, sc, p.ConfigMap); err != nil {
+			log.FromContext(ctx).Error(err, "failed to delete the planned statements ConfigMap", "name", p.ConfigMap)
+		}
+	}
+	sc.Status.Planned = nil
+}
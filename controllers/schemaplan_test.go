@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func TestReconcile_Planned(t *testing.T) {
+	tt := newTest(t)
+	tt.mockCLI().plan = "ALTER TABLE `foo` ADD COLUMN `bar` varchar(255) NOT NULL DEFAULT 'secret'"
+	sc := conditionReconciling()
+	sc.Spec.DryRun = true
+	tt.k8s.put(sc)
+	tt.k8s.put(devDBReady())
+	schema := func() *dbv1alpha1.AtlasSchema {
+		return tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema)
+	}
+	configMap := func() (*corev1.ConfigMap, bool) {
+		cm, ok := tt.k8s.state[types.NamespacedName{Namespace: "test", Name: "my-atlas-schema-plan"}].(*corev1.ConfigMap)
+		return cm, ok
+	}
+
+	// Small plans are previewed in the status only.
+	_, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	p := schema().Status.Planned
+	require.NotNil(t, p)
+	require.Equal(t, schema().Status.Plan.Hash, p.PlanHash)
+	require.Equal(t, 1, p.Statements)
+	require.Equal(t, "ALTER TABLE `foo` ADD COLUMN `bar` varchar(255) NOT NULL DEFAULT 'xxxxx';\n", p.SQL)
+	require.False(t, p.Truncated)
+	require.Empty(t, p.ConfigMap)
+
+	// Large plans are truncated, and stored in full in a ConfigMap.
+	var stmts []string
+	for i := 0; i < 200; i++ {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE `foo` ADD COLUMN `c%d` varchar(255) NOT NULL DEFAULT 'secret'", i))
+	}
+	tt.mockCLI().plan = strings.Join(stmts, ";\n")
+	sc = schema()
+	sc.Spec.Schema.SQL = "CREATE TABLE foo (id INT PRIMARY KEY, c0 varchar(255));"
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	p = schema().Status.Planned
+	require.True(t, p.Truncated)
+	require.LessOrEqual(t, len(p.SQL), maxStatusSQL)
+	require.NotContains(t, p.SQL, "secret")
+	require.Equal(t, "my-atlas-schema-plan", p.ConfigMap)
+	cm, ok := configMap()
+	require.True(t, ok)
+	require.Equal(t, strings.Join(stmts, ";\n")+";\n", cm.Data[plannedKey])
+	// The plan in the status omits the statements that do not fit in it.
+	plan := schema().Status.Plan
+	require.True(t, plan.Truncated)
+	require.Empty(t, plan.Statements)
+	require.Equal(t, &dbv1alpha1.SchemaPlan{Statements: []string{"a", "b"}}, statusPlan(&dbv1alpha1.SchemaPlan{Statements: []string{"a", "b"}}, false))
+	large := statusPlan(&dbv1alpha1.SchemaPlan{Statements: []string{"a", strings.Repeat("b", maxStatusSQL)}}, false)
+	require.Equal(t, []string{"a"}, large.Statements)
+	require.True(t, large.Truncated)
+
+	// The preview and its ConfigMap are removed once the plan is applied.
+	sc = schema()
+	sc.Spec.DryRun = false
+	tt.k8s.put(devDBReady())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.Nil(t, schema().Status.Planned)
+	_, ok = configMap()
+	require.False(t, ok)
+
+	// ConfigMaps of other owners are not deleted.
+	other := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-atlas-schema-plan", Namespace: "test"},
+		Data:       map[string]string{"app.conf": "debug=false"},
+	}
+	tt.k8s.put(other)
+	sc = schema()
+	sc.Status.Planned = &dbv1alpha1.PlannedStatus{ConfigMap: other.Name}
+	tt.r.clearPlanned(context.Background(), sc)
+	cm, ok = configMap()
+	require.True(t, ok)
+	require.Equal(t, other.Data, cm.Data)
+}
+
+func TestReconcile_SecretSchema(t *testing.T) {
+	tt := newTest(t)
+	tt.mockCLI().plan = "CREATE TABLE `payroll` (`salary` int)"
+	tt.mockCLI().applied = []string{tt.mockCLI().plan}
+	tt.k8s.put(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "schema-secret", Namespace: "test"},
+		Data:       map[string][]byte{"schema.sql": []byte("CREATE TABLE payroll (salary int);")},
+	})
+	sc := conditionReconciling()
+	sc.Spec.Schema = dbv1alpha1.Schema{
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "schema-secret"},
+			Key:                  "schema.sql",
+		},
+	}
+	sc.Spec.DryRun = true
+	tt.k8s.put(sc)
+	tt.k8s.put(devDBReady())
+	schema := func() *dbv1alpha1.AtlasSchema {
+		return tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema)
+	}
+
+	// The planned statements are counted, and not copied to the status.
+	_, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	p := schema().Status.Planned
+	require.Equal(t, 1, p.Statements)
+	require.Empty(t, p.SQL)
+	require.Empty(t, p.ConfigMap)
+	require.Empty(t, schema().Status.Plan.Statements)
+	require.True(t, schema().Status.Plan.Truncated)
+
+	sc = schema()
+	sc.Spec.DryRun = false
+	tt.k8s.put(devDBReady())
+	_, err = tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	require.Equal(t, metav1.ConditionTrue, tt.cond().Status)
+	require.NotContains(t, tt.cond().Message, "payroll")
+	require.NotEmpty(t, schema().Status.History)
+	for _, h := range schema().Status.History {
+		require.Empty(t, h.Statements)
+	}
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// A URL is either bound to a single schema, e.g. "mysql://host/app" or
+// "postgres://host/db?search_path=app", and manages the objects of this schema
+// only, or database-scoped, and manages all schemas of the database, or the
+// ones listed in spec.schemas. Atlas inspects and plans the two scopes
+// differently, e.g. schema-bound plans do not qualify objects with their
+// schema, so the dev database must have the same scope as the target URL.
+
+// schemaBoundAnnotation records the scope of the URL the dev database was
+// created for.
+const schemaBoundAnnotation = "atlasgo.io/schema-bound"
+
+// scopeErr is returned when the named schemas of a resource or the scope of
+// its URL are invalid.
+type scopeErr struct {
+	msg string
+}
+
+func (e *scopeErr) Error() string {
+	return e.msg
+}
+
+// urlSchema returns the schema the URL is bound to, or an empty string if the
+// URL is database-scoped.
+func urlSchema(u *url.URL) string {
+	switch driver(u.Scheme) {
+	case "mysql":
+		return strings.Trim(u.Path, "/")
+	case "postgres":
+		return u.Query().Get("search_path")
+	}
+	return ""
+}
+
+// checkScope validates the named schemas of a resource against the scope of
+// its URL, and returns them normalized: trimmed, without duplicates, and empty
+// if the URL is bound to the only named schema, as both forms manage the same
+// objects. Otherwise, named schemas take precedence over the schema of the URL,
+// and the resource is database-scoped.
+func checkScope(u *url.URL, schemas []string) ([]string, error) {
+	bound := urlSchema(u)
+	if driver(u.Scheme) == "postgres" && strings.Contains(bound, ",") {
+		return nil, &scopeErr{msg: fmt.Sprintf("the search_path %q of the URL names several schemas. "+
+			"Bind the URL to a single schema, or remove search_path and list the schemas in spec.schemas", bound)}
+	}
+	var names []string
+	for _, s := range schemas {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return nil, &scopeErr{msg: "spec.schemas must not contain empty names"}
+		}
+		if !slices.Contains(names, s) {
+			names = append(names, s)
+		}
+	}
+	if len(names) == 1 && names[0] == bound {
+		return nil, nil
+	}
+	return names, nil
+}
+
+// devScope binds the dev database URL to a schema if the target URL is
+// schema-bound.
+func devScope(devURL string, m *managed) (string, error) {
+	if m.driver != "postgres" || !m.schemaBound() {
+		return devURL, nil
+	}
+	u, err := url.Parse(devURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("search_path", "public")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// devDBStale reports why the dev database must be recreated: it was created
+// for a URL of another scope, or runs another image. Dev databases created
+// before their scope was recorded are recreated, as their scope is unknown.
+func devDBStale(d *v1.Deployment, m *managed) string {
+	switch v, ok := d.Annotations[schemaBoundAnnotation]; {
+	case !ok:
+		return "The scope of the dev database is unknown"
+	case v != strconv.FormatBool(m.schemaBound()):
+		return "The scope of the URL changed"
+	}
+	if c := d.Spec.Template.Spec.Containers; len(c) > 0 && c[0].Image != devDBImage(m.devScheme(), m.devDB) {
+		return "The image of the dev database changed"
+	}
+	return ""
+}
+
+// recreateDevDB deletes the stale dev database, to be created again by the
+// next reconcile.
+func (r *AtlasSchemaReconciler) recreateDevDB(ctx context.Context, sc *dbv1alpha1.AtlasSchema, d *v1.Deployment, why string) error {
+	if err := r.Delete(ctx, d); err != nil {
+		return transient(err)
+	}
+	r.recorder.Eventf(sc, corev1.EventTypeNormal, dbv1alpha1.ReasonRecreatingDevDB,
+		"%s. Recreating dev database deployment: %s", why, d.Name)
+	return nil
+}
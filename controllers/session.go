@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// sessionName matches the names of session settings, e.g. "sql_mode" or
+// "lock_timeout".
+var sessionName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// dsnParams are the lowercased parameters of the URL configuring the
+// connection itself, by driver, that are not session settings. Setting them
+// as settings would e.g. disable TLS or allow reading local files.
+var dsnParams = map[string][]string{
+	"mysql": {
+		"allowallfiles", "allowcleartextpasswords", "allowfallbacktoplaintext", "allownativepasswords",
+		"allowoldpasswords", "charset", "checkconnliveness", "clientfoundrows", "collation", "columnswithalias",
+		"connectionattributes", "interpolateparams", "loc", "maxallowedpacket", "multistatements", "parsetime",
+		"readtimeout", "rejectreadonly", "serverpubkey", "timeout", "tls", "writetimeout",
+	},
+	"postgres": {
+		"binary_parameters", "connect_timeout", "dbname", "disable_prepared_binary_result",
+		"fallback_application_name", "host", "krbspn", "krbsrvname", "options", "passfile", "password", "port",
+		"service", "sslcert", "sslinline", "sslkey", "sslmode", "sslpassword", "sslrootcert", "sslsni",
+		"target_session_attrs", "user",
+	},
+}
+
+// withSession returns the URL with the given session settings rendered as
+// query parameters of its dialect. MySQL settings are set as system variables
+// of the connection, quoting values that are not numbers. PostgreSQL settings
+// are sent as run-time parameters of the connection. Parameters configuring
+// the connection itself, e.g. "tls" or "sslmode", are not session settings and
+// are rejected.
+func withSession(u *url.URL, session map[string]string) (*url.URL, error) {
+	if len(session) == 0 {
+		return u, nil
+	}
+	quote := func(v string) string { return v }
+	switch d := driver(u.Scheme); d {
+	case "mysql":
+		quote = func(v string) string {
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				return v
+			}
+			return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		}
+	case "postgres":
+	default:
+		return nil, fmt.Errorf("session settings are not supported for %q URLs", u.Scheme)
+	}
+	names := make([]string, 0, len(session))
+	for n := range session {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	q := u.Query()
+	for _, n := range names {
+		if !sessionName.MatchString(n) {
+			return nil, fmt.Errorf("invalid session setting name %q", n)
+		}
+		if slices.Contains(dsnParams[driver(u.Scheme)], strings.ToLower(n)) {
+			return nil, fmt.Errorf("%q is a connection parameter, not a session setting, set it in the URL instead", n)
+		}
+		if q.Has(n) {
+			return nil, fmt.Errorf("session setting %q is already set by the URL", n)
+		}
+		q.Set(n, quote(session[n]))
+	}
+	s := *u
+	s.RawQuery = q.Encode()
+	return &s, nil
+}
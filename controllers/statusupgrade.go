@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// statusVersion is the version of the structure of the statuses written by
+// the operator. Bump it when the upgrade of older statuses changes.
+const statusVersion = 1
+
+// legacyReason maps a reason of a condition written by older operator
+// versions to the reason reporting the same state today. Older versions
+// reported some failures under a generic reason, told apart by the message
+// of the condition only.
+type legacyReason struct {
+	typ, reason string
+	// msg is matched against the message of the condition. Empty matches all.
+	msg       string
+	newReason string
+}
+
+// legacyReasons are the reasons of conditions written by older operator versions.
+var legacyReasons = []legacyReason{
+	// Checksum errors of the directory were reported by the CLI when applying it.
+	{typ: dbv1alpha1.MigrateReadyCond, reason: dbv1alpha1.ReasonMigrating, msg: "checksum mismatch", newReason: dbv1alpha1.ReasonChecksumMismatch},
+}
+
+// upgradeSchemaStatus upgrades the status of an AtlasSchema written by an
+// older operator version, and reports if it was upgraded. Statuses of new
+// resources are stamped with the current version.
+func upgradeSchemaStatus(s *dbv1alpha1.AtlasSchemaStatus) bool {
+	return upgradeStatus(&s.StatusVersion, s.Conditions, &s.Transitions)
+}
+
+// upgradeMigrationStatus upgrades the status of an AtlasMigration written by
+// an older operator version, and reports if it was upgraded.
+func upgradeMigrationStatus(s *dbv1alpha1.AtlasMigrationStatus) bool {
+	return upgradeStatus(&s.StatusVersion, s.Conditions, &s.Transitions)
+}
+
+// upgradeStatus maps the legacy reasons of the conditions of a status written
+// by an older operator version to their current reasons, and fills the
+// transitions from the conditions if they were not recorded, so the status has
+// the same structure as the statuses of new resources. The history of applies
+// is not filled, as older versions did not record what they applied.
+func upgradeStatus(version *int, conds []metav1.Condition, trans *[]dbv1alpha1.ConditionTransition) bool {
+	if *version >= statusVersion {
+		return false
+	}
+	*version = statusVersion
+	// Statuses without conditions were never reconciled.
+	if len(conds) == 0 {
+		return false
+	}
+	for i := range conds {
+		conds[i].Reason = currentReason(conds[i])
+	}
+	if len(*trans) == 0 {
+		for _, t := range transitions(nil, conds) {
+			if c := meta.FindStatusCondition(conds, t.Type); !c.LastTransitionTime.IsZero() {
+				t.Time = c.LastTransitionTime
+			}
+			*trans = dbv1alpha1.AppendTransition(*trans, t)
+		}
+	}
+	return true
+}
+
+// currentReason returns the current reason of a condition written by an
+// older operator version.
+func currentReason(c metav1.Condition) string {
+	for _, l := range legacyReasons {
+		if c.Type == l.typ && c.Reason == l.reason && strings.Contains(c.Message, l.msg) {
+			return l.newReason
+		}
+	}
+	return c.Reason
+}
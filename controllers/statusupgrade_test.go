@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func TestUpgradeStatus(t *testing.T) {
+	applied := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	legacy := dbv1alpha1.AtlasMigrationStatus{
+		Conditions: []metav1.Condition{{
+			Type:               "Ready",
+			Status:             metav1.ConditionTrue,
+			Reason:             "Applied",
+			LastTransitionTime: metav1.NewTime(applied),
+		}},
+		LastAppliedVersion: "20230501",
+		LastApplied:        applied.Unix(),
+	}
+	// The transitions are filled from the conditions, and no history is made up.
+	s := legacy
+	require.True(t, upgradeMigrationStatus(&s))
+	require.Equal(t, statusVersion, s.StatusVersion)
+	require.Empty(t, s.History)
+	require.Equal(t, []dbv1alpha1.ConditionTransition{{Time: metav1.NewTime(applied), Type: "Ready", Status: metav1.ConditionTrue, Reason: "Applied"}}, s.Transitions)
+	// Upgraded statuses are kept as is.
+	s.Transitions = nil
+	require.False(t, upgradeMigrationStatus(&s))
+	require.Empty(t, s.Transitions)
+
+	// Legacy reasons are mapped to their current reasons.
+	s = legacy
+	s.Conditions = []metav1.Condition{{
+		Type:    "Ready",
+		Status:  metav1.ConditionFalse,
+		Reason:  "Migrating",
+		Message: "Error: checksum mismatch",
+	}}
+	require.True(t, upgradeMigrationStatus(&s))
+	require.Equal(t, dbv1alpha1.ReasonChecksumMismatch, s.Conditions[0].Reason)
+	require.Equal(t, dbv1alpha1.ReasonChecksumMismatch, s.Transitions[0].Reason)
+	s = legacy
+	s.Conditions = []metav1.Condition{{Type: "Ready", Status: metav1.ConditionFalse, Reason: "Migrating", Message: "Error: connection refused"}}
+	require.True(t, upgradeMigrationStatus(&s))
+	require.Equal(t, dbv1alpha1.ReasonMigrating, s.Conditions[0].Reason)
+
+	// New resources are stamped only.
+	s = dbv1alpha1.AtlasMigrationStatus{}
+	require.False(t, upgradeMigrationStatus(&s))
+	require.Equal(t, statusVersion, s.StatusVersion)
+	require.Empty(t, s.Transitions)
+}
+
+func TestReconcile_UpgradeStatus(t *testing.T) {
+	tt := newTest(t)
+	sc := conditionReconciling()
+	sc.Status.LastApplied = time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC).Unix()
+	tt.mockCLI().applied = []string{"ALTER TABLE `foo` ADD COLUMN `bar` int"}
+	tt.k8s.put(sc)
+	tt.k8s.put(devDBReady())
+	_, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	st := tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema).Status
+	require.Equal(t, statusVersion, st.StatusVersion)
+	// Only the apply of this reconcile is in the history.
+	require.Len(t, st.History, 1)
+	require.NotEqual(t, sc.Status.LastApplied, st.History[0].Time.Unix())
+	// The legacy condition precedes its transition to ready.
+	require.Len(t, st.Transitions, 2)
+	require.Equal(t, metav1.ConditionFalse, st.Transitions[0].Status)
+	require.Equal(t, metav1.ConditionTrue, st.Transitions[1].Status)
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+// tlsKeys are the keys of the client certificate, its key and the CA
+// certificate in the secrets referenced by tlsFrom.
+var tlsKeys = [3]string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey, "ca.crt"}
+
+// tlsParams are the URL parameters of the client certificate files: the
+// client certificate, its key and the CA certificate. The MySQL driver of the
+// Atlas CLI accepts only the names of registered TLS configs, so client
+// certificates are supported on PostgreSQL only.
+var tlsParams = [3]string{"sslcert", "sslkey", "sslrootcert"}
+
+// withClientTLS materializes the client certificate of the referenced secret
+// to files, and returns the URL with the parameters pointing to them. The
+// files of a resource are kept in the same directory, so the URL does not
+// change between reconciles, and are rewritten by every reconcile so rotated
+// certificates are used once the secret is updated. The returned hash changes
+// with the content of the secret.
+func withClientTLS(ctx context.Context, r client.Reader, owner client.Object, kind string, u *url.URL, from dbv1alpha1.TLSFrom) (*url.URL, string, error) {
+	ref := from.SecretRef
+	if ref == nil {
+		return u, "", nil
+	}
+	if driver(u.Scheme) != "postgres" {
+		return nil, "", fmt.Errorf("tlsFrom is supported for postgres URLs only, got %q", u.Scheme)
+	}
+	params := tlsParams
+	q := u.Query()
+	for _, p := range params {
+		if q.Has(p) {
+			return nil, "", fmt.Errorf("%s is already set by the URL", p)
+		}
+	}
+	sec := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: owner.GetNamespace()}, sec); err != nil {
+		return nil, "", transient(err)
+	}
+	for _, k := range tlsKeys[:2] {
+		if len(sec.Data[k]) == 0 {
+			return nil, "", fmt.Errorf("secret %s/%s does not contain key %s", owner.GetNamespace(), ref.Name, k)
+		}
+	}
+	dir := filepath.Join(os.TempDir(), "atlas-tls", kind, owner.GetNamespace(), owner.GetName())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, "", err
+	}
+	h := sha256.New()
+	for i, k := range tlsKeys {
+		v, ok := sec.Data[k]
+		if !ok {
+			continue
+		}
+		name := filepath.Join(dir, k)
+		if err := os.WriteFile(name, v, 0600); err != nil {
+			return nil, "", err
+		}
+		q.Set(params[i], name)
+		h.Write([]byte(k))
+		h.Write(v)
+	}
+	if _, ok := q["sslmode"]; !ok {
+		// Without a mode, the server certificate is verified only if the CA is set.
+		q.Set("sslmode", "require")
+		if q.Has(params[2]) {
+			q.Set("sslmode", "verify-full")
+		}
+	}
+	t := *u
+	t.RawQuery = q.Encode()
+	return &t, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// withCA writes the CA bundle referenced by credentials.tls to a file, and
+// sets the parameters verifying the certificate of the database with it. The
+// file is named after the hash of the bundle, so the URL changes only when
+// the bundle does. Like client certificates, CA files are supported on
+// PostgreSQL only.
+func withCA(ctx context.Context, r client.Reader, ns string, creds *dbv1alpha1.Credentials) error {
+	from := creds.TLS.CAFrom
+	var (
+		ca  string
+		err error
+	)
+	switch {
+	case from.SecretKeyRef != nil:
+		ca, err = getSecretValue(ctx, r, ns, *from.SecretKeyRef)
+	case from.ConfigMapKeyRef != nil:
+		ca, err = getConfigMapValue(ctx, r, ns, *from.ConfigMapKeyRef)
+	default:
+		return errors.New("credentials.tls.caFrom must reference a secret or a configmap key")
+	}
+	if err != nil {
+		return err
+	}
+	if driver(creds.Scheme) != "postgres" {
+		return fmt.Errorf("credentials.tls is supported for postgres only, got %q", creds.Scheme)
+	}
+	param := tlsParams[2]
+	if _, ok := creds.Parameters[param]; ok {
+		return fmt.Errorf("%s is already set by the parameters", param)
+	}
+	if strings.TrimSpace(ca) == "" {
+		return errors.New("credentials.tls.caFrom references an empty CA bundle")
+	}
+	sum := sha256.Sum256([]byte(ca))
+	name := filepath.Join(os.TempDir(), "atlas-ca", hex.EncodeToString(sum[:])+".pem")
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(name, []byte(ca), 0600); err != nil {
+		return err
+	}
+	params := make(map[string]string, len(creds.Parameters)+2)
+	for k, v := range creds.Parameters {
+		params[k] = v
+	}
+	params[param] = name
+	if _, ok := params["sslmode"]; !ok {
+		params["sslmode"] = "verify-full"
+	}
+	creds.Parameters = params
+	return nil
+}
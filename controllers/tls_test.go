@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func TestWithClientTLS(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	tt := newMigrationTest(t)
+	am := &dbv1alpha1.AtlasMigration{ObjectMeta: migrationObjmeta()}
+	sec := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-client", Namespace: "default"},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte("cert"),
+			corev1.TLSPrivateKeyKey: []byte("key"),
+			"ca.crt":                []byte("ca"),
+		},
+	}
+	tt.k8s.put(sec)
+	from := dbv1alpha1.TLSFrom{SecretRef: &corev1.LocalObjectReference{Name: "db-client"}}
+	ctx := context.Background()
+
+	// The files are materialized and referenced by the URL parameters.
+	u, _ := url.Parse("postgres://root@db:5432/app")
+	pg, h1, err := withClientTLS(ctx, tt.r, am, "AtlasMigration", u, from)
+	require.NoError(t, err)
+	dir := filepath.Join(os.TempDir(), "atlas-tls", "AtlasMigration", "default", "atlas-migration")
+	q := pg.Query()
+	require.Equal(t, filepath.Join(dir, "tls.crt"), q.Get("sslcert"))
+	require.Equal(t, filepath.Join(dir, "tls.key"), q.Get("sslkey"))
+	require.Equal(t, filepath.Join(dir, "ca.crt"), q.Get("sslrootcert"))
+	require.Equal(t, "verify-full", q.Get("sslmode"))
+	key, err := os.ReadFile(q.Get("sslkey"))
+	require.NoError(t, err)
+	require.Equal(t, "key", string(key))
+
+	// Rotated certificates keep the URL, and change the hash.
+	sec.Data[corev1.TLSCertKey] = []byte("rotated")
+	pg2, h2, err := withClientTLS(ctx, tt.r, am, "AtlasMigration", u, from)
+	require.NoError(t, err)
+	require.Equal(t, pg.String(), pg2.String())
+	require.NotEqual(t, h1, h2)
+
+	u, _ = url.Parse("mysql://root@db:3306/app")
+	_, _, err = withClientTLS(ctx, tt.r, am, "AtlasMigration", u, from)
+	require.EqualError(t, err, `tlsFrom is supported for postgres URLs only, got "mysql"`)
+
+	u, _ = url.Parse("postgres://root@db:5432/app?sslcert=/etc/cert")
+	_, _, err = withClientTLS(ctx, tt.r, am, "AtlasMigration", u, from)
+	require.EqualError(t, err, "sslcert is already set by the URL")
+	u, _ = url.Parse("sqlite://file.db")
+	_, _, err = withClientTLS(ctx, tt.r, am, "AtlasMigration", u, from)
+	require.EqualError(t, err, `tlsFrom is supported for postgres URLs only, got "sqlite"`)
+	delete(sec.Data, corev1.TLSPrivateKeyKey)
+	u, _ = url.Parse("postgres://root@db:5432/app")
+	_, _, err = withClientTLS(ctx, tt.r, am, "AtlasMigration", u, from)
+	require.EqualError(t, err, "secret default/db-client does not contain key tls.key")
+}
+
+func TestHydrateCredentials_CA(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	tt := newMigrationTest(t)
+	tt.k8s.put(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "private-ca", Namespace: "default"},
+		Data:       map[string]string{"ca.pem": "bundle"},
+	})
+	creds := dbv1alpha1.Credentials{
+		Scheme: "postgres",
+		Host:   "db",
+		TLS: &dbv1alpha1.CredentialsTLS{
+			CAFrom: dbv1alpha1.CAFrom{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "private-ca"},
+					Key:                  "ca.pem",
+				},
+			},
+		},
+	}
+	ctx := context.Background()
+
+	// The bundle is written to a file verifying the server certificate.
+	pg := creds
+	require.NoError(t, hydrateCredentials(ctx, &pg, tt.r, "default"))
+	require.Equal(t, "verify-full", pg.Parameters["sslmode"])
+	ca, err := os.ReadFile(pg.Parameters["sslrootcert"])
+	require.NoError(t, err)
+	require.Equal(t, "bundle", string(ca))
+	require.Nil(t, creds.Parameters)
+
+	my := creds
+	my.Scheme = "mysql"
+	require.EqualError(t, hydrateCredentials(ctx, &my, tt.r, "default"), `credentials.tls is supported for postgres only, got "mysql"`)
+
+	bad := creds
+	bad.Parameters = map[string]string{"sslrootcert": "/etc/ca.pem"}
+	require.EqualError(t, hydrateCredentials(ctx, &bad, tt.r, "default"), "sslrootcert is already set by the parameters")
+	bad = creds
+	bad.TLS = &dbv1alpha1.CredentialsTLS{}
+	require.EqualError(t, hydrateCredentials(ctx, &bad, tt.r, "default"), "credentials.tls.caFrom must reference a secret or a configmap key")
+}
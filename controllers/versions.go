@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// cliVersion resolves the version of the Atlas CLI once, as the CLI does not
+// change while the operator runs. Failures are retried on the next call.
+type cliVersion struct {
+	mu sync.Mutex
+	v  string
+}
+
+// get returns the version of the CLI, or an empty string if it cannot be
+// resolved. A nil cliVersion resolves the version on every call.
+func (c *cliVersion) get(ctx context.Context, cli interface {
+	Version(context.Context) (string, error)
+}) string {
+	if cli == nil {
+		return ""
+	}
+	if c != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.v != "" {
+			return c.v
+		}
+	}
+	v, err := cli.Version(ctx)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to resolve the version of the atlas CLI")
+		return ""
+	}
+	if c != nil {
+		c.v = v
+	}
+	return v
+}
+
+// stampVersions records the versions of the operator and of the Atlas CLI
+// that reconciled a resource. Versions that cannot be resolved are kept.
+func stampVersions(operator, atlas *string, operatorVersion, atlasVersion string) {
+	if operatorVersion != "" {
+		*operator = operatorVersion
+	}
+	if atlasVersion != "" {
+		*atlas = atlasVersion
+	}
+}
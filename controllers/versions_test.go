@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+type versionCLI struct {
+	v     string
+	err   error
+	calls int
+}
+
+func (c *versionCLI) Version(context.Context) (string, error) {
+	c.calls++
+	return c.v, c.err
+}
+
+func TestCLIVersion(t *testing.T) {
+	var (
+		c   cliVersion
+		cli = &versionCLI{err: errors.New("exec: atlas: not found")}
+	)
+	// Failures are retried.
+	require.Empty(t, c.get(context.Background(), cli))
+	cli.v, cli.err = "v0.14.1", nil
+	require.Equal(t, "v0.14.1", c.get(context.Background(), cli))
+	// The version is resolved once.
+	require.Equal(t, "v0.14.1", c.get(context.Background(), cli))
+	require.Equal(t, 2, cli.calls)
+	require.Empty(t, c.get(context.Background(), nil))
+}
+
+func TestReconcile_Versions(t *testing.T) {
+	tt := newTest(t)
+	tt.r.version = "v0.5.0"
+	sc := conditionReconciling()
+	sc.Status.AtlasVersion = "v0.13.0"
+	tt.k8s.put(sc)
+	tt.k8s.put(devDBReady())
+	_, err := tt.r.Reconcile(context.Background(), req())
+	require.NoError(t, err)
+	st := tt.k8s.state[req().NamespacedName].(*dbv1alpha1.AtlasSchema).Status
+	require.Equal(t, "v0.5.0", st.OperatorVersion)
+	require.Equal(t, "v0.14.1", st.AtlasVersion)
+
+	// Versions are stamped on every reconcile.
+	tm := newMigrationTest(t)
+	tm.r.version = "v0.5.0"
+	tm.r.CLI = &mockMigrateCLI{version: "v0.14.2"}
+	tm.k8s.put(&dbv1alpha1.AtlasMigration{ObjectMeta: migrationObjmeta()})
+	_, err = tm.r.Reconcile(context.Background(), migrationReq())
+	require.NoError(t, err)
+	require.Equal(t, "v0.5.0", tm.status().OperatorVersion)
+	require.Equal(t, "v0.14.2", tm.status().AtlasVersion)
+}
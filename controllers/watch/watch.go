@@ -1,10 +1,16 @@
 package watch
 
 import (
+	"bytes"
+	"sync"
+	"time"
+
 	"golang.org/x/exp/slices"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -13,58 +19,190 @@ import (
 // a watched object changes. It's designed to only be used for a single type of object.
 // If multiple types should be watched, one ResourceWatcher for each type should be used.
 type ResourceWatcher struct {
+	mu      *sync.Mutex
 	watched map[types.NamespacedName][]types.NamespacedName
+	// keys holds the data keys of the watched Secrets and ConfigMaps that
+	// each dependent object references. Nil if it references all keys.
+	keys map[dependency][]string
+	// window is the minimum time between two reconciliations triggered for
+	// the same dependent object. Events received within the window are
+	// coalesced into a single reconciliation at its end.
+	window time.Duration
+	// next holds the earliest time each dependent object may be enqueued again.
+	next map[types.NamespacedName]time.Time
+	now  func() time.Time
+}
+
+// dependency is a dependent object referencing a watched object.
+type dependency struct {
+	watched, dependent types.NamespacedName
+}
+
+// Option configures a ResourceWatcher.
+type Option func(*ResourceWatcher)
+
+// WithDedupWindow sets the minimum time between two reconciliations
+// triggered for the same dependent object.
+func WithDedupWindow(d time.Duration) Option {
+	return func(w *ResourceWatcher) {
+		w.window = d
+	}
 }
 
 // New will create a new ResourceWatcher with no watched objects.
-func New() ResourceWatcher {
-	return ResourceWatcher{
+func New(opts ...Option) ResourceWatcher {
+	w := ResourceWatcher{
+		mu:      &sync.Mutex{},
 		watched: make(map[types.NamespacedName][]types.NamespacedName),
+		keys:    make(map[dependency][]string),
+		next:    make(map[types.NamespacedName]time.Time),
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(&w)
 	}
+	return w
 }
 
-// Watch will add a new object to watch.
-func (w ResourceWatcher) Watch(watchedName, dependentName types.NamespacedName) {
+// Watch will add a new object to watch. If keys are given, updates of the watched
+// Secret or ConfigMap trigger a reconciliation only if one of these keys changed.
+func (w ResourceWatcher) Watch(watchedName, dependentName types.NamespacedName, keys ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	dep := dependency{watched: watchedName, dependent: dependentName}
 	// Check if resource is already being watched.
 	existing := w.watched[watchedName]
-	if slices.Contains(existing, dependentName) {
+	if !slices.Contains(existing, dependentName) {
+		w.watched[watchedName] = append(existing, dependentName)
+		if len(keys) > 0 {
+			w.keys[dep] = slices.Clone(keys)
+		}
 		return
 	}
-	w.watched[watchedName] = append(existing, dependentName)
+	switch prev := w.keys[dep]; {
+	case prev == nil:
+	case len(keys) == 0:
+		delete(w.keys, dep)
+	default:
+		for _, k := range keys {
+			if !slices.Contains(prev, k) {
+				prev = append(prev, k)
+			}
+		}
+		w.keys[dep] = prev
+	}
 }
 
 func (w ResourceWatcher) Read(watchedName types.NamespacedName) []types.NamespacedName {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	return w.watched[watchedName]
 }
 
 func (w ResourceWatcher) Create(event event.CreateEvent, queue workqueue.RateLimitingInterface) {
-	w.handleEvent(event.Object, queue)
+	w.handleEvent(event.Object, queue, nil)
 }
 
 func (w ResourceWatcher) Update(event event.UpdateEvent, queue workqueue.RateLimitingInterface) {
-	w.handleEvent(event.ObjectOld, queue)
+	w.handleEvent(event.ObjectOld, queue, func(keys []string) bool {
+		return changed(event.ObjectOld, event.ObjectNew, keys)
+	})
 }
 
 func (w ResourceWatcher) Delete(event event.DeleteEvent, queue workqueue.RateLimitingInterface) {
-	w.handleEvent(event.Object, queue)
+	w.handleEvent(event.Object, queue, nil)
 }
 
 func (w ResourceWatcher) Generic(event event.GenericEvent, queue workqueue.RateLimitingInterface) {
-	w.handleEvent(event.Object, queue)
+	w.handleEvent(event.Object, queue, nil)
 }
 
 // handleEvent is called when an event is received for an object.
 // It will check if the object is being watched and trigger a reconciliation for
-// the dependent object.
-func (w ResourceWatcher) handleEvent(meta metav1.Object, queue workqueue.RateLimitingInterface) {
+// the dependent object. If set, the changed function filters the dependent
+// objects by the keys they reference.
+func (w ResourceWatcher) handleEvent(meta metav1.Object, queue workqueue.RateLimitingInterface, changed func([]string) bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	changedObjectName := types.NamespacedName{
 		Name:      meta.GetName(),
 		Namespace: meta.GetNamespace(),
 	}
 	// Enqueue reconciliation for each dependent object.
 	for _, reconciledObjectName := range w.watched[changedObjectName] {
-		queue.Add(reconcile.Request{
-			NamespacedName: reconciledObjectName,
-		})
+		keys := w.keys[dependency{watched: changedObjectName, dependent: reconciledObjectName}]
+		if changed != nil && !changed(keys) {
+			continue
+		}
+		w.enqueue(queue, reconciledObjectName)
+	}
+}
+
+// enqueue adds a reconciliation of the object to the queue. Within the dedup
+// window of its previous reconciliation, it is delayed to the end of the window.
+func (w ResourceWatcher) enqueue(queue workqueue.RateLimitingInterface, name types.NamespacedName) {
+	req := reconcile.Request{NamespacedName: name}
+	if w.window <= 0 {
+		queue.Add(req)
+		return
+	}
+	now := w.now()
+	switch next := w.next[name]; {
+	case now.Before(next.Add(-w.window)):
+		// A delayed reconciliation is already scheduled. The queue
+		// deduplicates requests waiting for the same object.
+		queue.AddAfter(req, next.Add(-w.window).Sub(now))
+	case now.Before(next):
+		queue.AddAfter(req, next.Sub(now))
+		w.next[name] = next.Add(w.window)
+	default:
+		queue.Add(req)
+		w.next[name] = now.Add(w.window)
+	}
+}
+
+// changed reports if the data of the given keys changed between the old and
+// the new versions of a Secret or ConfigMap. All keys are compared if keys is
+// nil. Updates of other objects are always reported as changed.
+func changed(old, new client.Object, keys []string) bool {
+	o, ok1 := data(old)
+	n, ok2 := data(new)
+	if !ok1 || !ok2 {
+		return true
+	}
+	if keys == nil {
+		if len(o) != len(n) {
+			return true
+		}
+		for k := range o {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		ov, ok1 := o[k]
+		nv, ok2 := n[k]
+		if ok1 != ok2 || !bytes.Equal(ov, nv) {
+			return true
+		}
+	}
+	return false
+}
+
+// data returns the data of a Secret or ConfigMap.
+func data(obj client.Object) (map[string][]byte, bool) {
+	switch o := obj.(type) {
+	case *corev1.Secret:
+		return o.Data, true
+	case *corev1.ConfigMap:
+		d := make(map[string][]byte, len(o.Data)+len(o.BinaryData))
+		for k, v := range o.Data {
+			d[k] = []byte(v)
+		}
+		for k, v := range o.BinaryData {
+			d[k] = v
+		}
+		return d, true
+	default:
+		return nil, false
 	}
 }
@@ -2,6 +2,7 @@ package watch
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
@@ -10,6 +11,7 @@ import (
 	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
 )
@@ -148,3 +150,91 @@ func TestWatcherAdd(t *testing.T) {
 		mdb2.NamespacedName(),
 	}, watcher.watched[watchedName])
 }
+
+// delayQueue records the delays of the requests added to the queue.
+type delayQueue struct {
+	controllertest.Queue
+	delays []time.Duration
+}
+
+func (q *delayQueue) Add(item interface{}) {
+	q.AddAfter(item, 0)
+}
+
+func (q *delayQueue) AddAfter(item interface{}, d time.Duration) {
+	q.delays = append(q.delays, d)
+	q.Queue.Add(item)
+}
+
+func TestWatcherKeys(t *testing.T) {
+	secret := func(data map[string]string) *corev1.Secret {
+		s := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "namespace"},
+			Data:       make(map[string][]byte),
+		}
+		for k, v := range data {
+			s.Data[k] = []byte(v)
+		}
+		return s
+	}
+	watchedName := types.NamespacedName{Name: "secret", Namespace: "namespace"}
+	mdb1 := types.NamespacedName{Name: "mdb1", Namespace: "namespace"}
+	mdb2 := types.NamespacedName{Name: "mdb2", Namespace: "namespace"}
+	watcher := New()
+	watcher.Watch(watchedName, mdb1, "url")
+	watcher.Watch(watchedName, mdb2)
+	update := func(old, new *corev1.Secret) []types.NamespacedName {
+		q := &delayQueue{Queue: controllertest.Queue{Interface: workqueue.New()}}
+		watcher.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: new}, q)
+		var names []types.NamespacedName
+		for q.Len() > 0 {
+			item, _ := q.Get()
+			names = append(names, item.(reconcile.Request).NamespacedName)
+		}
+		return names
+	}
+
+	// Metadata only updates are ignored.
+	old := secret(map[string]string{"url": "a", "token": "b"})
+	updated := old.DeepCopy()
+	updated.Labels = map[string]string{"k": "v"}
+	assert.Empty(t, update(old, updated))
+
+	// Only objects watching all keys are reconciled on unrelated key changes.
+	assert.Equal(t, []types.NamespacedName{mdb2}, update(old, secret(map[string]string{"url": "a", "token": "c"})))
+	assert.Equal(t, []types.NamespacedName{mdb2}, update(old, secret(map[string]string{"url": "a"})))
+
+	// Changes of the referenced key reconcile both objects.
+	assert.Equal(t, []types.NamespacedName{mdb1, mdb2}, update(old, secret(map[string]string{"url": "b", "token": "b"})))
+	assert.Equal(t, []types.NamespacedName{mdb1, mdb2}, update(old, secret(map[string]string{"token": "b"})))
+
+	// Watching all keys of an object overrides the keys it referenced.
+	watcher.Watch(watchedName, mdb1)
+	assert.Equal(t, []types.NamespacedName{mdb1, mdb2}, update(old, secret(map[string]string{"url": "a", "token": "c"})))
+}
+
+func TestWatcherDedup(t *testing.T) {
+	watchedName := types.NamespacedName{Name: "secret", Namespace: "namespace"}
+	obj := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "namespace"}}
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	watcher := New(WithDedupWindow(5 * time.Second))
+	watcher.now = func() time.Time { return now }
+	watcher.Watch(watchedName, types.NamespacedName{Name: "mdb1", Namespace: "namespace"})
+	q := &delayQueue{Queue: controllertest.Queue{Interface: workqueue.New()}}
+	create := func(after time.Duration) {
+		now = now.Add(after)
+		watcher.Create(event.CreateEvent{Object: obj}, q)
+	}
+
+	// The first event is enqueued immediately.
+	create(0)
+	// Events within the window are delayed to its end.
+	create(time.Second)
+	// Events received before the delayed reconcile are coalesced into it.
+	create(time.Second)
+	// Events after the delayed reconcile open a new window.
+	create(4 * time.Second)
+	// Events after the window are enqueued immediately.
+	create(time.Minute)
+	assert.Equal(t, []time.Duration{0, 4 * time.Second, 3 * time.Second, 4 * time.Second, 0}, q.delays)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// vapgen generates the ValidatingAdmissionPolicy objects enforcing the
+// admission rules of the API types, and their bindings.
+//
+//	go run ./hack/vapgen > config/vap/policies.yaml
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	admissionv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
+)
+
+func main() {
+	var buf bytes.Buffer
+	buf.WriteString("# Code generated by hack/vapgen. DO NOT EDIT.\n")
+	rules := dbv1alpha1.AdmissionRules()
+	kinds := make([]string, 0, len(rules))
+	for k := range rules {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		policy, binding := policies(k, rules[k])
+		for _, o := range []any{policy, binding} {
+			b, err := yaml.Marshal(o)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			buf.WriteString("---\n")
+			buf.Write(b)
+		}
+	}
+	os.Stdout.Write(buf.Bytes())
+}
+
+// policies returns the policy enforcing the rules of the kind, and its binding.
+func policies(kind string, rules []dbv1alpha1.AdmissionRule) (*admissionv1alpha1.ValidatingAdmissionPolicy, *admissionv1alpha1.ValidatingAdmissionPolicyBinding) {
+	gvr, _ := meta.UnsafeGuessKindToResource(dbv1alpha1.GroupVersion.WithKind(kind))
+	name := gvr.GroupResource().String()
+	fail := admissionv1alpha1.Fail
+	policy := &admissionv1alpha1.ValidatingAdmissionPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionv1alpha1.SchemeGroupVersion.String(),
+			Kind:       "ValidatingAdmissionPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: admissionv1alpha1.ValidatingAdmissionPolicySpec{
+			FailurePolicy: &fail,
+			MatchConstraints: &admissionv1alpha1.MatchResources{
+				ResourceRules: []admissionv1alpha1.NamedRuleWithOperations{{
+					RuleWithOperations: admissionv1alpha1.RuleWithOperations{
+						Operations: []admissionv1alpha1.OperationType{admissionv1alpha1.Create, admissionv1alpha1.Update},
+						Rule: admissionv1alpha1.Rule{
+							APIGroups:   []string{gvr.Group},
+							APIVersions: []string{gvr.Version},
+							Resources:   []string{gvr.Resource},
+						},
+					},
+				}},
+			},
+		},
+	}
+	for _, r := range rules {
+		policy.Spec.Validations = append(policy.Spec.Validations, admissionv1alpha1.Validation{
+			Expression: r.Expression,
+			Message:    r.Message,
+		})
+	}
+	binding := &admissionv1alpha1.ValidatingAdmissionPolicyBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionv1alpha1.SchemeGroupVersion.String(),
+			Kind:       "ValidatingAdmissionPolicyBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       admissionv1alpha1.ValidatingAdmissionPolicyBindingSpec{PolicyName: name},
+	}
+	return policy, binding
+}
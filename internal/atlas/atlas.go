@@ -14,7 +14,7 @@ import (
 type (
 	// Client is a client for the Atlas CLI.
 	Client struct {
-		path string
+		runner Runner
 	}
 	// ApplyParams are the parameters for the `migrate apply` command.
 	ApplyParams struct {
@@ -25,7 +25,21 @@ type (
 		RevisionsSchema string
 		BaselineVersion string
 		TxMode          string
+		AllowDirty      bool
+		ExecOrder       string
+		DryRun          bool
 		Amount          uint64
+		Vars            Vars
+	}
+	// DownParams are the parameters for the `migrate down` command.
+	DownParams struct {
+		Env       string
+		ConfigURL string
+		URL       string
+		DirURL    string
+		DevURL    string
+		ToVersion string
+		Vars      Vars
 	}
 	// StatusParams are the parameters for the `migrate status` command.
 	StatusParams struct {
@@ -34,6 +48,7 @@ type (
 		DirURL          string
 		URL             string
 		RevisionsSchema string
+		Vars            Vars
 	}
 	// LintParams are the parameters for the `migrate lint` command.
 	LintParams struct {
@@ -79,7 +94,7 @@ func NewClient(dir, name string) (*Client, error) {
 
 // NewClientWithPath returns a new Atlas client with the given atlas-cli path.
 func NewClientWithPath(path string) *Client {
-	return &Client{path: path}
+	return NewClientWithRunner(&LocalRunner{Path: path})
 }
 
 // Apply runs the 'migrate apply' command.
@@ -106,6 +121,16 @@ func (c *Client) Apply(ctx context.Context, data *ApplyParams) (*ApplyReport, er
 	if data.TxMode != "" {
 		args = append(args, "--tx-mode", data.TxMode)
 	}
+	if data.AllowDirty {
+		args = append(args, "--allow-dirty")
+	}
+	if data.ExecOrder != "" {
+		args = append(args, "--exec-order", data.ExecOrder)
+	}
+	if data.DryRun {
+		args = append(args, "--dry-run")
+	}
+	args = append(args, data.Vars.AsArgs()...)
 	if data.Amount > 0 {
 		args = append(args, strconv.FormatUint(data.Amount, 10))
 	}
@@ -158,8 +183,11 @@ func (c *Client) SchemaInspect(ctx context.Context, data *SchemaInspectParams) (
 	if data.DevURL != "" {
 		args = append(args, "--dev-url", data.DevURL)
 	}
-	if data.Format == "sql" {
+	switch data.Format {
+	case "sql":
 		args = append(args, "--format", "{{ sql . }}")
+	case "json":
+		args = append(args, "--format", "{{ json . }}")
 	}
 	if len(data.Schema) > 0 {
 		args = append(args, "--schema", strings.Join(data.Schema, ","))
@@ -191,6 +219,35 @@ func (c *Client) Lint(ctx context.Context, data *LintParams) (*SummaryReport, er
 	return &report, nil
 }
 
+// Down runs the 'migrate down' command.
+func (c *Client) Down(ctx context.Context, data *DownParams) (*DownReport, error) {
+	args := []string{
+		"migrate", "down",
+		"--format", "{{ json . }}",
+	}
+	if data.ConfigURL != "" {
+		args = append(args, "-c", data.ConfigURL, "--env", data.Env)
+	}
+	if data.URL != "" {
+		args = append(args, "--url", data.URL)
+	}
+	if data.DirURL != "" {
+		args = append(args, "--dir", data.DirURL)
+	}
+	if data.DevURL != "" {
+		args = append(args, "--dev-url", data.DevURL)
+	}
+	if data.ToVersion != "" {
+		args = append(args, "--to-version", data.ToVersion)
+	}
+	args = append(args, data.Vars.AsArgs()...)
+	var report DownReport
+	if _, err := c.runCommand(ctx, args, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
 // Status runs the 'migrate status' command.
 func (c *Client) Status(ctx context.Context, data *StatusParams) (*StatusReport, error) {
 	args := []string{
@@ -208,6 +265,7 @@ func (c *Client) Status(ctx context.Context, data *StatusParams) (*StatusReport,
 	if data.RevisionsSchema != "" {
 		args = append(args, "--revisions-schema", data.RevisionsSchema)
 	}
+	args = append(args, data.Vars.AsArgs()...)
 	var report StatusReport
 	if _, err := c.runCommand(ctx, args, &report); err != nil {
 		return nil, err
@@ -215,11 +273,30 @@ func (c *Client) Status(ctx context.Context, data *StatusParams) (*StatusReport,
 	return &report, nil
 }
 
+// Version runs the 'version' command and returns the version of the CLI,
+// e.g. "v0.14.1" or "v0.14.2-a1b2c3d-canary".
+func (c *Client) Version(ctx context.Context) (string, error) {
+	out, err := c.runCommand(ctx, []string{"version"}, nil)
+	if err != nil {
+		return "", err
+	}
+	// The first line is formatted as "atlas version <version>".
+	line, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	f := strings.Fields(line)
+	if len(f) < 3 || f[0] != "atlas" || f[1] != "version" {
+		return "", fmt.Errorf("atlas: unexpected version output %q", line)
+	}
+	return f[2], nil
+}
+
 // runCommand runs the given command and unmarshals the output into the given
 // interface.
 func (c *Client) runCommand(ctx context.Context, args []string, report interface{}) (string, error) {
-	cmd := exec.CommandContext(ctx, c.path, args...)
-	cmd.Env = append(cmd.Env, "ATLAS_NO_UPDATE_NOTIFIER=1")
+	record(ctx, args)
+	cmd, err := c.runner.Command(ctx, args)
+	if err != nil {
+		return "", err
+	}
 	output, err := cmd.Output()
 	if err != nil {
 		exitErr, ok := err.(*exec.ExitError)
@@ -38,6 +38,31 @@ type (
 		// but by Atlas, e.g. when committing or rolling back a transaction.
 		Error string `json:"Error,omitempty"`
 	}
+	// RevertedFile is part of a DownReport containing information about a reverted file.
+	RevertedFile struct {
+		File
+		Start   time.Time
+		End     time.Time
+		Skipped int      // Amount of skipped SQL statements in a partially reverted file.
+		Applied []string // SQL statements applied with success
+		Scope   string   // Scope of the revert. e.g., statement, versions, etc.
+		Error   *struct {
+			SQL   string // SQL statement that failed.
+			Error string // Error returned by the database.
+		}
+	}
+	// DownReport contains a summary of a migration reverting attempt on a database.
+	DownReport struct {
+		Planned  []File          `json:"Planned,omitempty"`  // Planned migration files
+		Reverted []*RevertedFile `json:"Reverted,omitempty"` // Reverted files
+		Current  string          `json:"Current,omitempty"`  // Current migration version
+		Target   string          `json:"Target,omitempty"`   // Target migration version
+		Total    int             `json:"Total,omitempty"`    // Total number of migrations to revert
+		Start    time.Time
+		End      time.Time
+		Status   string `json:"Status,omitempty"` // Status of the revert
+		Error    string `json:"Error,omitempty"`  // Error that occurred during the revert
+	}
 	// A Revision denotes an applied migration in a deployment. Used to track migration executions state of a database.
 	Revision struct {
 		Version         string        `json:"Version"`             // Version of the migration.
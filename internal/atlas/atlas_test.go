@@ -0,0 +1,26 @@
+package atlas
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Version(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "atlas")
+	write := func(out string) {
+		require.NoError(t, os.WriteFile(bin, []byte("#!/bin/sh\nprintf '"+out+"'\n"), 0755))
+	}
+	c := NewClientWithPath(bin)
+	write(`atlas version v0.14.2-a1b2c3d-canary\nhttps://github.com/ariga/atlas/releases/latest\n`)
+	v, err := c.Version(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "v0.14.2-a1b2c3d-canary", v)
+
+	write(`unknown command "version"\n`)
+	_, err = c.Version(context.Background())
+	require.EqualError(t, err, `atlas: unexpected version output "unknown command \"version\""`)
+}
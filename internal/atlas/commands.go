@@ -0,0 +1,83 @@
+package atlas
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+type (
+	// CommandLog records the command lines run by a Client, with credentials
+	// and variable values redacted, so they can be reproduced locally.
+	CommandLog struct {
+		mu   sync.Mutex
+		cmds []string
+	}
+	commandLogKey struct{}
+)
+
+// WithCommandLog returns a context recording the commands run with it.
+func WithCommandLog(ctx context.Context) (context.Context, *CommandLog) {
+	l := &CommandLog{}
+	return context.WithValue(ctx, commandLogKey{}, l), l
+}
+
+// Commands returns the recorded command lines, in the order they were run.
+func (l *CommandLog) Commands() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.cmds...)
+}
+
+// record adds the command to the log of the context, if there is one.
+func record(ctx context.Context, args []string) {
+	l, ok := ctx.Value(commandLogKey{}).(*CommandLog)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cmds = append(l.cmds, CommandLine(args))
+}
+
+// CommandLine returns the shell command line of the atlas arguments. The
+// passwords and auth tokens of URLs and the values of variables are redacted.
+func CommandLine(args []string) string {
+	parts := make([]string, 0, len(args)+1)
+	parts = append(parts, "atlas")
+	for i, a := range args {
+		switch {
+		case i > 0 && args[i-1] == "--var":
+			if k, _, ok := strings.Cut(a, "="); ok {
+				a = k + "=xxxxx"
+			}
+		case strings.Contains(a, "://"):
+			if u, err := url.Parse(a); err == nil {
+				a = redactURL(u)
+			}
+		}
+		parts = append(parts, quote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// redactURL returns the URL with its password and its auth token redacted.
+// Remote libSQL databases, e.g. Turso, carry the token in the query.
+func redactURL(u *url.URL) string {
+	if q := u.Query(); q.Has("authToken") {
+		q.Set("authToken", "xxxxx")
+		c := *u
+		c.RawQuery = q.Encode()
+		u = &c
+	}
+	return u.Redacted()
+}
+
+// quote quotes the argument for POSIX shells if needed.
+func quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`{}*?&;|<>()[]#~!") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
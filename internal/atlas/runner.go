@@ -0,0 +1,265 @@
+package atlas
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Runner creates the commands executing the Atlas CLI with the given arguments.
+	Runner interface {
+		Command(ctx context.Context, args []string) (*exec.Cmd, error)
+	}
+	// LocalRunner executes the Atlas CLI installed at Path.
+	LocalRunner struct {
+		Path string
+	}
+	// SSHRunner executes the Atlas CLI on a remote host over SSH, e.g. a bastion
+	// that is the only host allowed to connect to the database network. The local
+	// files referenced by the arguments, such as the generated project file,
+	// migration directories and the certificates of database URLs, are copied to
+	// the same paths on the remote host for the duration of the command. Only
+	// files inside the temporary directory are copied.
+	SSHRunner struct {
+		// SSH is the path of the ssh client. Defaults to "ssh".
+		SSH string
+		// Host to connect to, and the optional User and Port.
+		Host, User string
+		Port       int
+		// IdentityFile is the private key used to authenticate.
+		IdentityFile string
+		// KnownHostsFile holds the public key of the host. Unknown hosts are
+		// rejected.
+		KnownHostsFile string
+		// Path of the Atlas CLI on the remote host. Defaults to "atlas".
+		Path string
+	}
+)
+
+// NewClientWithRunner returns a new Atlas client executing the CLI with the given runner.
+func NewClientWithRunner(r Runner) *Client {
+	return &Client{runner: r}
+}
+
+// Command implements Runner.
+func (r *LocalRunner) Command(ctx context.Context, args []string) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, r.Path, args...)
+	cmd.Env = append(cmd.Env, "ATLAS_NO_UPDATE_NOTIFIER=1")
+	return cmd, nil
+}
+
+// Command implements Runner.
+func (r *SSHRunner) Command(ctx context.Context, args []string) (*exec.Cmd, error) {
+	if r.Host == "" {
+		return nil, fmt.Errorf("atlas: ssh host is required")
+	}
+	files := localFiles(args)
+	argsFile, err := remoteArgsFile()
+	if err != nil {
+		return nil, err
+	}
+	var stdin bytes.Buffer
+	if err := tarFiles(&stdin, files, argsFile, args); err != nil {
+		return nil, err
+	}
+	ssh, path := r.SSH, r.Path
+	if ssh == "" {
+		ssh = "ssh"
+	}
+	if path == "" {
+		path = "atlas"
+	}
+	sshArgs := []string{"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=yes"}
+	if r.KnownHostsFile != "" {
+		sshArgs = append(sshArgs, "-o", "UserKnownHostsFile="+r.KnownHostsFile)
+	}
+	if r.IdentityFile != "" {
+		sshArgs = append(sshArgs, "-i", r.IdentityFile)
+	}
+	if r.Port != 0 {
+		sshArgs = append(sshArgs, "-p", strconv.Itoa(r.Port))
+	}
+	if r.User != "" {
+		sshArgs = append(sshArgs, "-l", r.User)
+	}
+	sshArgs = append(sshArgs, r.Host, "--", remoteScript(path, argsFile, files))
+	cmd := exec.CommandContext(ctx, ssh, sshArgs...)
+	cmd.Stdin = &stdin
+	return cmd, nil
+}
+
+// remoteScript returns the shell script extracting the files sent on the
+// standard input, running the CLI and removing the files when it exits. The
+// arguments of the CLI hold the credentials of the databases, so they are sent
+// in argsFile instead of the command line of the remote shell, which is visible
+// to the other users of the host. The exit status of the CLI is kept, as
+// status 1 reports findings, not failures.
+func remoteScript(path, argsFile string, files []string) string {
+	var b strings.Builder
+	b.WriteString("trap 'rm -rf --")
+	for _, f := range append(files, argsFile) {
+		b.WriteString(" " + quote(f))
+	}
+	b.WriteString("' EXIT; tar -xPf - || exit 2; . ")
+	b.WriteString(quote(argsFile))
+	b.WriteString(" || exit 2; ATLAS_NO_UPDATE_NOTIFIER=1 ")
+	b.WriteString(quote(path))
+	b.WriteString(` "$@"`)
+	return b.String()
+}
+
+// remoteArgsFile returns a random path for the file holding the arguments of
+// the CLI on the remote host.
+func remoteArgsFile() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "/tmp/atlas-args-" + hex.EncodeToString(b), nil
+}
+
+var (
+	// fileURL matches the file URLs in the project files passed to the CLI.
+	fileURL = regexp.MustCompile(`file://[^"'?\s]+`)
+	// fileParam matches the parameters of database URLs holding the paths of
+	// certificate files, e.g. written for tlsFrom.
+	fileParam = regexp.MustCompile(`[?&](?:sslcert|sslkey|sslrootcert)=([^&"'\s]+)`)
+)
+
+// localFiles returns the existing local paths referenced by the file URLs and
+// the certificate parameters of the database URLs of the arguments, and of the
+// project file passed in -c. The files of the desired schema are written
+// by users, so their content is not scanned. Only paths inside the temporary
+// directory are returned, as they are removed from the remote host once the
+// command exits.
+func localFiles(args []string) []string {
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if p = tempPath(p); p == "" || seen[p] {
+			return
+		}
+		if _, err := os.Stat(p); err == nil {
+			seen[p] = true
+		}
+	}
+	for i, a := range args {
+		scan(a, add)
+		if i > 0 && (args[i-1] == "-c" || args[i-1] == "--config") {
+			if p := tempPath(strings.TrimPrefix(a, "file://")); p != "" && filepath.Ext(p) == ".hcl" {
+				if b, err := os.ReadFile(p); err == nil {
+					scan(string(b), add)
+				}
+			}
+		}
+	}
+	files := make([]string, 0, len(seen))
+	for f := range seen {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// tempPath returns the cleaned path if it is inside the temporary directory,
+// and so does its target if it is a symbolic link. It returns an empty string
+// otherwise.
+func tempPath(p string) string {
+	if !filepath.IsAbs(p) {
+		return ""
+	}
+	p = filepath.Clean(p)
+	if !within(filepath.Clean(os.TempDir()), p) {
+		return ""
+	}
+	tmp, err := filepath.EvalSymlinks(os.TempDir())
+	if err != nil {
+		return ""
+	}
+	if r, err := filepath.EvalSymlinks(p); err != nil || !within(tmp, r) {
+		return ""
+	}
+	return p
+}
+
+// within reports if p is a path inside dir.
+func within(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// scan calls add with the local paths referenced by s.
+func scan(s string, add func(string)) {
+	for _, m := range fileURL.FindAllString(s, -1) {
+		if u, err := url.Parse(m); err == nil && u.Host == "" {
+			add(u.Path)
+		}
+	}
+	for _, m := range fileParam.FindAllStringSubmatch(s, -1) {
+		if p, err := url.QueryUnescape(m[1]); err == nil {
+			add(p)
+		}
+	}
+}
+
+// tarFiles writes the files and directories to w as a tarball keeping their
+// absolute paths, followed by argsFile setting the positional parameters of
+// the remote shell to args. Only the remote user can read argsFile.
+func tarFiles(w io.Writer, files []string, argsFile string, args []string) error {
+	tw := tar.NewWriter(w)
+	for _, root := range files {
+		err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() && !fi.Mode().IsRegular() {
+				return nil
+			}
+			hdr, err := tar.FileInfoHeader(fi, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = p
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if fi.IsDir() {
+				return nil
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("atlas: copying %s to the remote host: %w", root, err)
+		}
+	}
+	set := "set --"
+	for _, a := range args {
+		set += " " + quote(a)
+	}
+	set += "\n"
+	if err := tw.WriteHeader(&tar.Header{Name: argsFile, Mode: 0600, Size: int64(len(set)), Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(tw, set); err != nil {
+		return err
+	}
+	return tw.Close()
+}
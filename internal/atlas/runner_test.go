@@ -0,0 +1,144 @@
+package atlas
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHRunner(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "migrations"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "migrations", "1.sql"), []byte("CREATE TABLE t (id int);"), 0644))
+	config := filepath.Join(dir, "atlas.hcl")
+	require.NoError(t, os.WriteFile(config, []byte(`env {
+  migration {
+    dir = "file://`+dir+`/migrations"
+  }
+}`), 0644))
+	// The fake ssh client records its arguments and input.
+	ssh := filepath.Join(dir, "ssh")
+	require.NoError(t, os.WriteFile(ssh, []byte(`#!/bin/sh
+printf '%s\n' "$@" > "$(dirname "$0")/args"
+cat > "$(dirname "$0")/stdin"
+echo '{"Status":"OK"}'
+`), 0755))
+
+	c := NewClientWithRunner(&SSHRunner{
+		SSH:          ssh,
+		Host:         "bastion.internal",
+		User:         "atlas",
+		Port:         2222,
+		IdentityFile: "/etc/atlas-ssh/id_ed25519",
+		Path:         "/usr/local/bin/atlas",
+	})
+	s, err := c.Status(context.Background(), &StatusParams{
+		Env:       "kubernetes",
+		ConfigURL: "file://" + config,
+		URL:       "mysql://root:pass@db:3306/app",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "OK", s.Status)
+
+	b, err := os.ReadFile(filepath.Join(dir, "args"))
+	require.NoError(t, err)
+	args := strings.Split(strings.TrimSpace(string(b)), "\n")
+	require.Equal(t, []string{
+		"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=yes",
+		"-i", "/etc/atlas-ssh/id_ed25519", "-p", "2222", "-l", "atlas",
+		"bastion.internal", "--",
+	}, args[:len(args)-1])
+	// The credentials are not part of the command line.
+	script := args[len(args)-1]
+	require.NotContains(t, script, "pass")
+	argsFile := regexp.MustCompile(`/tmp/atlas-args-[0-9a-f]{16}`).FindString(script)
+	require.NotEmpty(t, argsFile, script)
+	require.Equal(t, "trap 'rm -rf -- "+quote(config)+" "+quote(dir+"/migrations")+" "+argsFile+"' EXIT; tar -xPf - || exit 2; . "+argsFile+` || exit 2; ATLAS_NO_UPDATE_NOTIFIER=1 /usr/local/bin/atlas "$@"`, script)
+
+	// The project file, the directory it references and the arguments are sent
+	// to the host.
+	f, err := os.Open(filepath.Join(dir, "stdin"))
+	require.NoError(t, err)
+	defer f.Close()
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		if hdr.Name == argsFile {
+			require.EqualValues(t, 0600, hdr.Mode)
+			b, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(string(b), "set -- migrate status"), string(b))
+			require.Contains(t, string(b), " --url mysql://root:pass@db:3306/app")
+		}
+	}
+	require.Equal(t, []string{config, dir + "/migrations", dir + "/migrations/1.sql", argsFile}, names)
+}
+
+func TestSSHRunner_certificates(t *testing.T) {
+	dir := t.TempDir()
+	key := filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(key, []byte("key"), 0600))
+	config := filepath.Join(dir, "atlas.hcl")
+	require.NoError(t, os.WriteFile(config, []byte(`env {
+  url = "postgres://root@db:5432/app?sslkey=`+url.QueryEscape(key)+`&sslmode=require"
+}`), 0644))
+	// The certificates referenced by the URLs of the arguments and of the
+	// project files are sent to the host.
+	for _, args := range [][]string{
+		{"schema", "inspect", "--url", "postgres://root@db:5432/app?sslmode=require&sslkey=" + url.QueryEscape(key)},
+		{"schema", "inspect", "--env", "kubernetes", "-c", "file://" + config},
+	} {
+		require.Contains(t, localFiles(args), key)
+	}
+	require.Empty(t, localFiles([]string{"--url", "postgres://root@db:5432/app?sslkey=relative.key"}))
+}
+
+func TestSSHRunner_userFiles(t *testing.T) {
+	dir := t.TempDir()
+	// The content of the desired schema is written by users, and not scanned.
+	schema := filepath.Join(dir, "schema.hcl")
+	require.NoError(t, os.WriteFile(schema, []byte("# file://"+dir+"/other\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other"), nil, 0644))
+	require.Equal(t, []string{schema}, localFiles([]string{"schema", "apply", "--to", "file://" + schema}))
+
+	// Paths outside the temporary directory are never sent, nor removed.
+	config := filepath.Join(dir, "atlas.hcl")
+	require.NoError(t, os.WriteFile(config, []byte(`# file:///etc/hosts file:///tmp file://`+dir+`/../../../etc`), 0644))
+	require.NoError(t, os.Symlink("/etc", filepath.Join(dir, "etc")))
+	require.Equal(t, []string{config}, localFiles([]string{"schema", "apply", "-c", "file://" + config, "--url", "file://" + dir + "/etc"}))
+	require.Empty(t, localFiles([]string{"--url", "postgres://root@db:5432/app?sslkey=/etc/hosts"}))
+}
+
+func TestSSHRunner_noFiles(t *testing.T) {
+	cmd, err := (&SSHRunner{Host: "bastion"}).Command(context.Background(), []string{"schema", "inspect", "--url", "file://missing.hcl"})
+	require.NoError(t, err)
+	script := cmd.Args[len(cmd.Args)-1]
+	require.Regexp(t, `^trap 'rm -rf -- /tmp/atlas-args-[0-9a-f]{16}' EXIT; tar -xPf - \|\| exit 2; \. /tmp/atlas-args-[0-9a-f]{16} \|\| exit 2; ATLAS_NO_UPDATE_NOTIFIER=1 atlas "\$@"$`, script)
+	// Only the arguments are sent.
+	tr := tar.NewReader(cmd.Stdin)
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	require.Contains(t, script, hdr.Name)
+	b, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	require.Equal(t, "set -- schema inspect --url file://missing.hcl\n", string(b))
+	_, err = tr.Next()
+	require.Equal(t, io.EOF, err)
+
+	_, err = (&SSHRunner{}).Command(context.Background(), nil)
+	require.EqualError(t, err, "atlas: ssh host is required")
+}
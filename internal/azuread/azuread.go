@@ -0,0 +1,209 @@
+// Package azuread acquires Microsoft Entra ID (Azure AD) access tokens used
+// to authenticate with Azure Database servers, with the workload identity of
+// the pod or the managed identity of its node.
+package azuread
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScopeOSSRDBMS is the scope of the tokens of Azure Database for PostgreSQL and MySQL.
+const ScopeOSSRDBMS = "https://ossrdbms-aad.database.windows.net/.default"
+
+// expiryDelta is the time before their expiry cached tokens are refreshed.
+const expiryDelta = 5 * time.Minute
+
+type (
+	// Config identifies the identity tokens are acquired for.
+	Config struct {
+		// ClientID of the identity. Tokens of the system-assigned managed
+		// identity are acquired if it is empty and no federated token is set.
+		ClientID string
+		// TenantID of the workload identity.
+		TenantID string
+		// TokenFile holds the federated token of the workload identity. The
+		// managed identity of the node is used if it is empty.
+		TokenFile string
+		// AuthorityHost of Entra ID. Defaults to https://login.microsoftonline.com/.
+		AuthorityHost string
+		// IMDSEndpoint overrides the address of the managed identity endpoint.
+		IMDSEndpoint string
+	}
+	// Source acquires tokens, and caches them until shortly before they expire.
+	Source struct {
+		http *http.Client
+		now  func() time.Time
+		mu   sync.Mutex
+		// cache holds the tokens by identity and scope.
+		cache map[string]token
+	}
+	token struct {
+		value   string
+		expires time.Time
+	}
+)
+
+// ConfigFromEnv returns the config of the workload identity injected in the
+// environment of the pod by the Azure Workload Identity webhook, if any.
+func ConfigFromEnv() Config {
+	return Config{
+		ClientID:      os.Getenv("AZURE_CLIENT_ID"),
+		TenantID:      os.Getenv("AZURE_TENANT_ID"),
+		TokenFile:     os.Getenv("AZURE_FEDERATED_TOKEN_FILE"),
+		AuthorityHost: os.Getenv("AZURE_AUTHORITY_HOST"),
+	}
+}
+
+// New returns a new token source.
+func New() *Source {
+	return &Source{
+		http:  &http.Client{Timeout: 30 * time.Second},
+		now:   time.Now,
+		cache: make(map[string]token),
+	}
+}
+
+// Token returns an access token of the identity for the scope.
+func (s *Source) Token(ctx context.Context, cfg Config, scope string) (string, error) {
+	k := key(cfg, scope)
+	s.mu.Lock()
+	t, ok := s.cache[k]
+	s.mu.Unlock()
+	if ok && s.now().Add(expiryDelta).Before(t.expires) {
+		return t.value, nil
+	}
+	var err error
+	if cfg.TokenFile != "" {
+		t, err = s.federated(ctx, cfg, scope)
+	} else {
+		t, err = s.managed(ctx, cfg, scope)
+	}
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.cache[k] = t
+	s.mu.Unlock()
+	return t.value, nil
+}
+
+// Invalidate drops the cached token of the identity for the scope, e.g. after
+// the database rejected it.
+func (s *Source) Invalidate(cfg Config, scope string) {
+	s.mu.Lock()
+	delete(s.cache, key(cfg, scope))
+	s.mu.Unlock()
+}
+
+func key(cfg Config, scope string) string {
+	return strings.Join([]string{cfg.TenantID, cfg.ClientID, cfg.TokenFile, scope}, "|")
+}
+
+// federated exchanges the federated token of the workload identity for an
+// access token, with the client credentials flow.
+func (s *Source) federated(ctx context.Context, cfg Config, scope string) (token, error) {
+	if cfg.ClientID == "" || cfg.TenantID == "" {
+		return token{}, errors.New("azuread: workload identity requires a client ID and a tenant ID")
+	}
+	assertion, err := os.ReadFile(cfg.TokenFile)
+	if err != nil {
+		return token{}, fmt.Errorf("azuread: reading federated token: %w", err)
+	}
+	host := cfg.AuthorityHost
+	if host == "" {
+		host = "https://login.microsoftonline.com/"
+	}
+	form := url.Values{
+		"client_id":             {cfg.ClientID},
+		"scope":                 {scope},
+		"grant_type":            {"client_credentials"},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {strings.TrimSpace(string(assertion))},
+	}
+	u := strings.TrimSuffix(host, "/") + "/" + url.PathEscape(cfg.TenantID) + "/oauth2/v2.0/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := s.do(req, &resp); err != nil {
+		return token{}, err
+	}
+	return token{value: resp.AccessToken, expires: s.now().Add(time.Duration(resp.ExpiresIn) * time.Second)}, nil
+}
+
+// managed acquires an access token of the managed identity from the instance
+// metadata service of the node.
+func (s *Source) managed(ctx context.Context, cfg Config, scope string) (token, error) {
+	endpoint := cfg.IMDSEndpoint
+	if endpoint == "" {
+		endpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+	}
+	q := url.Values{
+		"api-version": {"2018-02-01"},
+		"resource":    {strings.TrimSuffix(scope, "/.default")},
+	}
+	if cfg.ClientID != "" {
+		q.Set("client_id", cfg.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return token{}, err
+	}
+	req.Header.Set("Metadata", "true")
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		// ExpiresOn is the expiry in seconds since the epoch, as a string.
+		ExpiresOn string `json:"expires_on"`
+	}
+	if err := s.do(req, &resp); err != nil {
+		return token{}, err
+	}
+	sec, err := strconv.ParseInt(resp.ExpiresOn, 10, 64)
+	if err != nil {
+		return token{}, fmt.Errorf("azuread: invalid token expiry %q", resp.ExpiresOn)
+	}
+	return token{value: resp.AccessToken, expires: time.Unix(sec, 0)}, nil
+}
+
+// do sends the request and decodes the token response. The errors of Entra
+// ID are returned as is, as they explain why the token was refused.
+func (s *Source) do(req *http.Request, v any) error {
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("azuread: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("azuread: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Description != "" {
+			return fmt.Errorf("azuread: unexpected status %s: %s", resp.Status, e.Description)
+		}
+		return fmt.Errorf("azuread: unexpected status %s", resp.Status)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("azuread: decoding token: %w", err)
+	}
+	return nil
+}
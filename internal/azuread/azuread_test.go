@@ -0,0 +1,76 @@
+package azuread
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource_Federated(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		require.Equal(t, "/tenant/oauth2/v2.0/token", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "client", r.PostForm.Get("client_id"))
+		require.Equal(t, ScopeOSSRDBMS, r.PostForm.Get("scope"))
+		require.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		require.Equal(t, "federated", r.PostForm.Get("client_assertion"))
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600}`, calls)
+	}))
+	defer srv.Close()
+	file := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(file, []byte("federated\n"), 0600))
+	cfg := Config{ClientID: "client", TenantID: "tenant", TokenFile: file, AuthorityHost: srv.URL}
+	s := New()
+	ctx := context.Background()
+
+	// Tokens are cached until shortly before they expire.
+	tok, err := s.Token(ctx, cfg, ScopeOSSRDBMS)
+	require.NoError(t, err)
+	require.Equal(t, "token-1", tok)
+	tok, err = s.Token(ctx, cfg, ScopeOSSRDBMS)
+	require.NoError(t, err)
+	require.Equal(t, "token-1", tok)
+	s.now = func() time.Time { return time.Now().Add(56 * time.Minute) }
+	tok, err = s.Token(ctx, cfg, ScopeOSSRDBMS)
+	require.NoError(t, err)
+	require.Equal(t, "token-2", tok)
+
+	// Invalidated tokens are acquired again.
+	s.Invalidate(cfg, ScopeOSSRDBMS)
+	tok, err = s.Token(ctx, cfg, ScopeOSSRDBMS)
+	require.NoError(t, err)
+	require.Equal(t, "token-3", tok)
+
+	_, err = s.Token(ctx, Config{TokenFile: file}, ScopeOSSRDBMS)
+	require.EqualError(t, err, "azuread: workload identity requires a client ID and a tenant ID")
+}
+
+func TestSource_Managed(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Unix()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "true", r.Header.Get("Metadata"))
+		require.Equal(t, "https://ossrdbms-aad.database.windows.net", r.URL.Query().Get("resource"))
+		if r.URL.Query().Get("client_id") == "unknown" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_request","error_description":"Identity not found"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"managed","expires_on":"%d"}`, expires)
+	}))
+	defer srv.Close()
+	s := New()
+	tok, err := s.Token(context.Background(), Config{IMDSEndpoint: srv.URL}, ScopeOSSRDBMS)
+	require.NoError(t, err)
+	require.Equal(t, "managed", tok)
+	_, err = s.Token(context.Background(), Config{ClientID: "unknown", IMDSEndpoint: srv.URL}, ScopeOSSRDBMS)
+	require.EqualError(t, err, "azuread: unexpected status 400 Bad Request: Identity not found")
+}
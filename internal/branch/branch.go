@@ -0,0 +1,305 @@
+package branch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Supported branch providers.
+const (
+	PlanetScale = "planetscale"
+	Neon        = "neon"
+)
+
+// ErrNotFound is returned when a branch does not exist.
+var ErrNotFound = errors.New("branch: not found")
+
+type (
+	// Branch is a branch of a database.
+	Branch struct {
+		Name  string
+		ID    string
+		Ready bool
+	}
+	// Provider manages the branches of a database.
+	Provider interface {
+		// Get returns the branch with the given name, or ErrNotFound.
+		Get(ctx context.Context, name string) (*Branch, error)
+		// Create creates a branch with the given name from the parent branch.
+		Create(ctx context.Context, name string) (*Branch, error)
+		// URL returns an Atlas URL used to connect to the branch.
+		URL(ctx context.Context, b *Branch) (string, error)
+		// Promote merges the changes of the branch into its parent, or makes
+		// it the primary branch, depending on the provider.
+		Promote(ctx context.Context, b *Branch) error
+		// Delete deletes the branch.
+		Delete(ctx context.Context, b *Branch) error
+	}
+	// Config configures a branch provider.
+	Config struct {
+		// Provider is one of "planetscale" or "neon".
+		Provider string
+		// Token used to authenticate with the provider API.
+		Token string
+		// Organization is the PlanetScale organization.
+		Organization string
+		// Project is the Neon project ID.
+		Project string
+		// Database is the name of the database.
+		Database string
+		// Role is the Neon role used to connect to the branch.
+		Role string
+		// ParentBranch the branches are created from.
+		ParentBranch string
+		// BaseURL overrides the address of the provider API.
+		BaseURL string
+	}
+)
+
+// NewProvider returns the branch provider for the given config.
+func NewProvider(cfg *Config) (Provider, error) {
+	if cfg.ParentBranch == "" {
+		cfg.ParentBranch = "main"
+	}
+	api := &apiClient{token: cfg.Token, base: cfg.BaseURL, http: &http.Client{Timeout: 30 * time.Second}}
+	switch cfg.Provider {
+	case PlanetScale:
+		if cfg.Organization == "" || cfg.Database == "" {
+			return nil, errors.New("branch: planetscale requires an organization and a database")
+		}
+		if api.base == "" {
+			api.base = "https://api.planetscale.com/v1"
+		}
+		return &planetScale{api: api, cfg: cfg}, nil
+	case Neon:
+		if cfg.Project == "" || cfg.Database == "" || cfg.Role == "" {
+			return nil, errors.New("branch: neon requires a project, a database and a role")
+		}
+		if api.base == "" {
+			api.base = "https://console.neon.tech/api/v2"
+		}
+		api.token = "Bearer " + api.token
+		return &neon{api: api, cfg: cfg}, nil
+	default:
+		return nil, fmt.Errorf("branch: unsupported provider %q", cfg.Provider)
+	}
+}
+
+type planetScale struct {
+	api *apiClient
+	cfg *Config
+}
+
+func (p *planetScale) path(format string, args ...any) string {
+	return fmt.Sprintf("/organizations/%s/databases/%s", url.PathEscape(p.cfg.Organization), url.PathEscape(p.cfg.Database)) +
+		fmt.Sprintf(format, args...)
+}
+
+// Get implements Provider.
+func (p *planetScale) Get(ctx context.Context, name string) (*Branch, error) {
+	var b struct {
+		Name  string `json:"name"`
+		Ready bool   `json:"ready"`
+	}
+	if err := p.api.do(ctx, http.MethodGet, p.path("/branches/%s", url.PathEscape(name)), nil, &b); err != nil {
+		return nil, err
+	}
+	return &Branch{Name: b.Name, ID: b.Name, Ready: b.Ready}, nil
+}
+
+// Create implements Provider.
+func (p *planetScale) Create(ctx context.Context, name string) (*Branch, error) {
+	var b struct {
+		Name  string `json:"name"`
+		Ready bool   `json:"ready"`
+	}
+	body := map[string]string{"name": name, "parent_branch": p.cfg.ParentBranch}
+	if err := p.api.do(ctx, http.MethodPost, p.path("/branches"), body, &b); err != nil {
+		return nil, err
+	}
+	return &Branch{Name: b.Name, ID: b.Name, Ready: b.Ready}, nil
+}
+
+// passwordName is the name of the branch passwords created by the operator.
+const passwordName = "atlas-operator"
+
+// URL implements Provider. It creates a new password for the branch, as
+// passwords are returned only when created, and deletes the passwords it
+// created before, so they do not pile up on the branch.
+func (p *planetScale) URL(ctx context.Context, b *Branch) (string, error) {
+	var list struct {
+		Data []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"data"`
+	}
+	if err := p.api.do(ctx, http.MethodGet, p.path("/branches/%s/passwords", url.PathEscape(b.Name)), nil, &list); err != nil {
+		return "", err
+	}
+	for _, pw := range list.Data {
+		if pw.Name != passwordName {
+			continue
+		}
+		if err := p.api.do(ctx, http.MethodDelete, p.path("/branches/%s/passwords/%s", url.PathEscape(b.Name), url.PathEscape(pw.ID)), nil, nil); err != nil && !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+	}
+	var pw struct {
+		Username string `json:"username"`
+		Password string `json:"plain_text"`
+		Host     string `json:"access_host_url"`
+	}
+	body := map[string]string{"role": "admin", "name": passwordName}
+	if err := p.api.do(ctx, http.MethodPost, p.path("/branches/%s/passwords", url.PathEscape(b.Name)), body, &pw); err != nil {
+		return "", err
+	}
+	u := &url.URL{
+		Scheme:   "mysql",
+		User:     url.UserPassword(pw.Username, pw.Password),
+		Host:     pw.Host,
+		Path:     p.cfg.Database,
+		RawQuery: "tls=true",
+	}
+	return u.String(), nil
+}
+
+// Promote implements Provider. It opens a deploy request from the branch into its parent.
+func (p *planetScale) Promote(ctx context.Context, b *Branch) error {
+	body := map[string]string{"branch": b.Name, "into_branch": p.cfg.ParentBranch}
+	return p.api.do(ctx, http.MethodPost, p.path("/deploy-requests"), body, nil)
+}
+
+// Delete implements Provider.
+func (p *planetScale) Delete(ctx context.Context, b *Branch) error {
+	return p.api.do(ctx, http.MethodDelete, p.path("/branches/%s", url.PathEscape(b.Name)), nil, nil)
+}
+
+type (
+	neon struct {
+		api *apiClient
+		cfg *Config
+	}
+	neonBranch struct {
+		ID           string `json:"id"`
+		Name         string `json:"name"`
+		CurrentState string `json:"current_state"`
+	}
+)
+
+func (n *neon) path(format string, args ...any) string {
+	return fmt.Sprintf("/projects/%s", url.PathEscape(n.cfg.Project)) + fmt.Sprintf(format, args...)
+}
+
+func (n *neon) find(ctx context.Context, name string) (*neonBranch, error) {
+	var list struct {
+		Branches []neonBranch `json:"branches"`
+	}
+	if err := n.api.do(ctx, http.MethodGet, n.path("/branches"), nil, &list); err != nil {
+		return nil, err
+	}
+	for i := range list.Branches {
+		if list.Branches[i].Name == name {
+			return &list.Branches[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Get implements Provider.
+func (n *neon) Get(ctx context.Context, name string) (*Branch, error) {
+	b, err := n.find(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Branch{Name: b.Name, ID: b.ID, Ready: b.CurrentState == "ready"}, nil
+}
+
+// Create implements Provider.
+func (n *neon) Create(ctx context.Context, name string) (*Branch, error) {
+	parent, err := n.find(ctx, n.cfg.ParentBranch)
+	if err != nil {
+		return nil, fmt.Errorf("branch: parent branch %q: %w", n.cfg.ParentBranch, err)
+	}
+	var resp struct {
+		Branch neonBranch `json:"branch"`
+	}
+	body := map[string]any{
+		"branch":    map[string]string{"name": name, "parent_id": parent.ID},
+		"endpoints": []map[string]string{{"type": "read_write"}},
+	}
+	if err := n.api.do(ctx, http.MethodPost, n.path("/branches"), body, &resp); err != nil {
+		return nil, err
+	}
+	return &Branch{Name: resp.Branch.Name, ID: resp.Branch.ID, Ready: resp.Branch.CurrentState == "ready"}, nil
+}
+
+// URL implements Provider.
+func (n *neon) URL(ctx context.Context, b *Branch) (string, error) {
+	var resp struct {
+		URI string `json:"uri"`
+	}
+	q := url.Values{"branch_id": {b.ID}, "database_name": {n.cfg.Database}, "role_name": {n.cfg.Role}}
+	if err := n.api.do(ctx, http.MethodGet, n.path("/connection_uri?%s", q.Encode()), nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.URI, nil
+}
+
+// Promote implements Provider. It sets the branch as the primary branch of the project.
+func (n *neon) Promote(ctx context.Context, b *Branch) error {
+	return n.api.do(ctx, http.MethodPost, n.path("/branches/%s/set_as_primary", url.PathEscape(b.ID)), nil, nil)
+}
+
+// Delete implements Provider.
+func (n *neon) Delete(ctx context.Context, b *Branch) error {
+	return n.api.do(ctx, http.MethodDelete, n.path("/branches/%s", url.PathEscape(b.ID)), nil, nil)
+}
+
+// apiClient is a minimal JSON client for the provider APIs.
+type apiClient struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+func (c *apiClient) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode >= 300:
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("branch: %s %s: unexpected status %s: %s", method, path, resp.Status, bytes.TrimSpace(b))
+	case out != nil:
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
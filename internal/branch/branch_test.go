@@ -0,0 +1,139 @@
+package branch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanetScale(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.EqualValues(t, "id:token", r.Header.Get("Authorization"))
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /organizations/org/databases/db/branches/preview":
+			w.WriteHeader(http.StatusNotFound)
+		case "POST /organizations/org/databases/db/branches":
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.EqualValues(t, map[string]string{"name": "preview", "parent_branch": "main"}, body)
+			fmt.Fprint(w, `{"name":"preview","ready":true}`)
+		case "GET /organizations/org/databases/db/branches/preview/passwords":
+			fmt.Fprint(w, `{"data":[]}`)
+		case "POST /organizations/org/databases/db/branches/preview/passwords":
+			fmt.Fprint(w, `{"username":"u","plain_text":"p","access_host_url":"aws.connect.psdb.cloud"}`)
+		case "POST /organizations/org/databases/db/deploy-requests":
+			fmt.Fprint(w, `{"number":1}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	p, err := NewProvider(&Config{Provider: PlanetScale, Token: "id:token", Organization: "org", Database: "db", BaseURL: srv.URL})
+	require.NoError(t, err)
+	ctx := context.Background()
+	_, err = p.Get(ctx, "preview")
+	require.ErrorIs(t, err, ErrNotFound)
+	b, err := p.Create(ctx, "preview")
+	require.NoError(t, err)
+	require.EqualValues(t, &Branch{Name: "preview", ID: "preview", Ready: true}, b)
+	u, err := p.URL(ctx, b)
+	require.NoError(t, err)
+	require.EqualValues(t, "mysql://u:p@aws.connect.psdb.cloud/db?tls=true", u)
+	require.NoError(t, p.Promote(ctx, b))
+	require.ErrorContains(t, p.Delete(ctx, b), "unexpected status 400 Bad Request")
+	require.Len(t, calls, 6)
+}
+
+func TestPlanetScale_passwords(t *testing.T) {
+	// Passwords of the branch by ID, including one created by its users.
+	passwords := map[string]string{"user": "deploy"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const path = "/organizations/org/databases/db/branches/preview/passwords"
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == path:
+			var list struct {
+				Data []map[string]string `json:"data"`
+			}
+			for id, name := range passwords {
+				list.Data = append(list.Data, map[string]string{"id": id, "name": name})
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(list))
+		case r.Method == http.MethodPost && r.URL.Path == path:
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			id := fmt.Sprintf("pw-%d", len(passwords))
+			passwords[id] = body["name"]
+			fmt.Fprintf(w, `{"id":%q,"username":"u","plain_text":%q,"access_host_url":"aws.connect.psdb.cloud"}`, id, id)
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, path+"/"):
+			delete(passwords, strings.TrimPrefix(r.URL.Path, path+"/"))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	p, err := NewProvider(&Config{Provider: PlanetScale, Token: "id:token", Organization: "org", Database: "db", BaseURL: srv.URL})
+	require.NoError(t, err)
+	b := &Branch{Name: "preview", ID: "preview", Ready: true}
+	for i := 0; i < 3; i++ {
+		_, err := p.URL(context.Background(), b)
+		require.NoError(t, err)
+	}
+	// A single password of the operator is kept per branch.
+	var own int
+	for _, name := range passwords {
+		if name == "atlas-operator" {
+			own++
+		}
+	}
+	require.Equal(t, 1, own)
+	require.Len(t, passwords, 2)
+}
+
+func TestNeon(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.EqualValues(t, "Bearer token", r.Header.Get("Authorization"))
+		switch r.Method + " " + r.URL.Path {
+		case "GET /projects/proj/branches":
+			fmt.Fprint(w, `{"branches":[{"id":"br-main","name":"main","current_state":"ready"}]}`)
+		case "POST /projects/proj/branches":
+			var body struct {
+				Branch map[string]string `json:"branch"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.EqualValues(t, map[string]string{"name": "preview", "parent_id": "br-main"}, body.Branch)
+			fmt.Fprint(w, `{"branch":{"id":"br-preview","name":"preview","current_state":"init"}}`)
+		case "GET /projects/proj/connection_uri":
+			require.EqualValues(t, "br-preview", r.URL.Query().Get("branch_id"))
+			require.EqualValues(t, "db", r.URL.Query().Get("database_name"))
+			require.EqualValues(t, "owner", r.URL.Query().Get("role_name"))
+			fmt.Fprint(w, `{"uri":"postgres://owner:p@ep.neon.tech/db"}`)
+		case "POST /projects/proj/branches/br-preview/set_as_primary", "DELETE /projects/proj/branches/br-preview":
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	_, err := NewProvider(&Config{Provider: Neon, Token: "token", Project: "proj", Database: "db"})
+	require.EqualError(t, err, "branch: neon requires a project, a database and a role")
+	p, err := NewProvider(&Config{Provider: Neon, Token: "token", Project: "proj", Database: "db", Role: "owner", BaseURL: srv.URL})
+	require.NoError(t, err)
+	ctx := context.Background()
+	_, err = p.Get(ctx, "preview")
+	require.ErrorIs(t, err, ErrNotFound)
+	b, err := p.Create(ctx, "preview")
+	require.NoError(t, err)
+	require.EqualValues(t, &Branch{Name: "preview", ID: "br-preview"}, b)
+	u, err := p.URL(ctx, b)
+	require.NoError(t, err)
+	require.EqualValues(t, "postgres://owner:p@ep.neon.tech/db", u)
+	require.NoError(t, p.Promote(ctx, b))
+	require.NoError(t, p.Delete(ctx, b))
+}
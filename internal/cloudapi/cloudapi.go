@@ -0,0 +1,230 @@
+package cloudapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the endpoint of the Atlas Cloud API.
+const DefaultURL = "https://api.atlasgo.cloud/query"
+
+// dirStateQuery is the query the Atlas CLI runs to fetch a remote directory.
+const dirStateQuery = `query dirState($input: DirStateInput!) {
+  dirState(input: $input) {
+    content
+  }
+}`
+
+// schemaPlanQuery is the query the Atlas CLI runs to fetch a schema plan.
+const schemaPlanQuery = `query schemaPlanByRef($ref: String!) {
+  schemaPlanByRef(ref: $ref) {
+    name
+    status
+    migration
+  }
+}`
+
+// PlanApproved is the status of approved schema plans.
+const PlanApproved = "APPROVED"
+
+var (
+	// ErrUnauthorized is returned when the token is invalid or expired.
+	ErrUnauthorized = errors.New("cloudapi: the token is invalid or expired")
+	// ErrForbidden is returned when the token has no access to the directory.
+	ErrForbidden = errors.New("cloudapi: the token has no access to the directory")
+	// ErrPlanNotFound is returned when the schema plan does not exist.
+	ErrPlanNotFound = errors.New("cloudapi: schema plan not found")
+)
+
+type (
+	// Client checks Atlas Cloud tokens.
+	Client struct {
+		http *http.Client
+	}
+	// CheckParams are the parameters of a token check.
+	CheckParams struct {
+		// URL of the Atlas Cloud API. Defaults to DefaultURL.
+		URL   string
+		Token string
+		// Dir and Tag of the remote directory the token must have access to.
+		Dir, Tag string
+	}
+	// TokenStatus reports the health of a token.
+	TokenStatus struct {
+		// ExpiresAt is the expiry of the token, if it carries one.
+		ExpiresAt *time.Time
+		// RateLimit and RateRemaining are the request quota of the token and
+		// the requests left in the current window. They are -1 if not reported.
+		RateLimit, RateRemaining int
+	}
+	// PlanParams are the parameters of a schema plan fetch.
+	PlanParams struct {
+		// URL of the Atlas Cloud API. Defaults to DefaultURL.
+		URL   string
+		Token string
+		// Ref of the plan, e.g. "atlas://app/plans/add-users".
+		Ref string
+	}
+	// SchemaPlan is a schema plan reviewed in Atlas Cloud.
+	SchemaPlan struct {
+		Name string `json:"name"`
+		// Status of the review, e.g. "APPROVED".
+		Status string `json:"status"`
+		// Migration holds the SQL statements of the plan.
+		Migration string `json:"migration"`
+	}
+	// gqlError is an error reported in the response of a query.
+	gqlError struct {
+		msg string
+	}
+)
+
+func (e *gqlError) Error() string {
+	return "cloudapi: " + e.msg
+}
+
+// New returns a new Client using the given HTTP client.
+func New(c *http.Client) *Client {
+	return &Client{http: c}
+}
+
+// Check verifies the token can read the remote directory, the same way the
+// Atlas CLI fetches it, and reports the expiry and the rate limit of the token.
+// It returns ErrUnauthorized or ErrForbidden if the token cannot be used.
+func (c *Client) Check(ctx context.Context, p *CheckParams) (*TokenStatus, error) {
+	h, err := c.query(ctx, p.URL, p.Token, dirStateQuery, map[string]any{
+		"input": map[string]string{"name": p.Dir, "tag": p.Tag},
+	}, nil)
+	if h == nil {
+		return nil, err
+	}
+	st := &TokenStatus{
+		ExpiresAt:     expiry(p.Token),
+		RateLimit:     header(h, "X-RateLimit-Limit"),
+		RateRemaining: header(h, "X-RateLimit-Remaining"),
+	}
+	var gErr *gqlError
+	if errors.As(err, &gErr) {
+		switch msg := strings.ToLower(gErr.msg); {
+		case strings.Contains(msg, "unauthorized"):
+			return st, ErrUnauthorized
+		case strings.Contains(msg, "not found"), strings.Contains(msg, "forbidden"):
+			return st, fmt.Errorf("%w: %s", ErrForbidden, gErr.msg)
+		}
+	}
+	return st, err
+}
+
+// SchemaPlan fetches the schema plan with the given ref. It returns
+// ErrUnauthorized if the token cannot be used, and ErrPlanNotFound if the plan
+// does not exist.
+func (c *Client) SchemaPlan(ctx context.Context, p *PlanParams) (*SchemaPlan, error) {
+	var out struct {
+		Plan *SchemaPlan `json:"schemaPlanByRef"`
+	}
+	_, err := c.query(ctx, p.URL, p.Token, schemaPlanQuery, map[string]any{"ref": p.Ref}, &out)
+	var gErr *gqlError
+	switch {
+	case errors.As(err, &gErr) && strings.Contains(strings.ToLower(gErr.msg), "unauthorized"):
+		return nil, ErrUnauthorized
+	case errors.As(err, &gErr) && strings.Contains(strings.ToLower(gErr.msg), "not found"), err == nil && out.Plan == nil:
+		return nil, ErrPlanNotFound
+	case errors.Is(err, ErrForbidden):
+		return nil, fmt.Errorf("cloudapi: the token has no access to the plan %s", p.Ref)
+	case err != nil:
+		return nil, err
+	}
+	return out.Plan, nil
+}
+
+// query runs a GraphQL query, and decodes its data into out, if not nil. It
+// returns the headers of the response, if one was received, and a *gqlError
+// for the first error reported in the response.
+func (c *Client) query(ctx context.Context, endpoint, token, query string, vars map[string]any, out any) (http.Header, error) {
+	if endpoint == "" {
+		endpoint = DefaultURL
+	}
+	body, err := json.Marshal(map[string]any{
+		"query":     query,
+		"variables": vars,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", "Ariga-Atlas-Operator")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cloudapi: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return resp.Header, ErrUnauthorized
+	case http.StatusForbidden:
+		return resp.Header, ErrForbidden
+	default:
+		return resp.Header, fmt.Errorf("cloudapi: unexpected status %s", resp.Status)
+	}
+	var r struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return resp.Header, fmt.Errorf("cloudapi: decoding response: %w", err)
+	}
+	if len(r.Errors) > 0 {
+		return resp.Header, &gqlError{msg: r.Errors[0].Message}
+	}
+	if out != nil && len(r.Data) > 0 {
+		if err := json.Unmarshal(r.Data, out); err != nil {
+			return resp.Header, fmt.Errorf("cloudapi: decoding response: %w", err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// header returns the integer value of the header, or -1 if it is not set.
+func header(h http.Header, name string) int {
+	v, err := strconv.Atoi(h.Get(name))
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+// expiry returns the "exp" claim of a JWT token. Opaque tokens have no expiry.
+func expiry(token string) *time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(b, &claims); err != nil || claims.Exp == 0 {
+		return nil
+	}
+	t := time.Unix(claims.Exp, 0)
+	return &t
+}
@@ -0,0 +1,97 @@
+package cloudapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Check(t *testing.T) {
+	var (
+		auth  string
+		input map[string]any
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		var body struct {
+			Variables struct {
+				Input map[string]any `json:"input"`
+			} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		input = body.Variables.Input
+		switch auth {
+		case "Bearer invalid":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "Bearer other":
+			_, _ = w.Write([]byte(`{"errors":[{"message":"dir not found"}]}`))
+		default:
+			w.Header().Set("X-RateLimit-Limit", "100")
+			w.Header().Set("X-RateLimit-Remaining", "7")
+			_, _ = w.Write([]byte(`{"data":{"dirState":{"content":""}}}`))
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.Client())
+
+	st, err := c.Check(context.Background(), &CheckParams{URL: srv.URL, Token: "aci_token", Dir: "app", Tag: "v1"})
+	require.NoError(t, err)
+	require.Equal(t, "Bearer aci_token", auth)
+	require.Equal(t, map[string]any{"name": "app", "tag": "v1"}, input)
+	require.Equal(t, &TokenStatus{RateLimit: 100, RateRemaining: 7}, st)
+
+	// The expiry of JWT tokens is reported.
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"exp":1700000000}`))
+	st, err = c.Check(context.Background(), &CheckParams{URL: srv.URL, Token: "e30." + claims + ".sig", Dir: "app"})
+	require.NoError(t, err)
+	require.EqualValues(t, 1700000000, st.ExpiresAt.Unix())
+
+	_, err = c.Check(context.Background(), &CheckParams{URL: srv.URL, Token: "invalid", Dir: "app"})
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = c.Check(context.Background(), &CheckParams{URL: srv.URL, Token: "other", Dir: "app"})
+	require.ErrorIs(t, err, ErrForbidden)
+	require.EqualError(t, err, "cloudapi: the token has no access to the directory: dir not found")
+}
+
+func TestClient_SchemaPlan(t *testing.T) {
+	var ref any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables map[string]any `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		ref = body.Variables["ref"]
+		switch r.Header.Get("Authorization") {
+		case "Bearer invalid":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "Bearer forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			if ref == "atlas://app/plans/missing" {
+				_, _ = w.Write([]byte(`{"errors":[{"message":"plan not found"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":{"schemaPlanByRef":{"name":"add-users","status":"APPROVED","migration":"CREATE TABLE users (id int);"}}}`))
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.Client())
+
+	p, err := c.SchemaPlan(context.Background(), &PlanParams{URL: srv.URL, Token: "aci_token", Ref: "atlas://app/plans/add-users"})
+	require.NoError(t, err)
+	require.Equal(t, "atlas://app/plans/add-users", ref)
+	require.Equal(t, &SchemaPlan{Name: "add-users", Status: PlanApproved, Migration: "CREATE TABLE users (id int);"}, p)
+
+	_, err = c.SchemaPlan(context.Background(), &PlanParams{URL: srv.URL, Token: "aci_token", Ref: "atlas://app/plans/missing"})
+	require.ErrorIs(t, err, ErrPlanNotFound)
+	_, err = c.SchemaPlan(context.Background(), &PlanParams{URL: srv.URL, Token: "invalid", Ref: "atlas://app/plans/add-users"})
+	require.ErrorIs(t, err, ErrUnauthorized)
+	_, err = c.SchemaPlan(context.Background(), &PlanParams{URL: srv.URL, Token: "forbidden", Ref: "atlas://app/plans/add-users"})
+	require.EqualError(t, err, "cloudapi: the token has no access to the plan atlas://app/plans/add-users")
+}
@@ -0,0 +1,170 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// Client is a client for the git CLI.
+	Client struct {
+		path string
+		// schemes are the URL schemes repositories may be fetched with.
+		schemes []string
+	}
+	// ReadFileParams are the parameters used to read a file from a repository.
+	ReadFileParams struct {
+		// Repo is the URL of the repository.
+		Repo string
+		// Ref is the branch, tag or commit to read the file from. Defaults to HEAD.
+		Ref string
+		// Path of the file within the repository.
+		Path string
+		// User and Password are used for HTTPS authentication, if set.
+		User     string
+		Password string
+	}
+	// File is a file read from a repository.
+	File struct {
+		// Content of the file.
+		Content string
+		// Commit is the SHA of the commit the ref was resolved to.
+		Commit string
+	}
+)
+
+// NewClient returns a new git client that uses the given git binary.
+func NewClient(path string) *Client {
+	// Keys and known hosts cannot be configured for ssh, so only https is allowed.
+	return &Client{path: path, schemes: []string{"https"}}
+}
+
+// validate rejects repositories fetched with other transports than the
+// allowed ones, e.g. file:// or ext::, and values that git would parse as
+// options.
+func (c *Client) validate(data *ReadFileParams) error {
+	switch {
+	case strings.HasPrefix(data.Repo, "-"):
+		return fmt.Errorf("git: invalid repository %q", data.Repo)
+	case strings.HasPrefix(data.Ref, "-"):
+		return fmt.Errorf("git: invalid ref %q", data.Ref)
+	case data.Repo == "":
+		return errors.New("git: repository is required")
+	}
+	if !strings.Contains(data.Repo, "://") {
+		// Repositories without a scheme are local paths, transports, e.g. ext::,
+		// or the scp-like syntax of ssh.
+		return fmt.Errorf("git: unsupported repository %q, use an https URL", data.Repo)
+	}
+	u, err := url.Parse(data.Repo)
+	if err != nil {
+		return fmt.Errorf("git: invalid repository %q: %w", data.Repo, err)
+	}
+	for _, s := range c.schemes {
+		if s == u.Scheme {
+			return nil
+		}
+	}
+	return fmt.Errorf("git: unsupported repository scheme %q, use an https URL", u.Scheme)
+}
+
+// ReadFile fetches the given ref of the repository and returns the content
+// of the file at the given path, and the commit the ref was resolved to.
+func (c *Client) ReadFile(ctx context.Context, data *ReadFileParams) (*File, error) {
+	if err := c.validate(data); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "atlas-git-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	ref := data.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth", "1", "--", data.Repo, ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		if _, err := c.runCommand(ctx, dir, data, args...); err != nil {
+			return nil, err
+		}
+	}
+	commit, err := c.runCommand(ctx, dir, data, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	content, err := readFile(dir, data.Path)
+	if err != nil {
+		return nil, fmt.Errorf("git: reading %q at %s: %w", data.Path, ref, err)
+	}
+	return &File{
+		Content: string(content),
+		Commit:  strings.TrimSpace(commit),
+	}, nil
+}
+
+// readFile reads the regular file at the given path of the repository in dir.
+// Symbolic links are resolved, and must not lead outside the repository, e.g.
+// to the files of the operator.
+func readFile(dir, path string) ([]byte, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	p, err := filepath.EvalSymlinks(filepath.Join(root, filepath.Clean("/"+path)))
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(root, p); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, errors.New("the file is outside the repository")
+	}
+	fi, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, errors.New("not a regular file")
+	}
+	return os.ReadFile(p)
+}
+
+// runCommand runs the given git command in dir and returns its output.
+// Credentials are passed to git through a credential helper reading them
+// from the environment, to keep them out of the command arguments.
+func (c *Client) runCommand(ctx context.Context, dir string, data *ReadFileParams, args ...string) (string, error) {
+	name := args[0]
+	if data.Password != "" {
+		args = append([]string{
+			"-c", "credential.helper=",
+			"-c", `credential.helper=!f() { echo "username=${GIT_USER}"; echo "password=${GIT_PASSWORD}"; }; f`,
+		}, args...)
+	}
+	cmd := exec.CommandContext(ctx, c.path, args...)
+	cmd.Dir = dir
+	// Restrict the transports of the repository and its redirects to the allowed ones.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ALLOW_PROTOCOL="+strings.Join(c.schemes, ":"))
+	if data.Password != "" {
+		user := data.User
+		if user == "" {
+			user = "git"
+		}
+		cmd.Env = append(cmd.Env, "GIT_USER="+user, "GIT_PASSWORD="+data.Password)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", name, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return string(output), nil
+}
@@ -0,0 +1,111 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ReadFile(t *testing.T) {
+	path, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	run := func(args ...string) string {
+		cmd := exec.Command(path, args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return string(out)
+	}
+	run("init", "--quiet", "--initial-branch", "main")
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "schema"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "schema", "schema.sql"), []byte("CREATE TABLE t (id int);"), 0644))
+	secret := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(secret, []byte("token"), 0600))
+	require.NoError(t, os.Symlink(secret, filepath.Join(repo, "schema", "token.sql")))
+	require.NoError(t, os.Symlink("schema.sql", filepath.Join(repo, "schema", "link.sql")))
+	run("add", ".")
+	run("commit", "--quiet", "-m", "init")
+	sha := run("rev-parse", "HEAD")
+
+	c := NewClient(path)
+	c.schemes = append(c.schemes, "file")
+	f, err := c.ReadFile(context.Background(), &ReadFileParams{
+		Repo: "file://" + repo,
+		Ref:  "main",
+		Path: "schema/schema.sql",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE t (id int);", f.Content)
+	require.Equal(t, sha[:40], f.Commit)
+
+	// Paths outside the repository are not readable.
+	_, err = c.ReadFile(context.Background(), &ReadFileParams{
+		Repo: "file://" + repo,
+		Ref:  "main",
+		Path: "../../etc/passwd",
+	})
+	require.Error(t, err)
+
+	// Links are followed inside the repository only.
+	f, err = c.ReadFile(context.Background(), &ReadFileParams{
+		Repo: "file://" + repo,
+		Ref:  "main",
+		Path: "schema/link.sql",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE t (id int);", f.Content)
+	_, err = c.ReadFile(context.Background(), &ReadFileParams{
+		Repo: "file://" + repo,
+		Ref:  "main",
+		Path: "schema/token.sql",
+	})
+	require.EqualError(t, err, `git: reading "schema/token.sql" at main: the file is outside the repository`)
+	_, err = c.ReadFile(context.Background(), &ReadFileParams{
+		Repo: "file://" + repo,
+		Ref:  "main",
+		Path: "schema",
+	})
+	require.EqualError(t, err, `git: reading "schema" at main: not a regular file`)
+
+	// Unknown refs are reported.
+	_, err = c.ReadFile(context.Background(), &ReadFileParams{
+		Repo: "file://" + repo,
+		Ref:  "unknown",
+		Path: "schema/schema.sql",
+	})
+	require.ErrorContains(t, err, "git fetch")
+	require.ErrorContains(t, err, "git fetch")
+}
+
+func TestClient_validate(t *testing.T) {
+	c := NewClient("git")
+	for _, repo := range []string{
+		"https://github.com/org/repo.git",
+	} {
+		require.NoError(t, c.validate(&ReadFileParams{Repo: repo}), repo)
+	}
+	for repo, msg := range map[string]string{
+		"file:///etc":                       `git: unsupported repository scheme "file", use an https URL`,
+		"ext::sh -c touch% /tmp/pwned":      `git: unsupported repository "ext::sh -c touch% /tmp/pwned", use an https URL`,
+		"/var/run/secrets":                  `git: unsupported repository "/var/run/secrets", use an https URL`,
+		"--upload-pack=touch /tmp/pwned":    `git: invalid repository "--upload-pack=touch /tmp/pwned"`,
+		"http://github.com/org/repo.git":    `git: unsupported repository scheme "http", use an https URL`,
+		"ssh://git@github.com/org/repo.git": `git: unsupported repository scheme "ssh", use an https URL`,
+		"git@github.com:org/repo.git":       `git: unsupported repository "git@github.com:org/repo.git", use an https URL`,
+	} {
+		require.EqualError(t, c.validate(&ReadFileParams{Repo: repo}), msg)
+	}
+	err := c.validate(&ReadFileParams{Repo: "https://github.com/org/repo.git", Ref: "--upload-pack=id"})
+	require.EqualError(t, err, `git: invalid ref "--upload-pack=id"`)
+}
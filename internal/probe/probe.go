@@ -0,0 +1,55 @@
+package probe
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"ariga.io/atlas/sql/sqlclient"
+
+	_ "ariga.io/atlas/sql/mysql"
+	_ "ariga.io/atlas/sql/postgres"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+// Client runs probe queries against a target database.
+type Client struct{}
+
+// New returns a new probe client.
+func New() *Client {
+	return &Client{}
+}
+
+// Value connects to the database at the given Atlas URL, runs the given
+// query and returns the single numeric value it produces. A query that
+// returns no rows, or a NULL value, is reported as 0.
+func (c *Client) Value(ctx context.Context, url, query string) (float64, error) {
+	client, err := sqlclient.Open(ctx, url)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+	var v sql.NullFloat64
+	switch err := client.DB.QueryRowContext(ctx, query).Scan(&v); {
+	case err == sql.ErrNoRows:
+		return 0, nil
+	case err != nil:
+		return 0, fmt.Errorf("probe: running query: %w", err)
+	}
+	return v.Float64, nil
+}
+
+// Exec connects to the database at the given Atlas URL and runs the given
+// statement.
+func (c *Client) Exec(ctx context.Context, url, stmt string) error {
+	client, err := sqlclient.Open(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if _, err := client.DB.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("probe: running statement: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package vitess
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"ariga.io/atlas/sql/sqlclient"
+
+	_ "ariga.io/atlas/sql/mysql"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// Migration statuses reported by Vitess for online DDL migrations.
+const (
+	StatusComplete  = "complete"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+type (
+	// Client queries the online DDL migrations of a Vitess keyspace through vtgate.
+	Client struct{}
+	// Migration is an online DDL migration submitted to Vitess.
+	Migration struct {
+		UUID    string
+		Status  string
+		Message string
+	}
+)
+
+// NewClient returns a new Vitess client.
+func NewClient() *Client {
+	return &Client{}
+}
+
+// Migrations returns the online DDL migrations that were submitted with the
+// given migration context to the keyspace at the given Atlas URL.
+func (c *Client) Migrations(ctx context.Context, url, migrationContext string) ([]Migration, error) {
+	client, err := sqlclient.Open(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	// vtgate does not accept placeholders in SHOW statements.
+	rows, err := client.DB.QueryContext(ctx, "SHOW VITESS_MIGRATIONS LIKE "+likeLiteral(migrationContext))
+	if err != nil {
+		return nil, fmt.Errorf("vitess: listing migrations: %w", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(columns))
+		for i, c := range columns {
+			row[c] = values[i].String
+		}
+		// LIKE also matches UUIDs and statuses, keep only the exact context.
+		if row["migration_context"] != migrationContext {
+			continue
+		}
+		migrations = append(migrations, Migration{
+			UUID:    row["migration_uuid"],
+			Status:  row["migration_status"],
+			Message: row["message"],
+		})
+	}
+	return migrations, rows.Err()
+}
+
+// likeLiteral returns the string literal of a LIKE pattern matching s only.
+func likeLiteral(s string) string {
+	p := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(p) + "'"
+}
+
+// Done reports if all migrations have completed, and returns the first
+// migration that failed or was cancelled, if any.
+func Done(migrations []Migration) (bool, *Migration) {
+	done := true
+	for i, m := range migrations {
+		switch m.Status {
+		case StatusComplete:
+		case StatusFailed, StatusCancelled:
+			return true, &migrations[i]
+		default:
+			done = false
+		}
+	}
+	return done, nil
+}
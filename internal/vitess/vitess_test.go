@@ -0,0 +1,27 @@
+package vitess
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDone(t *testing.T) {
+	done, failed := Done(nil)
+	require.True(t, done)
+	require.Nil(t, failed)
+
+	done, failed = Done([]Migration{{UUID: "a", Status: StatusComplete}, {UUID: "b", Status: "running"}})
+	require.False(t, done)
+	require.Nil(t, failed)
+
+	done, failed = Done([]Migration{{UUID: "a", Status: "queued"}, {UUID: "b", Status: StatusFailed}})
+	require.True(t, done)
+	require.Equal(t, "b", failed.UUID)
+}
+
+func TestLikeLiteral(t *testing.T) {
+	require.Equal(t, `'atlas:test/app'`, likeLiteral("atlas:test/app"))
+	require.Equal(t, `'a\\_b\\%'`, likeLiteral("a_b%"))
+	require.Equal(t, `'x\' OR 1=1 -- \\\\'`, likeLiteral(`x' OR 1=1 -- \`))
+}
@@ -18,9 +18,17 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
 	"time"
+	// Embed the time zone database, so the time zones of apply windows
+	// are resolved in images without one.
+	_ "time/tzdata"
 
 	"golang.org/x/mod/semver"
 
@@ -31,12 +39,18 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	dbv1alpha1 "github.com/ariga/atlas-operator/api/v1alpha1"
 	"github.com/ariga/atlas-operator/controllers"
@@ -65,14 +79,95 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap" {
+		os.Exit(runBootstrap(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bulk" {
+		os.Exit(runBulk(os.Args[2:]))
+	}
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var replan bool
+	var approvalWebhook bool
+	var riskWebhook bool
+	var deletionWebhook bool
+	var scheduleWebhook bool
+	var allowProjectFiles bool
+	var allowedImages string
+	var allowAzureAD bool
+	var changeReport bool
+	var report reportFlags
+	var maxConcurrentReconciles, maxAppliesPerHost int
+	var watchDedupWindow, janitorRetention time.Duration
+	var remote atlas.SSHRunner
+	var holdNamespace, networkCheckAddr string
+	var networkBackoff time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&replan, "replan", false,
+		"Re-plan every managed resource in check-only mode, print a JSON report of their plans and exit. "+
+			"Exits with status 1 if the plan of a resource in sync has changed.")
+	flag.BoolVar(&changeReport, "change-report", false,
+		"Write a report of the changes applied by every managed resource within a time range and exit.")
+	flag.StringVar(&report.since, "report-since", "", "Include changes applied at or after this RFC 3339 time.")
+	flag.StringVar(&report.until, "report-until", "", "Include changes applied before this RFC 3339 time.")
+	flag.StringVar(&report.namespaces, "report-namespaces", "",
+		"Comma-separated list of namespaces to include in the report. All namespaces if empty.")
+	flag.StringVar(&report.format, "report-format", "json", "The format of the report: json or csv.")
+	flag.StringVar(&report.configMap, "report-configmap", "",
+		"Store the report in this ConfigMap, in the form <namespace>/<name>, instead of printing it.")
+	flag.StringVar(&report.uploadURL, "report-upload-url", "",
+		"Upload the report with a PUT request to this URL, e.g. a pre-signed object storage URL, instead of printing it.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The number of resources each controller reconciles concurrently.")
+	flag.IntVar(&maxAppliesPerHost, "max-applies-per-host", 0,
+		"The maximum number of schema changes applied concurrently to the same database server, "+
+			"across all the databases it hosts. Zero means unlimited.")
+	flag.DurationVar(&watchDedupWindow, "watch-dedup-window", 5*time.Second,
+		"The minimum time between two reconciles of a resource triggered by changes of the Secrets and ConfigMaps it references. "+
+			"Changes within the window are coalesced into a single reconcile.")
+	flag.StringVar(&holdNamespace, "hold-namespace", "",
+		"The namespace of the Leases holding the applies of resources across namespaces. "+
+			"Leases hold the resources of their own namespace only if empty.")
+	flag.StringVar(&networkCheckAddr, "network-check-address", "",
+		"A known-good TCP endpoint dialed when a database is unreachable, e.g. kubernetes.default.svc:443. If it is "+
+			"unreachable too, the networking of the cluster is considered broken and all reconciles back off. "+
+			"The check is disabled if empty.")
+	flag.DurationVar(&networkBackoff, "network-backoff", time.Minute,
+		"How long all reconciles back off when the networking of the cluster is broken.")
+	flag.DurationVar(&janitorRetention, "janitor-retention", 24*time.Hour,
+		"How long expired plans, unused Jobs and lint reports, and temporary files are kept before they are pruned. "+
+			"Zero disables pruning.")
+	flag.BoolVar(&approvalWebhook, "enable-approval-webhook", false,
+		"Serve the webhooks that require the \"approve\" verb to approve the plans of AtlasSchema resources, "+
+			"and the \"break-glass\" verb to bypass their approval.")
+	flag.BoolVar(&riskWebhook, "enable-risk-webhook", false,
+		"Serve the webhook warning about updates of AtlasSchema resources that may drop objects from the database.")
+	flag.BoolVar(&deletionWebhook, "enable-deletion-webhook", false,
+		"Serve the webhook rejecting the deletion of AtlasMigration resources while their migrations are applied.")
+	flag.BoolVar(&scheduleWebhook, "enable-schedule-webhook", false,
+		"Serve the webhook rejecting AtlasSchema and AtlasMigration resources with invalid apply windows.")
+	flag.BoolVar(&allowProjectFiles, "allow-project-files", false,
+		"Allow AtlasMigration resources to use their own atlas.hcl project files. Project files are evaluated by the "+
+			"operator, with its environment and network access, so enable it only if their authors are trusted.")
+	flag.StringVar(&allowedImages, "allowed-images", "",
+		"Comma-separated list of image prefixes, e.g. ghcr.io/org/, the resources may run as Jobs in their namespace, "+
+			"such as external schema programs. Nothing is allowed if empty.")
+	flag.BoolVar(&allowAzureAD, "allow-azure-ad", false,
+		"Allow the resources to authenticate with Azure AD tokens of credentials.azureAD. Tokens are acquired with the "+
+			"identity of the operator, so enable it only if the resources of all namespaces are trusted.")
+	flag.StringVar(&remote.Host, "atlas-ssh-host", "",
+		"Run the Atlas CLI on this host over SSH, e.g. a bastion with access to the databases, instead of in the operator pod.")
+	flag.StringVar(&remote.User, "atlas-ssh-user", "", "The user to log in as on the SSH host.")
+	flag.IntVar(&remote.Port, "atlas-ssh-port", 0, "The port of the SSH host. Defaults to the port of the ssh client.")
+	flag.StringVar(&remote.IdentityFile, "atlas-ssh-identity-file", "", "The private key used to authenticate to the SSH host.")
+	flag.StringVar(&remote.KnownHostsFile, "atlas-ssh-known-hosts-file", "",
+		"The known_hosts file holding the public key of the SSH host. Unknown hosts are rejected.")
+	flag.StringVar(&remote.Path, "atlas-ssh-path", "atlas", "The path of the Atlas CLI on the SSH host.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -81,6 +176,12 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if replan {
+		os.Exit(runReplan())
+	}
+	if changeReport {
+		os.Exit(runChangeReport(report))
+	}
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                        scheme,
 		MetricsBindAddress:            metricsAddr,
@@ -89,6 +190,11 @@ func main() {
 		LeaderElection:                enableLeaderElection,
 		LeaderElectionID:              "5220c287.atlasgo.io",
 		LeaderElectionReleaseOnCancel: true,
+		NewCache: cache.BuilderWithOptions(cache.Options{
+			SelectorsByObject: cache.SelectorsByObject{
+				&coordinationv1.Lease{}: {Label: controllers.HoldLeaseSelector()},
+			},
+		}),
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -100,22 +206,81 @@ func main() {
 		os.Exit(1)
 	}
 	cli, err := atlas.NewClient(cwd, "atlas")
+	if remote.Host != "" {
+		cli, err = atlas.NewClientWithRunner(&remote), nil
+	}
 	if err != nil {
 		setupLog.Error(err, "unable to create atlas client")
 		os.Exit(1)
 	}
-	if err = controllers.NewAtlasSchemaReconciler(mgr, cli).
+	// The pod name identifies the operator instance in the applying markers.
+	identity, err := os.Hostname()
+	if err != nil {
+		setupLog.Error(err, "unable to get hostname")
+		os.Exit(1)
+	}
+	reconcilerOpts := controllers.Options{
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		ApplyLimiter:            controllers.NewHostLimiter(maxAppliesPerHost),
+		Identity:                identity,
+		WatchDedupWindow:        watchDedupWindow,
+		HoldNamespace:           holdNamespace,
+		Version:                 version,
+		AllowProjectFiles:       allowProjectFiles,
+		AllowAzureAD:            allowAzureAD,
+	}
+	if allowedImages != "" {
+		reconcilerOpts.AllowedImages = strings.Split(allowedImages, ",")
+	}
+	if networkCheckAddr != "" {
+		reconcilerOpts.NetworkGuard = controllers.NewNetworkGuard(networkCheckAddr, networkBackoff)
+	}
+	if err = controllers.NewAtlasSchemaReconciler(mgr, cli, reconcilerOpts).
 		SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AtlasSchema")
 		os.Exit(1)
 	}
 
-	if err = controllers.NewAtlasMigrationReconciler(mgr, cli).
+	if err = controllers.NewAtlasMigrationReconciler(mgr, cli, reconcilerOpts).
 		SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AtlasMigration")
 		os.Exit(1)
 	}
+	if err = controllers.NewAtlasMonitorReconciler(mgr, cli, reconcilerOpts).
+		SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AtlasMonitor")
+		os.Exit(1)
+	}
+	if janitorRetention > 0 {
+		if err = mgr.Add(controllers.NewJanitor(mgr, janitorRetention)); err != nil {
+			setupLog.Error(err, "unable to add janitor")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
+	if approvalWebhook {
+		mgr.GetWebhookServer().Register(controllers.ApprovalWebhookPath, &webhook.Admission{
+			Handler: controllers.NewApprovalValidator(mgr.GetClient()),
+		})
+		mgr.GetWebhookServer().Register(controllers.BreakGlassWebhookPath, &webhook.Admission{
+			Handler: controllers.NewBreakGlassValidator(mgr.GetClient()),
+		})
+	}
+	if riskWebhook {
+		mgr.GetWebhookServer().Register(controllers.RiskWebhookPath, &webhook.Admission{
+			Handler: controllers.NewRiskValidator(),
+		})
+	}
+	if deletionWebhook {
+		mgr.GetWebhookServer().Register(controllers.DeletionWebhookPath, &webhook.Admission{
+			Handler: controllers.NewDeletionValidator(),
+		})
+	}
+	if scheduleWebhook {
+		mgr.GetWebhookServer().Register(controllers.ScheduleWebhookPath, &webhook.Admission{
+			Handler: controllers.NewScheduleValidator(),
+		})
+	}
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -133,6 +298,218 @@ func main() {
 	}
 }
 
+// runReplan re-plans every managed resource without applying any change,
+// writes the report to stdout and returns the exit code of the process.
+func runReplan() int {
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		return 1
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		setupLog.Error(err, "unable to get current working directory")
+		return 1
+	}
+	cli, err := atlas.NewClient(cwd, "atlas")
+	if err != nil {
+		setupLog.Error(err, "unable to create atlas client")
+		return 1
+	}
+	reports, err := controllers.Replan(ctrl.SetupSignalHandler(), c, cli)
+	if err != nil {
+		setupLog.Error(err, "unable to re-plan resources")
+		return 1
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(reports); err != nil {
+		setupLog.Error(err, "unable to write report")
+		return 1
+	}
+	for _, r := range reports {
+		if r.Changed {
+			return 1
+		}
+	}
+	return 0
+}
+
+// reportFlags holds the flags of the change report mode.
+type reportFlags struct {
+	since, until, namespaces, format, configMap, uploadURL string
+}
+
+// runChangeReport writes a report of the changes applied by the managed
+// resources, and returns the exit code of the operator.
+func runChangeReport(f reportFlags) int {
+	var (
+		opts controllers.ReportOptions
+		err  error
+	)
+	if f.since != "" {
+		if opts.Since, err = time.Parse(time.RFC3339, f.since); err != nil {
+			setupLog.Error(err, "invalid --report-since")
+			return 1
+		}
+	}
+	if f.until != "" {
+		if opts.Until, err = time.Parse(time.RFC3339, f.until); err != nil {
+			setupLog.Error(err, "invalid --report-until")
+			return 1
+		}
+	}
+	if f.namespaces != "" {
+		opts.Namespaces = strings.Split(f.namespaces, ",")
+	}
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		return 1
+	}
+	ctx := ctrl.SetupSignalHandler()
+	entries, err := controllers.ChangeReport(ctx, c, opts)
+	if err != nil {
+		setupLog.Error(err, "unable to build change report")
+		return 1
+	}
+	var buf bytes.Buffer
+	if err := controllers.WriteReport(&buf, entries, f.format); err != nil {
+		setupLog.Error(err, "unable to write change report")
+		return 1
+	}
+	switch {
+	case f.configMap != "":
+		ns, name, ok := strings.Cut(f.configMap, "/")
+		if !ok {
+			setupLog.Error(errors.New("expected <namespace>/<name>"), "invalid --report-configmap")
+			return 1
+		}
+		err = controllers.StoreReportConfigMap(ctx, c, types.NamespacedName{Namespace: ns, Name: name}, f.format, buf.Bytes())
+	case f.uploadURL != "":
+		err = controllers.UploadReport(ctx, &http.Client{Timeout: time.Minute}, f.uploadURL, f.format, buf.Bytes())
+	default:
+		_, err = buf.WriteTo(os.Stdout)
+	}
+	if err != nil {
+		setupLog.Error(err, "unable to store change report")
+		return 1
+	}
+	return 0
+}
+
+// runBootstrap inspects an existing database, writes the manifests of the
+// resources managing it to stdout, and returns the exit code of the process.
+func runBootstrap(args []string) int {
+	var (
+		opts             controllers.BootstrapOptions
+		schemas, exclude string
+		fs               = flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	)
+	fs.StringVar(&opts.URL, "url", "", "The URL of the existing database.")
+	fs.StringVar(&opts.DevURL, "dev-url", "", "The URL of a dev database used to normalize the inspected schema.")
+	fs.StringVar(&opts.Name, "name", "", "The name of the generated resources.")
+	fs.StringVar(&opts.Namespace, "namespace", "default", "The namespace of the generated resources.")
+	fs.StringVar(&opts.Kind, "kind", "AtlasSchema", "The kind of the generated resource: AtlasSchema or AtlasMigration.")
+	fs.StringVar(&opts.Format, "format", "sql", "The format of the desired schema of an AtlasSchema: sql or hcl.")
+	fs.StringVar(&schemas, "schemas", "", "Comma-separated list of schemas to inspect. All schemas if empty.")
+	fs.StringVar(&exclude, "exclude", "", "Comma-separated list of glob patterns of objects to exclude.")
+	_ = fs.Parse(args)
+	if opts.URL == "" || opts.Name == "" {
+		fmt.Fprintln(os.Stderr, "bootstrap: --url and --name are required")
+		fs.Usage()
+		return 2
+	}
+	if schemas != "" {
+		opts.Schemas = strings.Split(schemas, ",")
+	}
+	if exclude != "" {
+		opts.Exclude = strings.Split(exclude, ",")
+	}
+	opts.Now = time.Now()
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bootstrap: unable to get current working directory:", err)
+		return 1
+	}
+	cli, err := atlas.NewClient(cwd, "atlas")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bootstrap: unable to create atlas client:", err)
+		return 1
+	}
+	objs, err := controllers.Bootstrap(ctrl.SetupSignalHandler(), cli, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := controllers.WriteManifests(os.Stdout, objs); err != nil {
+		fmt.Fprintln(os.Stderr, "bootstrap: unable to write manifests:", err)
+		return 1
+	}
+	return 0
+}
+
+// runBulk applies an action to the resources selected by labels, prints the
+// result for each of them, and returns the exit code of the process.
+func runBulk(args []string) int {
+	var (
+		opts                    controllers.BulkOptions
+		selector, kinds, annots string
+		allNamespaces           bool
+		fs                      = flag.NewFlagSet("bulk", flag.ExitOnError)
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: atlas-operator bulk <approve|suspend|resume|rerun|annotate> [flags]")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&selector, "selector", "", "The label selector of the resources, e.g. team=payments. All resources if empty.")
+	fs.StringVar(&opts.Namespace, "namespace", "default", "The namespace of the resources.")
+	fs.BoolVar(&allNamespaces, "all-namespaces", false, "Select the resources of all namespaces.")
+	fs.StringVar(&kinds, "kinds", "", "Comma-separated list of kinds to select: AtlasSchema and AtlasMigration. Both if empty.")
+	fs.StringVar(&annots, "annotations", "", "Comma-separated list of annotations set (key=value) or removed (key-) by annotate.")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Print the results of the action without applying it.")
+	if len(args) == 0 {
+		fs.Usage()
+		return 2
+	}
+	opts.Action = args[0]
+	_ = fs.Parse(args[1:])
+	if allNamespaces {
+		opts.Namespace = ""
+	}
+	if kinds != "" {
+		opts.Kinds = strings.Split(kinds, ",")
+	}
+	var err error
+	if selector != "" {
+		if opts.Selector, err = labels.Parse(selector); err != nil {
+			fmt.Fprintln(os.Stderr, "bulk: invalid --selector:", err)
+			return 2
+		}
+	}
+	if annots != "" {
+		if opts.Annotate, opts.Remove, err = controllers.ParseAnnotations(strings.Split(annots, ",")); err != nil {
+			fmt.Fprintln(os.Stderr, "bulk:", err)
+			return 2
+		}
+	}
+	opts.Now = time.Now()
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bulk: unable to create client:", err)
+		return 1
+	}
+	results, err := controllers.Bulk(ctrl.SetupSignalHandler(), c, opts)
+	for _, r := range results {
+		fmt.Printf("%s %s/%s: %s\n", strings.ToLower(r.Kind), r.Namespace, r.Name, r.Result)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
 // checkForUpdate checks for version updates and security advisories for the Atlas Operator.
 func checkForUpdate() {
 	log := ctrl.Log.WithName("vercheck")